@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -14,8 +16,10 @@ import (
 
 	"github.com/HappyLadySauce/NexusPointWG/cmd/app/options"
 	"github.com/HappyLadySauce/NexusPointWG/cmd/app/router"
-	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/service"
 	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/secret"
 
 	authRoutes "github.com/HappyLadySauce/NexusPointWG/cmd/app/routes/auth"
 	userRoutes "github.com/HappyLadySauce/NexusPointWG/cmd/app/routes/user"
@@ -73,6 +77,11 @@ func NewAPICommand(ctx context.Context) *cobra.Command {
 	nfs := opts.AddFlags(cmd.Flags())
 	flag.SetUsageAndHelpFunc(cmd, *nfs, 80)
 
+	cmd.AddCommand(NewQRCodeCommand())
+	cmd.AddCommand(NewAssembleConfigCommand())
+	cmd.AddCommand(NewRekeyCommand(ctx))
+	cmd.AddCommand(NewDriftCommand(ctx))
+
 	return cmd
 }
 
@@ -86,6 +95,27 @@ func run(ctx context.Context, opts *options.Options) error {
 		WireGuard:       opts.WireGuard,
 	})
 
+	// Configure the exec applier hook and config history before any config is written
+	wireguard.RegisterApplier(wireguard.NewExecApplier(opts.WireGuard.ApplyCommand))
+	wireguard.SetHistoryLimit(opts.WireGuard.HistoryLimit)
+	if opts.WireGuard.StatusDumpDir != "" {
+		wireguard.SetStatusSource(wireguard.NewFileStatusSource(opts.WireGuard.StatusDumpDir))
+	}
+
+	// Encrypt peer secrets at rest if a master key is configured (before the store is opened)
+	masterKey, err := opts.Encryption.LoadMasterKey()
+	if err != nil {
+		return err
+	}
+	if masterKey != nil {
+		cipher, err := secret.NewCipher(masterKey)
+		if err != nil {
+			return err
+		}
+		secret.SetMasterCipher(cipher)
+		klog.V(1).InfoS("Encryption of peer secrets at rest enabled", "masterKeyID", cipher.KeyID())
+	}
+
 	// Initialize router with SQLite options from config
 	// This must be done after config.Init() to ensure the correct database path is used
 	if err := router.Init(config.Get().Sqlite); err != nil {
@@ -100,11 +130,45 @@ func run(ctx context.Context, opts *options.Options) error {
 	// Sync all peers and IP allocations from config files on startup
 	// This runs asynchronously to avoid blocking server startup
 	go func() {
-		if err := ip.SyncAllFromConfigFiles(ctx, router.StoreIns); err != nil {
+		// Make sure the default interface exists and bind legacy IP pools and peers to it
+		if _, err := service.NewService(router.StoreIns).WGInterfaces().EnsureDefaultInterface(ctx); err != nil {
+			klog.V(1).InfoS("Failed to ensure default WireGuard interface", "error", err)
+		}
+		if _, err := service.NewService(router.StoreIns).Reconciler().Reconcile(ctx, "startup", true); err != nil {
 			klog.V(1).InfoS("Failed to sync from config files", "error", err)
 		}
+		// Load the firewall rulesets enforcing the isolation policy of IP pools
+		service.NewService(router.StoreIns).IPPools().SyncFirewalls(ctx)
+		// Load the port forwards to active peers
+		service.NewService(router.StoreIns).PortForwards().SyncPortForwards(ctx)
+		// Load the policy routing of exit nodes
+		service.NewService(router.StoreIns).WGPeers().SyncExitRouting(ctx)
+		// Write the NAT scripts run from PostUp and load their rules
+		service.NewService(router.StoreIns).WGServer().SyncNAT(ctx)
 	}()
 
+	// Sample peer transfer counters for traffic accounting
+	if opts.WireGuard.TrafficInterval > 0 {
+		go service.NewService(router.StoreIns).Traffic().Run(ctx, opts.WireGuard.TrafficInterval)
+	}
+
+	// Disable or delete expired peers
+	if opts.WireGuard.ReapInterval > 0 {
+		deleteExpired := strings.EqualFold(strings.TrimSpace(opts.WireGuard.ExpiredPeerAction), "delete")
+		go service.NewService(router.StoreIns).WGPeers().RunReaper(ctx, opts.WireGuard.ReapInterval, deleteExpired)
+	}
+
+	// Reconcile the database with config files edited outside of NexusPointWG
+	if opts.WireGuard.ReconcileInterval > 0 || opts.WireGuard.ReconcileWatch {
+		go service.NewService(router.StoreIns).Reconciler().Run(ctx, opts.WireGuard.ReconcileInterval, opts.WireGuard.ReconcileWatch)
+	}
+
+	// Rotate peer keys older than the rotation policy
+	if opts.WireGuard.KeyRotationDays > 0 {
+		maxAge := time.Duration(opts.WireGuard.KeyRotationDays) * 24 * time.Hour
+		go service.NewService(router.StoreIns).WGPeers().RunKeyRotation(ctx, time.Hour, maxAge)
+	}
+
 	serve(opts)
 	<-ctx.Done()
 	os.Exit(0)
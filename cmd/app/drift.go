@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/options"
+	"github.com/HappyLadySauce/NexusPointWG/internal/service"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store/sqlite"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/secret"
+)
+
+// NewDriftCommand returns the command that prints the drift between the database and the config files.
+func NewDriftCommand(ctx context.Context) *cobra.Command {
+	opts := options.NewOptions()
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Show the drift between the database and the config files",
+		Long: `Compare the database with the server config files under root-dir and print the peers present only in the
+database, present only in a config file, or present in both with different AllowedIPs, keepalive or comment, as well as
+the IP pools that importing them would create. Nothing is changed: resolve the differences one by one with
+POST /api/v1/wg/reconcile/drift/{id}/approve, or all at once with POST /api/v1/wg/reconcile, e.g.:
+
+  NexusPointWG drift --data-source-name /var/lib/nexuspointwg/db.sqlite --wireguard.root-dir /etc/wireguard`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := viper.BindPFlags(cmd.Flags()); err != nil {
+				return err
+			}
+			if err := viper.Unmarshal(opts); err != nil {
+				return err
+			}
+			for _, validate := range []func() []error{opts.Sqlite.Validate, opts.WireGuard.Validate, opts.Encryption.Validate} {
+				if errs := validate(); len(errs) != 0 {
+					return errs[0]
+				}
+			}
+
+			// The peer secrets are not compared, but the store reads them with the master key if one is configured
+			masterKey, err := opts.Encryption.LoadMasterKey()
+			if err != nil {
+				return err
+			}
+			if masterKey != nil {
+				cipher, err := secret.NewCipher(masterKey)
+				if err != nil {
+					return err
+				}
+				secret.SetMasterCipher(cipher)
+			}
+
+			config.Init(&config.Config{
+				InsecureServing: opts.InsecureServing,
+				Sqlite:          opts.Sqlite,
+				Log:             opts.Log,
+				JWT:             opts.JWT,
+				WireGuard:       opts.WireGuard,
+			})
+			storeIns, err := sqlite.GetSqliteFactoryOr(opts.Sqlite)
+			if err != nil {
+				return fmt.Errorf("failed to open database: %w", err)
+			}
+			defer storeIns.Close()
+
+			report, err := service.NewService(storeIns).Reconciler().DriftReport(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to compare the database with the config files: %w", err)
+			}
+			return printDriftReport(cmd, report)
+		},
+	}
+	opts.Sqlite.AddFlags(cmd.Flags())
+	opts.WireGuard.AddFlags(cmd.Flags())
+	opts.Encryption.AddFlags(cmd.Flags())
+	return cmd
+}
+
+func printDriftReport(cmd *cobra.Command, report *service.DriftReport) error {
+	out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintf(out, "Generated at:\t%s\n", report.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(out, "Reconcile policy:\t%s\n", report.Policy)
+	if len(report.ExternalEdits) > 0 {
+		fmt.Fprintf(out, "Edited outside of NexusPointWG:\t%s\n", strings.Join(report.ExternalEdits, ", "))
+	}
+
+	if len(report.Actions) == 0 {
+		fmt.Fprintln(out, "\nThe database and the config files agree.")
+	} else {
+		fmt.Fprintf(out, "\n%d differences:\n", len(report.Actions))
+		fmt.Fprintln(out, "ID\tKIND\tINTERFACE\tPUBLIC KEY\tDETAIL")
+		for _, action := range report.Actions {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", action.ID, action.Kind, action.Interface, action.PublicKey, action.Detail)
+			for _, difference := range action.Differences {
+				fmt.Fprintf(out, "\t  %s:\tdatabase %q\tconfig file %q\t\n", difference.Field, difference.Database, difference.ConfigFile)
+			}
+		}
+	}
+
+	if len(report.IPPools) > 0 {
+		fmt.Fprintln(out, "\nIP pools created on import:")
+		fmt.Fprintln(out, "NAME\tCIDR\tINTERFACE\tPEERS")
+		for _, pool := range report.IPPools {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%d\n", pool.Name, pool.CIDR, pool.Interface, len(pool.PublicKeys))
+		}
+	}
+
+	for _, reportErr := range report.Errors {
+		fmt.Fprintf(out, "\nError: %s\n", reportErr)
+	}
+	return out.Flush()
+}
@@ -16,6 +16,7 @@ type Options struct {
 	JWT             *options.JWTOptions             `mapstructure:"jwt"`
 	Log             *options.LogOptions             `mapstructure:"logs"`
 	WireGuard       *options.WireGuardOptions       `mapstructure:"wireguard"`
+	Encryption      *options.EncryptionOptions      `mapstructure:"encryption"`
 }
 
 func NewOptions() *Options {
@@ -25,6 +26,7 @@ func NewOptions() *Options {
 		JWT:             options.NewJWTOptions(),
 		Log:             options.NewLogOptions(),
 		WireGuard:       options.NewWireGuardOptions(),
+		Encryption:      options.NewEncryptionOptions(),
 	}
 }
 
@@ -56,6 +58,10 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) *flag.NamedFlagSets {
 	wgFS := nfs.FlagSet("WireGuard")
 	o.WireGuard.AddFlags(wgFS)
 
+	// add encryption flags
+	encryptionFS := nfs.FlagSet("Encryption")
+	o.Encryption.AddFlags(encryptionFS)
+
 	// add the flags to the main Command
 	for _, name := range nfs.Order {
 		fs.AddFlagSet(nfs.FlagSets[name])
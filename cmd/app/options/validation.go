@@ -8,6 +8,7 @@ func (o *Options) Validate() []error {
 	errs = append(errs, o.Sqlite.Validate()...)
 	errs = append(errs, o.JWT.Validate()...)
 	errs = append(errs, o.WireGuard.Validate()...)
+	errs = append(errs, o.Encryption.Validate()...)
 
 	return errs
 }
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/qrcode"
+)
+
+// NewQRCodeCommand returns the command that prints a client configuration as a terminal QR code.
+func NewQRCodeCommand() *cobra.Command {
+	var privateKeyFile string
+	cmd := &cobra.Command{
+		Use:   "qrcode [FILE]",
+		Short: "Print a WireGuard client configuration as a QR code",
+		Long: `Print a WireGuard client configuration as a QR code in the terminal, to scan it with the WireGuard mobile app.
+The configuration is read from FILE, or from stdin if FILE is omitted or "-", e.g.:
+
+  curl -H "Authorization: Bearer $TOKEN" http://localhost:51830/api/v1/wg/peers/$ID/config | NexusPointWG qrcode
+
+For public-key-only peers, --private-key-file fills in the private key kept on the client.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			content, err := readClientConfig(cmd, args, privateKeyFile)
+			if err != nil {
+				return err
+			}
+
+			terminal, err := qrcode.Terminal(content)
+			if err != nil {
+				return fmt.Errorf("failed to render QR code: %w", err)
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), terminal)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&privateKeyFile, "private-key-file", "", "File holding the client private key to fill into the configuration (e.g. from `wg genkey`)")
+	return cmd
+}
+
+// NewAssembleConfigCommand returns the command that fills the client private key into a downloaded configuration.
+func NewAssembleConfigCommand() *cobra.Command {
+	var privateKeyFile string
+	cmd := &cobra.Command{
+		Use:   "assemble-config [FILE]",
+		Short: "Fill the client private key into a public-key-only WireGuard configuration",
+		Long: `Fill the private key kept on the client into the configuration of a public-key-only peer, whose downloaded
+configuration only holds a placeholder. The configuration is read from FILE, or from stdin if FILE is omitted or "-",
+and the complete configuration is printed, e.g.:
+
+  wg genkey | tee privatekey | wg pubkey   # register the public key as client_public_key
+  NexusPointWG assemble-config --private-key-file privatekey peer.conf > wg0.conf`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			content, err := readClientConfig(cmd, args, privateKeyFile)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), content)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&privateKeyFile, "private-key-file", "", "File holding the client private key (e.g. from `wg genkey`)")
+	_ = cmd.MarkFlagRequired("private-key-file")
+	return cmd
+}
+
+// readClientConfig reads a client configuration from the file in args or stdin,
+// filling in the private key from privateKeyFile if set.
+func readClientConfig(cmd *cobra.Command, args []string, privateKeyFile string) (string, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if len(args) == 0 || args[0] == "-" {
+		content, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		content, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read client config: %w", err)
+	}
+	if len(content) == 0 {
+		return "", fmt.Errorf("client config is empty")
+	}
+	if privateKeyFile == "" {
+		return string(content), nil
+	}
+
+	privateKey, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key: %w", err)
+	}
+	filled, err := wireguard.FillPrivateKey(string(content), strings.TrimSpace(string(privateKey)))
+	if err != nil {
+		return "", fmt.Errorf("failed to fill in private key: %w", err)
+	}
+	return filled, nil
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/options"
+	"github.com/HappyLadySauce/NexusPointWG/internal/service"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store/sqlite"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/secret"
+)
+
+// NewRekeyCommand returns the command that re-encrypts the stored peer secrets with the current master key.
+func NewRekeyCommand(ctx context.Context) *cobra.Command {
+	opts := options.NewOptions()
+	var oldMasterKeyFiles []string
+	cmd := &cobra.Command{
+		Use:   "rekey",
+		Short: "Re-encrypt stored peer secrets with the current master key",
+		Long: `Re-encrypt the peer private and preshared keys in the database and the client config files under
+user-dir with the master key configured through encryption.master-key-file or NEXUSPOINTWG_ENCRYPTION_MASTER_KEY.
+Secrets encrypted with a previous master key are read with the keys passed as --old-master-key-file, and plaintext
+secrets stored before encryption was enabled are encrypted as well. Stop the server before rotating the master key, e.g.:
+
+  openssl rand -base64 32 > /etc/wireguard/master.key.new
+  NexusPointWG rekey --encryption.master-key-file /etc/wireguard/master.key.new --old-master-key-file /etc/wireguard/master.key`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := viper.BindPFlags(cmd.Flags()); err != nil {
+				return err
+			}
+			if err := viper.Unmarshal(opts); err != nil {
+				return err
+			}
+			for _, validate := range []func() []error{opts.Sqlite.Validate, opts.WireGuard.Validate, opts.Encryption.Validate} {
+				if errs := validate(); len(errs) != 0 {
+					return errs[0]
+				}
+			}
+
+			masterKey, err := opts.Encryption.LoadMasterKey()
+			if err != nil {
+				return err
+			}
+			if masterKey == nil {
+				return fmt.Errorf("no master key configured, set encryption.master-key-file or NEXUSPOINTWG_ENCRYPTION_MASTER_KEY")
+			}
+			var oldMasterKeys [][]byte
+			for _, file := range oldMasterKeyFiles {
+				key, err := secret.LoadMasterKey(file)
+				if err != nil {
+					return err
+				}
+				oldMasterKeys = append(oldMasterKeys, key)
+			}
+			cipher, err := secret.NewCipher(masterKey, oldMasterKeys...)
+			if err != nil {
+				return err
+			}
+			secret.SetMasterCipher(cipher)
+
+			config.Init(&config.Config{
+				InsecureServing: opts.InsecureServing,
+				Sqlite:          opts.Sqlite,
+				Log:             opts.Log,
+				JWT:             opts.JWT,
+				WireGuard:       opts.WireGuard,
+			})
+			storeIns, err := sqlite.GetSqliteFactoryOr(opts.Sqlite)
+			if err != nil {
+				return fmt.Errorf("failed to open database: %w", err)
+			}
+			defer storeIns.Close()
+
+			peers, files, err := service.NewService(storeIns).WGPeers().ReencryptSecrets(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to re-encrypt secrets (%d peers, %d client configs done): %w", peers, files, err)
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Re-encrypted %d peers and %d client config files with master key %s\n", peers, files, cipher.KeyID())
+			return err
+		},
+	}
+	opts.Sqlite.AddFlags(cmd.Flags())
+	opts.WireGuard.AddFlags(cmd.Flags())
+	opts.Encryption.AddFlags(cmd.Flags())
+	cmd.Flags().StringSliceVar(&oldMasterKeyFiles, "old-master-key-file", nil, "File holding a previous master key the secrets may still be encrypted with (repeatable)")
+	return cmd
+}
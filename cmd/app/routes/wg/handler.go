@@ -10,6 +10,9 @@ import (
 func RegisterRoutes() {
 	wgController := wireguard.NewWGController(router.StoreIns)
 
+	// Config share links are signed and used without authentication
+	router.V1().GET("/wg/share/:token", wgController.DownloadSharedConfig)
+
 	// WireGuard routes require authentication
 	authed := router.Authed()
 
@@ -20,6 +23,29 @@ func RegisterRoutes() {
 	authed.PUT("/wg/peers/:id", wgController.UpdatePeer)
 	authed.DELETE("/wg/peers/:id", wgController.DeletePeer)
 	authed.GET("/wg/peers/:id/config", wgController.DownloadPeerConfig)
+	authed.GET("/wg/peers/:id/status", wgController.GetPeerStatus)
+	authed.GET("/wg/peers/:id/traffic", wgController.GetPeerTraffic)
+	authed.POST("/wg/peers/:id/rotate", wgController.RotatePeerKeys)
+	authed.POST("/wg/peers/rotate", wgController.RotatePeersKeys)
+	authed.POST("/wg/peers/export", wgController.ExportPeerConfigs)
+	authed.POST("/wg/peers/:id/share-links", wgController.CreateShareLink)
+	authed.GET("/wg/peers/:id/share-links", wgController.ListShareLinks)
+	authed.DELETE("/wg/share-links/:id", wgController.RevokeShareLink)
+	authed.POST("/wg/peers/:id/revoke", wgController.RevokePeer)
+	authed.GET("/wg/revocations", wgController.ListRevokedKeys)
+	authed.GET("/wg/users/:id/traffic", wgController.GetUserTraffic)
+
+	// Traffic quota management routes (admin only, enforced in controller)
+	authed.POST("/wg/quotas", wgController.CreateQuota)
+	authed.GET("/wg/quotas", wgController.ListQuotas)
+	authed.PUT("/wg/quotas/:id", wgController.UpdateQuota)
+	authed.DELETE("/wg/quotas/:id", wgController.DeleteQuota)
+
+	// Port forward management routes (admin only, enforced in controller)
+	authed.POST("/wg/port-forwards", wgController.CreatePortForward)
+	authed.GET("/wg/port-forwards", wgController.ListPortForwards)
+	authed.PUT("/wg/port-forwards/:id", wgController.UpdatePortForward)
+	authed.DELETE("/wg/port-forwards/:id", wgController.DeletePortForward)
 
 	// IP pool management routes (admin only, enforced in controller)
 	authed.POST("/wg/ip-pools", wgController.CreateIPPool)
@@ -28,9 +54,23 @@ func RegisterRoutes() {
 	authed.DELETE("/wg/ip-pools/:id", wgController.DeleteIPPool)
 	authed.GET("/wg/ip-pools/:id/available-ips", wgController.GetAvailableIPs)
 
+	// Interface management routes (admin only, enforced in controller)
+	authed.POST("/wg/interfaces", wgController.CreateInterface)
+	authed.GET("/wg/interfaces", wgController.ListInterfaces)
+	authed.GET("/wg/interfaces/:id", wgController.GetInterface)
+	authed.PUT("/wg/interfaces/:id", wgController.UpdateInterface)
+	authed.DELETE("/wg/interfaces/:id", wgController.DeleteInterface)
+
 	// Server configuration management routes (admin only, enforced in controller)
 	authed.GET("/wg/server-config", wgController.GetServerConfig)
 	authed.PUT("/wg/server-config", wgController.UpdateServerConfig)
+	authed.GET("/wg/server-config/revisions", wgController.ListServerConfigRevisions)
+	authed.GET("/wg/server-config/revisions/:revision/diff", wgController.DiffServerConfigRevisions)
+	authed.POST("/wg/server-config/revisions/:revision/rollback", wgController.RollbackServerConfig)
+	authed.GET("/wg/reconcile", wgController.GetReconcileStatus)
+	authed.POST("/wg/reconcile", wgController.Reconcile)
+	authed.GET("/wg/reconcile/drift", wgController.GetDriftReport)
+	authed.POST("/wg/reconcile/drift/:id/approve", wgController.ApproveDriftAction)
 
 	// Batch operations routes
 	authed.POST("/wg/ip-pools/batch", wgController.BatchCreateIPPools)
@@ -8,6 +8,7 @@ import (
 
 	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
@@ -77,12 +78,8 @@ func (w *WGController) BatchCreateIPPools(c *gin.Context) {
 	// Get global config for calculating effective endpoint
 	cfg := config.Get()
 	var wgOpts *options.WireGuardOptions
-	var configManager *wireguard.ServerConfigManager
 	if cfg != nil && cfg.WireGuard != nil {
 		wgOpts = cfg.WireGuard
-		if wgOpts.ServerConfigPath() != "" {
-			configManager = wireguard.NewServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod)
-		}
 	}
 
 	// Convert requests to models
@@ -96,21 +93,35 @@ func (w *WGController) BatchCreateIPPools(c *gin.Context) {
 			return
 		}
 
-		// Calculate effective endpoint if not provided
+		// Calculate effective endpoint from the pool's interface config if not provided
 		endpoint := item.Endpoint
 		if endpoint == "" && wgOpts != nil {
+			configManager, err := w.srv.WGInterfaces().ConfigManager(context.Background(), item.InterfaceID)
+			if err != nil {
+				klog.V(1).InfoS("failed to get server config manager", "interfaceID", item.InterfaceID, "error", err)
+				core.WriteResponse(c, err, nil)
+				return
+			}
 			endpoint = service.CalculateIPPoolEndpoint("", wgOpts, configManager, context.Background())
 		}
 
 		pool := &model.IPPool{
-			ID:          poolID,
-			Name:        item.Name,
-			CIDR:        item.CIDR,
-			Routes:      item.Routes,
-			DNS:         item.DNS,
-			Endpoint:    endpoint,
-			Description: item.Description,
-			Status:      model.IPPoolStatusActive,
+			ID:                   poolID,
+			Name:                 item.Name,
+			CIDR:                 item.CIDR,
+			Routes:               item.Routes,
+			DNS:                  item.DNS,
+			Endpoint:             endpoint,
+			Description:          item.Description,
+			RequirePresharedKey:  item.RequirePresharedKey,
+			InterfaceID:          item.InterfaceID,
+			PeerLifetimeHours:    item.PeerLifetimeHours,
+			RequirePublicKeyOnly: item.RequirePublicKeyOnly,
+			PrefixLength:         item.PrefixLength,
+			PrefixLengthV6:       item.PrefixLengthV6,
+			Isolation:            item.Isolation,
+			ExitPeerID:           item.ExitPeerID,
+			Status:               model.IPPoolStatusActive,
 		}
 
 		pools = append(pools, pool)
@@ -185,12 +196,8 @@ func (w *WGController) BatchUpdateIPPools(c *gin.Context) {
 	// Get global config for calculating effective endpoint
 	cfg := config.Get()
 	var wgOpts *options.WireGuardOptions
-	var configManager *wireguard.ServerConfigManager
 	if cfg != nil && cfg.WireGuard != nil {
 		wgOpts = cfg.WireGuard
-		if wgOpts.ServerConfigPath() != "" {
-			configManager = wireguard.NewServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod)
-		}
 	}
 
 	// Convert requests to models
@@ -210,7 +217,8 @@ func (w *WGController) BatchUpdateIPPools(c *gin.Context) {
 		}
 		if item.CIDR != nil {
 			// Check if CIDR is being modified and pool has allocated IPs
-			if *item.CIDR != existing.CIDR {
+			// (adding an address family to a pool in use is allowed)
+			if *item.CIDR != existing.CIDR && !ip.IsPoolCIDRExtension(existing.CIDR, *item.CIDR) {
 				hasAllocated, err := w.srv.IPPools().HasAllocatedIPs(context.Background(), item.ID)
 				if err != nil {
 					klog.V(1).InfoS("failed to check allocated IPs", "poolID", item.ID, "error", err)
@@ -221,8 +229,22 @@ func (w *WGController) BatchUpdateIPPools(c *gin.Context) {
 					core.WriteResponse(c, errors.WithCode(code.ErrIPPoolInUse, "IP pool is in use and CIDR cannot be modified"), nil)
 					return
 				}
-				existing.CIDR = *item.CIDR
 			}
+			existing.CIDR = *item.CIDR
+		}
+		if item.InterfaceID != nil && *item.InterfaceID != existing.InterfaceID {
+			// Peers follow the interface of their pool, so only unused pools can be moved
+			hasAllocated, err := w.srv.IPPools().HasAllocatedIPs(context.Background(), item.ID)
+			if err != nil {
+				klog.V(1).InfoS("failed to check allocated IPs", "poolID", item.ID, "error", err)
+				core.WriteResponse(c, err, nil)
+				return
+			}
+			if hasAllocated {
+				core.WriteResponse(c, errors.WithCode(code.ErrIPPoolInUse, "IP pool is in use and interface cannot be modified"), nil)
+				return
+			}
+			existing.InterfaceID = *item.InterfaceID
 		}
 		if item.Routes != nil {
 			existing.Routes = *item.Routes
@@ -232,6 +254,12 @@ func (w *WGController) BatchUpdateIPPools(c *gin.Context) {
 		}
 		if item.Endpoint != nil {
 			if *item.Endpoint == "" && wgOpts != nil {
+				configManager, err := w.srv.WGInterfaces().ConfigManager(context.Background(), existing.InterfaceID)
+				if err != nil {
+					klog.V(1).InfoS("failed to get server config manager", "interfaceID", existing.InterfaceID, "error", err)
+					core.WriteResponse(c, err, nil)
+					return
+				}
 				existing.Endpoint = service.CalculateIPPoolEndpoint("", wgOpts, configManager, context.Background())
 			} else {
 				existing.Endpoint = *item.Endpoint
@@ -243,12 +271,33 @@ func (w *WGController) BatchUpdateIPPools(c *gin.Context) {
 		if item.Status != nil {
 			existing.Status = *item.Status
 		}
+		if item.RequirePresharedKey != nil {
+			existing.RequirePresharedKey = *item.RequirePresharedKey
+		}
+		if item.PeerLifetimeHours != nil {
+			existing.PeerLifetimeHours = *item.PeerLifetimeHours
+		}
+		if item.RequirePublicKeyOnly != nil {
+			existing.RequirePublicKeyOnly = *item.RequirePublicKeyOnly
+		}
+		if item.PrefixLength != nil {
+			existing.PrefixLength = *item.PrefixLength
+		}
+		if item.PrefixLengthV6 != nil {
+			existing.PrefixLengthV6 = *item.PrefixLengthV6
+		}
+		if item.Isolation != nil {
+			existing.Isolation = *item.Isolation
+		}
+		if item.ExitPeerID != nil {
+			existing.ExitPeerID = *item.ExitPeerID
+		}
 
 		pools = append(pools, existing)
 	}
 
 	// Call Service layer to batch update IP pools
-	if err := w.srv.IPPools().BatchUpdateIPPools(context.Background(), pools); err != nil {
+	if err := w.srv.IPPools().BatchUpdateIPPools(revisionContext(c), pools); err != nil {
 		klog.V(1).InfoS("failed to batch update IP pools", "count", len(req.Items), "error", err)
 		core.WriteResponse(c, err, nil)
 		return
@@ -416,9 +465,38 @@ func (w *WGController) BatchCreateWGPeers(c *gin.Context) {
 			break
 		}
 
+		// Setting an explicit expiration additionally requires wg_peer:update_sensitive
+		expiresAt, err := parseExpiresAt(item.ExpiresAt)
+		if err != nil {
+			firstError = err
+			break
+		}
+		// So does routing subnets behind the peer or pinning its endpoint
+		if expiresAt != nil || item.RoutedSubnets != "" || item.PeerEndpoint != "" {
+			allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdateSensitive)
+			if err != nil {
+				firstError = errors.WithCode(code.ErrUnknown, "authorization engine error")
+				break
+			}
+			if !allowed {
+				firstError = errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied))
+				break
+			}
+		}
+
+		// Generate preshared key on request (service layer also generates one when required by policy)
+		presharedKey := item.PresharedKey
+		if presharedKey == "" && item.EnablePresharedKey != nil && *item.EnablePresharedKey {
+			presharedKey, err = wireguard.GeneratePresharedKey()
+			if err != nil {
+				firstError = err
+				break
+			}
+		}
+
 		// Create peer using existing method (includes IP allocation, key generation, config files)
-		_, err = w.srv.WGPeers().CreatePeer(
-			context.Background(),
+		_, _, err = w.srv.WGPeers().CreatePeer(
+			revisionContext(c),
 			targetUserID,
 			item.DeviceName,
 			item.IPPoolID,
@@ -427,7 +505,12 @@ func (w *WGController) BatchCreateWGPeers(c *gin.Context) {
 			item.DNS,
 			item.Endpoint,
 			item.ClientPrivateKey,
+			item.ClientPublicKey,
+			presharedKey,
+			item.RoutedSubnets,
+			item.PeerEndpoint,
 			item.PersistentKeepalive,
+			expiresAt,
 		)
 		if err != nil {
 			firstError = err
@@ -508,6 +591,13 @@ func (w *WGController) BatchUpdateWGPeers(c *gin.Context) {
 			return
 		}
 
+		// Routes are validated against all other peers and change the server config,
+		// which this database-only endpoint does not update
+		if item.RoutedSubnets != nil || item.PeerEndpoint != nil {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "peer %s: routed_subnets and peer_endpoint can only be changed by updating the peer", item.ID), nil)
+			return
+		}
+
 		// Check permission
 		scope := spec.ScopeAny
 		if requesterID != "" && requesterID == existing.UserID {
@@ -516,7 +606,7 @@ func (w *WGController) BatchUpdateWGPeers(c *gin.Context) {
 		obj := spec.Obj(spec.ResourceWGPeer, scope)
 
 		// Check if request includes sensitive updates
-		hasSensitive := item.ClientPrivateKey != nil || item.Username != nil
+		hasSensitive := item.ClientPrivateKey != nil || item.Username != nil || item.PresharedKey != nil || item.ExpiresAt != nil
 
 		allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdate)
 		if err != nil {
@@ -568,11 +658,59 @@ func (w *WGController) BatchUpdateWGPeers(c *gin.Context) {
 			existing.PersistentKeepalive = *item.PersistentKeepalive
 		}
 		if item.Status != nil {
+			// Peers disabled by a traffic quota come back when the quota resets, or when it is raised or deleted
+			if *item.Status == model.WGPeerStatusActive && existing.DisabledReason == model.WGPeerDisabledReasonQuota {
+				core.WriteResponse(c, errors.WithCode(code.ErrWGPeerQuotaExceeded, "peer %s: %s", item.ID, code.Message(code.ErrWGPeerQuotaExceeded)), nil)
+				return
+			}
+			if *item.Status == model.WGPeerStatusActive && existing.DisabledReason == model.WGPeerDisabledReasonExpired {
+				core.WriteResponse(c, errors.WithCode(code.ErrWGPeerExpired, "peer %s: %s", item.ID, code.Message(code.ErrWGPeerExpired)), nil)
+				return
+			}
+			if *item.Status != existing.Status && existing.DisabledReason == model.WGPeerDisabledReasonRevoked {
+				core.WriteResponse(c, errors.WithCode(code.ErrWGPeerRevoked, "peer %s: %s", item.ID, code.Message(code.ErrWGPeerRevoked)), nil)
+				return
+			}
+			if *item.Status != existing.Status {
+				existing.DisabledReason = ""
+			}
 			existing.Status = *item.Status
 		}
 		if item.ClientPrivateKey != nil {
 			existing.ClientPrivateKey = *item.ClientPrivateKey
 		}
+		if item.PresharedKey != nil {
+			existing.PresharedKey = *item.PresharedKey
+		}
+		// Regenerating the preshared key requires wg_config:rotate
+		if item.RegeneratePresharedKey != nil && *item.RegeneratePresharedKey {
+			allowed, err := spec.Enforce(requesterRole, spec.Obj(spec.ResourceWGConfig, scope), spec.ActionWGConfigRotate)
+			if err != nil {
+				klog.V(1).InfoS("authz enforce failed for preshared key rotation", "peerID", item.ID, "error", err)
+				core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+				return
+			}
+			if !allowed {
+				klog.V(1).InfoS("permission denied for preshared key rotation", "peerID", item.ID, "requesterRole", requesterRole)
+				core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+				return
+			}
+			presharedKey, err := wireguard.GeneratePresharedKey()
+			if err != nil {
+				klog.V(1).InfoS("failed to generate preshared key", "peerID", item.ID, "error", err)
+				core.WriteResponse(c, err, nil)
+				return
+			}
+			existing.PresharedKey = presharedKey
+		}
+		if item.ExpiresAt != nil {
+			expiresAt, err := parseExpiresAt(*item.ExpiresAt)
+			if err != nil {
+				core.WriteResponse(c, err, nil)
+				return
+			}
+			existing.ExpiresAt = expiresAt
+		}
 		if item.Username != nil {
 			// Look up user and update UserID
 			user, err := w.srv.Users().GetUserByUsername(context.Background(), *item.Username)
@@ -587,7 +725,7 @@ func (w *WGController) BatchUpdateWGPeers(c *gin.Context) {
 	}
 
 	// Call Service layer to batch update peers (database only)
-	if err := w.srv.WGPeers().BatchUpdatePeers(context.Background(), peers); err != nil {
+	if err := w.srv.WGPeers().BatchUpdatePeers(revisionContext(c), peers); err != nil {
 		klog.V(1).InfoS("failed to batch update WireGuard peers", "count", len(req.Items), "error", err)
 		core.WriteResponse(c, err, nil)
 		return
@@ -658,6 +796,12 @@ func (w *WGController) BatchDeleteWGPeers(c *gin.Context) {
 			return
 		}
 
+		// Deleting an exit node unassigns its peers and pools, which this database-only endpoint does not do
+		if peer.ExitNode {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "peer %s: exit nodes can only be deleted individually", peerID), nil)
+			return
+		}
+
 		// Check permission
 		scope := spec.ScopeAny
 		if requesterID != "" && requesterID == peer.UserID {
@@ -679,7 +823,7 @@ func (w *WGController) BatchDeleteWGPeers(c *gin.Context) {
 	}
 
 	// Call Service layer to batch delete peers (database only)
-	if err := w.srv.WGPeers().BatchDeletePeers(context.Background(), req.IDs); err != nil {
+	if err := w.srv.WGPeers().BatchDeletePeers(revisionContext(c), req.IDs); err != nil {
 		klog.V(1).InfoS("failed to batch delete WireGuard peers", "count", len(req.IDs), "error", err)
 		core.WriteResponse(c, err, nil)
 		return
@@ -0,0 +1,64 @@
+package wireguard
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// DeleteInterface deletes a WireGuard interface by ID (admin only).
+// @Summary Delete WireGuard interface
+// @Description Delete a WireGuard interface by ID. Admin only. The interface is stopped and its config file is archived. The default interface and interfaces with IP pools or peers cannot be deleted.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "Interface ID"
+// @Success 200 {object} core.SuccessResponse "WireGuard interface deleted successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - interface is in use"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - interface not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/interfaces/{id} [delete]
+func (w *WGController) DeleteInterface(c *gin.Context) {
+	klog.V(1).Info("wireguard interface delete function called.")
+
+	interfaceID := c.Param("id")
+	if interfaceID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing interface ID"), nil)
+		return
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGInterface, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGInterfaceDelete)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Delete interface (Service layer will check if interface is in use)
+	if err := w.srv.WGInterfaces().DeleteInterface(context.Background(), interfaceID); err != nil {
+		klog.V(1).InfoS("failed to delete WireGuard interface", "interfaceID", interfaceID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("wireguard interface deleted successfully", "interfaceID", interfaceID)
+	core.WriteResponse(c, nil, nil)
+}
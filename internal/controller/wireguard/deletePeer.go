@@ -10,6 +10,7 @@ import (
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
 	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
 	"github.com/HappyLadySauce/errors"
 )
@@ -20,7 +21,7 @@ import (
 // @Tags wireguard
 // @Produce json
 // @Param id path string true "Peer ID"
-// @Success 200 {object} core.SuccessResponse "Peer deleted successfully"
+// @Success 200 {object} v1.WGApplyResponse "Peer deleted successfully"
 // @Failure 400 {object} core.ErrResponse "Bad request - invalid peer ID"
 // @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
 // @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
@@ -76,12 +77,13 @@ func (w *WGController) DeletePeer(c *gin.Context) {
 	isHardDelete := requesterRole == model.UserRoleAdmin
 
 	// Delete peer (IP allocation release/delete is handled in Service layer)
-	if err := w.srv.WGPeers().DeletePeer(context.Background(), peerID, isHardDelete); err != nil {
+	applied, err := w.srv.WGPeers().DeletePeer(revisionContext(c), peerID, isHardDelete)
+	if err != nil {
 		klog.V(1).InfoS("failed to delete peer", "peerID", peerID, "error", err)
 		core.WriteResponse(c, err, nil)
 		return
 	}
 
 	klog.V(1).InfoS("wireguard peer deleted successfully", "peerID", peerID, "hardDelete", isHardDelete)
-	core.WriteResponse(c, nil, nil)
+	core.WriteResponse(c, nil, v1.WGApplyResponse{Applied: toApplyResultResponses(applied...)})
 }
@@ -3,7 +3,7 @@ package wireguard
 import (
 	"context"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"k8s.io/klog/v2"
@@ -15,16 +15,24 @@ import (
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
 	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
 	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/qrcode"
 	"github.com/HappyLadySauce/errors"
 )
 
+const (
+	mimeTextPlain = "text/plain"
+	mimePNG       = "image/png"
+	mimeSVG       = "image/svg+xml"
+)
+
 // DownloadPeerConfig downloads the WireGuard client configuration file for a peer.
 // @Summary Download WireGuard peer configuration
-// @Description Download the WireGuard client configuration file for a peer. Admin can download any peer's config, regular users can only download their own peer configs.
+// @Description Download the WireGuard client configuration file for a peer, as text or as a QR code for the mobile apps (PNG or SVG through the Accept header or format=qr, ANSI for terminals). Admin can download any peer's config, regular users can only download their own peer configs.
 // @Tags wireguard
-// @Produce text/plain
+// @Produce text/plain,image/png,image/svg+xml
 // @Param id path string true "Peer ID"
-// @Success 200 {string} string "Configuration file content"
+// @Param format query string false "Output format (text/qr/png/svg/ansi), overrides the Accept header"
+// @Success 200 {string} string "Configuration file content or QR code"
 // @Failure 400 {object} core.ErrResponse "Bad request - invalid peer ID"
 // @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
 // @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
@@ -76,32 +84,60 @@ func (w *WGController) DownloadPeerConfig(c *gin.Context) {
 		return
 	}
 
+	w.writePeerConfig(c, peer)
+}
+
+// writePeerConfig writes the client configuration file of a peer to the response.
+func (w *WGController) writePeerConfig(c *gin.Context, peer *model.WGPeer) {
+	configContent, err := w.peerConfigContent(peer)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	writeConfigContent(c, configContent)
+}
+
+// peerConfigContent returns the client configuration file of a peer,
+// generating it on the fly if it has not been saved yet.
+func (w *WGController) peerConfigContent(peer *model.WGPeer) ([]byte, error) {
+	peerID := peer.ID
+
+	// Revoked peers never get their configuration back
+	if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+		return nil, errors.WithCode(code.ErrWGPeerRevoked, "%s", code.Message(code.ErrWGPeerRevoked))
+	}
+
 	// Get WireGuard config
 	cfg := config.Get()
 	if cfg == nil || cfg.WireGuard == nil {
-		core.WriteResponse(c, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized"), nil)
-		return
+		return nil, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
 	}
 
 	wgOpts := cfg.WireGuard
 
 	// Try to read existing config file first
-	userDir := wgOpts.ResolvedUserDir()
-	configPath := filepath.Join(userDir, peerID+".conf")
-
-	configContent, err := os.ReadFile(configPath)
+	configContent, err := wireguard.LoadClientConfig(wgOpts.ResolvedUserDir(), peerID)
 	if err == nil {
 		// File exists, return it
-		c.Data(200, "text/plain; charset=utf-8", configContent)
-		return
+		return []byte(configContent), nil
+	}
+	if !os.IsNotExist(err) {
+		klog.V(1).InfoS("failed to read client config file", "peerID", peerID, "error", err)
+		// Continue to generate it on the fly
+	}
+	if peer.SecretsUnreadable {
+		return nil, errors.WithCode(code.ErrDecodingFailed, "failed to decrypt the secrets of peer %s", peerID)
 	}
 
 	// File doesn't exist, generate it on the fly
 	// Get server public key and MTU
 	var serverPublicKey string
 	var mtu int
-	if wgOpts.ServerConfigPath() != "" {
-		configManager := wireguard.NewServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod)
+	configManager, err := w.srv.WGInterfaces().ConfigManager(context.Background(), peer.InterfaceID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get server config manager", "interfaceID", peer.InterfaceID, "error", err)
+		// Continue with empty server public key
+	} else {
 		serverPublicKey, err = configManager.GetServerPublicKey()
 		if err != nil {
 			klog.V(1).InfoS("failed to get server public key", "error", err)
@@ -139,6 +175,9 @@ func (w *WGController) DownloadPeerConfig(c *gin.Context) {
 		}
 	}
 
+	// Route to the subnets behind the site-to-site peers of the interface
+	allowedIPs = w.srv.WGPeers().ClientAllowedIPs(context.Background(), peer, allowedIPs)
+
 	// Generate client config
 	clientConfig := &wireguard.ClientConfig{
 		PrivateKey:          peer.ClientPrivateKey,
@@ -147,6 +186,7 @@ func (w *WGController) DownloadPeerConfig(c *gin.Context) {
 		MTU:                 mtu,
 		Endpoint:            endpoint,
 		PublicKey:           serverPublicKey,
+		PresharedKey:        peer.PresharedKey,
 		AllowedIPs:          allowedIPs,
 		PersistentKeepalive: peer.PersistentKeepalive,
 	}
@@ -154,5 +194,65 @@ func (w *WGController) DownloadPeerConfig(c *gin.Context) {
 	configContentStr := wireguard.GenerateClientConfig(clientConfig)
 
 	// Return config content
-	c.Data(200, "text/plain; charset=utf-8", []byte(configContentStr))
+	return []byte(configContentStr), nil
+}
+
+// writeConfigContent writes a client configuration in the format requested through the format query
+// parameter (text, qr, png, svg or ansi) or, if it is absent, the Accept header. "qr" is a PNG QR code
+// unless SVG is accepted.
+func writeConfigContent(c *gin.Context, configContent []byte) {
+	contentType, data, err := renderConfigContent(c, configContent)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	c.Data(200, contentType, data)
+}
+
+// renderConfigContent renders a client configuration in the format requested like writeConfigContent,
+// and returns its content type and content.
+func renderConfigContent(c *gin.Context, configContent []byte) (string, []byte, error) {
+	format := strings.ToLower(strings.TrimSpace(c.Query("format")))
+	if format == "" || format == "qr" {
+		switch c.NegotiateFormat(mimeTextPlain, mimePNG, mimeSVG) {
+		case mimePNG:
+			format = "png"
+		case mimeSVG:
+			format = "svg"
+		default:
+			if format == "" {
+				format = "text"
+			} else {
+				format = "png"
+			}
+		}
+	}
+
+	switch format {
+	case "text":
+		return mimeTextPlain + "; charset=utf-8", configContent, nil
+	case "png":
+		png, err := qrcode.PNG(string(configContent), qrcode.DefaultPNGSize)
+		if err != nil {
+			klog.V(1).InfoS("failed to render QR code", "format", format, "error", err)
+			return "", nil, errors.WithCode(code.ErrUnknown, "failed to render QR code: %s", err.Error())
+		}
+		return mimePNG, png, nil
+	case "svg":
+		svg, err := qrcode.SVG(string(configContent))
+		if err != nil {
+			klog.V(1).InfoS("failed to render QR code", "format", format, "error", err)
+			return "", nil, errors.WithCode(code.ErrUnknown, "failed to render QR code: %s", err.Error())
+		}
+		return mimeSVG, svg, nil
+	case "ansi":
+		terminal, err := qrcode.Terminal(string(configContent))
+		if err != nil {
+			klog.V(1).InfoS("failed to render QR code", "format", format, "error", err)
+			return "", nil, errors.WithCode(code.ErrUnknown, "failed to render QR code: %s", err.Error())
+		}
+		return mimeTextPlain + "; charset=utf-8", []byte(terminal), nil
+	default:
+		return "", nil, errors.WithCode(code.ErrValidation, "invalid format, must be 'text', 'qr', 'png', 'svg' or 'ansi'")
+	}
 }
@@ -0,0 +1,240 @@
+package wireguard
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/qrcode"
+	"github.com/HappyLadySauce/errors"
+)
+
+// maxExportSize is the maximum number of peers in one config export.
+const maxExportSize = 500
+
+// exportFileNameInvalidChars matches the characters not allowed in exported file names.
+var exportFileNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_=+.-]+`)
+
+// ExportPeerConfigs streams the client configurations of many peers as a ZIP archive.
+// @Summary Export WireGuard peer configurations
+// @Description Download a ZIP archive of client configurations, selected by peer IDs or by user and/or IP pool (regular users default to their own peers). Files are named after the device name, optionally with PNG QR codes and a manifest.json. Peers the requester may not download are rejected when listed by ID and left out otherwise.
+// @Tags wireguard
+// @Accept json
+// @Produce application/zip
+// @Param request body v1.ExportWGPeerConfigsRequest true "Peers to export"
+// @Success 200 {file} file "ZIP archive of client configurations"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or validation failed"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - peer not found or no peers to export"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/peers/export [post]
+func (w *WGController) ExportPeerConfigs(c *gin.Context) {
+	klog.V(1).Info("wireguard peer config export function called.")
+
+	// Get requester info from JWTAuth middleware
+	requesterIDAny, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "missing auth context"), nil)
+		return
+	}
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterID, _ := requesterIDAny.(string)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// Parse request body
+	var req v1.ExportWGPeerConfigsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	// --- Authorization (Casbin) ---
+	canDownload := func(peer *model.WGPeer) (bool, error) {
+		scope := spec.ScopeAny
+		if requesterID != "" && requesterID == peer.UserID {
+			scope = spec.ScopeSelf
+		}
+		return spec.Enforce(requesterRole, spec.Obj(spec.ResourceWGConfig, scope), spec.ActionWGConfigDownload)
+	}
+
+	var peers []*model.WGPeer
+	if len(req.PeerIDs) > 0 {
+		// Explicitly listed peers must all be downloadable
+		for _, peerID := range req.PeerIDs {
+			peer, err := w.srv.WGPeers().GetPeer(context.Background(), peerID)
+			if err != nil {
+				klog.V(1).InfoS("failed to get peer", "peerID", peerID, "error", err)
+				core.WriteResponse(c, err, nil)
+				return
+			}
+			allowed, err := canDownload(peer)
+			if err != nil {
+				klog.V(1).InfoS("authz enforce failed", "error", err)
+				core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+				return
+			}
+			if !allowed {
+				core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+				return
+			}
+			if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+				core.WriteResponse(c, errors.WithCode(code.ErrWGPeerRevoked, "peer %s: %s", peer.ID, code.Message(code.ErrWGPeerRevoked)), nil)
+				return
+			}
+			peers = append(peers, peer)
+		}
+	} else {
+		opt := store.WGPeerListOptions{
+			UserID:   req.UserID,
+			IPPoolID: req.IPPoolID,
+		}
+		if opt.UserID == "" && opt.IPPoolID == "" {
+			opt.UserID = requesterID
+		}
+
+		// Peers selected by filter are left out if they may not be downloaded
+		for {
+			opt.Limit = 200
+			page, total, err := w.srv.WGPeers().ListPeers(context.Background(), opt)
+			if err != nil {
+				klog.V(1).InfoS("failed to list peers", "error", err)
+				core.WriteResponse(c, err, nil)
+				return
+			}
+			for _, peer := range page {
+				allowed, err := canDownload(peer)
+				if err != nil {
+					klog.V(1).InfoS("authz enforce failed", "error", err)
+					core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+					return
+				}
+				if allowed && peer.DisabledReason != model.WGPeerDisabledReasonRevoked {
+					peers = append(peers, peer)
+				}
+			}
+			opt.Offset += len(page)
+			if len(page) == 0 || int64(opt.Offset) >= total {
+				break
+			}
+		}
+		if len(peers) > maxExportSize {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "export size exceeds maximum of %d peers", maxExportSize), nil)
+			return
+		}
+	}
+
+	if len(peers) == 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrWGPeerNotFound, "no peers to export"), nil)
+		return
+	}
+
+	// Stream the archive; errors past this point can only be logged
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="wireguard-configs-%s.zip"`, time.Now().Format("20060102-150405")))
+	c.Status(200)
+
+	archive := zip.NewWriter(c.Writer)
+	defer func() {
+		if err := archive.Close(); err != nil {
+			klog.V(1).InfoS("failed to finish config export archive", "error", err)
+		}
+	}()
+
+	usedNames := make(map[string]bool, len(peers))
+	manifest := make([]v1.ExportManifestEntry, 0, len(peers))
+	for _, peer := range peers {
+		configContent, err := w.peerConfigContent(peer)
+		if err != nil {
+			klog.V(1).InfoS("failed to get peer config for export", "peerID", peer.ID, "error", err)
+			continue
+		}
+
+		name := exportFileName(peer, usedNames)
+		entry := v1.ExportManifestEntry{
+			PeerID:      peer.ID,
+			UserID:      peer.UserID,
+			DeviceName:  peer.DeviceName,
+			ClientIP:    peer.ClientIP,
+			IPPoolID:    peer.IPPoolID,
+			InterfaceID: peer.InterfaceID,
+			Status:      peer.Status,
+			ExpiresAt:   formatExpiresAt(peer.ExpiresAt),
+			ConfigFile:  name + ".conf",
+		}
+		if err := writeZipFile(archive, entry.ConfigFile, configContent); err != nil {
+			klog.V(1).InfoS("failed to write peer config to export archive", "peerID", peer.ID, "error", err)
+			return
+		}
+
+		if req.IncludeQR {
+			png, err := qrcode.PNG(string(configContent), qrcode.DefaultPNGSize)
+			if err != nil {
+				klog.V(1).InfoS("failed to render QR code for export", "peerID", peer.ID, "error", err)
+			} else {
+				entry.QRFile = name + ".png"
+				if err := writeZipFile(archive, entry.QRFile, png); err != nil {
+					klog.V(1).InfoS("failed to write QR code to export archive", "peerID", peer.ID, "error", err)
+					return
+				}
+			}
+		}
+		manifest = append(manifest, entry)
+	}
+
+	if req.IncludeManifest {
+		manifestContent, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			klog.V(1).InfoS("failed to encode export manifest", "error", err)
+			return
+		}
+		if err := writeZipFile(archive, "manifest.json", manifestContent); err != nil {
+			klog.V(1).InfoS("failed to write manifest to export archive", "error", err)
+			return
+		}
+	}
+
+	klog.V(1).InfoS("wireguard peer configs exported successfully", "count", len(manifest), "requesterID", requesterID)
+}
+
+// exportFileName returns a unique file name (without extension) for a peer, based on its device name.
+func exportFileName(peer *model.WGPeer, usedNames map[string]bool) string {
+	base := exportFileNameInvalidChars.ReplaceAllString(peer.DeviceName, "_")
+	if base == "" || base == "." || base == ".." {
+		base = peer.ID
+	}
+	name := base
+	for i := 2; usedNames[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	usedNames[name] = true
+	return name
+}
+
+// writeZipFile adds a file to a ZIP archive.
+func writeZipFile(archive *zip.Writer, name string, content []byte) error {
+	f, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
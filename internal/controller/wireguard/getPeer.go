@@ -82,14 +82,23 @@ func (w *WGController) GetPeer(c *gin.Context) {
 		Username:            "",
 		DeviceName:          peer.DeviceName,
 		ClientPublicKey:     peer.ClientPublicKey,
+		PublicKeyOnly:       peer.ClientPrivateKey == "",
 		ClientPrivateKey:    peer.ClientPrivateKey,
+		PresharedKey:        peer.PresharedKey,
 		ClientIP:            peer.ClientIP,
 		AllowedIPs:          peer.AllowedIPs,
 		DNS:                 peer.DNS,
 		Endpoint:            peer.Endpoint,
+		RoutedSubnets:       peer.RoutedSubnets,
+		PeerEndpoint:        peer.PeerEndpoint,
+		ExitNode:            peer.ExitNode,
+		ExitPeerID:          peer.ExitPeerID,
 		PersistentKeepalive: peer.PersistentKeepalive,
 		Status:              peer.Status,
+		DisabledReason:      peer.DisabledReason,
 		IPPoolID:            peer.IPPoolID,
+		InterfaceID:         peer.InterfaceID,
+		ExpiresAt:           formatExpiresAt(peer.ExpiresAt),
 		CreatedAt:           peer.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:           peer.UpdatedAt.Format(time.RFC3339),
 	}
@@ -97,5 +106,12 @@ func (w *WGController) GetPeer(c *gin.Context) {
 		resp.Username = user.Username
 	}
 
+	// Runtime status is best effort: the interface may be down
+	if status, err := w.srv.WGPeers().GetPeerStatus(context.Background(), peer); err == nil {
+		applyPeerStatus(&resp, status)
+	} else {
+		klog.V(2).InfoS("peer runtime status not available", "peerID", peer.ID, "error", err)
+	}
+
 	core.WriteResponse(c, nil, resp)
 }
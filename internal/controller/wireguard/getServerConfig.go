@@ -16,9 +16,10 @@ import (
 
 // GetServerConfig gets the server WireGuard configuration (admin only).
 // @Summary Get server configuration
-// @Description Get the WireGuard server configuration. Admin only.
+// @Description Get the WireGuard server configuration of an interface. Admin only.
 // @Tags wireguard
 // @Produce json
+// @Param interface_id query string false "WireGuard interface ID (default interface if empty)"
 // @Success 200 {object} v1.GetServerConfigResponse "Server configuration"
 // @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
 // @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
@@ -45,24 +46,35 @@ func (w *WGController) GetServerConfig(c *gin.Context) {
 	}
 
 	// Get server config
-	interfaceConfig, publicKey, serverIP, dns, err := w.srv.WGServer().GetServerConfig(context.Background())
+	interfaceConfig, publicKey, serverIP, dns, err := w.srv.WGServer().GetServerConfig(context.Background(), c.Query("interface_id"))
 	if err != nil {
 		klog.V(1).InfoS("failed to get server config", "error", err)
 		core.WriteResponse(c, err, nil)
 		return
 	}
 
+	natSettings, err := w.srv.WGServer().GetNATSettings(context.Background(), c.Query("interface_id"))
+	if err != nil {
+		klog.V(1).InfoS("failed to get NAT settings", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
 	// Convert to response type
 	resp := v1.GetServerConfigResponse{
-		Address:    interfaceConfig.Address,
-		ListenPort: interfaceConfig.ListenPort,
-		PrivateKey: interfaceConfig.PrivateKey,
-		MTU:        interfaceConfig.MTU,
-		PostUp:     interfaceConfig.PostUp,
-		PostDown:   interfaceConfig.PostDown,
-		PublicKey:  publicKey,
-		ServerIP:   serverIP,
-		DNS:        dns,
+		Address:         interfaceConfig.Address,
+		ListenPort:      interfaceConfig.ListenPort,
+		PrivateKey:      interfaceConfig.PrivateKey,
+		MTU:             interfaceConfig.MTU,
+		PostUp:          interfaceConfig.PostUp,
+		PostDown:        interfaceConfig.PostDown,
+		EgressInterface: natSettings.EgressInterface,
+		Masquerade:      natSettings.Masquerade,
+		IPForwarding:    natSettings.IPForwarding,
+		MSSClamping:     natSettings.MSSClamping,
+		PublicKey:       publicKey,
+		ServerIP:        serverIP,
+		DNS:             dns,
 	}
 
 	klog.V(1).InfoS("wireguard server config retrieved successfully")
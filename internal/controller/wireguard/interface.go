@@ -0,0 +1,255 @@
+package wireguard
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// CreateInterface creates a new WireGuard interface (admin only).
+// @Summary Create WireGuard interface
+// @Description Create a new WireGuard interface with its own server config file, listen port and key pair. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param interface body v1.CreateWGInterfaceRequest true "WireGuard interface information"
+// @Success 200 {object} v1.WGInterfaceResponse "WireGuard interface created successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or validation failed"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/interfaces [post]
+func (w *WGController) CreateInterface(c *gin.Context) {
+	klog.V(1).Info("wireguard interface create function called.")
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGInterface, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGInterfaceCreate)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Parse request body
+	var req v1.CreateWGInterfaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	// Raw PostUp/PostDown commands run as root and additionally require wg_server:raw_hooks
+	if req.PostUp != "" || req.PostDown != "" {
+		if !w.enforceRawHooks(c, requesterRole) {
+			return
+		}
+	}
+
+	iface := &model.WGInterface{
+		Name:        req.Name,
+		Description: req.Description,
+		Status:      model.WGInterfaceStatusActive,
+	}
+	ifaceConfig := &wireguard.InterfaceConfig{
+		PrivateKey: req.PrivateKey,
+		Address:    req.Address,
+		ListenPort: req.ListenPort,
+		MTU:        req.MTU,
+		PostUp:     req.PostUp,
+		PostDown:   req.PostDown,
+	}
+
+	// Create interface (Service layer writes the server config file)
+	if err := w.srv.WGInterfaces().CreateInterface(revisionContext(c), iface, ifaceConfig); err != nil {
+		klog.V(1).InfoS("failed to create WireGuard interface", "name", req.Name, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("wireguard interface created successfully", "interfaceID", iface.ID, "name", iface.Name)
+	core.WriteResponse(c, nil, w.buildInterfaceResponse(context.Background(), iface))
+}
+
+// ListInterfaces lists WireGuard interfaces with pagination (admin only).
+// @Summary List WireGuard interfaces
+// @Description List WireGuard interfaces with optional filters and pagination. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param status query string false "Filter by status (active/disabled)"
+// @Param offset query int false "Offset for pagination (default: 0)"
+// @Param limit query int false "Limit for pagination (default: 20, max: 200)"
+// @Success 200 {object} v1.WGInterfaceListResponse "WireGuard interfaces listed successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid parameters"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/interfaces [get]
+func (w *WGController) ListInterfaces(c *gin.Context) {
+	klog.V(1).Info("wireguard interface list function called.")
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGInterface, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGInterfaceList)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Build list options
+	opt := store.WGInterfaceListOptions{
+		Status: c.Query("status"),
+	}
+
+	// Parse pagination parameters
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid offset"), nil)
+			return
+		}
+		opt.Offset = offset
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid limit"), nil)
+			return
+		}
+		opt.Limit = limit
+	}
+
+	// List interfaces
+	ifaces, total, err := w.srv.WGInterfaces().ListInterfaces(context.Background(), opt)
+	if err != nil {
+		klog.V(1).InfoS("failed to list WireGuard interfaces", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// Convert to response format
+	items := make([]v1.WGInterfaceResponse, 0, len(ifaces))
+	for _, iface := range ifaces {
+		items = append(items, w.buildInterfaceResponse(context.Background(), iface))
+	}
+
+	resp := v1.WGInterfaceListResponse{
+		Total: total,
+		Items: items,
+	}
+
+	core.WriteResponse(c, nil, resp)
+}
+
+// GetInterface gets a WireGuard interface by ID (admin only).
+// @Summary Get WireGuard interface
+// @Description Get a WireGuard interface by ID, including address, listen port and public key from its server config file. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "Interface ID"
+// @Success 200 {object} v1.WGInterfaceResponse "WireGuard interface retrieved successfully"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - interface not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/interfaces/{id} [get]
+func (w *WGController) GetInterface(c *gin.Context) {
+	klog.V(1).Info("wireguard interface get function called.")
+
+	interfaceID := c.Param("id")
+	if interfaceID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing interface ID"), nil)
+		return
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGInterface, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGInterfaceGet)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	iface, err := w.srv.WGInterfaces().GetInterface(context.Background(), interfaceID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get WireGuard interface", "interfaceID", interfaceID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, w.buildInterfaceResponse(context.Background(), iface))
+}
+
+// buildInterfaceResponse converts an interface to its response, filling in
+// the fields stored in the interface's server config file.
+func (w *WGController) buildInterfaceResponse(ctx context.Context, iface *model.WGInterface) v1.WGInterfaceResponse {
+	resp := v1.WGInterfaceResponse{
+		ID:          iface.ID,
+		Name:        iface.Name,
+		Description: iface.Description,
+		Status:      iface.Status,
+		CreatedAt:   iface.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   iface.UpdatedAt.Format(time.RFC3339),
+	}
+	if cfg := config.Get(); cfg != nil && cfg.WireGuard != nil {
+		resp.IsDefault = iface.Name == cfg.WireGuard.Interface
+	}
+
+	configManager, err := w.srv.WGInterfaces().ConfigManager(ctx, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get server config manager", "interfaceID", iface.ID, "error", err)
+		return resp
+	}
+	serverConfig, err := configManager.ReadServerConfig()
+	if err != nil || serverConfig.Interface == nil {
+		klog.V(1).InfoS("failed to read server config", "interfaceID", iface.ID, "error", err)
+		return resp
+	}
+	resp.Address = serverConfig.Interface.Address
+	resp.ListenPort = serverConfig.Interface.ListenPort
+	if publicKey, err := configManager.GetServerPublicKey(); err == nil {
+		resp.PublicKey = publicKey
+	}
+	return resp
+}
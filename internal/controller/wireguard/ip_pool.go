@@ -72,14 +72,17 @@ func (w *WGController) CreateIPPool(c *gin.Context) {
 		return
 	}
 
-	// Get global config for calculating effective endpoint
+	// Get global config and the pool's interface config for calculating effective endpoint
 	cfg := config.Get()
 	var wgOpts *options.WireGuardOptions
 	var configManager *wireguard.ServerConfigManager
 	if cfg != nil && cfg.WireGuard != nil {
 		wgOpts = cfg.WireGuard
-		if wgOpts.ServerConfigPath() != "" {
-			configManager = wireguard.NewServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod)
+		configManager, err = w.srv.WGInterfaces().ConfigManager(context.Background(), req.InterfaceID)
+		if err != nil {
+			klog.V(1).InfoS("failed to get server config manager", "interfaceID", req.InterfaceID, "error", err)
+			core.WriteResponse(c, err, nil)
+			return
 		}
 	}
 
@@ -91,14 +94,22 @@ func (w *WGController) CreateIPPool(c *gin.Context) {
 
 	// Create pool model
 	pool := &model.IPPool{
-		ID:          poolID,
-		Name:        req.Name,
-		CIDR:        req.CIDR,
-		Routes:      req.Routes,
-		DNS:         req.DNS,
-		Endpoint:    endpoint,
-		Description: req.Description,
-		Status:      model.IPPoolStatusActive,
+		ID:                   poolID,
+		Name:                 req.Name,
+		CIDR:                 req.CIDR,
+		Routes:               req.Routes,
+		DNS:                  req.DNS,
+		Endpoint:             endpoint,
+		Description:          req.Description,
+		RequirePresharedKey:  req.RequirePresharedKey,
+		InterfaceID:          req.InterfaceID,
+		PeerLifetimeHours:    req.PeerLifetimeHours,
+		RequirePublicKeyOnly: req.RequirePublicKeyOnly,
+		PrefixLength:         req.PrefixLength,
+		PrefixLengthV6:       req.PrefixLengthV6,
+		Isolation:            req.Isolation,
+		ExitPeerID:           req.ExitPeerID,
+		Status:               model.IPPoolStatusActive,
 	}
 
 	// Create IP pool
@@ -109,16 +120,24 @@ func (w *WGController) CreateIPPool(c *gin.Context) {
 	}
 
 	resp := v1.IPPoolResponse{
-		ID:          pool.ID,
-		Name:        pool.Name,
-		CIDR:        pool.CIDR,
-		Routes:      pool.Routes,
-		DNS:         pool.DNS,
-		Endpoint:    pool.Endpoint,
-		Description: pool.Description,
-		Status:      pool.Status,
-		CreatedAt:   pool.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   pool.UpdatedAt.Format(time.RFC3339),
+		ID:                   pool.ID,
+		Name:                 pool.Name,
+		CIDR:                 pool.CIDR,
+		Routes:               pool.Routes,
+		DNS:                  pool.DNS,
+		Endpoint:             pool.Endpoint,
+		Description:          pool.Description,
+		Status:               pool.Status,
+		RequirePresharedKey:  pool.RequirePresharedKey,
+		InterfaceID:          pool.InterfaceID,
+		PeerLifetimeHours:    pool.PeerLifetimeHours,
+		RequirePublicKeyOnly: pool.RequirePublicKeyOnly,
+		PrefixLength:         pool.PrefixLength,
+		PrefixLengthV6:       pool.PrefixLengthV6,
+		Isolation:            pool.Isolation,
+		ExitPeerID:           pool.ExitPeerID,
+		CreatedAt:            pool.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:            pool.UpdatedAt.Format(time.RFC3339),
 	}
 
 	klog.V(1).InfoS("wireguard IP pool created successfully", "poolID", poolID)
@@ -131,6 +150,7 @@ func (w *WGController) CreateIPPool(c *gin.Context) {
 // @Tags wireguard
 // @Produce json
 // @Param status query string false "Filter by status (active/disabled)"
+// @Param interface_id query string false "Filter by WireGuard interface ID"
 // @Param offset query int false "Offset for pagination (default: 0)"
 // @Param limit query int false "Limit for pagination (default: 20, max: 200)"
 // @Success 200 {object} v1.IPPoolListResponse "IP pools listed successfully"
@@ -161,7 +181,8 @@ func (w *WGController) ListIPPools(c *gin.Context) {
 
 	// Build list options
 	opt := store.IPPoolListOptions{
-		Status: c.Query("status"),
+		Status:      c.Query("status"),
+		InterfaceID: c.Query("interface_id"),
 	}
 
 	// Parse pagination parameters
@@ -195,16 +216,24 @@ func (w *WGController) ListIPPools(c *gin.Context) {
 	items := make([]v1.IPPoolResponse, 0, len(pools))
 	for _, pool := range pools {
 		items = append(items, v1.IPPoolResponse{
-			ID:          pool.ID,
-			Name:        pool.Name,
-			CIDR:        pool.CIDR,
-			Routes:      pool.Routes,
-			DNS:         pool.DNS,
-			Endpoint:    pool.Endpoint,
-			Description: pool.Description,
-			Status:      pool.Status,
-			CreatedAt:   pool.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   pool.UpdatedAt.Format(time.RFC3339),
+			ID:                   pool.ID,
+			Name:                 pool.Name,
+			CIDR:                 pool.CIDR,
+			Routes:               pool.Routes,
+			DNS:                  pool.DNS,
+			Endpoint:             pool.Endpoint,
+			Description:          pool.Description,
+			Status:               pool.Status,
+			RequirePresharedKey:  pool.RequirePresharedKey,
+			InterfaceID:          pool.InterfaceID,
+			PeerLifetimeHours:    pool.PeerLifetimeHours,
+			RequirePublicKeyOnly: pool.RequirePublicKeyOnly,
+			PrefixLength:         pool.PrefixLength,
+			PrefixLengthV6:       pool.PrefixLengthV6,
+			Isolation:            pool.Isolation,
+			ExitPeerID:           pool.ExitPeerID,
+			CreatedAt:            pool.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:            pool.UpdatedAt.Format(time.RFC3339),
 		})
 	}
 
@@ -218,7 +247,7 @@ func (w *WGController) ListIPPools(c *gin.Context) {
 
 // GetAvailableIPs gets available IP addresses from an IP pool (admin only).
 // @Summary Get available IPs
-// @Description Get a list of available IP addresses from an IP pool. Admin only.
+// @Description Get a list of available IP addresses from an IP pool. Pools allocating a prefix per peer list the available prefixes as CIDRs. Admin only.
 // @Tags wireguard
 // @Produce json
 // @Param id path string true "IP Pool ID"
@@ -282,10 +311,12 @@ func (w *WGController) GetAvailableIPs(c *gin.Context) {
 	}
 
 	resp := v1.AvailableIPsResponse{
-		IPPoolID: poolID,
-		CIDR:     pool.CIDR,
-		IPs:      availableIPs,
-		Total:    len(availableIPs),
+		IPPoolID:       poolID,
+		CIDR:           pool.CIDR,
+		PrefixLength:   pool.PrefixLength,
+		PrefixLengthV6: pool.PrefixLengthV6,
+		IPs:            availableIPs,
+		Total:          len(availableIPs),
 	}
 
 	core.WriteResponse(c, nil, resp)
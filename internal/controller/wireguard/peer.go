@@ -10,6 +10,7 @@ import (
 
 	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
 	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
@@ -20,7 +21,7 @@ import (
 
 // CreatePeer creates a new WireGuard peer.
 // @Summary Create WireGuard peer
-// @Description Create a new WireGuard peer for a user. Admin can create peers for any user, regular users can only create peers for themselves.
+// @Description Create a new WireGuard peer for a user. Admin can create peers for any user, regular users can only create peers for themselves. A preshared key is generated when enable_preshared_key is set or when required by the IP pool or server policy. Peers expire at expires_at (admin only) or after the IP pool's default lifetime.
 // @Tags wireguard
 // @Accept json
 // @Produce json
@@ -87,9 +88,61 @@ func (w *WGController) CreatePeer(c *gin.Context) {
 		return
 	}
 
+	// Setting an explicit expiration additionally requires wg_peer:update_sensitive
+	// (otherwise the IP pool's default lifetime applies)
+	expiresAt, err := parseExpiresAt(req.ExpiresAt)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	if expiresAt != nil {
+		allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdateSensitive)
+		if err != nil {
+			klog.V(1).InfoS("authz enforce failed for peer expiration", "error", err)
+			core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+			return
+		}
+		if !allowed {
+			klog.V(1).InfoS("permission denied for peer expiration", "requesterRole", requesterRole)
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+			return
+		}
+		if !expiresAt.After(time.Now()) {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "expires_at must be in the future"), nil)
+			return
+		}
+	}
+
+	// Routing subnets behind a peer or pinning its endpoint changes the server routing table,
+	// so it additionally requires wg_peer:update_sensitive
+	if req.RoutedSubnets != "" || req.PeerEndpoint != "" {
+		allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdateSensitive)
+		if err != nil {
+			klog.V(1).InfoS("authz enforce failed for peer routes", "error", err)
+			core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+			return
+		}
+		if !allowed {
+			klog.V(1).InfoS("permission denied for peer routes", "requesterRole", requesterRole)
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+			return
+		}
+	}
+
+	// Generate preshared key on request (service layer also generates one when required by policy)
+	presharedKey := req.PresharedKey
+	if presharedKey == "" && req.EnablePresharedKey != nil && *req.EnablePresharedKey {
+		presharedKey, err = wireguard.GeneratePresharedKey()
+		if err != nil {
+			klog.V(1).InfoS("failed to generate preshared key", "error", err)
+			core.WriteResponse(c, err, nil)
+			return
+		}
+	}
+
 	// Call Service layer to create peer (includes IP allocation and key generation)
-	peer, err := w.srv.WGPeers().CreatePeer(
-		context.Background(),
+	peer, applied, err := w.srv.WGPeers().CreatePeer(
+		revisionContext(c),
 		targetUserID,
 		req.DeviceName,
 		req.IPPoolID,
@@ -98,7 +151,12 @@ func (w *WGController) CreatePeer(c *gin.Context) {
 		req.DNS,
 		req.Endpoint,
 		req.ClientPrivateKey,
+		req.ClientPublicKey,
+		presharedKey,
+		req.RoutedSubnets,
+		req.PeerEndpoint,
 		req.PersistentKeepalive,
+		expiresAt,
 	)
 	if err != nil {
 		klog.V(1).InfoS("failed to create peer", "error", err)
@@ -119,15 +177,25 @@ func (w *WGController) CreatePeer(c *gin.Context) {
 		Username:            "",
 		DeviceName:          peer.DeviceName,
 		ClientPublicKey:     peer.ClientPublicKey,
+		PublicKeyOnly:       peer.ClientPrivateKey == "",
+		PresharedKey:        peer.PresharedKey,
 		ClientIP:            peer.ClientIP,
 		AllowedIPs:          peer.AllowedIPs,
 		DNS:                 peer.DNS,
 		Endpoint:            peer.Endpoint,
+		RoutedSubnets:       peer.RoutedSubnets,
+		PeerEndpoint:        peer.PeerEndpoint,
+		ExitNode:            peer.ExitNode,
+		ExitPeerID:          peer.ExitPeerID,
 		PersistentKeepalive: peer.PersistentKeepalive,
 		Status:              peer.Status,
+		DisabledReason:      peer.DisabledReason,
 		IPPoolID:            peer.IPPoolID,
+		InterfaceID:         peer.InterfaceID,
+		ExpiresAt:           formatExpiresAt(peer.ExpiresAt),
 		CreatedAt:           peer.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:           peer.UpdatedAt.Format(time.RFC3339),
+		Applied:             toApplyResultResponses(applied...),
 	}
 	if user != nil {
 		resp.Username = user.Username
@@ -149,6 +217,7 @@ func (w *WGController) CreatePeer(c *gin.Context) {
 // @Param user_id query string false "Filter by user ID"
 // @Param status query string false "Filter by status (active/disabled)"
 // @Param ip_pool_id query string false "Filter by IP pool ID"
+// @Param interface_id query string false "Filter by WireGuard interface ID"
 // @Param device_name query string false "Filter by device name (partial match)"
 // @Param offset query int false "Offset for pagination (default: 0)"
 // @Param limit query int false "Limit for pagination (default: 20, max: 200)"
@@ -173,10 +242,11 @@ func (w *WGController) ListPeers(c *gin.Context) {
 
 	// Build list options
 	opt := store.WGPeerListOptions{
-		UserID:     c.Query("user_id"),
-		Status:     c.Query("status"),
-		IPPoolID:   c.Query("ip_pool_id"),
-		DeviceName: c.Query("device_name"),
+		UserID:      c.Query("user_id"),
+		Status:      c.Query("status"),
+		IPPoolID:    c.Query("ip_pool_id"),
+		InterfaceID: c.Query("interface_id"),
+		DeviceName:  c.Query("device_name"),
 	}
 
 	// Regular users can only see their own peers
@@ -232,6 +302,7 @@ func (w *WGController) ListPeers(c *gin.Context) {
 	// Convert to response format
 	// Endpoint and DNS values are already calculated and stored in the database during create/update
 	items := make([]v1.WGPeerResponse, 0, len(peers))
+	statuses := w.srv.WGPeers().GetPeerStatuses(context.Background(), peers)
 	for _, peer := range peers {
 		// Get user info
 		user, _ := w.srv.Users().GetUser(context.Background(), peer.UserID)
@@ -242,20 +313,30 @@ func (w *WGController) ListPeers(c *gin.Context) {
 			Username:            "",
 			DeviceName:          peer.DeviceName,
 			ClientPublicKey:     peer.ClientPublicKey,
+			PublicKeyOnly:       peer.ClientPrivateKey == "",
 			ClientPrivateKey:    peer.ClientPrivateKey,
+			PresharedKey:        peer.PresharedKey,
 			ClientIP:            peer.ClientIP,
 			AllowedIPs:          peer.AllowedIPs,
 			DNS:                 peer.DNS,
 			Endpoint:            peer.Endpoint,
+			RoutedSubnets:       peer.RoutedSubnets,
+			PeerEndpoint:        peer.PeerEndpoint,
+			ExitNode:            peer.ExitNode,
+			ExitPeerID:          peer.ExitPeerID,
 			PersistentKeepalive: peer.PersistentKeepalive,
 			Status:              peer.Status,
+			DisabledReason:      peer.DisabledReason,
 			IPPoolID:            peer.IPPoolID,
+			InterfaceID:         peer.InterfaceID,
+			ExpiresAt:           formatExpiresAt(peer.ExpiresAt),
 			CreatedAt:           peer.CreatedAt.Format(time.RFC3339),
 			UpdatedAt:           peer.UpdatedAt.Format(time.RFC3339),
 		})
 		if user != nil {
 			items[len(items)-1].Username = user.Username
 		}
+		applyPeerStatus(&items[len(items)-1], statuses[peer.ID])
 	}
 
 	resp := v1.WGPeerListResponse{
@@ -0,0 +1,260 @@
+package wireguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// CreatePortForward exposes a port of a peer on the server (admin only).
+// @Summary Create port forward
+// @Description Forward a TCP or UDP port of the server's public address to a port of a peer. The forward is loaded as an nftables DNAT rule while the peer is active and removed with the peer. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param forward body v1.CreatePortForwardRequest true "Port forward information"
+// @Success 200 {object} v1.PortForwardResponse "Port forward created successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or public port already in use"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - peer not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/port-forwards [post]
+func (w *WGController) CreatePortForward(c *gin.Context) {
+	klog.V(1).Info("wireguard port forward create function called.")
+
+	if !w.enforcePortForwardAccess(c, spec.ActionWGForwardCreate) {
+		return
+	}
+
+	// Parse request body
+	var req v1.CreatePortForwardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	forward := &model.PortForward{
+		Protocol:    req.Protocol,
+		PublicPort:  req.PublicPort,
+		PeerID:      req.PeerID,
+		TargetPort:  req.TargetPort,
+		Description: req.Description,
+	}
+	if forward.TargetPort == 0 {
+		forward.TargetPort = forward.PublicPort
+	}
+
+	if err := w.srv.PortForwards().CreatePortForward(context.Background(), forward); err != nil {
+		klog.V(1).InfoS("failed to create port forward", "protocol", req.Protocol, "publicPort", req.PublicPort, "peerID", req.PeerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("port forward created successfully", "forwardID", forward.ID, "protocol", forward.Protocol, "publicPort", forward.PublicPort, "peerID", forward.PeerID)
+	core.WriteResponse(c, nil, w.buildPortForwardResponse(context.Background(), forward))
+}
+
+// ListPortForwards lists port forwards (admin only).
+// @Summary List port forwards
+// @Description List port forwards, optionally only those to one peer. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param peer_id query string false "Filter by target peer ID"
+// @Success 200 {object} v1.PortForwardListResponse "Port forwards listed successfully"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/port-forwards [get]
+func (w *WGController) ListPortForwards(c *gin.Context) {
+	klog.V(1).Info("wireguard port forward list function called.")
+
+	if !w.enforcePortForwardAccess(c, spec.ActionWGForwardList) {
+		return
+	}
+
+	opt := store.PortForwardListOptions{
+		PeerID: c.Query("peer_id"),
+	}
+	forwards, err := w.srv.PortForwards().ListPortForwards(context.Background(), opt)
+	if err != nil {
+		klog.V(1).InfoS("failed to list port forwards", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	items := make([]v1.PortForwardResponse, 0, len(forwards))
+	for _, forward := range forwards {
+		items = append(items, w.buildPortForwardResponse(context.Background(), forward))
+	}
+
+	resp := v1.PortForwardListResponse{
+		Total: int64(len(items)),
+		Items: items,
+	}
+	core.WriteResponse(c, nil, resp)
+}
+
+// UpdatePortForward updates a port forward by ID (admin only).
+// @Summary Update port forward
+// @Description Update the ports, protocol or target peer of a port forward. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param id path string true "Port forward ID"
+// @Param forward body v1.UpdatePortForwardRequest true "Port forward update information"
+// @Success 200 {object} v1.PortForwardResponse "Port forward updated successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or public port already in use"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - port forward or peer not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/port-forwards/{id} [put]
+func (w *WGController) UpdatePortForward(c *gin.Context) {
+	klog.V(1).Info("wireguard port forward update function called.")
+
+	forwardID := c.Param("id")
+	if forwardID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing port forward ID"), nil)
+		return
+	}
+
+	if !w.enforcePortForwardAccess(c, spec.ActionWGForwardUpdate) {
+		return
+	}
+
+	// Parse request body
+	var req v1.UpdatePortForwardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	forward, err := w.srv.PortForwards().GetPortForward(context.Background(), forwardID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get port forward", "forwardID", forwardID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// Update fields if provided
+	if req.Protocol != nil {
+		forward.Protocol = *req.Protocol
+	}
+	if req.PublicPort != nil {
+		forward.PublicPort = *req.PublicPort
+	}
+	if req.PeerID != nil {
+		forward.PeerID = *req.PeerID
+	}
+	if req.TargetPort != nil {
+		forward.TargetPort = *req.TargetPort
+	}
+	if req.Description != nil {
+		forward.Description = *req.Description
+	}
+
+	if err := w.srv.PortForwards().UpdatePortForward(context.Background(), forward); err != nil {
+		klog.V(1).InfoS("failed to update port forward", "forwardID", forwardID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("port forward updated successfully", "forwardID", forwardID)
+	core.WriteResponse(c, nil, w.buildPortForwardResponse(context.Background(), forward))
+}
+
+// DeletePortForward deletes a port forward by ID (admin only).
+// @Summary Delete port forward
+// @Description Delete a port forward by ID and unload its rule. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "Port forward ID"
+// @Success 200 {object} core.SuccessResponse "Port forward deleted successfully"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - port forward not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/port-forwards/{id} [delete]
+func (w *WGController) DeletePortForward(c *gin.Context) {
+	klog.V(1).Info("wireguard port forward delete function called.")
+
+	forwardID := c.Param("id")
+	if forwardID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing port forward ID"), nil)
+		return
+	}
+
+	if !w.enforcePortForwardAccess(c, spec.ActionWGForwardDelete) {
+		return
+	}
+
+	if err := w.srv.PortForwards().DeletePortForward(context.Background(), forwardID); err != nil {
+		klog.V(1).InfoS("failed to delete port forward", "forwardID", forwardID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("port forward deleted successfully", "forwardID", forwardID)
+	core.WriteResponse(c, nil, nil)
+}
+
+// enforcePortForwardAccess checks that the requester may perform action on port forwards.
+func (w *WGController) enforcePortForwardAccess(c *gin.Context, action spec.Action) bool {
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGForward, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, action)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return false
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return false
+	}
+	return true
+}
+
+// buildPortForwardResponse converts a port forward to its response, filling in its target peer.
+func (w *WGController) buildPortForwardResponse(ctx context.Context, forward *model.PortForward) v1.PortForwardResponse {
+	resp := v1.PortForwardResponse{
+		ID:          forward.ID,
+		Protocol:    forward.Protocol,
+		PublicPort:  forward.PublicPort,
+		PeerID:      forward.PeerID,
+		TargetPort:  forward.TargetPort,
+		Description: forward.Description,
+		CreatedAt:   forward.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   forward.UpdatedAt.Format(time.RFC3339),
+	}
+
+	peer, err := w.srv.WGPeers().GetPeer(ctx, forward.PeerID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get target peer of port forward", "forwardID", forward.ID, "peerID", forward.PeerID, "error", err)
+		return resp
+	}
+	resp.DeviceName = peer.DeviceName
+	if targetIP := w.srv.PortForwards().TargetIP(peer); targetIP.IsValid() {
+		resp.TargetIP = targetIP.String()
+		resp.Active = peer.Status == model.WGPeerStatusActive
+	}
+	return resp
+}
@@ -0,0 +1,258 @@
+package wireguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// CreateQuota creates a traffic quota on a user, a peer or an IP pool (admin only).
+// @Summary Create traffic quota
+// @Description Create a monthly or rolling byte quota on a user, a peer or an IP pool. Peers over a quota are disabled until the period resets. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param quota body v1.CreateTrafficQuotaRequest true "Traffic quota information"
+// @Success 200 {object} v1.TrafficQuotaResponse "Traffic quota created successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or quota already exists"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - quota target not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/quotas [post]
+func (w *WGController) CreateQuota(c *gin.Context) {
+	klog.V(1).Info("wireguard traffic quota create function called.")
+
+	if !w.enforceQuotaAccess(c, spec.ActionWGQuotaCreate) {
+		return
+	}
+
+	// Parse request body
+	var req v1.CreateTrafficQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	quota := &model.TrafficQuota{
+		Scope:       req.Scope,
+		TargetID:    req.TargetID,
+		LimitBytes:  req.LimitBytes,
+		Period:      req.Period,
+		RollingDays: req.RollingDays,
+	}
+	if quota.Period == "" {
+		quota.Period = model.TrafficQuotaPeriodMonthly
+	}
+	if quota.RollingDays == 0 {
+		quota.RollingDays = 30
+	}
+
+	if err := w.srv.TrafficQuotas().CreateQuota(context.Background(), quota); err != nil {
+		klog.V(1).InfoS("failed to create traffic quota", "scope", req.Scope, "targetID", req.TargetID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("traffic quota created successfully", "quotaID", quota.ID, "scope", quota.Scope, "targetID", quota.TargetID)
+	core.WriteResponse(c, nil, w.buildQuotaResponse(context.Background(), quota))
+}
+
+// ListQuotas lists traffic quotas with their usage in the current period (admin only).
+// @Summary List traffic quotas
+// @Description List traffic quotas with optional filters, including the bytes used in the current period. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param scope query string false "Filter by scope (user/peer/ip_pool)"
+// @Param target_id query string false "Filter by target ID"
+// @Success 200 {object} v1.TrafficQuotaListResponse "Traffic quotas listed successfully"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/quotas [get]
+func (w *WGController) ListQuotas(c *gin.Context) {
+	klog.V(1).Info("wireguard traffic quota list function called.")
+
+	if !w.enforceQuotaAccess(c, spec.ActionWGQuotaList) {
+		return
+	}
+
+	opt := store.TrafficQuotaListOptions{
+		Scope:    c.Query("scope"),
+		TargetID: c.Query("target_id"),
+	}
+	quotas, err := w.srv.TrafficQuotas().ListQuotas(context.Background(), opt)
+	if err != nil {
+		klog.V(1).InfoS("failed to list traffic quotas", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	items := make([]v1.TrafficQuotaResponse, 0, len(quotas))
+	for _, quota := range quotas {
+		items = append(items, w.buildQuotaResponse(context.Background(), quota))
+	}
+
+	resp := v1.TrafficQuotaListResponse{
+		Total: int64(len(items)),
+		Items: items,
+	}
+	core.WriteResponse(c, nil, resp)
+}
+
+// UpdateQuota updates a traffic quota by ID (admin only).
+// @Summary Update traffic quota
+// @Description Update the limit or period of a traffic quota. Peers disabled by the quota are re-enabled on the next check if they are within it again. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param id path string true "Quota ID"
+// @Param quota body v1.UpdateTrafficQuotaRequest true "Traffic quota update information"
+// @Success 200 {object} v1.TrafficQuotaResponse "Traffic quota updated successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or validation failed"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - quota not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/quotas/{id} [put]
+func (w *WGController) UpdateQuota(c *gin.Context) {
+	klog.V(1).Info("wireguard traffic quota update function called.")
+
+	quotaID := c.Param("id")
+	if quotaID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing quota ID"), nil)
+		return
+	}
+
+	if !w.enforceQuotaAccess(c, spec.ActionWGQuotaUpdate) {
+		return
+	}
+
+	// Parse request body
+	var req v1.UpdateTrafficQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	quota, err := w.srv.TrafficQuotas().GetQuota(context.Background(), quotaID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get traffic quota", "quotaID", quotaID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// Update fields if provided
+	if req.LimitBytes != nil {
+		quota.LimitBytes = *req.LimitBytes
+	}
+	if req.Period != nil {
+		quota.Period = *req.Period
+	}
+	if req.RollingDays != nil {
+		quota.RollingDays = *req.RollingDays
+	}
+
+	if err := w.srv.TrafficQuotas().UpdateQuota(context.Background(), quota); err != nil {
+		klog.V(1).InfoS("failed to update traffic quota", "quotaID", quotaID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("traffic quota updated successfully", "quotaID", quotaID)
+	core.WriteResponse(c, nil, w.buildQuotaResponse(context.Background(), quota))
+}
+
+// DeleteQuota deletes a traffic quota by ID (admin only).
+// @Summary Delete traffic quota
+// @Description Delete a traffic quota by ID. Peers disabled only by this quota are re-enabled on the next check. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "Quota ID"
+// @Success 200 {object} core.SuccessResponse "Traffic quota deleted successfully"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - quota not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/quotas/{id} [delete]
+func (w *WGController) DeleteQuota(c *gin.Context) {
+	klog.V(1).Info("wireguard traffic quota delete function called.")
+
+	quotaID := c.Param("id")
+	if quotaID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing quota ID"), nil)
+		return
+	}
+
+	if !w.enforceQuotaAccess(c, spec.ActionWGQuotaDelete) {
+		return
+	}
+
+	if err := w.srv.TrafficQuotas().DeleteQuota(context.Background(), quotaID); err != nil {
+		klog.V(1).InfoS("failed to delete traffic quota", "quotaID", quotaID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("traffic quota deleted successfully", "quotaID", quotaID)
+	core.WriteResponse(c, nil, nil)
+}
+
+// enforceQuotaAccess checks that the requester may perform action on traffic quotas.
+func (w *WGController) enforceQuotaAccess(c *gin.Context, action spec.Action) bool {
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGQuota, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, action)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return false
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return false
+	}
+	return true
+}
+
+// buildQuotaResponse converts a quota to its response, filling in the usage of the current period.
+func (w *WGController) buildQuotaResponse(ctx context.Context, quota *model.TrafficQuota) v1.TrafficQuotaResponse {
+	resp := v1.TrafficQuotaResponse{
+		ID:         quota.ID,
+		Scope:      quota.Scope,
+		TargetID:   quota.TargetID,
+		LimitBytes: quota.LimitBytes,
+		Period:     quota.Period,
+		CreatedAt:  quota.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  quota.UpdatedAt.Format(time.RFC3339),
+	}
+	if quota.Period == model.TrafficQuotaPeriodRolling {
+		resp.RollingDays = quota.RollingDays
+	}
+
+	used, from, err := w.srv.TrafficQuotas().QuotaUsage(ctx, quota)
+	if err != nil {
+		klog.V(1).InfoS("failed to get traffic quota usage", "quotaID", quota.ID, "error", err)
+	}
+	resp.PeriodStart = from
+	resp.UsedBytes = used
+	resp.Exceeded = used >= quota.LimitBytes
+	return resp
+}
@@ -0,0 +1,215 @@
+package wireguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/service"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// GetReconcileStatus gets the status of the reconciliation between the database and the config files (admin only).
+// @Summary Get reconcile status
+// @Description Get the last run, errors and pending actions of the reconciliation between the database and the server config files. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Success 200 {object} v1.ReconcileStatusResponse "Reconcile status"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/reconcile [get]
+func (w *WGController) GetReconcileStatus(c *gin.Context) {
+	klog.V(1).Info("wireguard reconcile status get function called.")
+
+	if !w.enforceReconcile(c, spec.ActionWGServerGet) {
+		return
+	}
+
+	core.WriteResponse(c, nil, toReconcileStatusResponse(w.srv.Reconciler().Status()))
+}
+
+// Reconcile reconciles the database with the config files now (admin only).
+// @Summary Reconcile with config files
+// @Description Compare the database with the server config files and resolve all differences with the configured reconcile policy (import, enforce or report). Admin only.
+// @Tags wireguard
+// @Produce json
+// @Success 200 {object} v1.ReconcileStatusResponse "Reconcile status after the run"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/reconcile [post]
+func (w *WGController) Reconcile(c *gin.Context) {
+	klog.V(1).Info("wireguard reconcile function called.")
+
+	if !w.enforceReconcile(c, spec.ActionWGServerReconcile) {
+		return
+	}
+
+	status, err := w.srv.Reconciler().Reconcile(context.Background(), "api", true)
+	if err != nil {
+		klog.V(1).InfoS("failed to reconcile with config files", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, toReconcileStatusResponse(status))
+}
+
+// GetDriftReport lists the differences between the database and the config files without changing either (admin only).
+// @Summary Get drift report
+// @Description Dry run of the reconciliation: list the peers present only in the database, present only in a config file, or present in both with different AllowedIPs, keepalive or comment, and the IP pools that importing them would create. Nothing is changed. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Success 200 {object} v1.DriftReportResponse "Drift report"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/reconcile/drift [get]
+func (w *WGController) GetDriftReport(c *gin.Context) {
+	klog.V(1).Info("wireguard drift report get function called.")
+
+	if !w.enforceReconcile(c, spec.ActionWGServerGet) {
+		return
+	}
+
+	report, err := w.srv.Reconciler().DriftReport(context.Background())
+	if err != nil {
+		klog.V(1).InfoS("failed to get drift report", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	resp := v1.DriftReportResponse{
+		GeneratedAt:   report.GeneratedAt.Format(time.RFC3339),
+		Policy:        report.Policy,
+		ExternalEdits: append([]string{}, report.ExternalEdits...),
+		Actions:       make([]v1.ReconcileActionResponse, 0, len(report.Actions)),
+		IPPools:       make([]v1.DriftIPPoolResponse, 0, len(report.IPPools)),
+		Errors:        append([]string{}, report.Errors...),
+	}
+	for _, action := range report.Actions {
+		resp.Actions = append(resp.Actions, toReconcileActionResponse(action))
+	}
+	for _, pool := range report.IPPools {
+		resp.IPPools = append(resp.IPPools, v1.DriftIPPoolResponse{
+			Name:       pool.Name,
+			CIDR:       pool.CIDR,
+			Interface:  pool.Interface,
+			PublicKeys: pool.PublicKeys,
+		})
+	}
+
+	core.WriteResponse(c, nil, resp)
+}
+
+// ApproveDriftAction resolves a single difference of the drift report (admin only).
+// @Summary Approve drift action
+// @Description Resolve a single difference of the drift report, in favor of the config file (import) or of the database (enforce). Without a resolution the reconcile policy is used. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param id path string true "Drift action ID"
+// @Param request body v1.ApproveDriftActionRequest false "Resolution"
+// @Success 200 {object} v1.ReconcileActionResponse "Approved action"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid parameters"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Drift action not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/reconcile/drift/{id}/approve [post]
+func (w *WGController) ApproveDriftAction(c *gin.Context) {
+	klog.V(1).Info("wireguard drift action approve function called.")
+
+	if !w.enforceReconcile(c, spec.ActionWGServerReconcile) {
+		return
+	}
+
+	// Parse request body, the resolution is optional
+	var req v1.ApproveDriftActionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			klog.V(1).InfoS("invalid request body", "error", err)
+			core.WriteResponseBindErr(c, err, nil)
+			return
+		}
+	}
+
+	action, err := w.srv.Reconciler().ApproveDriftAction(context.Background(), c.Param("id"), req.Resolution)
+	if err != nil {
+		klog.V(1).InfoS("failed to approve drift action", "id", c.Param("id"), "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, toReconcileActionResponse(*action))
+}
+
+// enforceReconcile checks that the requester may perform action on the server.
+func (w *WGController) enforceReconcile(c *gin.Context, action spec.Action) bool {
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGServer, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, action)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return false
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return false
+	}
+	return true
+}
+
+func toReconcileStatusResponse(status *service.ReconcileStatus) v1.ReconcileStatusResponse {
+	resp := v1.ReconcileStatusResponse{
+		Policy:         status.Policy,
+		Running:        status.Running,
+		LastTrigger:    status.LastTrigger,
+		LastDurationMs: status.LastDuration.Milliseconds(),
+		Runs:           status.Runs,
+		Applied:        status.Applied,
+		ExternalEdits:  append([]string{}, status.ExternalEdits...),
+		PendingActions: make([]v1.ReconcileActionResponse, 0, len(status.PendingActions)),
+		Errors:         append([]string{}, status.Errors...),
+	}
+	if !status.LastRun.IsZero() {
+		resp.LastRun = status.LastRun.Format(time.RFC3339)
+	}
+	for _, action := range status.PendingActions {
+		resp.PendingActions = append(resp.PendingActions, toReconcileActionResponse(action))
+	}
+	return resp
+}
+
+func toReconcileActionResponse(action service.ReconcileAction) v1.ReconcileActionResponse {
+	resp := v1.ReconcileActionResponse{
+		ID:        action.ID,
+		Kind:      action.Kind,
+		Interface: action.Interface,
+		PublicKey: action.PublicKey,
+		PeerID:    action.PeerID,
+		Detail:    action.Detail,
+		IPPool:    action.IPPool,
+	}
+	for _, difference := range action.Differences {
+		resp.Differences = append(resp.Differences, v1.DriftDifferenceResponse{
+			Field:      difference.Field,
+			Database:   difference.Database,
+			ConfigFile: difference.ConfigFile,
+		})
+	}
+	return resp
+}
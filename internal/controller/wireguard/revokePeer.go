@@ -0,0 +1,186 @@
+package wireguard
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// RevokePeer revokes a WireGuard peer, e.g. when its device is lost.
+// @Summary Revoke WireGuard peer
+// @Description Revoke a peer: remove it from the live interface, block its public key from ever being added again, delete its stored client configuration and record the reason. Admin can revoke any peer, regular users can only revoke their own peers.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param id path string true "Peer ID"
+// @Param request body v1.RevokeWGPeerRequest true "Revocation reason"
+// @Success 200 {object} v1.WGApplyResponse "WireGuard peer revoked successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or peer already revoked"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - peer not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/peers/{id}/revoke [post]
+func (w *WGController) RevokePeer(c *gin.Context) {
+	klog.V(1).Info("wireguard peer revocation function called.")
+
+	peerID := c.Param("id")
+	if peerID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing peer ID"), nil)
+		return
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterIDAny, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "missing auth context"), nil)
+		return
+	}
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterNameAny, _ := c.Get(middleware.UsernameKey)
+	requesterID, _ := requesterIDAny.(string)
+	requesterRole, _ := requesterRoleAny.(string)
+	requesterName, _ := requesterNameAny.(string)
+
+	// Get peer
+	peer, err := w.srv.WGPeers().GetPeer(context.Background(), peerID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get peer", "peerID", peerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// --- Authorization (Casbin) ---
+	scope := spec.ScopeAny
+	if requesterID != "" && requesterID == peer.UserID {
+		scope = spec.ScopeSelf
+	}
+	obj := spec.Obj(spec.ResourceWGConfig, scope)
+
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGConfigRevoke)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Parse request body
+	var req v1.RevokeWGPeerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	applied, err := w.srv.WGPeers().RevokePeer(revisionContext(c), peer, req.Reason, requesterName)
+	if err != nil {
+		klog.V(1).InfoS("failed to revoke peer", "peerID", peerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("wireguard peer revoked successfully", "peerID", peerID, "requesterID", requesterID)
+	core.WriteResponse(c, nil, v1.WGApplyResponse{Applied: toApplyResultResponses(applied...)})
+}
+
+// ListRevokedKeys lists revoked WireGuard public keys (admin only).
+// @Summary List revoked WireGuard keys
+// @Description List the revocation list with the reason and who revoked each key. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param user_id query string false "Filter by user ID"
+// @Param peer_id query string false "Filter by peer ID"
+// @Param offset query int false "Offset for pagination (default: 0)"
+// @Param limit query int false "Limit for pagination (default: 20, max: 200)"
+// @Success 200 {object} v1.RevokedKeyListResponse "Revoked keys listed successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid parameters"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/revocations [get]
+func (w *WGController) ListRevokedKeys(c *gin.Context) {
+	klog.V(1).Info("wireguard revoked key list function called.")
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGConfig, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGConfigRevoke)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Build list options
+	opt := store.RevokedKeyListOptions{
+		UserID: c.Query("user_id"),
+		PeerID: c.Query("peer_id"),
+	}
+
+	// Parse pagination parameters
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid offset"), nil)
+			return
+		}
+		opt.Offset = offset
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid limit"), nil)
+			return
+		}
+		opt.Limit = limit
+	}
+
+	keys, total, err := w.srv.WGPeers().ListRevokedKeys(context.Background(), opt)
+	if err != nil {
+		klog.V(1).InfoS("failed to list revoked keys", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	items := make([]v1.RevokedKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, v1.RevokedKeyResponse{
+			PublicKey:  key.PublicKey,
+			PeerID:     key.PeerID,
+			UserID:     key.UserID,
+			DeviceName: key.DeviceName,
+			Reason:     key.Reason,
+			RevokedBy:  key.RevokedBy,
+			CreatedAt:  key.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	resp := v1.RevokedKeyListResponse{
+		Total: total,
+		Items: items,
+	}
+	core.WriteResponse(c, nil, resp)
+}
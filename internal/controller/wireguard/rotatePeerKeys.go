@@ -0,0 +1,156 @@
+package wireguard
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// RotatePeerKeys rotates the keys of a WireGuard peer and returns its new client configuration.
+// @Summary Rotate WireGuard peer keys
+// @Description Generate a new key pair (and preshared key, if the peer has one) for a peer, replace the old public key in the server config, apply it and return the new client configuration (in the same formats as the config download). Admin can rotate any peer, regular users can only rotate their own peers.
+// @Tags wireguard
+// @Produce text/plain,image/png,image/svg+xml
+// @Param id path string true "Peer ID"
+// @Param format query string false "Output format (text/qr/png/svg/ansi), overrides the Accept header"
+// @Success 200 {string} string "New configuration file content"
+// @Header 200 {string} X-WG-Applied "What applying the server config changed, as a JSON list of v1.WGApplyResultResponse"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid peer ID"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - peer not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/peers/{id}/rotate [post]
+func (w *WGController) RotatePeerKeys(c *gin.Context) {
+	klog.V(1).Info("wireguard peer key rotation function called.")
+
+	peerID := c.Param("id")
+	if peerID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing peer ID"), nil)
+		return
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterIDAny, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "missing auth context"), nil)
+		return
+	}
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterID, _ := requesterIDAny.(string)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// Get peer
+	peer, err := w.srv.WGPeers().GetPeer(context.Background(), peerID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get peer", "peerID", peerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// --- Authorization (Casbin) ---
+	scope := spec.ScopeAny
+	if requesterID != "" && requesterID == peer.UserID {
+		scope = spec.ScopeSelf
+	}
+	obj := spec.Obj(spec.ResourceWGConfig, scope)
+
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGConfigRotate)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Rotate keys (Service layer updates the server config and regenerates the client config)
+	applied, err := w.srv.WGPeers().RotatePeerKeys(revisionContext(c), peer)
+	if err != nil {
+		klog.V(1).InfoS("failed to rotate peer keys", "peerID", peerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// The body is the new client configuration, report what was applied in a header
+	if header, err := json.Marshal(toApplyResultResponses(applied...)); err == nil {
+		c.Header("X-WG-Applied", string(header))
+	}
+
+	klog.V(1).InfoS("wireguard peer keys rotated successfully", "peerID", peerID, "requesterID", requesterID)
+	w.writePeerConfig(c, peer)
+}
+
+// RotatePeersKeys rotates the keys of many WireGuard peers (admin only).
+// @Summary Rotate WireGuard peer keys in bulk
+// @Description Rotate the keys of all peers, optionally limited to an IP pool or a user and to keys older than older_than_days. Clients need to download their new configuration afterwards. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param request body v1.RotateWGPeerKeysRequest true "Peers to rotate"
+// @Success 200 {object} v1.RotateWGPeerKeysResponse "WireGuard peer keys rotated successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or validation failed"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/peers/rotate [post]
+func (w *WGController) RotatePeersKeys(c *gin.Context) {
+	klog.V(1).Info("wireguard bulk peer key rotation function called.")
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGConfig, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGConfigRotate)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Parse request body
+	var req v1.RotateWGPeerKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	opt := store.WGPeerListOptions{
+		IPPoolID: req.IPPoolID,
+		UserID:   req.UserID,
+	}
+	maxAge := time.Duration(req.OlderThanDays) * 24 * time.Hour
+
+	rotated, err := w.srv.WGPeers().RotatePeersKeys(revisionContext(c), opt, maxAge)
+	if err != nil {
+		klog.V(1).InfoS("failed to rotate peer keys", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("wireguard peer keys rotated successfully", "count", rotated)
+	resp := v1.RotateWGPeerKeysResponse{
+		Count: int64(rotated),
+	}
+	core.WriteResponse(c, nil, resp)
+}
@@ -0,0 +1,198 @@
+package wireguard
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// ListServerConfigRevisions lists the stored revisions of the server config (admin only).
+// @Summary List server config revisions
+// @Description List the stored revisions of an interface's server config file, newest first. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param interface_id query string false "WireGuard interface ID (default interface if empty)"
+// @Success 200 {object} v1.ServerConfigRevisionListResponse "Server config revisions"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/server-config/revisions [get]
+func (w *WGController) ListServerConfigRevisions(c *gin.Context) {
+	klog.V(1).Info("wireguard server config revisions list function called.")
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGServer, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGServerGet)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	revisions, err := w.srv.WGServer().ListConfigRevisions(context.Background(), c.Query("interface_id"))
+	if err != nil {
+		klog.V(1).InfoS("failed to list server config revisions", "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	items := make([]v1.ServerConfigRevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		items = append(items, toServerConfigRevisionResponse(revision))
+	}
+
+	resp := v1.ServerConfigRevisionListResponse{
+		Total: int64(len(items)),
+		Items: items,
+	}
+
+	core.WriteResponse(c, nil, resp)
+}
+
+// DiffServerConfigRevisions diffs a server config revision against another one (admin only).
+// @Summary Diff server config revisions
+// @Description Get a unified diff from a revision to another revision, or to the live config file if "to" is omitted. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param revision path int true "Revision number"
+// @Param to query int false "Target revision number (default: live config file)"
+// @Param interface_id query string false "WireGuard interface ID (default interface if empty)"
+// @Success 200 {object} v1.ServerConfigDiffResponse "Server config diff"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid parameters"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - revision not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/server-config/revisions/{revision}/diff [get]
+func (w *WGController) DiffServerConfigRevisions(c *gin.Context) {
+	klog.V(1).Info("wireguard server config revisions diff function called.")
+
+	from, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || from <= 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid revision"), nil)
+		return
+	}
+	to := 0
+	if toStr := c.Query("to"); toStr != "" {
+		to, err = strconv.Atoi(toStr)
+		if err != nil || to < 0 {
+			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid target revision"), nil)
+			return
+		}
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGServer, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGServerGet)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	diff, err := w.srv.WGServer().DiffConfigRevisions(context.Background(), c.Query("interface_id"), from, to)
+	if err != nil {
+		klog.V(1).InfoS("failed to diff server config revisions", "from", from, "to", to, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	resp := v1.ServerConfigDiffResponse{
+		From: from,
+		To:   to,
+		Diff: diff,
+	}
+
+	core.WriteResponse(c, nil, resp)
+}
+
+// RollbackServerConfig restores a previous server config revision (admin only).
+// @Summary Roll back server config
+// @Description Restore a previous revision of an interface's server config file and apply it. The rollback is recorded as a new revision. Admin only.
+// @Tags wireguard
+// @Produce json
+// @Param revision path int true "Revision number to restore"
+// @Param interface_id query string false "WireGuard interface ID (default interface if empty)"
+// @Success 200 {object} v1.ServerConfigRevisionResponse "Revision created by the rollback"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid parameters"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - revision not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/server-config/revisions/{revision}/rollback [post]
+func (w *WGController) RollbackServerConfig(c *gin.Context) {
+	klog.V(1).Info("wireguard server config rollback function called.")
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision <= 0 {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid revision"), nil)
+		return
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGServer, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGServerUpdate)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	newRevision, applied, err := w.srv.WGServer().RollbackConfig(revisionContext(c), c.Query("interface_id"), revision)
+	if err != nil {
+		klog.V(1).InfoS("failed to roll back server config", "revision", revision, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("wireguard server config rolled back successfully", "revision", revision, "newRevision", newRevision.Number)
+	resp := toServerConfigRevisionResponse(newRevision)
+	resp.Applied = toApplyResultResponses(applied)
+	core.WriteResponse(c, nil, resp)
+}
+
+func toServerConfigRevisionResponse(revision *wireguard.Revision) v1.ServerConfigRevisionResponse {
+	return v1.ServerConfigRevisionResponse{
+		Revision:  revision.Number,
+		CreatedAt: revision.CreatedAt.Format(time.RFC3339),
+		Actor:     revision.Actor,
+		Action:    revision.Action,
+		SHA256:    revision.SHA256,
+		Size:      revision.Size,
+	}
+}
@@ -0,0 +1,287 @@
+package wireguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+const (
+	// defaultShareLinkMaxUses is how many times a share link can be used if not specified.
+	defaultShareLinkMaxUses = 1
+	// defaultShareLinkTTL is how long a share link is valid if not specified.
+	defaultShareLinkTTL = time.Hour
+)
+
+// CreateShareLink creates a download link for the client configuration of a peer.
+// @Summary Create config share link
+// @Description Create a signed, time-limited link to download the client configuration of a peer without logging in, e.g. on a phone. The link can be used max_uses times (default 1) within expires_in_minutes (default 60). The URL is only returned once. Admin can share any peer, regular users can only share their own peers.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param id path string true "Peer ID"
+// @Param request body v1.CreateShareLinkRequest false "Share link options"
+// @Success 200 {object} v1.ShareLinkResponse "Share link created successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or peer revoked"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - peer not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/peers/{id}/share-links [post]
+func (w *WGController) CreateShareLink(c *gin.Context) {
+	klog.V(1).Info("wireguard config share link create function called.")
+
+	peer, ok := w.getSharedPeer(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	// Parse request body, all fields are optional
+	var req v1.CreateShareLinkRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			klog.V(1).InfoS("invalid request body", "error", err)
+			core.WriteResponseBindErr(c, err, nil)
+			return
+		}
+	}
+	maxUses := defaultShareLinkMaxUses
+	if req.MaxUses > 0 {
+		maxUses = req.MaxUses
+	}
+	ttl := defaultShareLinkTTL
+	if req.ExpiresInMinutes > 0 {
+		ttl = time.Duration(req.ExpiresInMinutes) * time.Minute
+	}
+
+	requesterNameAny, _ := c.Get(middleware.UsernameKey)
+	requesterName, _ := requesterNameAny.(string)
+
+	link, token, err := w.srv.ShareLinks().CreateShareLink(context.Background(), peer, requesterName, maxUses, ttl)
+	if err != nil {
+		klog.V(1).InfoS("failed to create share link", "peerID", peer.ID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	resp := buildShareLinkResponse(link, nil)
+	resp.URL = shareLinkURL(c, token)
+	core.WriteResponse(c, nil, resp)
+}
+
+// ListShareLinks lists the download links of a peer with their uses.
+// @Summary List config share links
+// @Description List the share links of a peer, including every recorded download. Admin can list any peer's links, regular users can only list their own peers' links.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "Peer ID"
+// @Success 200 {object} v1.ShareLinkListResponse "Share links listed successfully"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - peer not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/peers/{id}/share-links [get]
+func (w *WGController) ListShareLinks(c *gin.Context) {
+	klog.V(1).Info("wireguard config share link list function called.")
+
+	peer, ok := w.getSharedPeer(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	links, err := w.srv.ShareLinks().ListShareLinks(context.Background(), peer.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to list share links", "peerID", peer.ID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	items := make([]v1.ShareLinkResponse, 0, len(links))
+	for _, link := range links {
+		uses, err := w.srv.ShareLinks().ListShareLinkUses(context.Background(), link.ID)
+		if err != nil {
+			klog.V(1).InfoS("failed to list share link uses", "linkID", link.ID, "error", err)
+			core.WriteResponse(c, err, nil)
+			return
+		}
+		items = append(items, buildShareLinkResponse(link, uses))
+	}
+
+	resp := v1.ShareLinkListResponse{
+		Total: int64(len(items)),
+		Items: items,
+	}
+	core.WriteResponse(c, nil, resp)
+}
+
+// RevokeShareLink revokes a config download link.
+// @Summary Revoke config share link
+// @Description Revoke a share link so it can no longer be used. Its uses stay recorded. Admin can revoke any link, regular users can only revoke links of their own peers.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "Share link ID"
+// @Success 200 {object} core.SuccessResponse "Share link revoked successfully"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - share link not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/share-links/{id} [delete]
+func (w *WGController) RevokeShareLink(c *gin.Context) {
+	klog.V(1).Info("wireguard config share link revoke function called.")
+
+	linkID := c.Param("id")
+	if linkID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing share link ID"), nil)
+		return
+	}
+
+	link, err := w.srv.ShareLinks().GetShareLink(context.Background(), linkID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get share link", "linkID", linkID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	if _, ok := w.getSharedPeer(c, link.PeerID); !ok {
+		return
+	}
+
+	if err := w.srv.ShareLinks().RevokeShareLink(context.Background(), link); err != nil {
+		klog.V(1).InfoS("failed to revoke share link", "linkID", linkID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("wireguard config share link revoked successfully", "linkID", linkID, "peerID", link.PeerID)
+	core.WriteResponse(c, nil, nil)
+}
+
+// DownloadSharedConfig downloads a client configuration through a share link, without authentication.
+// @Summary Download shared WireGuard configuration
+// @Description Download the client configuration behind a share link, in the same formats as the config download. Each download counts against the link and is recorded. No authentication required.
+// @Tags wireguard
+// @Produce text/plain,image/png,image/svg+xml
+// @Param token path string true "Share link token"
+// @Param format query string false "Output format (text/qr/png/svg/ansi), overrides the Accept header"
+// @Success 200 {string} string "Configuration file content or QR code"
+// @Failure 400 {object} core.ErrResponse "Bad request - peer revoked"
+// @Failure 404 {object} core.ErrResponse "Not found - link invalid, expired, used up or revoked"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/share/{token} [get]
+func (w *WGController) DownloadSharedConfig(c *gin.Context) {
+	klog.V(1).Info("wireguard shared config download function called.")
+
+	link, peer, err := w.srv.ShareLinks().OpenShareLink(context.Background(), c.Param("token"))
+	if err != nil {
+		klog.V(1).InfoS("failed to open share link", "clientIP", c.ClientIP(), "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// Render the config before counting the use, so a failed download does not use up the link
+	configContent, err := w.peerConfigContent(peer)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	contentType, data, err := renderConfigContent(c, configContent)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := w.srv.ShareLinks().RedeemShareLink(context.Background(), link, peer, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		klog.V(1).InfoS("failed to redeem share link", "linkID", link.ID, "clientIP", c.ClientIP(), "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	c.Data(200, contentType, data)
+}
+
+// getSharedPeer gets a peer and checks that the requester may manage its share links.
+// It writes the error response and returns false otherwise.
+func (w *WGController) getSharedPeer(c *gin.Context, peerID string) (*model.WGPeer, bool) {
+	if peerID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing peer ID"), nil)
+		return nil, false
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterIDAny, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "missing auth context"), nil)
+		return nil, false
+	}
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterID, _ := requesterIDAny.(string)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	peer, err := w.srv.WGPeers().GetPeer(context.Background(), peerID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get peer", "peerID", peerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return nil, false
+	}
+
+	// --- Authorization (Casbin) ---
+	scope := spec.ScopeAny
+	if requesterID != "" && requesterID == peer.UserID {
+		scope = spec.ScopeSelf
+	}
+	obj := spec.Obj(spec.ResourceWGConfig, scope)
+
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGConfigShare)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return nil, false
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return nil, false
+	}
+	return peer, true
+}
+
+// buildShareLinkResponse converts a share link and its uses to its response.
+func buildShareLinkResponse(link *model.ShareLink, uses []*model.ShareLinkUse) v1.ShareLinkResponse {
+	resp := v1.ShareLinkResponse{
+		ID:        link.ID,
+		PeerID:    link.PeerID,
+		CreatedBy: link.CreatedBy,
+		MaxUses:   link.MaxUses,
+		UseCount:  link.UseCount,
+		ExpiresAt: link.ExpiresAt.Format(time.RFC3339),
+		Usable:    link.Usable(time.Now()),
+		CreatedAt: link.CreatedAt.Format(time.RFC3339),
+	}
+	if link.RevokedAt != nil {
+		resp.RevokedAt = link.RevokedAt.Format(time.RFC3339)
+	}
+	for _, use := range uses {
+		resp.Uses = append(resp.Uses, v1.ShareLinkUseResponse{
+			ClientIP:  use.ClientIP,
+			UserAgent: use.UserAgent,
+			CreatedAt: use.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return resp
+}
+
+// shareLinkURL returns the absolute download URL of a share link token, as seen by the requester.
+func shareLinkURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/api/v1/wg/share/" + token
+}
@@ -0,0 +1,215 @@
+package wireguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// GetPeerTraffic retrieves the traffic usage of a WireGuard peer.
+// @Summary Get WireGuard peer traffic
+// @Description Get the daily or monthly traffic usage of a peer. Admin can get any peer, regular users can only get their own peers.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "Peer ID"
+// @Param period query string false "Group by day or month (default: day)"
+// @Param from query string false "First day, inclusive (YYYY-MM-DD)"
+// @Param to query string false "Last day, inclusive (YYYY-MM-DD)"
+// @Success 200 {object} v1.TrafficUsageListResponse "Peer traffic retrieved successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid parameters"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - peer not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/peers/{id}/traffic [get]
+func (w *WGController) GetPeerTraffic(c *gin.Context) {
+	klog.V(1).Info("wireguard peer traffic function called.")
+
+	peerID := c.Param("id")
+	if peerID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing peer ID"), nil)
+		return
+	}
+	opt, err := parseTrafficUsageOptions(c)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	opt.PeerID = peerID
+
+	// Get requester info from JWTAuth middleware
+	requesterIDAny, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "missing auth context"), nil)
+		return
+	}
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterID, _ := requesterIDAny.(string)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// Get peer
+	peer, err := w.srv.WGPeers().GetPeer(context.Background(), peerID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get peer", "peerID", peerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// --- Authorization (Casbin) ---
+	scope := spec.ScopeAny
+	if requesterID != "" && requesterID == peer.UserID {
+		scope = spec.ScopeSelf
+	}
+	if !w.enforceTrafficAccess(c, requesterRole, scope) {
+		return
+	}
+
+	points, err := w.srv.Traffic().ListUsage(context.Background(), opt)
+	if err != nil {
+		klog.V(1).InfoS("failed to list peer traffic", "peerID", peerID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	resp := buildTrafficUsageResponse(opt, points)
+	resp.PeerID = peer.ID
+	core.WriteResponse(c, nil, resp)
+}
+
+// GetUserTraffic retrieves the traffic usage of all peers of a user.
+// @Summary Get user traffic
+// @Description Get the daily or monthly traffic usage of a user, summed over all of the user's peers including deleted ones. Admin can get any user, regular users can only get themselves.
+// @Tags wireguard
+// @Produce json
+// @Param id path string true "User ID"
+// @Param period query string false "Group by day or month (default: day)"
+// @Param from query string false "First day, inclusive (YYYY-MM-DD)"
+// @Param to query string false "Last day, inclusive (YYYY-MM-DD)"
+// @Success 200 {object} v1.TrafficUsageListResponse "User traffic retrieved successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid parameters"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - user not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/users/{id}/traffic [get]
+func (w *WGController) GetUserTraffic(c *gin.Context) {
+	klog.V(1).Info("wireguard user traffic function called.")
+
+	userID := c.Param("id")
+	if userID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing user ID"), nil)
+		return
+	}
+	opt, err := parseTrafficUsageOptions(c)
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+	opt.UserID = userID
+
+	// Get requester info from JWTAuth middleware
+	requesterIDAny, ok := c.Get(middleware.UserIDKey)
+	if !ok {
+		core.WriteResponse(c, errors.WithCode(code.ErrTokenInvalid, "missing auth context"), nil)
+		return
+	}
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterID, _ := requesterIDAny.(string)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) ---
+	scope := spec.ScopeAny
+	if requesterID != "" && requesterID == userID {
+		scope = spec.ScopeSelf
+	}
+	if !w.enforceTrafficAccess(c, requesterRole, scope) {
+		return
+	}
+
+	if _, err := w.srv.Users().GetUser(context.Background(), userID); err != nil {
+		klog.V(1).InfoS("failed to get user", "userID", userID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	points, err := w.srv.Traffic().ListUsage(context.Background(), opt)
+	if err != nil {
+		klog.V(1).InfoS("failed to list user traffic", "userID", userID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	resp := buildTrafficUsageResponse(opt, points)
+	resp.UserID = userID
+	core.WriteResponse(c, nil, resp)
+}
+
+// enforceTrafficAccess checks that the requester may see peer usage in the given scope.
+// Traffic usage is visible to whoever can list the peers.
+func (w *WGController) enforceTrafficAccess(c *gin.Context, requesterRole string, scope spec.Scope) bool {
+	obj := spec.Obj(spec.ResourceWGPeer, scope)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerList)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return false
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return false
+	}
+	return true
+}
+
+// parseTrafficUsageOptions parses the period, from and to query parameters.
+func parseTrafficUsageOptions(c *gin.Context) (store.TrafficUsageOptions, error) {
+	opt := store.TrafficUsageOptions{
+		Period: c.DefaultQuery("period", store.TrafficPeriodDay),
+		From:   c.Query("from"),
+		To:     c.Query("to"),
+	}
+	if opt.Period != store.TrafficPeriodDay && opt.Period != store.TrafficPeriodMonth {
+		return opt, errors.WithCode(code.ErrValidation, "period must be day or month")
+	}
+	if opt.From != "" {
+		if _, err := time.Parse(model.TrafficDayLayout, opt.From); err != nil {
+			return opt, errors.WithCode(code.ErrValidation, "invalid from date, expected YYYY-MM-DD")
+		}
+	}
+	if opt.To != "" {
+		if _, err := time.Parse(model.TrafficDayLayout, opt.To); err != nil {
+			return opt, errors.WithCode(code.ErrValidation, "invalid to date, expected YYYY-MM-DD")
+		}
+	}
+	return opt, nil
+}
+
+func buildTrafficUsageResponse(opt store.TrafficUsageOptions, points []*store.TrafficUsagePoint) v1.TrafficUsageListResponse {
+	resp := v1.TrafficUsageListResponse{
+		Period: opt.Period,
+		Items:  make([]v1.TrafficUsageResponse, 0, len(points)),
+	}
+	for _, point := range points {
+		resp.Items = append(resp.Items, v1.TrafficUsageResponse{
+			Period:     point.Period,
+			RxBytes:    point.RxBytes,
+			TxBytes:    point.TxBytes,
+			TotalBytes: point.RxBytes + point.TxBytes,
+		})
+		resp.RxBytes += point.RxBytes
+		resp.TxBytes += point.TxBytes
+	}
+	resp.TotalBytes = resp.RxBytes + resp.TxBytes
+	return resp
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
 	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
@@ -80,8 +81,8 @@ func (w *WGController) UpdateIPPool(c *gin.Context) {
 	oldEndpoint := existingPool.Endpoint
 	oldDNS := existingPool.DNS
 
-	// Check if CIDR is being modified
-	if req.CIDR != nil && *req.CIDR != existingPool.CIDR {
+	// Check if CIDR is being modified (adding an address family to a pool in use is allowed)
+	if req.CIDR != nil && *req.CIDR != existingPool.CIDR && !ip.IsPoolCIDRExtension(existingPool.CIDR, *req.CIDR) {
 		// Check if there are any allocated IPs from this pool
 		hasAllocated, err := w.srv.IPPools().HasAllocatedIPs(context.Background(), poolID)
 		if err != nil {
@@ -93,18 +94,37 @@ func (w *WGController) UpdateIPPool(c *gin.Context) {
 			core.WriteResponse(c, errors.WithCode(code.ErrIPPoolInUse, "IP pool is in use and CIDR cannot be modified"), nil)
 			return
 		}
-		// CIDR can be modified
+	}
+	if req.CIDR != nil {
 		existingPool.CIDR = *req.CIDR
 	}
 
-	// Get global config for calculating effective endpoint
+	// Check if interface is being modified (peers follow the interface of their pool)
+	if req.InterfaceID != nil && *req.InterfaceID != existingPool.InterfaceID {
+		hasAllocated, err := w.srv.IPPools().HasAllocatedIPs(context.Background(), poolID)
+		if err != nil {
+			klog.V(1).InfoS("failed to check allocated IPs", "poolID", poolID, "error", err)
+			core.WriteResponse(c, err, nil)
+			return
+		}
+		if hasAllocated {
+			core.WriteResponse(c, errors.WithCode(code.ErrIPPoolInUse, "IP pool is in use and interface cannot be modified"), nil)
+			return
+		}
+		existingPool.InterfaceID = *req.InterfaceID
+	}
+
+	// Get global config and the pool's interface config for calculating effective endpoint
 	cfg := config.Get()
 	var wgOpts *options.WireGuardOptions
 	var configManager *wireguard.ServerConfigManager
 	if cfg != nil && cfg.WireGuard != nil {
 		wgOpts = cfg.WireGuard
-		if wgOpts.ServerConfigPath() != "" {
-			configManager = wireguard.NewServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod)
+		configManager, err = w.srv.WGInterfaces().ConfigManager(context.Background(), existingPool.InterfaceID)
+		if err != nil {
+			klog.V(1).InfoS("failed to get server config manager", "interfaceID", existingPool.InterfaceID, "error", err)
+			core.WriteResponse(c, err, nil)
+			return
 		}
 	}
 
@@ -135,6 +155,27 @@ func (w *WGController) UpdateIPPool(c *gin.Context) {
 	if req.Status != nil {
 		existingPool.Status = *req.Status
 	}
+	if req.RequirePresharedKey != nil {
+		existingPool.RequirePresharedKey = *req.RequirePresharedKey
+	}
+	if req.PeerLifetimeHours != nil {
+		existingPool.PeerLifetimeHours = *req.PeerLifetimeHours
+	}
+	if req.RequirePublicKeyOnly != nil {
+		existingPool.RequirePublicKeyOnly = *req.RequirePublicKeyOnly
+	}
+	if req.PrefixLength != nil {
+		existingPool.PrefixLength = *req.PrefixLength
+	}
+	if req.PrefixLengthV6 != nil {
+		existingPool.PrefixLengthV6 = *req.PrefixLengthV6
+	}
+	if req.Isolation != nil {
+		existingPool.Isolation = *req.Isolation
+	}
+	if req.ExitPeerID != nil {
+		existingPool.ExitPeerID = *req.ExitPeerID
+	}
 
 	// Check if Endpoint or DNS changed
 	endpointChanged := oldEndpoint != existingPool.Endpoint
@@ -149,23 +190,31 @@ func (w *WGController) UpdateIPPool(c *gin.Context) {
 
 	// If Endpoint or DNS changed, update all affected peers
 	if endpointChanged || dnsChanged {
-		if err := w.srv.WGPeers().UpdatePeersForIPPoolChange(context.Background(), poolID, existingPool); err != nil {
+		if err := w.srv.WGPeers().UpdatePeersForIPPoolChange(revisionContext(c), poolID, existingPool); err != nil {
 			klog.V(1).InfoS("failed to update peers after pool change", "poolID", poolID, "error", err)
 			// Log error but don't fail the request - pool update succeeded
 		}
 	}
 
 	resp := v1.IPPoolResponse{
-		ID:          existingPool.ID,
-		Name:        existingPool.Name,
-		CIDR:        existingPool.CIDR,
-		Routes:      existingPool.Routes,
-		DNS:         existingPool.DNS,
-		Endpoint:    existingPool.Endpoint,
-		Description: existingPool.Description,
-		Status:      existingPool.Status,
-		CreatedAt:   existingPool.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   existingPool.UpdatedAt.Format(time.RFC3339),
+		ID:                   existingPool.ID,
+		Name:                 existingPool.Name,
+		CIDR:                 existingPool.CIDR,
+		Routes:               existingPool.Routes,
+		DNS:                  existingPool.DNS,
+		Endpoint:             existingPool.Endpoint,
+		Description:          existingPool.Description,
+		Status:               existingPool.Status,
+		RequirePresharedKey:  existingPool.RequirePresharedKey,
+		InterfaceID:          existingPool.InterfaceID,
+		PeerLifetimeHours:    existingPool.PeerLifetimeHours,
+		RequirePublicKeyOnly: existingPool.RequirePublicKeyOnly,
+		PrefixLength:         existingPool.PrefixLength,
+		PrefixLengthV6:       existingPool.PrefixLengthV6,
+		Isolation:            existingPool.Isolation,
+		ExitPeerID:           existingPool.ExitPeerID,
+		CreatedAt:            existingPool.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:            existingPool.UpdatedAt.Format(time.RFC3339),
 	}
 
 	klog.V(1).InfoS("wireguard IP pool updated successfully", "poolID", poolID)
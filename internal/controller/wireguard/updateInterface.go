@@ -0,0 +1,89 @@
+package wireguard
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog/v2"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/spec"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/core"
+	"github.com/HappyLadySauce/errors"
+)
+
+// UpdateInterface updates a WireGuard interface by ID (admin only).
+// @Summary Update WireGuard interface
+// @Description Update the description or status of a WireGuard interface. Disabled interfaces accept no new peers or IP pools. Admin only.
+// @Tags wireguard
+// @Accept json
+// @Produce json
+// @Param id path string true "Interface ID"
+// @Param interface body v1.UpdateWGInterfaceRequest true "WireGuard interface update information"
+// @Success 200 {object} v1.WGInterfaceResponse "WireGuard interface updated successfully"
+// @Failure 400 {object} core.ErrResponse "Bad request - invalid input or validation failed"
+// @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
+// @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
+// @Failure 404 {object} core.ErrResponse "Not found - interface not found"
+// @Failure 500 {object} core.ErrResponse "Internal server error"
+// @Router /api/v1/wg/interfaces/{id} [put]
+func (w *WGController) UpdateInterface(c *gin.Context) {
+	klog.V(1).Info("wireguard interface update function called.")
+
+	interfaceID := c.Param("id")
+	if interfaceID == "" {
+		core.WriteResponse(c, errors.WithCode(code.ErrValidation, "missing interface ID"), nil)
+		return
+	}
+
+	// Get requester info from JWTAuth middleware
+	requesterRoleAny, _ := c.Get(middleware.UserRoleKey)
+	requesterRole, _ := requesterRoleAny.(string)
+
+	// --- Authorization (Casbin) - Admin only ---
+	obj := spec.Obj(spec.ResourceWGInterface, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGInterfaceUpdate)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return
+	}
+	if !allowed {
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return
+	}
+
+	// Parse request body
+	var req v1.UpdateWGInterfaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		klog.V(1).InfoS("invalid request body", "error", err)
+		core.WriteResponseBindErr(c, err, nil)
+		return
+	}
+
+	iface, err := w.srv.WGInterfaces().GetInterface(context.Background(), interfaceID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get WireGuard interface", "interfaceID", interfaceID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	// Update fields if provided
+	if req.Description != nil {
+		iface.Description = *req.Description
+	}
+	if req.Status != nil {
+		iface.Status = *req.Status
+	}
+
+	if err := w.srv.WGInterfaces().UpdateInterface(context.Background(), iface); err != nil {
+		klog.V(1).InfoS("failed to update WireGuard interface", "interfaceID", interfaceID, "error", err)
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	klog.V(1).InfoS("wireguard interface updated successfully", "interfaceID", interfaceID)
+	core.WriteResponse(c, nil, w.buildInterfaceResponse(context.Background(), iface))
+}
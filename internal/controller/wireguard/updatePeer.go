@@ -126,6 +126,107 @@ func (w *WGController) UpdatePeer(c *gin.Context) {
 		existingPeer.UserID = targetUser.ID
 	}
 
+	// 4) Sensitive updates (preshared key) additionally require wg_peer:update_sensitive
+	if req.PresharedKey != nil {
+		allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdateSensitive)
+		if err != nil {
+			klog.V(1).InfoS("authz enforce failed for preshared key update", "error", err)
+			core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+			return
+		}
+		if !allowed {
+			klog.V(1).InfoS("permission denied for preshared key update", "requesterRole", requesterRole, "peerID", peerID)
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+			return
+		}
+		existingPeer.PresharedKey = *req.PresharedKey
+	}
+
+	// 5) Regenerating the preshared key requires wg_config:rotate
+	if req.RegeneratePresharedKey != nil && *req.RegeneratePresharedKey {
+		allowed, err := spec.Enforce(requesterRole, spec.Obj(spec.ResourceWGConfig, scope), spec.ActionWGConfigRotate)
+		if err != nil {
+			klog.V(1).InfoS("authz enforce failed for preshared key rotation", "error", err)
+			core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+			return
+		}
+		if !allowed {
+			klog.V(1).InfoS("permission denied for preshared key rotation", "requesterRole", requesterRole, "peerID", peerID)
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+			return
+		}
+		presharedKey, err := wireguard.GeneratePresharedKey()
+		if err != nil {
+			klog.V(1).InfoS("failed to generate preshared key", "error", err)
+			core.WriteResponse(c, err, nil)
+			return
+		}
+		existingPeer.PresharedKey = presharedKey
+	}
+
+	// 6) Changing the expiration additionally requires wg_peer:update_sensitive
+	if req.ExpiresAt != nil {
+		allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdateSensitive)
+		if err != nil {
+			klog.V(1).InfoS("authz enforce failed for peer expiration update", "error", err)
+			core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+			return
+		}
+		if !allowed {
+			klog.V(1).InfoS("permission denied for peer expiration update", "requesterRole", requesterRole, "peerID", peerID)
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+			return
+		}
+		expiresAt, err := parseExpiresAt(*req.ExpiresAt)
+		if err != nil {
+			core.WriteResponse(c, err, nil)
+			return
+		}
+		existingPeer.ExpiresAt = expiresAt
+	}
+
+	// 7) Routing subnets behind the peer or pinning its endpoint additionally requires wg_peer:update_sensitive
+	if req.RoutedSubnets != nil || req.PeerEndpoint != nil {
+		allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdateSensitive)
+		if err != nil {
+			klog.V(1).InfoS("authz enforce failed for peer routes update", "error", err)
+			core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+			return
+		}
+		if !allowed {
+			klog.V(1).InfoS("permission denied for peer routes update", "requesterRole", requesterRole, "peerID", peerID)
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+			return
+		}
+		if req.RoutedSubnets != nil {
+			existingPeer.RoutedSubnets = *req.RoutedSubnets
+		}
+		if req.PeerEndpoint != nil {
+			existingPeer.PeerEndpoint = *req.PeerEndpoint
+		}
+	}
+
+	// 8) Exit node settings change the routing of other peers and additionally require wg_peer:update_sensitive
+	if req.ExitNode != nil || req.ExitPeerID != nil {
+		allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGPeerUpdateSensitive)
+		if err != nil {
+			klog.V(1).InfoS("authz enforce failed for peer exit node update", "error", err)
+			core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+			return
+		}
+		if !allowed {
+			klog.V(1).InfoS("permission denied for peer exit node update", "requesterRole", requesterRole, "peerID", peerID)
+			core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+			return
+		}
+		if req.ExitNode != nil {
+			existingPeer.ExitNode = *req.ExitNode
+		}
+		if req.ExitPeerID != nil {
+			existingPeer.ExitPeerID = *req.ExitPeerID
+		}
+	}
+
 	// Update peer fields (only update provided fields)
 	if req.DeviceName != nil {
 		existingPeer.DeviceName = *req.DeviceName
@@ -165,11 +266,30 @@ func (w *WGController) UpdatePeer(c *gin.Context) {
 			core.WriteResponse(c, errors.WithCode(code.ErrValidation, "invalid status, must be 'active' or 'disabled'"), nil)
 			return
 		}
+		// Peers disabled by a traffic quota come back when the quota resets, or when it is raised or deleted
+		if *req.Status == model.WGPeerStatusActive && existingPeer.DisabledReason == model.WGPeerDisabledReasonQuota {
+			core.WriteResponse(c, errors.WithCode(code.ErrWGPeerQuotaExceeded, "%s", code.Message(code.ErrWGPeerQuotaExceeded)), nil)
+			return
+		}
+		// Expired peers have released their IP addresses and cannot come back
+		if *req.Status == model.WGPeerStatusActive && existingPeer.DisabledReason == model.WGPeerDisabledReasonExpired {
+			core.WriteResponse(c, errors.WithCode(code.ErrWGPeerExpired, "%s", code.Message(code.ErrWGPeerExpired)), nil)
+			return
+		}
+		// Revoked peers have their public key on the revocation list and stay disabled for good
+		if *req.Status != existingPeer.Status && existingPeer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+			core.WriteResponse(c, errors.WithCode(code.ErrWGPeerRevoked, "%s", code.Message(code.ErrWGPeerRevoked)), nil)
+			return
+		}
+		if *req.Status != existingPeer.Status {
+			existingPeer.DisabledReason = ""
+		}
 		existingPeer.Status = *req.Status
 	}
 
 	// Update peer (service layer handles IP allocation and key validation)
-	if err := w.srv.WGPeers().UpdatePeer(context.Background(), existingPeer, req.ClientIP, req.IPPoolID); err != nil {
+	applied, err := w.srv.WGPeers().UpdatePeer(revisionContext(c), existingPeer, req.ClientIP, req.IPPoolID)
+	if err != nil {
 		klog.V(1).InfoS("failed to update peer", "peerID", peerID, "error", err)
 		core.WriteResponse(c, err, nil)
 		return
@@ -192,16 +312,26 @@ func (w *WGController) UpdatePeer(c *gin.Context) {
 		Username:            "",
 		DeviceName:          updatedPeer.DeviceName,
 		ClientPublicKey:     updatedPeer.ClientPublicKey,
+		PublicKeyOnly:       updatedPeer.ClientPrivateKey == "",
 		ClientPrivateKey:    updatedPeer.ClientPrivateKey,
+		PresharedKey:        updatedPeer.PresharedKey,
 		ClientIP:            updatedPeer.ClientIP,
 		AllowedIPs:          updatedPeer.AllowedIPs,
 		DNS:                 updatedPeer.DNS,
 		Endpoint:            updatedPeer.Endpoint,
+		RoutedSubnets:       updatedPeer.RoutedSubnets,
+		PeerEndpoint:        updatedPeer.PeerEndpoint,
+		ExitNode:            updatedPeer.ExitNode,
+		ExitPeerID:          updatedPeer.ExitPeerID,
 		PersistentKeepalive: updatedPeer.PersistentKeepalive,
 		Status:              updatedPeer.Status,
+		DisabledReason:      updatedPeer.DisabledReason,
 		IPPoolID:            updatedPeer.IPPoolID,
+		InterfaceID:         updatedPeer.InterfaceID,
+		ExpiresAt:           formatExpiresAt(updatedPeer.ExpiresAt),
 		CreatedAt:           updatedPeer.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:           updatedPeer.UpdatedAt.Format(time.RFC3339),
+		Applied:             toApplyResultResponses(applied...),
 	}
 	if user != nil {
 		resp.Username = user.Username
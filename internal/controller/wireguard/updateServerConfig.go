@@ -1,8 +1,6 @@
 package wireguard
 
 import (
-	"context"
-
 	"github.com/gin-gonic/gin"
 	"k8s.io/klog/v2"
 
@@ -16,12 +14,13 @@ import (
 
 // UpdateServerConfig updates the server WireGuard configuration (admin only).
 // @Summary Update server configuration
-// @Description Update the WireGuard server configuration. Admin only. Updates will automatically sync to all client configurations.
+// @Description Update the WireGuard server configuration of an interface. Admin only. Updates will automatically sync to the client configurations of the interface. NAT and forwarding are configured with the structured settings; raw PostUp/PostDown commands require the wg_server:raw_hooks permission.
 // @Tags wireguard
 // @Accept json
 // @Produce json
+// @Param interface_id query string false "WireGuard interface ID (default interface if empty)"
 // @Param request body v1.UpdateServerConfigRequest true "Server configuration update request"
-// @Success 200 {object} core.SuccessResponse "Server configuration updated successfully"
+// @Success 200 {object} v1.WGApplyResponse "Server configuration updated successfully"
 // @Failure 400 {object} core.ErrResponse "Bad request - validation failed"
 // @Failure 401 {object} core.ErrResponse "Unauthorized - invalid or expired token"
 // @Failure 403 {object} core.ErrResponse "Forbidden - permission denied"
@@ -55,14 +54,39 @@ func (w *WGController) UpdateServerConfig(c *gin.Context) {
 		return
 	}
 
+	// Raw PostUp/PostDown commands run as root and additionally require wg_server:raw_hooks,
+	// clearing them does not
+	if (req.PostUp != nil && *req.PostUp != "") || (req.PostDown != nil && *req.PostDown != "") {
+		if !w.enforceRawHooks(c, requesterRole) {
+			return
+		}
+	}
+
 	// Update server config
-	if err := w.srv.WGServer().UpdateServerConfig(context.Background(), &req); err != nil {
+	applied, err := w.srv.WGServer().UpdateServerConfig(revisionContext(c), c.Query("interface_id"), &req)
+	if err != nil {
 		klog.V(1).InfoS("failed to update server config", "error", err)
 		core.WriteResponse(c, err, nil)
 		return
 	}
 
 	klog.V(1).InfoS("wireguard server config updated successfully")
-	core.WriteResponse(c, nil, nil)
+	core.WriteResponse(c, nil, v1.WGApplyResponse{Applied: toApplyResultResponses(applied)})
 }
 
+// enforceRawHooks checks that the requester may set raw PostUp/PostDown commands.
+func (w *WGController) enforceRawHooks(c *gin.Context, requesterRole string) bool {
+	obj := spec.Obj(spec.ResourceWGServer, spec.ScopeAny)
+	allowed, err := spec.Enforce(requesterRole, obj, spec.ActionWGServerRawHooks)
+	if err != nil {
+		klog.V(1).InfoS("authz enforce failed", "error", err)
+		core.WriteResponse(c, errors.WithCode(code.ErrUnknown, "authorization engine error"), nil)
+		return false
+	}
+	if !allowed {
+		klog.V(1).InfoS("permission denied for raw server hooks", "requesterRole", requesterRole)
+		core.WriteResponse(c, errors.WithCode(code.ErrPermissionDenied, "%s", code.Message(code.ErrPermissionDenied)), nil)
+		return false
+	}
+	return true
+}
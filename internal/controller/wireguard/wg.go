@@ -1,8 +1,18 @@
 package wireguard
 
 import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/HappyLadySauce/NexusPointWG/cmd/app/middleware"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	v1 "github.com/HappyLadySauce/NexusPointWG/internal/pkg/types/v1"
 	srv "github.com/HappyLadySauce/NexusPointWG/internal/service"
 	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
 )
 
 // WGController creates a WireGuard handler used to handle requests for WireGuard resources.
@@ -16,3 +26,54 @@ func NewWGController(store store.Factory) *WGController {
 		srv: srv.NewService(store),
 	}
 }
+
+// revisionContext returns a context that attributes server config changes to the requester and API call.
+func revisionContext(c *gin.Context) context.Context {
+	usernameAny, _ := c.Get(middleware.UsernameKey)
+	username, _ := usernameAny.(string)
+	return wireguard.WithRevisionInfo(context.Background(), wireguard.RevisionInfo{
+		Actor:  username,
+		Action: c.Request.Method + " " + c.FullPath(),
+	})
+}
+
+// parseExpiresAt parses an RFC3339 expiration time. An empty string means the peer never expires.
+func parseExpiresAt(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrValidation, "invalid expires_at, expected RFC3339")
+	}
+	return &expiresAt, nil
+}
+
+// formatExpiresAt formats an expiration time for responses, empty if the peer never expires.
+func formatExpiresAt(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+	return expiresAt.Format(time.RFC3339)
+}
+
+// toApplyResultResponses converts what applying server configs changed for responses.
+func toApplyResultResponses(results ...*wireguard.ApplyResult) []v1.WGApplyResultResponse {
+	responses := make([]v1.WGApplyResultResponse, 0, len(results))
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		responses = append(responses, v1.WGApplyResultResponse{
+			Method:           result.Method,
+			Interface:        result.Interface,
+			Added:            result.Added,
+			Removed:          result.Removed,
+			Updated:          result.Updated,
+			InterfaceChanged: result.InterfaceChanged,
+			Restarted:        result.Restarted,
+			Exact:            result.Exact,
+		})
+	}
+	return responses
+}
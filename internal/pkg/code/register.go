@@ -57,6 +57,8 @@ func init() {
 	register(ErrWGPrivateKeyInvalid, 400, "Invalid WireGuard private key")
 	register(ErrWGKeyGenerationFailed, 500, "Failed to generate WireGuard key")
 	register(ErrWGPublicKeyGenerationFailed, 500, "Failed to generate public key from private key")
+	register(ErrWGPresharedKeyInvalid, 400, "Invalid WireGuard preshared key")
+	register(ErrWGPresharedKeyRequired, 400, "Preshared key is required by policy and cannot be removed")
 
 	// WireGuard: file operation errors
 	register(ErrWGUserConfigNotFound, 404, "User WireGuard configuration not found")
@@ -76,4 +78,60 @@ func init() {
 	register(ErrIPPoolInvalidCIDR, 400, "Invalid CIDR format for IP pool")
 	register(ErrIPPoolInUse, 400, "IP pool is in use and cannot be deleted")
 	register(ErrIPPoolDisabled, 400, "IP pool is disabled")
+
+	// WireGuard: address family errors
+	register(ErrIPFamilyDuplicate, 400, "Only one IP address per address family is allowed")
+	register(ErrIPFamilyNotInPool, 400, "IP address family is not served by the IP pool")
+
+	// WireGuard: interface errors
+	register(ErrWGInterfaceNotFound, 404, "WireGuard interface not found")
+	register(ErrWGInterfaceAlreadyExists, 400, "WireGuard interface already exists")
+	register(ErrWGInterfaceNameInvalid, 400, "Invalid WireGuard interface name")
+	register(ErrWGInterfaceInUse, 400, "WireGuard interface is in use and cannot be deleted")
+	register(ErrWGInterfaceDisabled, 400, "WireGuard interface is disabled")
+
+	// WireGuard: config history errors
+	register(ErrWGConfigRevisionNotFound, 404, "WireGuard server config revision not found")
+	register(ErrWGConfigHistoryFailed, 500, "Failed to access WireGuard server config history")
+
+	// WireGuard: runtime status errors
+	register(ErrWGStatusUnavailable, 500, "WireGuard runtime status is not available")
+
+	// WireGuard: traffic quota errors
+	register(ErrTrafficQuotaNotFound, 404, "Traffic quota not found")
+	register(ErrTrafficQuotaAlreadyExists, 400, "Traffic quota already exists for this target")
+	register(ErrWGPeerQuotaExceeded, 400, "Peer is disabled because its traffic quota is exceeded")
+
+	// WireGuard: peer expiration errors
+	register(ErrWGPeerExpired, 400, "Peer has expired, create a new peer instead")
+
+	// WireGuard: peer revocation errors
+	register(ErrWGPeerRevoked, 400, "Peer has been revoked")
+	register(ErrWGPeerKeyRevoked, 400, "Public key has been revoked and cannot be used again")
+
+	// WireGuard: config share link errors
+	register(ErrWGShareLinkNotFound, 404, "Share link not found")
+	register(ErrWGShareLinkInvalid, 404, "Share link is invalid, expired, used up or revoked")
+
+	// WireGuard: public-key-only peer errors
+	register(ErrWGPublicKeyOnlyRequired, 400, "IP pool requires public-key-only peers, submit the client public key instead of a private key")
+	register(ErrWGPeerPublicKeyOnly, 400, "Server does not hold the private key of this peer")
+
+	// WireGuard: site-to-site peer errors
+	register(ErrWGRoutedSubnetOverlap, 400, "Routed subnet overlaps an IP pool or the addresses of another peer")
+
+	// WireGuard: port forward errors
+	register(ErrWGPortForwardNotFound, 404, "Port forward not found")
+	register(ErrWGPortForwardConflict, 400, "Public port is already used by a WireGuard listen port, the API server or another port forward")
+
+	// WireGuard: exit node errors
+	register(ErrWGExitNodeConflict, 400, "Interface already has an exit node")
+	register(ErrWGExitNodeInvalid, 400, "Exit peer is not an exit node of the same interface")
+	register(ErrWGExitNodeInUse, 400, "Exit node is still assigned to peers or IP pools")
+
+	// WireGuard: drift errors
+	register(ErrWGDriftActionNotFound, 404, "Drift action not found, the database and the config file may already agree")
+
+	// WireGuard: peer key errors
+	register(ErrWGPeerKeyConflict, 400, "Public key is already used by another peer of the interface")
 }
@@ -66,7 +66,7 @@ const (
 	ErrWGIPAllocationFailed
 )
 
-// WireGuard: key errors (120020-120022)
+// WireGuard: key errors (120020-120024)
 const (
 	// ErrWGPrivateKeyInvalid - 400: Invalid WireGuard private key.
 	ErrWGPrivateKeyInvalid int = iota + 120020
@@ -76,6 +76,12 @@ const (
 
 	// ErrWGPublicKeyGenerationFailed - 500: Failed to generate public key from private key.
 	ErrWGPublicKeyGenerationFailed
+
+	// ErrWGPresharedKeyInvalid - 400: Invalid WireGuard preshared key.
+	ErrWGPresharedKeyInvalid
+
+	// ErrWGPresharedKeyRequired - 400: Preshared key is required by policy and cannot be removed.
+	ErrWGPresharedKeyRequired
 )
 
 // WireGuard: file operation errors (120030-120035)
@@ -125,3 +131,129 @@ const (
 	// ErrIPPoolDisabled - 400: IP pool is disabled.
 	ErrIPPoolDisabled
 )
+
+// WireGuard: address family errors (120060-120061)
+const (
+	// ErrIPFamilyDuplicate - 400: Only one IP address per address family is allowed.
+	ErrIPFamilyDuplicate int = iota + 120060
+
+	// ErrIPFamilyNotInPool - 400: IP address family is not served by the IP pool.
+	ErrIPFamilyNotInPool
+)
+
+// WireGuard: interface errors (120070-120074)
+const (
+	// ErrWGInterfaceNotFound - 404: WireGuard interface not found.
+	ErrWGInterfaceNotFound int = iota + 120070
+
+	// ErrWGInterfaceAlreadyExists - 400: WireGuard interface with the same name already exists.
+	ErrWGInterfaceAlreadyExists
+
+	// ErrWGInterfaceNameInvalid - 400: Invalid WireGuard interface name.
+	ErrWGInterfaceNameInvalid
+
+	// ErrWGInterfaceInUse - 400: WireGuard interface is in use and cannot be deleted.
+	ErrWGInterfaceInUse
+
+	// ErrWGInterfaceDisabled - 400: WireGuard interface is disabled.
+	ErrWGInterfaceDisabled
+)
+
+// WireGuard: config history errors (120080-120081)
+const (
+	// ErrWGConfigRevisionNotFound - 404: Server config revision not found.
+	ErrWGConfigRevisionNotFound int = iota + 120080
+
+	// ErrWGConfigHistoryFailed - 500: Failed to read or write server config history.
+	ErrWGConfigHistoryFailed
+)
+
+// WireGuard: runtime status errors (120090)
+const (
+	// ErrWGStatusUnavailable - 500: Runtime status of the interface could not be read.
+	ErrWGStatusUnavailable int = iota + 120090
+)
+
+// WireGuard: traffic quota errors (120100-120102)
+const (
+	// ErrTrafficQuotaNotFound - 404: Traffic quota not found.
+	ErrTrafficQuotaNotFound int = iota + 120100
+
+	// ErrTrafficQuotaAlreadyExists - 400: A traffic quota already exists for the target.
+	ErrTrafficQuotaAlreadyExists
+
+	// ErrWGPeerQuotaExceeded - 400: Peer is disabled because its traffic quota is exceeded.
+	ErrWGPeerQuotaExceeded
+)
+
+// WireGuard: peer expiration errors (120110)
+const (
+	// ErrWGPeerExpired - 400: Peer has expired and its IP addresses were released.
+	ErrWGPeerExpired int = iota + 120110
+)
+
+// WireGuard: peer revocation errors (120120-120121)
+const (
+	// ErrWGPeerRevoked - 400: Peer has been revoked.
+	ErrWGPeerRevoked int = iota + 120120
+
+	// ErrWGPeerKeyRevoked - 400: Public key has been revoked and cannot be used again.
+	ErrWGPeerKeyRevoked
+)
+
+// WireGuard: config share link errors (120130-120131)
+const (
+	// ErrWGShareLinkNotFound - 404: Share link not found.
+	ErrWGShareLinkNotFound int = iota + 120130
+
+	// ErrWGShareLinkInvalid - 404: Share link is invalid, expired, used up or revoked.
+	ErrWGShareLinkInvalid
+)
+
+// WireGuard: public-key-only peer errors (120140-120141)
+const (
+	// ErrWGPublicKeyOnlyRequired - 400: IP pool requires peers to submit only their public key.
+	ErrWGPublicKeyOnlyRequired int = iota + 120140
+
+	// ErrWGPeerPublicKeyOnly - 400: Server does not hold the private key of the peer.
+	ErrWGPeerPublicKeyOnly
+)
+
+// WireGuard: site-to-site peer errors (120150)
+const (
+	// ErrWGRoutedSubnetOverlap - 400: Routed subnet overlaps an IP pool or the addresses of another peer.
+	ErrWGRoutedSubnetOverlap int = iota + 120150
+)
+
+// WireGuard: port forward errors (120160-120161)
+const (
+	// ErrWGPortForwardNotFound - 404: Port forward not found.
+	ErrWGPortForwardNotFound int = iota + 120160
+
+	// ErrWGPortForwardConflict - 400: Public port is already used by a listen port or another port forward.
+	ErrWGPortForwardConflict
+)
+
+// WireGuard: exit node errors (120170-120172)
+const (
+	// ErrWGExitNodeConflict - 400: Interface already has an exit node.
+	ErrWGExitNodeConflict int = iota + 120170
+
+	// ErrWGExitNodeInvalid - 400: Assigned exit peer is not an exit node of the same interface.
+	ErrWGExitNodeInvalid
+
+	// ErrWGExitNodeInUse - 400: Exit node is still assigned to peers or IP pools.
+	ErrWGExitNodeInUse
+)
+
+// WireGuard: drift errors (120180)
+const (
+	// ErrWGDriftActionNotFound - 404: Drift action not found.
+	ErrWGDriftActionNotFound int = iota + 120180
+)
+
+// WireGuard: peer key errors (120190)
+const (
+	// ErrWGPeerKeyConflict - 400: Public key is already used by another peer of the interface.
+	ErrWGPeerKeyConflict int = iota + 120190
+)
@@ -2,12 +2,12 @@ package ip
 
 import (
 	"context"
-	"net"
-	"sort"
+	"net/netip"
 
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	iputil "github.com/HappyLadySauce/NexusPointWG/pkg/utils/ip"
 	"github.com/HappyLadySauce/errors"
 )
 
@@ -21,36 +21,38 @@ func NewAllocator(store store.Factory) *Allocator {
 	return &Allocator{store: store}
 }
 
-// AllocateIP allocates an IP address from the specified IP pool.
-// If preferredIP is provided and available, it will be used; otherwise, the first available IP will be allocated.
-// serverTunnelIP is the server tunnel IP address (from server config Address) to exclude from allocation.
-func (a *Allocator) AllocateIP(ctx context.Context, poolID, preferredIP, serverTunnelIP string) (string, error) {
+// AllocateIPs allocates one IP address per address family served by the specified IP pool.
+// A single-stack pool yields one address, a dual-stack pool yields one IPv4 and one IPv6 address.
+// Addresses are returned as host CIDRs (e.g. "100.100.100.2/32"); for pools delegating prefixes the
+// allocated prefix of the family is returned instead (e.g. "100.100.100.8/29").
+// preferredIPs may hold at most one address per family; families without a preferred IP are auto-allocated.
+// In a family delegating prefixes, a preferred IP must be the first address of a free prefix.
+// serverTunnelIP is the server tunnel IP address (from server config Address) to exclude from allocation,
+// and may contain one address per family, comma-separated.
+func (a *Allocator) AllocateIPs(ctx context.Context, poolID string, preferredIPs []string, serverTunnelIP string) ([]string, error) {
 	// Get IP pool
 	pool, err := a.store.IPPools().GetIPPool(ctx, poolID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if pool.Status != model.IPPoolStatusActive {
-		return "", errors.WithCode(code.ErrIPPoolDisabled, "IP pool %s is disabled", poolID)
+		return nil, errors.WithCode(code.ErrIPPoolDisabled, "IP pool %s is disabled", poolID)
 	}
 
-	// Get all allocated IPs for this pool
-	allocatedIPs, err := a.store.IPAllocations().GetAllocatedIPsByPoolID(ctx, poolID)
+	prefixes, err := ParsePoolCIDR(pool.CIDR)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get allocated IPs")
+		return nil, err
 	}
 
-	// Convert allocated IPs to a map for quick lookup
-	allocatedMap := make(map[string]bool)
-	for _, ip := range allocatedIPs {
-		allocatedMap[ip] = true
+	if err := ValidateIPList(preferredIPs); err != nil {
+		return nil, err
 	}
 
-	// Parse CIDR
-	_, ipNet, err := net.ParseCIDR(pool.CIDR)
+	// Get all allocated IPs and prefixes for this pool
+	allocatedMap, allocatedPrefixes, err := a.allocated(ctx, poolID)
 	if err != nil {
-		return "", errors.WithCode(code.ErrIPPoolInvalidCIDR, "invalid CIDR format: %s", pool.CIDR)
+		return nil, err
 	}
 
 	// Extract server tunnel IP from Address (e.g., "100.100.100.1/24" -> "100.100.100.1")
@@ -60,99 +62,170 @@ func (a *Allocator) AllocateIP(ctx context.Context, poolID, preferredIP, serverT
 		serverIPStr, _ = ExtractIPFromEndpoint(pool.Endpoint)
 	}
 
-	// If preferred IP is provided, validate and use it
-	if preferredIP != "" {
-		// Validate preferred IP
-		if err := ValidateIPv4(preferredIP); err != nil {
-			return "", err
-		}
+	// Every preferred IP must belong to one of the pool's address families
+	for _, preferredIP := range preferredIPs {
 		if err := ValidateIPInCIDR(preferredIP, pool.CIDR); err != nil {
-			return "", err
+			return nil, err
 		}
-		if err := ValidateIPNotReserved(preferredIP, pool.CIDR, serverIPStr); err != nil {
-			return "", err
+	}
+
+	result := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		preferredIP := ""
+		for _, candidate := range preferredIPs {
+			if addr, _ := netip.ParseAddr(candidate); addr.Is4() == prefix.Addr().Is4() {
+				preferredIP = addr.String() // canonical form, e.g. "fd00::2"
+				break
+			}
 		}
 
-		// Check if already allocated
-		if allocatedMap[preferredIP] {
-			return "", errors.WithCode(code.ErrIPAlreadyInUse, "IP address %s is already in use", preferredIP)
+		// Families delegating prefixes allocate a whole subnet per peer
+		if bits := PoolPrefixLength(pool, prefix); bits < prefix.Addr().BitLen() {
+			if preferredIP != "" {
+				delegated := netip.PrefixFrom(netip.MustParseAddr(preferredIP), bits)
+				if err := checkPrefixAvailable(delegated, prefix, serverIPStr, allocatedPrefixes); err != nil {
+					return nil, err
+				}
+				result = append(result, delegated.String())
+				continue
+			}
+
+			available := findAvailablePrefixes(prefix, bits, serverIPStr, allocatedPrefixes, 1)
+			if len(available) == 0 {
+				return nil, errors.WithCode(code.ErrWGIPAllocationFailed, "failed to allocate IP prefix: no available /%d prefixes in %s", bits, prefix)
+			}
+			result = append(result, available[0].String())
+			continue
 		}
 
-		return preferredIP, nil
-	}
+		// If preferred IP is provided, validate and use it
+		if preferredIP != "" {
+			if err := ValidateIPNotReserved(preferredIP, pool.CIDR, serverIPStr); err != nil {
+				return nil, err
+			}
 
-	// Auto-allocate: find the first available IP
-	availableIP, err := a.findFirstAvailableIP(ipNet, serverIPStr, allocatedMap)
-	if err != nil {
-		return "", errors.WithCode(code.ErrWGIPAllocationFailed, "failed to allocate IP address: %s", err.Error())
+			// Check if already allocated
+			if allocatedMap[preferredIP] {
+				return nil, errors.WithCode(code.ErrIPAlreadyInUse, "IP address %s is already in use", preferredIP)
+			}
+
+			cidr, _ := FormatIPAsCIDR(preferredIP)
+			result = append(result, cidr)
+			continue
+		}
+
+		// Auto-allocate: find the first available IP
+		availableIPs := a.findAvailableIPs(prefix, serverIPStr, allocatedMap, 1)
+		if len(availableIPs) == 0 {
+			return nil, errors.WithCode(code.ErrWGIPAllocationFailed, "failed to allocate IP address: no available IP addresses in %s", prefix)
+		}
+		cidr, _ := FormatIPAsCIDR(availableIPs[0])
+		result = append(result, cidr)
 	}
 
-	return availableIP, nil
+	return result, nil
 }
 
-// findFirstAvailableIP finds the first available IP address in the network range.
-func (a *Allocator) findFirstAvailableIP(ipNet *net.IPNet, serverIPStr string, allocatedMap map[string]bool) (string, error) {
-	networkIP := ipNet.IP
-	mask := ipNet.Mask
-
-	// Calculate network size
-	ones, bits := mask.Size()
-	if bits != 32 {
-		return "", errors.WithCode(code.ErrIPNotIPv4, "only IPv4 networks are supported")
+// PoolPrefixLength returns the length of the prefix allocated to each peer from the given prefix
+// of a pool: the prefix length delegated by the pool for that family, or a single address (/32, /128).
+func PoolPrefixLength(pool *model.IPPool, prefix netip.Prefix) int {
+	bits := pool.PrefixLength
+	if !prefix.Addr().Is4() {
+		bits = pool.PrefixLengthV6
+	}
+	if bits <= 0 {
+		return prefix.Addr().BitLen()
 	}
+	return bits
+}
 
-	// Generate all possible IPs in the network
-	var candidateIPs []string
-	for i := 1; i < (1<<(32-ones))-1; i++ {
-		ip := make(net.IP, 4)
-		copy(ip, networkIP)
+// allocated returns the IP addresses allocated from a pool, and the prefixes they cover
+// (host prefixes for single addresses, the delegated prefixes otherwise).
+func (a *Allocator) allocated(ctx context.Context, poolID string) (map[string]bool, []netip.Prefix, error) {
+	allocations, err := a.store.IPAllocations().GetAllocationsByPoolID(ctx, poolID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get allocated IPs")
+	}
 
-		// Add offset to network IP
-		offset := i
-		for j := 3; j >= 0 && offset > 0; j-- {
-			ip[j] += byte(offset & 0xff)
-			offset >>= 8
+	allocatedMap := make(map[string]bool, len(allocations))
+	prefixes := make([]netip.Prefix, 0, len(allocations))
+	for _, allocation := range allocations {
+		allocatedMap[allocation.IPAddress] = true
+		if prefix, err := netip.ParsePrefix(allocation.CIDR()); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
 		}
+	}
+	return allocatedMap, prefixes, nil
+}
 
-		ipStr := ip.String()
-
-		// Skip network address, broadcast address, and server IP
-		if ip.Equal(networkIP) {
-			continue
+// checkPrefixAvailable checks that a prefix delegated from poolPrefix is aligned, holds none of
+// the reserved addresses (network address, IPv4 broadcast address, server IPs) and overlaps no allocation.
+func checkPrefixAvailable(delegated, poolPrefix netip.Prefix, serverIPStr string, allocated []netip.Prefix) error {
+	if delegated.Addr() != delegated.Masked().Addr() {
+		return errors.WithCode(code.ErrValidation, "IP address %s is not the first address of a /%d prefix", delegated.Addr(), delegated.Bits())
+	}
+	if delegated.Contains(poolPrefix.Addr()) {
+		return errors.WithCode(code.ErrIPIsNetworkAddress, "prefix %s contains the network address", delegated)
+	}
+	if poolPrefix.Addr().Is4() && delegated.Contains(iputil.LastIPv4(poolPrefix)) {
+		return errors.WithCode(code.ErrIPIsBroadcastAddress, "prefix %s contains the broadcast address", delegated)
+	}
+	for _, serverIP := range SplitIPList(serverIPStr) {
+		if addr, err := netip.ParseAddr(serverIP); err == nil && delegated.Contains(addr) {
+			return errors.WithCode(code.ErrIPIsServerIP, "prefix %s contains the server IP", delegated)
 		}
+	}
+	for _, prefix := range allocated {
+		if delegated.Overlaps(prefix) {
+			return errors.WithCode(code.ErrIPAlreadyInUse, "prefix %s overlaps %s, which is already in use", delegated, prefix)
+		}
+	}
+	return nil
+}
 
-		// Check broadcast address
-		broadcastIP := make(net.IP, len(networkIP))
-		copy(broadcastIP, networkIP)
-		for j := range broadcastIP {
-			broadcastIP[j] |= ^mask[j]
+// findAvailablePrefixes returns up to limit available prefixes of the given length in poolPrefix, in address order.
+// Like findAvailableIPs, the cost is bounded by the number of allocations rather than the pool size.
+func findAvailablePrefixes(poolPrefix netip.Prefix, bits int, serverIPStr string, allocated []netip.Prefix, limit int) []netip.Prefix {
+	var available []netip.Prefix
+	for candidate := netip.PrefixFrom(poolPrefix.Addr(), bits); candidate.IsValid() && poolPrefix.Contains(candidate.Addr()) && len(available) < limit; {
+		if checkPrefixAvailable(candidate, poolPrefix, serverIPStr, allocated) == nil {
+			available = append(available, candidate)
 		}
-		if ip.Equal(broadcastIP) {
-			continue
+		next := iputil.LastIP(candidate).Next()
+		if !next.IsValid() {
+			break
+		}
+		candidate = netip.PrefixFrom(next, bits)
+	}
+	return available
+}
+
+// findAvailableIPs returns up to limit available IP addresses in the prefix, in address order.
+// The network address, the IPv4 broadcast address and the server IPs are skipped.
+// Addresses are walked sequentially, so the cost is bounded by the number of allocated
+// addresses rather than the prefix size, which keeps large IPv6 prefixes (e.g. /64) cheap.
+func (a *Allocator) findAvailableIPs(prefix netip.Prefix, serverIPStr string, allocatedMap map[string]bool, limit int) []string {
+	var availableIPs []string
+	last := iputil.LastIP(prefix)
+
+	for addr := prefix.Addr().Next(); addr.IsValid() && prefix.Contains(addr) && len(availableIPs) < limit; addr = addr.Next() {
+		// Skip broadcast address (IPv4 only)
+		if addr.Is4() && addr == last {
+			break
 		}
 
 		// Check server IP
-		if serverIPStr != "" {
-			serverIP := net.ParseIP(serverIPStr)
-			if serverIP != nil && ip.Equal(serverIP) {
-				continue
-			}
+		if isServerIP(addr, serverIPStr) {
+			continue
 		}
 
 		// Check if already allocated
-		if !allocatedMap[ipStr] {
-			candidateIPs = append(candidateIPs, ipStr)
+		if !allocatedMap[addr.String()] {
+			availableIPs = append(availableIPs, addr.String())
 		}
 	}
 
-	if len(candidateIPs) == 0 {
-		return "", errors.WithCode(code.ErrWGIPAllocationFailed, "no available IP addresses in pool")
-	}
-
-	// Sort IPs to ensure consistent allocation order
-	sort.Strings(candidateIPs)
-
-	return candidateIPs[0], nil
+	return availableIPs
 }
 
 // ValidateAndAllocateIP validates an IP address and allocates it if valid.
@@ -177,12 +250,27 @@ func (a *Allocator) ValidateAndAllocateIP(ctx context.Context, poolID, ipStr, se
 	}
 
 	// Validate IP
-	if err := ValidateIPv4(ipStr); err != nil {
+	if err := ValidateIP(ipStr); err != nil {
 		return err
 	}
 	if err := ValidateIPInCIDR(ipStr, pool.CIDR); err != nil {
 		return err
 	}
+
+	// In a family delegating prefixes the IP stands for the prefix starting at it
+	addr := netip.MustParseAddr(ipStr)
+	poolPrefix, err := poolPrefixForAddr(addr, pool.CIDR)
+	if err != nil {
+		return err
+	}
+	if bits := PoolPrefixLength(pool, poolPrefix); bits < addr.BitLen() {
+		_, allocatedPrefixes, err := a.allocated(ctx, poolID)
+		if err != nil {
+			return err
+		}
+		return checkPrefixAvailable(netip.PrefixFrom(addr, bits), poolPrefix, serverIPStr, allocatedPrefixes)
+	}
+
 	if err := ValidateIPNotReserved(ipStr, pool.CIDR, serverIPStr); err != nil {
 		return err
 	}
@@ -197,6 +285,7 @@ func (a *Allocator) ValidateAndAllocateIP(ctx context.Context, poolID, ipStr, se
 }
 
 // GetAvailableIPs returns a list of available IP addresses in the pool.
+// For families delegating prefixes the available prefixes are returned as CIDRs (e.g. "100.100.100.8/29").
 func (a *Allocator) GetAvailableIPs(ctx context.Context, poolID string, limit int) ([]string, error) {
 	// Get IP pool
 	pool, err := a.store.IPPools().GetIPPool(ctx, poolID)
@@ -208,22 +297,15 @@ func (a *Allocator) GetAvailableIPs(ctx context.Context, poolID string, limit in
 		return nil, errors.WithCode(code.ErrIPPoolDisabled, "IP pool %s is disabled", poolID)
 	}
 
-	// Get all allocated IPs for this pool
-	allocatedIPs, err := a.store.IPAllocations().GetAllocatedIPsByPoolID(ctx, poolID)
+	// Get all allocated IPs and prefixes for this pool
+	allocatedMap, allocatedPrefixes, err := a.allocated(ctx, poolID)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get allocated IPs")
-	}
-
-	// Convert allocated IPs to a map for quick lookup
-	allocatedMap := make(map[string]bool)
-	for _, ip := range allocatedIPs {
-		allocatedMap[ip] = true
+		return nil, err
 	}
 
-	// Parse CIDR
-	_, ipNet, err := net.ParseCIDR(pool.CIDR)
+	prefixes, err := ParsePoolCIDR(pool.CIDR)
 	if err != nil {
-		return nil, errors.WithCode(code.ErrIPPoolInvalidCIDR, "invalid CIDR format: %s", pool.CIDR)
+		return nil, err
 	}
 
 	// Extract server tunnel IP from Address (e.g., "100.100.100.1/24" -> "100.100.100.1")
@@ -231,76 +313,90 @@ func (a *Allocator) GetAvailableIPs(ctx context.Context, poolID string, limit in
 	// so we fall back to extracting from pool endpoint for backward compatibility
 	serverIPStr, _ := ExtractIPFromEndpoint(pool.Endpoint)
 
-	// Generate available IPs
+	// Generate available IPs, IPv4 first for dual-stack pools
 	var availableIPs []string
-	networkIP := ipNet.IP
-	mask := ipNet.Mask
-
-	ones, bits := mask.Size()
-	if bits != 32 {
-		return nil, errors.WithCode(code.ErrIPNotIPv4, "only IPv4 networks are supported")
-	}
-
-	for i := 1; i < (1<<(32-ones))-1 && len(availableIPs) < limit; i++ {
-		ip := make(net.IP, 4)
-		copy(ip, networkIP)
-
-		// Add offset to network IP
-		offset := i
-		for j := 3; j >= 0 && offset > 0; j-- {
-			ip[j] += byte(offset & 0xff)
-			offset >>= 8
+	for _, prefix := range prefixes {
+		if len(availableIPs) >= limit {
+			break
 		}
-
-		ipStr := ip.String()
-
-		// Skip network address, broadcast address, and server IP
-		if ip.Equal(networkIP) {
+		if bits := PoolPrefixLength(pool, prefix); bits < prefix.Addr().BitLen() {
+			for _, available := range findAvailablePrefixes(prefix, bits, serverIPStr, allocatedPrefixes, limit-len(availableIPs)) {
+				availableIPs = append(availableIPs, available.String())
+			}
 			continue
 		}
+		availableIPs = append(availableIPs, a.findAvailableIPs(prefix, serverIPStr, allocatedMap, limit-len(availableIPs))...)
+	}
 
-		broadcastIP := make(net.IP, len(networkIP))
-		copy(broadcastIP, networkIP)
-		for j := range broadcastIP {
-			broadcastIP[j] |= ^mask[j]
-		}
-		if ip.Equal(broadcastIP) {
-			continue
-		}
+	return availableIPs, nil
+}
 
-		if serverIPStr != "" {
-			serverIP := net.ParseIP(serverIPStr)
-			if serverIP != nil && ip.Equal(serverIP) {
-				continue
-			}
-		}
+// AllocationCIDRs formats IP addresses of a pool as allocation CIDRs: host CIDRs, or the prefixes
+// starting at them for families delegating prefixes (e.g. "100.100.100.8" -> "100.100.100.8/29").
+func (a *Allocator) AllocationCIDRs(ctx context.Context, poolID string, ips []string) ([]string, error) {
+	pool, err := a.store.IPPools().GetIPPool(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
 
-		// Check if already allocated
-		if !allocatedMap[ipStr] {
-			availableIPs = append(availableIPs, ipStr)
+	cidrs := make([]string, 0, len(ips))
+	for _, ipStr := range ips {
+		addr, err := netip.ParseAddr(ipStr)
+		if err != nil {
+			return nil, errors.WithCode(code.ErrValidation, "invalid IP address format: %s", ipStr)
+		}
+		bits := addr.BitLen()
+		if poolPrefix, err := poolPrefixForAddr(addr, pool.CIDR); err == nil {
+			bits = PoolPrefixLength(pool, poolPrefix)
 		}
+		cidrs = append(cidrs, netip.PrefixFrom(addr, bits).String())
 	}
+	return cidrs, nil
+}
 
-	// Sort IPs
-	sort.Strings(availableIPs)
+// RecordAllocation stores an allocation. Released records keep their IP address, which is unique,
+// so the released record of the address is re-activated for the new peer instead of inserting a new one.
+func (a *Allocator) RecordAllocation(ctx context.Context, allocation *model.IPAllocation) error {
+	released, err := a.store.IPAllocations().GetReleasedIPAllocationByIPAddress(ctx, allocation.IPAddress)
+	if err != nil {
+		return errors.Wrap(err, "failed to get released IP allocation")
+	}
+	if released == nil {
+		return a.store.IPAllocations().CreateIPAllocation(ctx, allocation)
+	}
 
-	return availableIPs, nil
+	allocation.ID = released.ID
+	allocation.CreatedAt = released.CreatedAt
+	allocation.Status = model.IPAllocationStatusAllocated
+	return a.store.IPAllocations().UpdateIPAllocation(ctx, allocation)
 }
 
-// ReleaseIP releases an allocated IP address.
+// ReleaseIP releases all IP addresses allocated to a peer.
 func (a *Allocator) ReleaseIP(ctx context.Context, peerID string) error {
-	allocation, err := a.store.IPAllocations().GetIPAllocationByPeerID(ctx, peerID)
+	return a.ReleaseIPAddresses(ctx, peerID, nil)
+}
+
+// ReleaseIPAddresses releases the given IP addresses allocated to a peer.
+// If ipAddresses is empty, all allocations of the peer are released.
+func (a *Allocator) ReleaseIPAddresses(ctx context.Context, peerID string, ipAddresses []string) error {
+	allocations, err := a.store.IPAllocations().GetIPAllocationsByPeerID(ctx, peerID)
 	if err != nil {
 		return errors.Wrap(err, "failed to get IP allocation")
 	}
 
-	if allocation == nil {
-		return nil // Already released or never allocated
+	release := make(map[string]bool, len(ipAddresses))
+	for _, ipAddress := range ipAddresses {
+		release[ipAddress] = true
 	}
 
-	allocation.Status = model.IPAllocationStatusReleased
-	if err := a.store.IPAllocations().UpdateIPAllocation(ctx, allocation); err != nil {
-		return errors.Wrap(err, "failed to release IP allocation")
+	for _, allocation := range allocations {
+		if len(release) > 0 && !release[allocation.IPAddress] {
+			continue
+		}
+		allocation.Status = model.IPAllocationStatusReleased
+		if err := a.store.IPAllocations().UpdateIPAllocation(ctx, allocation); err != nil {
+			return errors.Wrap(err, "failed to release IP allocation")
+		}
 	}
 
 	return nil
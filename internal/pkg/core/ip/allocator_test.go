@@ -0,0 +1,218 @@
+package ip
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/errors"
+)
+
+func TestParsePoolCIDR(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "IPv4", cidr: "100.100.100.0/24", want: []string{"100.100.100.0/24"}},
+		{name: "IPv6", cidr: "fd00:100::/64", want: []string{"fd00:100::/64"}},
+		{name: "dual-stack is ordered IPv4 first", cidr: "fd00:100::/64, 100.100.100.0/24", want: []string{"100.100.100.0/24", "fd00:100::/64"}},
+		{name: "host bits are masked", cidr: "100.100.100.7/24", want: []string{"100.100.100.0/24"}},
+		{name: "two IPv4 prefixes", cidr: "10.0.0.0/24,10.0.1.0/24", wantErr: true},
+		{name: "two IPv6 prefixes", cidr: "fd00::/64,fd01::/64", wantErr: true},
+		{name: "empty", cidr: " , ", wantErr: true},
+		{name: "invalid", cidr: "100.100.100.0/33", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParsePoolCIDR(tt.cidr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePoolCIDR(%q) = %v, want an error", tt.cidr, prefixes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePoolCIDR(%q) error = %v", tt.cidr, err)
+			}
+			got := make([]string, 0, len(prefixes))
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePoolCIDR(%q) = %v, want %v", tt.cidr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindAvailableIPs(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		serverIP  string
+		allocated []string
+		limit     int
+		want      []string
+	}{
+		{
+			name:   "network address is skipped",
+			prefix: "100.100.100.0/24", limit: 2,
+			want: []string{"100.100.100.1", "100.100.100.2"},
+		},
+		{
+			name:   "server IP and allocated IPs are skipped",
+			prefix: "100.100.100.0/24", serverIP: "100.100.100.1", allocated: []string{"100.100.100.2", "100.100.100.4"}, limit: 3,
+			want: []string{"100.100.100.3", "100.100.100.5", "100.100.100.6"},
+		},
+		{
+			name:   "IPv4 broadcast address is never returned",
+			prefix: "100.100.100.0/30", serverIP: "100.100.100.1", limit: 5,
+			want: []string{"100.100.100.2"},
+		},
+		{
+			name:   "IPv6 server IP of a dual-stack server",
+			prefix: "fd00:100::/64", serverIP: "100.100.100.1,fd00:100::1", allocated: []string{"fd00:100::2"}, limit: 2,
+			want: []string{"fd00:100::3", "fd00:100::4"},
+		},
+		{
+			name:   "IPv6 has no broadcast address",
+			prefix: "fd00:100::/126", limit: 5,
+			want: []string{"fd00:100::1", "fd00:100::2", "fd00:100::3"},
+		},
+		{
+			name:   "full prefix",
+			prefix: "100.100.100.0/30", allocated: []string{"100.100.100.1", "100.100.100.2"}, limit: 1,
+			want: nil,
+		},
+	}
+
+	a := &Allocator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocatedMap := make(map[string]bool, len(tt.allocated))
+			for _, ip := range tt.allocated {
+				allocatedMap[ip] = true
+			}
+			got := a.findAvailableIPs(netip.MustParsePrefix(tt.prefix), tt.serverIP, allocatedMap, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findAvailableIPs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindAvailablePrefixes(t *testing.T) {
+	tests := []struct {
+		name      string
+		pool      string
+		bits      int
+		serverIP  string
+		allocated []string
+		limit     int
+		want      []string
+	}{
+		{
+			name: "first prefixes hold the network address and the server IP",
+			pool: "100.100.100.0/24", bits: 29, serverIP: "100.100.100.1", limit: 2,
+			want: []string{"100.100.100.8/29", "100.100.100.16/29"},
+		},
+		{
+			name: "allocated prefixes and addresses are skipped",
+			pool: "100.100.100.0/24", bits: 29, serverIP: "100.100.100.1", allocated: []string{"100.100.100.8/29", "100.100.100.17/32"}, limit: 1,
+			want: []string{"100.100.100.24/29"},
+		},
+		{
+			name: "last prefix holds the broadcast address",
+			pool: "100.100.100.0/27", bits: 29, limit: 5,
+			want: []string{"100.100.100.8/29", "100.100.100.16/29"},
+		},
+		{
+			name: "IPv6 delegation",
+			pool: "fd00:100::/48", bits: 64, serverIP: "fd00:100::1", limit: 2,
+			want: []string{"fd00:100:0:1::/64", "fd00:100:0:2::/64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocated := make([]netip.Prefix, 0, len(tt.allocated))
+			for _, cidr := range tt.allocated {
+				allocated = append(allocated, netip.MustParsePrefix(cidr))
+			}
+			prefixes := findAvailablePrefixes(netip.MustParsePrefix(tt.pool), tt.bits, tt.serverIP, allocated, tt.limit)
+			got := make([]string, 0, len(prefixes))
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findAvailablePrefixes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPrefixAvailable(t *testing.T) {
+	pool := netip.MustParsePrefix("100.100.100.0/24")
+	allocated := []netip.Prefix{netip.MustParsePrefix("100.100.100.16/29"), netip.MustParsePrefix("100.100.100.42/32")}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		wantCode int
+	}{
+		{name: "free and aligned", prefix: "100.100.100.8/29"},
+		{name: "not aligned", prefix: "100.100.100.9/29", wantCode: code.ErrValidation},
+		{name: "network address", prefix: "100.100.100.0/29", wantCode: code.ErrIPIsNetworkAddress},
+		{name: "broadcast address", prefix: "100.100.100.248/29", wantCode: code.ErrIPIsBroadcastAddress},
+		{name: "server IP", prefix: "100.100.100.96/29", wantCode: code.ErrIPIsServerIP},
+		{name: "allocated prefix", prefix: "100.100.100.16/29", wantCode: code.ErrIPAlreadyInUse},
+		{name: "allocated address", prefix: "100.100.100.40/29", wantCode: code.ErrIPAlreadyInUse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPrefixAvailable(netip.MustParsePrefix(tt.prefix), pool, "100.100.100.97", allocated)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("checkPrefixAvailable(%s) error = %v", tt.prefix, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("checkPrefixAvailable(%s) error = nil, want code %d", tt.prefix, tt.wantCode)
+			}
+			if got := errors.ParseCoder(err).Code(); got != tt.wantCode {
+				t.Errorf("checkPrefixAvailable(%s) error code = %d, want %d", tt.prefix, got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPoolPrefixLength(t *testing.T) {
+	pool := &model.IPPool{PrefixLength: 29}
+	tests := []struct {
+		prefix string
+		want   int
+	}{
+		{prefix: "100.100.100.0/24", want: 29},
+		{prefix: "fd00:100::/64", want: 128},
+	}
+	for _, tt := range tests {
+		if got := PoolPrefixLength(pool, netip.MustParsePrefix(tt.prefix)); got != tt.want {
+			t.Errorf("PoolPrefixLength(%s) = %d, want %d", tt.prefix, got, tt.want)
+		}
+	}
+
+	pool = &model.IPPool{PrefixLengthV6: 64}
+	if got := PoolPrefixLength(pool, netip.MustParsePrefix("fd00:100::/48")); got != 64 {
+		t.Errorf("PoolPrefixLength(fd00:100::/48) = %d, want 64", got)
+	}
+	if got := PoolPrefixLength(pool, netip.MustParsePrefix("100.100.100.0/24")); got != 32 {
+		t.Errorf("PoolPrefixLength(100.100.100.0/24) = %d, want 32", got)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 	"github.com/HappyLadySauce/NexusPointWG/internal/store"
@@ -18,6 +19,9 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// ExternalDeviceNamePrefix 是从配置文件导入的外部 Peer 的设备名前缀，其后为配置文件中的注释
+const ExternalDeviceNamePrefix = "[External] "
+
 // SyncAllFromConfigFiles 从 WireGuard 根目录下的所有配置文件同步 Peer 和 IP 分配信息到数据库
 func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) error {
 	cfg := config.Get()
@@ -27,7 +31,7 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 	}
 
 	// 扫描所有配置文件
-	configFiles, err := scanConfigFiles(cfg.WireGuard.RootDir)
+	configFiles, err := ScanConfigFiles(cfg.WireGuard.RootDir)
 	if err != nil {
 		klog.V(1).InfoS("Failed to scan config files", "error", err)
 		return errors.Wrap(err, "failed to scan config files")
@@ -47,14 +51,12 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 		peersDisabled  int
 		ipAllocsSynced int
 		poolsCreated   int
+		peersRevoked   int
 		skipped        int
 	}{}
 
 	// 获取数据库中所有活跃的 Peer（用于对比）
-	dbPeers, _, err := storeFactory.WGPeers().ListPeers(ctx, store.WGPeerListOptions{
-		Status: model.WGPeerStatusActive,
-		Limit:  10000, // 获取所有活跃的 Peer
-	})
+	dbPeers, err := listActivePeers(ctx, storeFactory)
 	if err != nil {
 		klog.V(1).InfoS("Failed to list peers from database", "error", err)
 		return errors.Wrap(err, "failed to list peers from database")
@@ -68,24 +70,59 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 
 	// 收集所有配置文件中的 Peer
 	configPeerMap := make(map[string]*wireguard.ServerPeerConfig)
-	configPeerFiles := make(map[string]string) // PublicKey -> config file path
+	configPeerFiles := make(map[string]string)  // PublicKey -> config file path
+	configPeerIfaces := make(map[string]string) // PublicKey -> interface ID
+	configFileIfaces := make(map[string]string) // config file path -> interface ID
 
 	// 遍历所有配置文件
 	for _, configPath := range configFiles {
-		configManager := wireguard.NewServerConfigManager(configPath, cfg.WireGuard.ApplyMethod)
+		configManager := wireguard.GetServerConfigManager(configPath, cfg.WireGuard.ApplyMethod)
 		serverConfig, err := configManager.ReadServerConfig()
 		if err != nil {
 			klog.V(1).InfoS("Failed to read config file", "path", configPath, "error", err)
 			continue
 		}
 
+		// 每个配置文件对应一个 WireGuard 接口（文件名即接口名）
+		iface, err := FindOrCreateInterface(ctx, storeFactory, configManager.InterfaceName())
+		if err != nil {
+			klog.V(1).InfoS("Failed to find or create interface", "path", configPath, "error", err)
+			continue
+		}
+		configFileIfaces[configPath] = iface.ID
+
 		// 收集该配置文件中的所有 Peer
+		revokedRemoved := false
 		for _, peer := range serverConfig.Peers {
 			if peer.PublicKey == "" {
 				continue
 			}
+			// 已吊销的公钥不允许重新加入，从配置文件中移除
+			revoked, err := storeFactory.Revocations().IsKeyRevoked(ctx, peer.PublicKey)
+			if err != nil {
+				klog.V(1).InfoS("Failed to check key revocation", "publicKey", peer.PublicKey[:10]+"...", "error", err)
+				continue
+			}
+			if revoked {
+				revisionCtx := wireguard.WithRevisionInfo(ctx, wireguard.RevisionInfo{Actor: "sync", Action: "remove revoked peer"})
+				if err := configManager.RemovePeer(revisionCtx, peer.PublicKey); err != nil {
+					klog.V(1).InfoS("Failed to remove revoked peer from config file", "path", configPath, "publicKey", peer.PublicKey[:10]+"...", "error", err)
+					stats.skipped++
+					continue
+				}
+				revokedRemoved = true
+				stats.peersRevoked++
+				klog.V(1).InfoS("Removed revoked peer from config file", "path", configPath, "publicKey", peer.PublicKey[:10]+"...")
+				continue
+			}
 			configPeerMap[peer.PublicKey] = peer
 			configPeerFiles[peer.PublicKey] = configPath
+			configPeerIfaces[peer.PublicKey] = iface.ID
+		}
+		if revokedRemoved {
+			if _, err := configManager.ApplyConfig(); err != nil {
+				klog.V(1).InfoS("Failed to apply config file", "path", configPath, "error", err)
+			}
 		}
 	}
 
@@ -93,7 +130,7 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 	for publicKey, configPeer := range configPeerMap {
 		if _, exists := dbPeerMap[publicKey]; !exists {
 			// 创建外部添加的 Peer
-			if err := createExternalPeer(ctx, storeFactory, configPeer, configPeerFiles[publicKey], cfg); err != nil {
+			if err := CreateExternalPeer(ctx, storeFactory, configPeer, configPeerFiles[publicKey], configPeerIfaces[publicKey], cfg); err != nil {
 				klog.V(1).InfoS("Failed to create external peer", "publicKey", publicKey[:10]+"...", "error", err)
 				stats.skipped++
 				continue
@@ -119,7 +156,11 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 
 	// 3. 同步所有配置文件中的 Peer 的 IP 分配信息
 	for _, configPath := range configFiles {
-		configManager := wireguard.NewServerConfigManager(configPath, cfg.WireGuard.ApplyMethod)
+		interfaceID, ok := configFileIfaces[configPath]
+		if !ok {
+			continue
+		}
+		configManager := wireguard.GetServerConfigManager(configPath, cfg.WireGuard.ApplyMethod)
 		serverConfig, err := configManager.ReadServerConfig()
 		if err != nil {
 			klog.V(1).InfoS("Failed to read config file for IP sync", "path", configPath, "error", err)
@@ -132,17 +173,18 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 			}
 
 			// 同步该 Peer 的 IP 分配信息
-			if synced, err := syncPeerIPAllocation(ctx, storeFactory, peer); err != nil {
+			if synced, err := SyncPeerIPAllocation(ctx, storeFactory, peer, interfaceID); err != nil {
 				klog.V(1).InfoS("Failed to sync IP allocation", "publicKey", peer.PublicKey[:10]+"...", "error", err)
 				stats.skipped++
 			} else if synced {
 				stats.ipAllocsSynced++
 			}
 
-			// 更新 Peer 状态为 active（如果之前被禁用）
+			// 更新 Peer 状态为 active（如果之前被禁用），并绑定到配置文件所属的接口
 			dbPeer, err := storeFactory.WGPeers().GetPeerByPublicKey(ctx, peer.PublicKey)
-			if err == nil && dbPeer != nil && dbPeer.Status != model.WGPeerStatusActive {
+			if err == nil && dbPeer != nil && (dbPeer.Status != model.WGPeerStatusActive || dbPeer.InterfaceID != interfaceID) {
 				dbPeer.Status = model.WGPeerStatusActive
+				dbPeer.InterfaceID = interfaceID
 				if err := storeFactory.WGPeers().UpdatePeer(ctx, dbPeer); err == nil {
 					stats.peersUpdated++
 				}
@@ -154,6 +196,7 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 		"peersCreated", stats.peersCreated,
 		"peersUpdated", stats.peersUpdated,
 		"peersDisabled", stats.peersDisabled,
+		"peersRevoked", stats.peersRevoked,
 		"ipAllocsSynced", stats.ipAllocsSynced,
 		"poolsCreated", stats.poolsCreated,
 		"skipped", stats.skipped)
@@ -161,8 +204,8 @@ func SyncAllFromConfigFiles(ctx context.Context, storeFactory store.Factory) err
 	return nil
 }
 
-// scanConfigFiles 扫描 WireGuard 根目录下的所有 .conf 文件（排除 .backup 文件）
-func scanConfigFiles(rootDir string) ([]string, error) {
+// ScanConfigFiles 扫描 WireGuard 根目录下的所有 .conf 文件（排除 .backup 文件）
+func ScanConfigFiles(rootDir string) ([]string, error) {
 	var configFiles []string
 
 	entries, err := os.ReadDir(rootDir)
@@ -189,8 +232,8 @@ func scanConfigFiles(rootDir string) ([]string, error) {
 	return configFiles, nil
 }
 
-// inferCIDRFromIP 根据 IP 地址推断 CIDR（默认使用 /24 子网）
-// 例如: "100.100.100.2" -> "100.100.100.0/24"
+// inferCIDRFromIP 根据 IP 地址推断 CIDR（IPv4 默认使用 /24 子网，IPv6 默认使用 /64 子网）
+// 例如: "100.100.100.2" -> "100.100.100.0/24", "fd00::2" -> "fd00::/64"
 func inferCIDRFromIP(ipStr string) (string, error) {
 	ip := net.ParseIP(ipStr)
 	if ip == nil {
@@ -199,7 +242,11 @@ func inferCIDRFromIP(ipStr string) (string, error) {
 
 	ipv4 := ip.To4()
 	if ipv4 == nil {
-		return "", fmt.Errorf("not an IPv4 address: %s", ipStr)
+		_, ipNet, err := net.ParseCIDR(ipStr + "/64")
+		if err != nil {
+			return "", fmt.Errorf("invalid IPv6 address: %s", ipStr)
+		}
+		return ipNet.String(), nil
 	}
 
 	// 使用 /24 子网（最常见的 WireGuard 配置）
@@ -212,13 +259,46 @@ func inferCIDRFromIP(ipStr string) (string, error) {
 	return cidr, nil
 }
 
-// findOrCreateIPPool 查找或创建 IP Pool
+// FindOrCreateInterface 按名称查找或创建 WireGuard 接口记录
+func FindOrCreateInterface(ctx context.Context, storeFactory store.Factory, name string) (*model.WGInterface, error) {
+	iface, err := storeFactory.WGInterfaces().GetInterfaceByName(ctx, name)
+	if err == nil {
+		return iface, nil
+	}
+	if errors.ParseCoder(err).Code() != code.ErrWGInterfaceNotFound {
+		return nil, errors.Wrap(err, "failed to get interface")
+	}
+
+	if err := wireguard.ValidateInterfaceName(name); err != nil {
+		return nil, err
+	}
+
+	interfaceID, err := snowflake.GenerateID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate interface ID")
+	}
+
+	iface = &model.WGInterface{
+		ID:          interfaceID,
+		Name:        name,
+		Description: "Auto-created from config sync",
+		Status:      model.WGInterfaceStatusActive,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := storeFactory.WGInterfaces().CreateInterface(ctx, iface); err != nil {
+		return nil, errors.Wrap(err, "failed to create interface")
+	}
+
+	klog.V(1).InfoS("Auto-created WireGuard interface", "interfaceID", interfaceID, "name", name)
+	return iface, nil
+}
+
+// findOrCreateIPPool 查找或创建 IP Pool（未绑定接口的 Pool 会绑定到 interfaceID）
 // 返回 Pool 和是否为新创建的标志
-func findOrCreateIPPool(ctx context.Context, storeFactory store.Factory, ipAddr string, cfg *config.Config) (*model.IPPool, bool, error) {
+func findOrCreateIPPool(ctx context.Context, storeFactory store.Factory, ipAddr, interfaceID string, cfg *config.Config) (*model.IPPool, bool, error) {
 	// 获取所有活跃的 IP Pools
-	pools, _, err := storeFactory.IPPools().ListIPPools(ctx, store.IPPoolListOptions{
-		Status: model.IPPoolStatusActive,
-	})
+	pools, err := listActiveIPPools(ctx, storeFactory)
 	if err != nil {
 		return nil, false, errors.Wrap(err, "failed to list IP pools")
 	}
@@ -226,6 +306,7 @@ func findOrCreateIPPool(ctx context.Context, storeFactory store.Factory, ipAddr
 	// 查找匹配的 Pool
 	for _, pool := range pools {
 		if ipInCIDR(ipAddr, pool.CIDR) {
+			bindIPPoolToInterface(ctx, storeFactory, pool, interfaceID)
 			return pool, false, nil
 		}
 	}
@@ -246,6 +327,7 @@ func findOrCreateIPPool(ctx context.Context, storeFactory store.Factory, ipAddr
 				klog.V(1).InfoS("Failed to update pool status", "poolID", existingPool.ID, "error", err)
 			}
 		}
+		bindIPPoolToInterface(ctx, storeFactory, existingPool, interfaceID)
 		return existingPool, false, nil
 	}
 
@@ -256,7 +338,7 @@ func findOrCreateIPPool(ctx context.Context, storeFactory store.Factory, ipAddr
 	}
 
 	// 生成 Pool 名称（使用 CIDR 作为名称的一部分，确保唯一性）
-	poolName := fmt.Sprintf("auto-%s", strings.ReplaceAll(inferredCIDR, "/", "-"))
+	poolName := AutoIPPoolName(inferredCIDR)
 
 	// 使用全局配置的默认值
 	routes := inferredCIDR
@@ -271,6 +353,7 @@ func findOrCreateIPPool(ctx context.Context, storeFactory store.Factory, ipAddr
 		DNS:         dns,
 		Endpoint:    endpoint,
 		Description: "Auto-created from config sync",
+		InterfaceID: interfaceID,
 		Status:      model.IPPoolStatusActive,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
@@ -284,8 +367,46 @@ func findOrCreateIPPool(ctx context.Context, storeFactory store.Factory, ipAddr
 	return pool, true, nil
 }
 
-// createExternalPeer 创建外部添加的 Peer（配置文件中存在但数据库中没有）
-func createExternalPeer(ctx context.Context, storeFactory store.Factory, configPeer *wireguard.ServerPeerConfig, configPath string, cfg *config.Config) error {
+// PlannedIPPool 返回导入地址 ipAddr 时使用的 IP Pool 的 CIDR，以及该 Pool 是否需要自动创建（只读，不修改数据库）
+func PlannedIPPool(ctx context.Context, storeFactory store.Factory, ipAddr string) (string, bool, error) {
+	pools, err := listActiveIPPools(ctx, storeFactory)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to list IP pools")
+	}
+	for _, pool := range pools {
+		if ipInCIDR(ipAddr, pool.CIDR) {
+			return pool.CIDR, false, nil
+		}
+	}
+
+	inferredCIDR, err := inferCIDRFromIP(ipAddr)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to infer CIDR from IP")
+	}
+	if existingPool, err := storeFactory.IPPools().GetIPPoolByCIDR(ctx, inferredCIDR); err == nil && existingPool != nil {
+		return inferredCIDR, false, nil
+	}
+	return inferredCIDR, true, nil
+}
+
+// AutoIPPoolName 返回自动创建的 IP Pool 的名称（例如 "100.100.100.0/24" -> "auto-100.100.100.0-24"）
+func AutoIPPoolName(cidr string) string {
+	return fmt.Sprintf("auto-%s", strings.ReplaceAll(cidr, "/", "-"))
+}
+
+// bindIPPoolToInterface 将未绑定接口的 IP Pool 绑定到指定接口
+func bindIPPoolToInterface(ctx context.Context, storeFactory store.Factory, pool *model.IPPool, interfaceID string) {
+	if pool.InterfaceID != "" || interfaceID == "" {
+		return
+	}
+	pool.InterfaceID = interfaceID
+	if err := storeFactory.IPPools().UpdateIPPool(ctx, pool); err != nil {
+		klog.V(1).InfoS("Failed to bind pool to interface", "poolID", pool.ID, "interfaceID", interfaceID, "error", err)
+	}
+}
+
+// CreateExternalPeer 创建外部添加的 Peer（配置文件中存在但数据库中没有）
+func CreateExternalPeer(ctx context.Context, storeFactory store.Factory, configPeer *wireguard.ServerPeerConfig, configPath, interfaceID string, cfg *config.Config) error {
 	if configPeer.PublicKey == "" || configPeer.AllowedIPs == "" {
 		return fmt.Errorf("invalid peer config: missing PublicKey or AllowedIPs")
 	}
@@ -297,7 +418,7 @@ func createExternalPeer(ctx context.Context, storeFactory store.Factory, configP
 	}
 
 	// 查找或创建 IP Pool
-	pool, created, err := findOrCreateIPPool(ctx, storeFactory, ipAddr, cfg)
+	pool, created, err := findOrCreateIPPool(ctx, storeFactory, ipAddr, interfaceID, cfg)
 	if err != nil {
 		return errors.Wrap(err, "failed to find or create IP pool")
 	}
@@ -321,12 +442,12 @@ func createExternalPeer(ctx context.Context, storeFactory store.Factory, configP
 	if deviceName == "" {
 		// 使用 PublicKey 的前 8 个字符作为设备名
 		if len(configPeer.PublicKey) > 8 {
-			deviceName = "[External] " + configPeer.PublicKey[:8]
+			deviceName = ExternalDeviceNamePrefix + configPeer.PublicKey[:8]
 		} else {
-			deviceName = "[External] " + configPeer.PublicKey
+			deviceName = ExternalDeviceNamePrefix + configPeer.PublicKey
 		}
 	} else {
-		deviceName = "[External] " + deviceName
+		deviceName = ExternalDeviceNamePrefix + deviceName
 	}
 
 	// 生成 Peer ID
@@ -349,6 +470,7 @@ func createExternalPeer(ctx context.Context, storeFactory store.Factory, configP
 		DeviceName:          deviceName,
 		ClientPrivateKey:    "[external-managed]", // 外部管理的 Peer 没有 PrivateKey
 		ClientPublicKey:     configPeer.PublicKey,
+		PresharedKey:        configPeer.PresharedKey,
 		ClientIP:            clientIPCIDR,
 		AllowedIPs:          configPeer.AllowedIPs,
 		DNS:                 "",
@@ -356,6 +478,7 @@ func createExternalPeer(ctx context.Context, storeFactory store.Factory, configP
 		PersistentKeepalive: configPeer.PersistentKeepalive,
 		Status:              model.WGPeerStatusActive,
 		IPPoolID:            pool.ID,
+		InterfaceID:         interfaceID,
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 	}
@@ -380,7 +503,7 @@ func createExternalPeer(ctx context.Context, storeFactory store.Factory, configP
 		UpdatedAt: time.Now(),
 	}
 
-	if err := storeFactory.IPAllocations().CreateIPAllocation(ctx, allocation); err != nil {
+	if err := NewAllocator(storeFactory).RecordAllocation(ctx, allocation); err != nil {
 		// 回滚：删除 Peer
 		_ = storeFactory.WGPeers().DeletePeer(ctx, peerID)
 		return errors.Wrap(err, "failed to create IP allocation")
@@ -390,8 +513,8 @@ func createExternalPeer(ctx context.Context, storeFactory store.Factory, configP
 	return nil
 }
 
-// syncPeerIPAllocation 同步单个 Peer 的 IP 分配信息
-func syncPeerIPAllocation(ctx context.Context, storeFactory store.Factory, configPeer *wireguard.ServerPeerConfig) (bool, error) {
+// SyncPeerIPAllocation 同步单个 Peer 的 IP 分配信息
+func SyncPeerIPAllocation(ctx context.Context, storeFactory store.Factory, configPeer *wireguard.ServerPeerConfig, interfaceID string) (bool, error) {
 	if configPeer.PublicKey == "" || configPeer.AllowedIPs == "" {
 		return false, nil
 	}
@@ -405,7 +528,7 @@ func syncPeerIPAllocation(ctx context.Context, storeFactory store.Factory, confi
 	// 通过 PublicKey 查找 Peer
 	dbPeer, err := storeFactory.WGPeers().GetPeerByPublicKey(ctx, configPeer.PublicKey)
 	if err != nil || dbPeer == nil {
-		// Peer 不存在，跳过（应该已经在 createExternalPeer 中处理）
+		// Peer 不存在，跳过（应该已经在 CreateExternalPeer 中处理）
 		return false, nil
 	}
 
@@ -416,16 +539,26 @@ func syncPeerIPAllocation(ctx context.Context, storeFactory store.Factory, confi
 	}
 
 	// 查找或创建 IP Pool
-	pool, _, err := findOrCreateIPPool(ctx, storeFactory, ipAddr, cfg)
+	pool, _, err := findOrCreateIPPool(ctx, storeFactory, ipAddr, interfaceID, cfg)
 	if err != nil {
 		return false, errors.Wrap(err, "failed to find or create IP pool")
 	}
 
-	// 检查 IPAllocation 是否存在
-	existing, _ := storeFactory.IPAllocations().GetIPAllocationByPeerID(ctx, dbPeer.ID)
+	// 检查 IPAllocation 是否存在（双栈 Peer 有多条记录，只匹配同一地址族的记录）
+	var existing *model.IPAllocation
+	allocations, _ := storeFactory.IPAllocations().GetIPAllocationsByPeerID(ctx, dbPeer.ID)
+	for _, allocation := range allocations {
+		if sameIPFamily(allocation.IPAddress, ipAddr) {
+			existing = allocation
+			break
+		}
+	}
 	if existing != nil {
 		// 已存在，检查是否需要更新
 		if existing.IPAddress != ipAddr || existing.IPPoolID != pool.ID {
+			if existing.IPAddress != ipAddr {
+				replacePeerClientIP(ctx, storeFactory, dbPeer, existing.IPAddress, ipAddr)
+			}
 			existing.IPAddress = ipAddr
 			existing.IPPoolID = pool.ID
 			existing.Status = model.IPAllocationStatusAllocated
@@ -454,16 +587,20 @@ func syncPeerIPAllocation(ctx context.Context, storeFactory store.Factory, confi
 		UpdatedAt: time.Now(),
 	}
 
-	if err := storeFactory.IPAllocations().CreateIPAllocation(ctx, allocation); err != nil {
+	if err := NewAllocator(storeFactory).RecordAllocation(ctx, allocation); err != nil {
 		return false, errors.Wrap(err, "failed to create IP allocation")
 	}
 
 	// 更新 Peer 的 IPPoolID（如果不同）
 	if dbPeer.IPPoolID != pool.ID {
 		dbPeer.IPPoolID = pool.ID
-		clientIPCIDR, err := FormatIPAsCIDR(ipAddr)
-		if err == nil {
-			dbPeer.ClientIP = clientIPCIDR
+		// 仅在 ClientIP 不包含该地址时覆盖，避免丢失双栈 Peer 的另一地址族
+		existingIPs, _ := ExtractIPsFromCIDRs(dbPeer.ClientIP)
+		if !containsString(existingIPs, ipAddr) {
+			clientIPCIDR, err := FormatIPAsCIDR(ipAddr)
+			if err == nil {
+				dbPeer.ClientIP = clientIPCIDR
+			}
 		}
 		if err := storeFactory.WGPeers().UpdatePeer(ctx, dbPeer); err != nil {
 			klog.V(1).InfoS("Failed to update peer IPPoolID", "peerID", dbPeer.ID, "error", err)
@@ -473,6 +610,37 @@ func syncPeerIPAllocation(ctx context.Context, storeFactory store.Factory, confi
 	return true, nil
 }
 
+// replacePeerClientIP 将 Peer ClientIP 中的旧地址替换为配置文件中的新地址（保留另一地址族的地址）
+func replacePeerClientIP(ctx context.Context, storeFactory store.Factory, dbPeer *model.WGPeer, oldIP, newIP string) {
+	ips, err := ExtractIPsFromCIDRs(dbPeer.ClientIP)
+	if err != nil {
+		ips = nil
+	}
+	replaced := false
+	for i, ip := range ips {
+		if ip == oldIP {
+			ips[i] = newIP
+			replaced = true
+		}
+	}
+	if !replaced {
+		ips = []string{newIP}
+	}
+	clientIP, err := FormatIPsAsCIDR(ips)
+	if err != nil {
+		return
+	}
+	dbPeer.ClientIP = clientIP
+	if err := storeFactory.WGPeers().UpdatePeer(ctx, dbPeer); err != nil {
+		klog.V(1).InfoS("Failed to update peer ClientIP", "peerID", dbPeer.ID, "error", err)
+	}
+}
+
+// ConfigPeerAddress 返回配置文件中 Peer 同步到数据库的地址（AllowedIPs 中的第一个地址）
+func ConfigPeerAddress(allowedIPs string) string {
+	return extractIPFromCIDR(allowedIPs)
+}
+
 // extractIPFromCIDR 从 CIDR 格式提取 IP 地址
 // 例如: "100.100.100.2/32" -> "100.100.100.2"
 func extractIPFromCIDR(cidr string) string {
@@ -496,19 +664,28 @@ func extractIPFromCIDR(cidr string) string {
 	return ipStr
 }
 
-// ipInCIDR 检查 IP 是否在 CIDR 范围内
+// ipInCIDR 检查 IP 是否在 CIDR 范围内（支持双栈地址池 CIDR）
 func ipInCIDR(ipStr, cidr string) bool {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return false
-	}
+	return ValidateIPInCIDR(ipStr, cidr) == nil
+}
 
-	_, ipNet, err := net.ParseCIDR(cidr)
-	if err != nil {
+// sameIPFamily 检查两个 IP 是否属于同一地址族
+func sameIPFamily(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
 		return false
 	}
+	return (ipA.To4() == nil) == (ipB.To4() == nil)
+}
 
-	return ipNet.Contains(ip)
+// containsString 检查切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
 // SyncIPAllocationsFromConfig 保持向后兼容的旧函数名（已废弃，使用 SyncAllFromConfigFiles）
@@ -516,3 +693,39 @@ func ipInCIDR(ipStr, cidr string) bool {
 func SyncIPAllocationsFromConfig(ctx context.Context, storeFactory store.Factory) error {
 	return SyncAllFromConfigFiles(ctx, storeFactory)
 }
+
+// listActivePeers 分页获取数据库中所有活跃的 Peer（store 每页最多返回 200 条）
+func listActivePeers(ctx context.Context, storeFactory store.Factory) ([]*model.WGPeer, error) {
+	const pageSize = 200
+
+	var all []*model.WGPeer
+	opt := store.WGPeerListOptions{Status: model.WGPeerStatusActive, Limit: pageSize}
+	for opt.Offset = 0; ; opt.Offset += pageSize {
+		peers, total, err := storeFactory.WGPeers().ListPeers(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, peers...)
+		if len(peers) < pageSize || int64(len(all)) >= total {
+			return all, nil
+		}
+	}
+}
+
+// listActiveIPPools 分页获取数据库中所有活跃的 IP Pool（store 每页最多返回 200 条）
+func listActiveIPPools(ctx context.Context, storeFactory store.Factory) ([]*model.IPPool, error) {
+	const pageSize = 200
+
+	var all []*model.IPPool
+	opt := store.IPPoolListOptions{Status: model.IPPoolStatusActive, Limit: pageSize}
+	for opt.Offset = 0; ; opt.Offset += pageSize {
+		pools, total, err := storeFactory.IPPools().ListIPPools(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, pools...)
+		if len(pools) < pageSize || int64(len(all)) >= total {
+			return all, nil
+		}
+	}
+}
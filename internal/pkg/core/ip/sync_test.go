@@ -0,0 +1,106 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store/sqlite"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/options"
+)
+
+// newPoolStore returns a temp store holding count active /16 pools 10.0.0.0/16 … 10.<count-1>.0.0/16,
+// created in that order so that 10.0.0.0/16 is listed last.
+func newPoolStore(t *testing.T, count int) store.Factory {
+	t.Helper()
+	storeFactory, err := sqlite.NewSqliteFactory(&options.SqliteOptions{DataSourceName: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("NewSqliteFactory() error = %v", err)
+	}
+
+	created := time.Now().Add(-time.Hour)
+	for i := 0; i < count; i++ {
+		pool := &model.IPPool{
+			ID:        fmt.Sprintf("pool-%d", i),
+			Name:      fmt.Sprintf("pool-%d", i),
+			CIDR:      fmt.Sprintf("10.%d.0.0/16", i),
+			Status:    model.IPPoolStatusActive,
+			CreatedAt: created.Add(time.Duration(i) * time.Second),
+			UpdatedAt: created,
+		}
+		if err := storeFactory.IPPools().CreateIPPool(context.Background(), pool); err != nil {
+			t.Fatalf("CreateIPPool() error = %v", err)
+		}
+	}
+	return storeFactory
+}
+
+func TestPlannedIPPool(t *testing.T) {
+	// More pools than the store returns by default, the oldest one is on the second page
+	storeFactory := newPoolStore(t, 25)
+
+	tests := []struct {
+		name           string
+		ipAddr         string
+		wantCIDR       string
+		wantAutoCreate bool
+	}{
+		{name: "newest pool", ipAddr: "10.24.3.5", wantCIDR: "10.24.0.0/16"},
+		{name: "oldest pool", ipAddr: "10.0.3.5", wantCIDR: "10.0.0.0/16"},
+		{name: "no pool", ipAddr: "192.168.7.5", wantCIDR: "192.168.7.0/24", wantAutoCreate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cidr, autoCreate, err := PlannedIPPool(context.Background(), storeFactory, tt.ipAddr)
+			if err != nil {
+				t.Fatalf("PlannedIPPool() error = %v", err)
+			}
+			if cidr != tt.wantCIDR || autoCreate != tt.wantAutoCreate {
+				t.Errorf("PlannedIPPool(%s) = %s, %v, want %s, %v", tt.ipAddr, cidr, autoCreate, tt.wantCIDR, tt.wantAutoCreate)
+			}
+		})
+	}
+}
+
+func TestFindOrCreateIPPool(t *testing.T) {
+	cfg := &config.Config{WireGuard: &options.WireGuardOptions{}}
+
+	tests := []struct {
+		name        string
+		ipAddr      string
+		wantCIDR    string
+		wantCreated bool
+	}{
+		{name: "oldest pool", ipAddr: "10.0.3.5", wantCIDR: "10.0.0.0/16"},
+		{name: "no pool", ipAddr: "192.168.7.5", wantCIDR: "192.168.7.0/24", wantCreated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storeFactory := newPoolStore(t, 25)
+
+			pool, created, err := findOrCreateIPPool(context.Background(), storeFactory, tt.ipAddr, "wg0-id", cfg)
+			if err != nil {
+				t.Fatalf("findOrCreateIPPool() error = %v", err)
+			}
+			if pool.CIDR != tt.wantCIDR || created != tt.wantCreated {
+				t.Errorf("findOrCreateIPPool(%s) = %s, %v, want %s, %v", tt.ipAddr, pool.CIDR, created, tt.wantCIDR, tt.wantCreated)
+			}
+
+			// The pool is bound to the interface of the imported peer
+			stored, err := storeFactory.IPPools().GetIPPoolByCIDR(context.Background(), tt.wantCIDR)
+			if err != nil {
+				t.Fatalf("GetIPPoolByCIDR() error = %v", err)
+			}
+			if stored.InterfaceID != "wg0-id" {
+				t.Errorf("pool %s is bound to %q, want wg0-id", stored.CIDR, stored.InterfaceID)
+			}
+		})
+	}
+}
@@ -3,8 +3,11 @@ package ip
 import (
 	"fmt"
 	"net"
+	"net/netip"
+	"strings"
 
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	iputil "github.com/HappyLadySauce/NexusPointWG/pkg/utils/ip"
 	"github.com/HappyLadySauce/errors"
 )
 
@@ -20,71 +23,218 @@ func ValidateIPv4(ipStr string) error {
 	return nil
 }
 
+// ValidateIP validates that the given IP address is a valid IPv4 or IPv6 address.
+func ValidateIP(ipStr string) error {
+	if _, err := netip.ParseAddr(ipStr); err != nil {
+		return errors.WithCode(code.ErrValidation, "invalid IP address format: %s", ipStr)
+	}
+	return nil
+}
+
+// ValidateIPList validates a list of IP addresses holding at most one address per family.
+func ValidateIPList(ips []string) error {
+	seen := make(map[bool]string, 2)
+	for _, ipStr := range ips {
+		addr, err := netip.ParseAddr(ipStr)
+		if err != nil {
+			return errors.WithCode(code.ErrValidation, "invalid IP address format: %s", ipStr)
+		}
+		if other, ok := seen[addr.Is4()]; ok {
+			return errors.WithCode(code.ErrIPFamilyDuplicate, "IP addresses %s and %s belong to the same address family", other, ipStr)
+		}
+		seen[addr.Is4()] = ipStr
+	}
+	return nil
+}
+
+// SplitIPList splits a comma-separated list of IP addresses, skipping empty entries.
+func SplitIPList(s string) []string {
+	var ips []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			ips = append(ips, part)
+		}
+	}
+	return ips
+}
+
+// ParsePoolCIDR parses an IP pool CIDR, which is either a single IPv4/IPv6 CIDR
+// or a dual-stack pair such as "100.100.100.0/24,fd00:100::/64".
+// The returned prefixes are masked, IPv4 first.
+func ParsePoolCIDR(cidrStr string) ([]netip.Prefix, error) {
+	var v4, v6 netip.Prefix
+	for _, part := range strings.Split(cidrStr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, errors.WithCode(code.ErrIPPoolInvalidCIDR, "invalid CIDR format: %s", cidrStr)
+		}
+		prefix = prefix.Masked()
+		if prefix.Addr().Is4() {
+			if v4.IsValid() {
+				return nil, errors.WithCode(code.ErrIPPoolInvalidCIDR, "only one IPv4 CIDR is allowed: %s", cidrStr)
+			}
+			v4 = prefix
+		} else {
+			if v6.IsValid() {
+				return nil, errors.WithCode(code.ErrIPPoolInvalidCIDR, "only one IPv6 CIDR is allowed: %s", cidrStr)
+			}
+			v6 = prefix
+		}
+	}
+
+	var prefixes []netip.Prefix
+	if v4.IsValid() {
+		prefixes = append(prefixes, v4)
+	}
+	if v6.IsValid() {
+		prefixes = append(prefixes, v6)
+	}
+	if len(prefixes) == 0 {
+		return nil, errors.WithCode(code.ErrIPPoolInvalidCIDR, "invalid CIDR format: %s", cidrStr)
+	}
+	return prefixes, nil
+}
+
+// IsPoolCIDRExtension reports whether newCIDR keeps every prefix of oldCIDR and only adds
+// an address family, e.g. "100.100.100.0/24" -> "100.100.100.0/24,fd00:100::/64".
+// Such a change is safe even when IPs are already allocated from the pool.
+func IsPoolCIDRExtension(oldCIDR, newCIDR string) bool {
+	oldPrefixes, err := ParsePoolCIDR(oldCIDR)
+	if err != nil {
+		return false
+	}
+	newPrefixes, err := ParsePoolCIDR(newCIDR)
+	if err != nil {
+		return false
+	}
+	for _, oldPrefix := range oldPrefixes {
+		found := false
+		for _, newPrefix := range newPrefixes {
+			if oldPrefix == newPrefix {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePoolPrefixLength validates the prefix lengths a pool delegates to each peer:
+// 0 (a single address) or longer than the pool prefix of the family, e.g. /29 out of a /24.
+func ValidatePoolPrefixLength(cidrStr string, prefixLength, prefixLengthV6 int) error {
+	prefixes, err := ParsePoolCIDR(cidrStr)
+	if err != nil {
+		return err
+	}
+	hasV4, hasV6 := false, false
+	for _, prefix := range prefixes {
+		length := prefixLength
+		if prefix.Addr().Is4() {
+			hasV4 = true
+		} else {
+			hasV6 = true
+			length = prefixLengthV6
+		}
+		if length == 0 {
+			continue
+		}
+		if length <= prefix.Bits() || length > prefix.Addr().BitLen() {
+			return errors.WithCode(code.ErrValidation, "prefix length /%d must be longer than /%d and at most /%d", length, prefix.Bits(), prefix.Addr().BitLen())
+		}
+	}
+	if prefixLength != 0 && !hasV4 {
+		return errors.WithCode(code.ErrValidation, "prefix_length requires an IPv4 CIDR: %s", cidrStr)
+	}
+	if prefixLengthV6 != 0 && !hasV6 {
+		return errors.WithCode(code.ErrValidation, "prefix_length_v6 requires an IPv6 CIDR: %s", cidrStr)
+	}
+	return nil
+}
+
+// poolPrefixForAddr returns the pool prefix with the same address family as addr.
+func poolPrefixForAddr(addr netip.Addr, cidrStr string) (netip.Prefix, error) {
+	prefixes, err := ParsePoolCIDR(cidrStr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	for _, prefix := range prefixes {
+		if prefix.Addr().Is4() == addr.Is4() {
+			return prefix, nil
+		}
+	}
+	return netip.Prefix{}, errors.WithCode(code.ErrIPFamilyNotInPool, "IP address %s has no matching address family in %s", addr, cidrStr)
+}
+
 // ValidateIPInCIDR validates that the given IP address is within the CIDR range.
+// cidrStr may be a dual-stack pool CIDR; the prefix of the IP's family is used.
 func ValidateIPInCIDR(ipStr, cidrStr string) error {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return errors.WithCode(code.ErrIPNotIPv4, "invalid IP address format: %s", ipStr)
-	}
-	if ip.To4() == nil {
-		return errors.WithCode(code.ErrIPNotIPv4, "IP address is not IPv4: %s", ipStr)
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return errors.WithCode(code.ErrValidation, "invalid IP address format: %s", ipStr)
 	}
 
-	_, ipNet, err := net.ParseCIDR(cidrStr)
+	prefix, err := poolPrefixForAddr(addr, cidrStr)
 	if err != nil {
-		return errors.WithCode(code.ErrIPPoolInvalidCIDR, "invalid CIDR format: %s", cidrStr)
+		return err
 	}
 
-	if !ipNet.Contains(ip) {
-		return errors.WithCode(code.ErrIPOutOfRange, "IP address %s is not in CIDR range %s", ipStr, cidrStr)
+	if !prefix.Contains(addr) {
+		return errors.WithCode(code.ErrIPOutOfRange, "IP address %s is not in CIDR range %s", ipStr, prefix)
 	}
 
 	return nil
 }
 
 // ValidateIPNotReserved validates that the IP address is not a reserved address.
-// Reserved addresses include: network address, broadcast address, and server IP.
+// Reserved addresses include: network address, broadcast address (IPv4 only), and server IP.
+// serverIPStr may contain one server tunnel IP per address family, comma-separated.
 func ValidateIPNotReserved(ipStr, cidrStr, serverIPStr string) error {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return errors.WithCode(code.ErrIPNotIPv4, "invalid IP address format: %s", ipStr)
-	}
-	if ip.To4() == nil {
-		return errors.WithCode(code.ErrIPNotIPv4, "IP address is not IPv4: %s", ipStr)
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return errors.WithCode(code.ErrValidation, "invalid IP address format: %s", ipStr)
 	}
 
-	_, ipNet, err := net.ParseCIDR(cidrStr)
+	prefix, err := poolPrefixForAddr(addr, cidrStr)
 	if err != nil {
-		return errors.WithCode(code.ErrIPPoolInvalidCIDR, "invalid CIDR format: %s", cidrStr)
+		return err
 	}
 
 	// Check if IP is network address (first IP in the network)
-	networkIP := ipNet.IP
-	if ip.Equal(networkIP) {
+	if addr == prefix.Addr() {
 		return errors.WithCode(code.ErrIPIsNetworkAddress, "IP address %s is the network address", ipStr)
 	}
 
-	// Check if IP is broadcast address (last IP in the network)
-	broadcastIP := make(net.IP, len(networkIP))
-	copy(broadcastIP, networkIP)
-	for i := range broadcastIP {
-		broadcastIP[i] |= ^ipNet.Mask[i]
-	}
-	if ip.Equal(broadcastIP) {
+	// Check if IP is broadcast address (last IP in the network, IPv4 only)
+	if addr.Is4() && addr == iputil.LastIPv4(prefix) {
 		return errors.WithCode(code.ErrIPIsBroadcastAddress, "IP address %s is the broadcast address", ipStr)
 	}
 
 	// Check if IP is server IP
-	if serverIPStr != "" {
-		serverIP := net.ParseIP(serverIPStr)
-		if serverIP != nil && ip.Equal(serverIP) {
-			return errors.WithCode(code.ErrIPIsServerIP, "IP address %s is the server IP", ipStr)
-		}
+	if isServerIP(addr, serverIPStr) {
+		return errors.WithCode(code.ErrIPIsServerIP, "IP address %s is the server IP", ipStr)
 	}
 
 	return nil
 }
 
+// isServerIP reports whether addr is one of the comma-separated server tunnel IPs.
+func isServerIP(addr netip.Addr, serverIPStr string) bool {
+	for _, serverIP := range SplitIPList(serverIPStr) {
+		if parsed, err := netip.ParseAddr(serverIP); err == nil && parsed == addr {
+			return true
+		}
+	}
+	return false
+}
+
 // ExtractIPFromCIDR extracts the IP address from a CIDR string (e.g., "100.100.100.2/32" -> "100.100.100.2").
 func ExtractIPFromCIDR(cidrStr string) (string, error) {
 	ipStr, _, err := net.ParseCIDR(cidrStr)
@@ -94,16 +244,45 @@ func ExtractIPFromCIDR(cidrStr string) (string, error) {
 	return ipStr.String(), nil
 }
 
-// FormatIPAsCIDR formats an IP address as a CIDR with /32 prefix (e.g., "100.100.100.2" -> "100.100.100.2/32").
+// ExtractIPsFromCIDRs extracts the IP addresses from a comma-separated CIDR list
+// (e.g., "100.100.100.2/32,fd00::2/128" -> ["100.100.100.2", "fd00::2"]).
+func ExtractIPsFromCIDRs(cidrs string) ([]string, error) {
+	var ips []string
+	for _, part := range strings.Split(cidrs, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		ipStr, err := ExtractIPFromCIDR(part)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, ipStr)
+	}
+	return ips, nil
+}
+
+// FormatIPAsCIDR formats an IP address as a host CIDR
+// (e.g., "100.100.100.2" -> "100.100.100.2/32", "fd00::2" -> "fd00::2/128").
 func FormatIPAsCIDR(ipStr string) (string, error) {
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		return "", errors.WithCode(code.ErrIPNotIPv4, "invalid IP address format: %s", ipStr)
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return "", errors.WithCode(code.ErrValidation, "invalid IP address format: %s", ipStr)
 	}
-	if ip.To4() == nil {
-		return "", errors.WithCode(code.ErrIPNotIPv4, "IP address is not IPv4: %s", ipStr)
+	return fmt.Sprintf("%s/%d", addr, addr.BitLen()), nil
+}
+
+// FormatIPsAsCIDR formats IP addresses as a comma-separated list of host CIDRs.
+func FormatIPsAsCIDR(ips []string) (string, error) {
+	cidrs := make([]string, 0, len(ips))
+	for _, ipStr := range ips {
+		cidr, err := FormatIPAsCIDR(ipStr)
+		if err != nil {
+			return "", err
+		}
+		cidrs = append(cidrs, cidr)
 	}
-	return fmt.Sprintf("%s/32", ipStr), nil
+	return strings.Join(cidrs, ","), nil
 }
 
 // ExtractIPFromEndpoint extracts the IP address from an endpoint string (e.g., "10.10.10.10:51820" -> "10.10.10.10").
@@ -0,0 +1,347 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// Supported values of wireguard.apply-method.
+const (
+	// ApplyMethodSystemctl reloads the wg-quick systemd unit.
+	ApplyMethodSystemctl = "systemctl"
+	// ApplyMethodSyncconf feeds the stripped config to `wg syncconf`, which only touches changed peers.
+	ApplyMethodSyncconf = "syncconf"
+	// ApplyMethodWgctrl programs the running device peer by peer through wgctrl (netlink on Linux).
+	ApplyMethodWgctrl = "wgctrl"
+	// ApplyMethodExec runs a user-defined hook (wireguard.apply-command).
+	ApplyMethodExec = "exec"
+	// ApplyMethodFake records apply requests without touching the system.
+	ApplyMethodFake = "fake"
+	// ApplyMethodNone only writes the config file.
+	ApplyMethodNone = "none"
+)
+
+// ApplyRequest describes a config to bring the running interface in line with.
+type ApplyRequest struct {
+	Interface  string
+	ConfigPath string
+	// Config is the desired configuration as written to ConfigPath.
+	Config *ServerConfig
+	// Previous is the last successfully applied configuration, nil if unknown (e.g. after startup).
+	Previous *ServerConfig
+}
+
+// ApplyResult reports what an applier actually changed.
+type ApplyResult struct {
+	Method    string   `json:"method"`
+	Interface string   `json:"interface"`
+	Added     []string `json:"added,omitempty"`   // Public keys of added peers
+	Removed   []string `json:"removed,omitempty"` // Public keys of removed peers
+	Updated   []string `json:"updated,omitempty"` // Public keys of peers whose settings changed
+	// InterfaceChanged indicates the [Interface] section changed.
+	InterfaceChanged bool `json:"interface_changed"`
+	// Restarted indicates the whole interface was reloaded, which may reset every peer's session.
+	Restarted bool `json:"restarted"`
+	// Exact is false when the previous state was unknown and the change set is a best guess.
+	Exact bool `json:"exact"`
+}
+
+// Changed reports whether anything was changed.
+func (r *ApplyResult) Changed() bool {
+	return r.InterfaceChanged || len(r.Added) > 0 || len(r.Removed) > 0 || len(r.Updated) > 0
+}
+
+// Applier applies a written server config to the running WireGuard interface.
+type Applier interface {
+	// Name returns the apply method name.
+	Name() string
+	Apply(req *ApplyRequest) (*ApplyResult, error)
+}
+
+// appliers holds the registered appliers by apply method name.
+var appliers sync.Map
+
+func init() {
+	RegisterApplier(systemctlApplier{})
+	RegisterApplier(syncconfApplier{})
+	RegisterApplier(wgctrlApplier{})
+	RegisterApplier(NewExecApplier(""))
+	RegisterApplier(NewFakeApplier())
+	RegisterApplier(noneApplier{})
+}
+
+// RegisterApplier registers an applier under its name, replacing any previous one.
+func RegisterApplier(a Applier) {
+	appliers.Store(a.Name(), a)
+}
+
+// GetApplier returns the applier registered for the apply method (systemctl if method is empty).
+func GetApplier(method string) (Applier, error) {
+	method = strings.ToLower(strings.TrimSpace(method))
+	if method == "" {
+		method = ApplyMethodSystemctl
+	}
+	a, ok := appliers.Load(method)
+	if !ok {
+		return nil, errors.WithCode(code.ErrWGApplyFailed, "unknown apply method: %s", method)
+	}
+	return a.(Applier), nil
+}
+
+// DiffServerConfigs compares two configurations and returns the peers and interface settings that differ.
+// A nil previous config means every peer in next is reported as added.
+func DiffServerConfigs(previous, next *ServerConfig) *ApplyResult {
+	result := &ApplyResult{Exact: previous != nil}
+	if previous == nil {
+		previous = &ServerConfig{}
+	}
+	if next == nil {
+		next = &ServerConfig{}
+	}
+
+	result.InterfaceChanged = previous.Interface != nil && next.Interface != nil &&
+		!reflect.DeepEqual(*previous.Interface, *next.Interface)
+
+	oldPeers := peersByPublicKey(previous.Peers)
+	newPeers := peersByPublicKey(next.Peers)
+	for publicKey, peer := range newPeers {
+		old, ok := oldPeers[publicKey]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, publicKey)
+		case !samePeerSettings(old, peer):
+			result.Updated = append(result.Updated, publicKey)
+		}
+	}
+	for publicKey := range oldPeers {
+		if _, ok := newPeers[publicKey]; !ok {
+			result.Removed = append(result.Removed, publicKey)
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Updated)
+	return result
+}
+
+func peersByPublicKey(peers []*ServerPeerConfig) map[string]*ServerPeerConfig {
+	m := make(map[string]*ServerPeerConfig, len(peers))
+	for _, peer := range peers {
+		if peer == nil || peer.PublicKey == "" {
+			continue
+		}
+		m[peer.PublicKey] = peer
+	}
+	return m
+}
+
+// samePeerSettings compares the settings WireGuard itself uses (comments are ignored).
+// The endpoint only counts if b sets a static one: WireGuard learns the endpoint of roaming peers
+// itself, so a running device reports one for peers that have none configured.
+func samePeerSettings(a, b *ServerPeerConfig) bool {
+	return a.PresharedKey == b.PresharedKey &&
+		(b.Endpoint == "" || a.Endpoint == b.Endpoint) &&
+		a.PersistentKeepalive == b.PersistentKeepalive &&
+		normalizeAllowedIPs(a.AllowedIPs) == normalizeAllowedIPs(b.AllowedIPs)
+}
+
+// normalizeAllowedIPs sorts and trims a comma-separated CIDR list so that ordering and spacing do not count as changes.
+func normalizeAllowedIPs(allowedIPs string) string {
+	parts := strings.Split(allowedIPs, ",")
+	cidrs := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			cidrs = append(cidrs, part)
+		}
+	}
+	sort.Strings(cidrs)
+	return strings.Join(cidrs, ",")
+}
+
+// runCommand runs a command and wraps failures as ErrWGApplyFailed.
+func runCommand(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return output, errors.WithCode(code.ErrWGApplyFailed, "%s %s failed: %s", name, strings.Join(args, " "), strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+// systemctlApplier reloads the wg-quick systemd unit of the interface.
+type systemctlApplier struct{}
+
+func (systemctlApplier) Name() string { return ApplyMethodSystemctl }
+
+func (systemctlApplier) Apply(req *ApplyRequest) (*ApplyResult, error) {
+	result := DiffServerConfigs(req.Previous, req.Config)
+	if _, err := runCommand("systemctl", "reload", fmt.Sprintf("wg-quick@%s", req.Interface)); err != nil {
+		return nil, err
+	}
+	result.Restarted = true
+	return result, nil
+}
+
+// syncconfApplier applies the config with `wg syncconf`, which adds, updates and removes
+// only the peers that differ from the running device and never drops existing sessions.
+// Address, MTU and hook changes in [Interface] are not applied and need an interface restart.
+type syncconfApplier struct{}
+
+func (syncconfApplier) Name() string { return ApplyMethodSyncconf }
+
+func (syncconfApplier) Apply(req *ApplyRequest) (*ApplyResult, error) {
+	// Diff against the running device when possible, it is what syncconf compares against
+	result := DiffServerConfigs(req.Previous, req.Config)
+	if running, err := ReadDeviceConfig(req.Interface); err == nil {
+		result = diffAgainstDevice(running, req)
+	}
+
+	// wg syncconf only understands wg(8) keys, strip the wg-quick specific ones first
+	stripped, err := exec.Command("wg-quick", "strip", req.ConfigPath).Output()
+	if err != nil {
+		return nil, errors.WithCode(code.ErrWGApplyFailed, "wg-quick strip %s failed: %s", req.ConfigPath, err.Error())
+	}
+	tmpFile, err := writeTempFile("wg-syncconf-*.conf", stripped)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpFile)
+
+	if _, err := runCommand("wg", "syncconf", req.Interface, tmpFile); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// diffAgainstDevice diffs the desired peers against the running device, and the
+// [Interface] section against the previous config file (the device does not know about Address, MTU...).
+func diffAgainstDevice(running *ServerConfig, req *ApplyRequest) *ApplyResult {
+	result := DiffServerConfigs(&ServerConfig{Peers: running.Peers}, &ServerConfig{Peers: req.Config.Peers})
+	if req.Previous != nil {
+		result.InterfaceChanged = DiffServerConfigs(&ServerConfig{Interface: req.Previous.Interface}, &ServerConfig{Interface: req.Config.Interface}).InterfaceChanged
+	}
+	return result
+}
+
+// writeTempFile writes data to a private temporary file and returns its path.
+func writeTempFile(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", errors.WithCode(code.ErrWGApplyFailed, "failed to create temp file: %s", err.Error())
+	}
+	defer f.Close()
+	if err := f.Chmod(0600); err != nil {
+		_ = os.Remove(f.Name())
+		return "", errors.WithCode(code.ErrWGApplyFailed, "failed to chmod temp file: %s", err.Error())
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = os.Remove(f.Name())
+		return "", errors.WithCode(code.ErrWGApplyFailed, "failed to write temp file: %s", err.Error())
+	}
+	return f.Name(), nil
+}
+
+// ExecApplier runs a user-defined command after the config file is written.
+// The command gets the interface and the change set through the environment:
+// WG_INTERFACE, WG_CONFIG_PATH, WG_ADDED_PEERS, WG_REMOVED_PEERS, WG_UPDATED_PEERS
+// (comma-separated public keys) and WG_INTERFACE_CHANGED (true/false).
+type ExecApplier struct {
+	command string
+}
+
+// NewExecApplier creates an exec applier for the given command line (split on whitespace).
+func NewExecApplier(command string) *ExecApplier {
+	return &ExecApplier{command: command}
+}
+
+func (a *ExecApplier) Name() string { return ApplyMethodExec }
+
+func (a *ExecApplier) Apply(req *ApplyRequest) (*ApplyResult, error) {
+	fields := strings.Fields(a.command)
+	if len(fields) == 0 {
+		return nil, errors.WithCode(code.ErrWGApplyFailed, "wireguard.apply-command is not configured")
+	}
+
+	result := DiffServerConfigs(req.Previous, req.Config)
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Env = append(os.Environ(),
+		"WG_INTERFACE="+req.Interface,
+		"WG_CONFIG_PATH="+req.ConfigPath,
+		"WG_ADDED_PEERS="+strings.Join(result.Added, ","),
+		"WG_REMOVED_PEERS="+strings.Join(result.Removed, ","),
+		"WG_UPDATED_PEERS="+strings.Join(result.Updated, ","),
+		fmt.Sprintf("WG_INTERFACE_CHANGED=%t", result.InterfaceChanged),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.WithCode(code.ErrWGApplyFailed, "apply command failed: %s", strings.TrimSpace(string(output)))
+	}
+	klog.V(2).InfoS("apply command completed", "interface", req.Interface, "output", string(output))
+	return result, nil
+}
+
+// FakeApplier records apply requests in memory instead of touching the system. Intended for tests.
+type FakeApplier struct {
+	mu       sync.Mutex
+	requests []ApplyRequest
+	err      error
+}
+
+// NewFakeApplier creates an empty recording applier.
+func NewFakeApplier() *FakeApplier {
+	return &FakeApplier{}
+}
+
+func (a *FakeApplier) Name() string { return ApplyMethodFake }
+
+func (a *FakeApplier) Apply(req *ApplyRequest) (*ApplyResult, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, *req)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return DiffServerConfigs(req.Previous, req.Config), nil
+}
+
+// Requests returns the recorded apply requests in order.
+func (a *FakeApplier) Requests() []ApplyRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ApplyRequest(nil), a.requests...)
+}
+
+// SetError makes subsequent applies fail with err (nil to succeed again).
+func (a *FakeApplier) SetError(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.err = err
+}
+
+// Reset clears the recorded requests and error.
+func (a *FakeApplier) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = nil
+	a.err = nil
+}
+
+// noneApplier only reports the change set; the config file is applied manually.
+type noneApplier struct{}
+
+func (noneApplier) Name() string { return ApplyMethodNone }
+
+func (noneApplier) Apply(req *ApplyRequest) (*ApplyResult, error) {
+	klog.V(2).InfoS("apply method is 'none', skipping config reload")
+	return DiffServerConfigs(req.Previous, req.Config), nil
+}
@@ -0,0 +1,157 @@
+package wireguard
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newFakeApplied returns a config manager applying through a fresh FakeApplier, with a config
+// holding two peers that was applied once.
+func newFakeApplied(t *testing.T) (*ServerConfigManager, *FakeApplier) {
+	t.Helper()
+	fake := NewFakeApplier()
+	RegisterApplier(fake)
+	t.Cleanup(func() { RegisterApplier(NewFakeApplier()) })
+
+	m := NewServerConfigManager(filepath.Join(t.TempDir(), "wg0.conf"), ApplyMethodFake)
+	config := &ServerConfig{
+		Interface: &InterfaceConfig{PrivateKey: "cHJpdmF0ZQ==", Address: "100.100.100.1/24", ListenPort: 51820, MTU: 1420},
+		Peers: []*ServerPeerConfig{
+			{PublicKey: "cGVlcjE=", AllowedIPs: "100.100.100.2/32", Comment: "phone"},
+			{PublicKey: "cGVlcjI=", AllowedIPs: "100.100.100.3/32", Comment: "laptop"},
+		},
+	}
+	if err := m.WriteServerConfig(context.Background(), config); err != nil {
+		t.Fatalf("WriteServerConfig() error = %v", err)
+	}
+
+	result, err := m.ApplyConfig()
+	if err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+	// Nothing was applied before, every peer is a guess at being new
+	if result.Exact || !reflect.DeepEqual(result.Added, []string{"cGVlcjE=", "cGVlcjI="}) {
+		t.Fatalf("first ApplyConfig() = %+v, want both peers added and an inexact result", *result)
+	}
+	return m, fake
+}
+
+func TestApplyConfigFakeApplier(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		edit func(m *ServerConfigManager) error
+		want ApplyResult
+	}{
+		{
+			name: "adding a peer",
+			edit: func(m *ServerConfigManager) error {
+				return m.AddPeer(ctx, &ServerPeerConfig{PublicKey: "cGVlcjM=", AllowedIPs: "100.100.100.4/32"})
+			},
+			want: ApplyResult{Added: []string{"cGVlcjM="}},
+		},
+		{
+			name: "removing a peer",
+			edit: func(m *ServerConfigManager) error {
+				return m.RemovePeer(ctx, "cGVlcjE=")
+			},
+			want: ApplyResult{Removed: []string{"cGVlcjE="}},
+		},
+		{
+			name: "updating a peer",
+			edit: func(m *ServerConfigManager) error {
+				return m.UpdatePeer(ctx, "cGVlcjI=", &ServerPeerConfig{AllowedIPs: "100.100.100.3/32", PersistentKeepalive: 25, Comment: "laptop"})
+			},
+			want: ApplyResult{Updated: []string{"cGVlcjI="}},
+		},
+		{
+			name: "renaming a peer changes nothing WireGuard uses",
+			edit: func(m *ServerConfigManager) error {
+				return m.UpdatePeer(ctx, "cGVlcjI=", &ServerPeerConfig{AllowedIPs: "100.100.100.3/32", Comment: "work laptop"})
+			},
+			want: ApplyResult{},
+		},
+		{
+			name: "rotating a peer key",
+			edit: func(m *ServerConfigManager) error {
+				return m.ReplacePeerKey(ctx, "cGVlcjE=", &ServerPeerConfig{PublicKey: "bmV3cGVlcjE=", AllowedIPs: "100.100.100.2/32", Comment: "phone"})
+			},
+			want: ApplyResult{Added: []string{"bmV3cGVlcjE="}, Removed: []string{"cGVlcjE="}},
+		},
+		{
+			name: "changing the interface",
+			edit: func(m *ServerConfigManager) error {
+				config, err := m.ReadServerConfig()
+				if err != nil {
+					return err
+				}
+				config.Interface.MTU = 1380
+				return m.WriteServerConfig(ctx, config)
+			},
+			want: ApplyResult{InterfaceChanged: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, fake := newFakeApplied(t)
+			if err := tt.edit(m); err != nil {
+				t.Fatalf("edit error = %v", err)
+			}
+
+			result, err := m.ApplyConfig()
+			if err != nil {
+				t.Fatalf("ApplyConfig() error = %v", err)
+			}
+			want := tt.want
+			want.Method, want.Interface, want.Exact = ApplyMethodFake, "wg0", true
+			if !reflect.DeepEqual(*result, want) {
+				t.Errorf("ApplyConfig() = %+v, want %+v", *result, want)
+			}
+
+			// The applier got the previously applied config to diff against
+			requests := fake.Requests()
+			if len(requests) != 2 {
+				t.Fatalf("FakeApplier recorded %d requests, want 2", len(requests))
+			}
+			if requests[1].Previous != requests[0].Config {
+				t.Errorf("second request Previous is not the config of the first request")
+			}
+			if requests[1].Interface != "wg0" || requests[1].ConfigPath != m.configPath {
+				t.Errorf("second request is for %s (%s), want wg0 (%s)", requests[1].Interface, requests[1].ConfigPath, m.configPath)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFailureKeepsPrevious(t *testing.T) {
+	ctx := context.Background()
+	m, fake := newFakeApplied(t)
+
+	if err := m.AddPeer(ctx, &ServerPeerConfig{PublicKey: "cGVlcjM=", AllowedIPs: "100.100.100.4/32"}); err != nil {
+		t.Fatalf("AddPeer() error = %v", err)
+	}
+	fake.SetError(errors.New("device busy"))
+	if _, err := m.ApplyConfig(); err == nil {
+		t.Fatalf("ApplyConfig() error = nil, want the applier error")
+	}
+
+	// The failed apply is not the new baseline, the next one reports the peer again
+	fake.SetError(nil)
+	if err := m.RemovePeer(ctx, "cGVlcjE="); err != nil {
+		t.Fatalf("RemovePeer() error = %v", err)
+	}
+	result, err := m.ApplyConfig()
+	if err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.Added, []string{"cGVlcjM="}) || !reflect.DeepEqual(result.Removed, []string{"cGVlcjE="}) || result.Restarted {
+		t.Errorf("ApplyConfig() = %+v, want cGVlcjM= added and cGVlcjE= removed without a restart", *result)
+	}
+	if got := len(fake.Requests()); got != 3 {
+		t.Errorf("FakeApplier recorded %d requests, want 3", got)
+	}
+}
@@ -0,0 +1,361 @@
+package wireguard
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// confDocument is a wg-quick config file parsed line by line, so that it can be edited
+// and written back without losing unknown keys, comments, blank lines or section order.
+type confDocument struct {
+	// sections[0] holds the lines before the first section header (header is empty).
+	sections []*confSection
+}
+
+// confSection is one [Section] of a config file.
+type confSection struct {
+	// leading holds the comment lines directly above the header. The last one is the peer
+	// comment managed by NexusPointWG (the device name), the ones above it are notes kept as they are.
+	leading []string
+	header  string
+	name    string
+	lines   []confLine
+}
+
+// confLine is one raw line of a section. key is empty for comments, blank and invalid lines.
+type confLine struct {
+	raw   string
+	key   string
+	value string
+}
+
+// Multi-valued keys: wg-quick concatenates list keys and runs every hook line.
+var (
+	confListKeys = map[string]bool{"address": true, "dns": true, "allowedips": true}
+	confHookKeys = map[string]bool{"preup": true, "postup": true, "predown": true, "postdown": true}
+)
+
+// parseConfDocument parses a wg-quick config file.
+func parseConfDocument(r io.Reader) (*confDocument, error) {
+	doc := &confDocument{sections: []*confSection{{}}}
+	current := doc.sections[0]
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		raw := strings.TrimRight(scanner.Text(), "\r")
+		line := strings.TrimSpace(raw)
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			// Comments directly above a header belong to the new section
+			leadingStart := len(current.lines)
+			for leadingStart > 0 && isCommentLine(current.lines[leadingStart-1].raw) {
+				leadingStart--
+			}
+			section := &confSection{
+				header: raw,
+				name:   strings.TrimSpace(strings.Trim(line, "[]")),
+			}
+			for _, l := range current.lines[leadingStart:] {
+				section.leading = append(section.leading, l.raw)
+			}
+			current.lines = current.lines[:leadingStart]
+			doc.sections = append(doc.sections, section)
+			current = section
+			continue
+		}
+
+		current.lines = append(current.lines, parseConfLine(raw))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// parseConfLine parses a key = value line. Like wg-quick, everything after '#' is a comment.
+func parseConfLine(raw string) confLine {
+	line := strings.TrimSpace(raw)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return confLine{raw: raw}
+	}
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return confLine{raw: raw}
+	}
+	return confLine{
+		raw:   raw,
+		key:   strings.TrimSpace(parts[0]),
+		value: strings.TrimSpace(parts[1]),
+	}
+}
+
+func isCommentLine(raw string) bool {
+	return strings.HasPrefix(strings.TrimSpace(raw), "#")
+}
+
+func commentText(raw string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "#"))
+}
+
+func (s *confSection) is(name string) bool {
+	return strings.EqualFold(s.name, name)
+}
+
+// get returns the value of a key, joining repeated list and hook keys the way wg-quick applies them.
+func (s *confSection) get(key string) string {
+	var values []string
+	for _, l := range s.lines {
+		if strings.EqualFold(l.key, key) && l.value != "" {
+			values = append(values, l.value)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	lower := strings.ToLower(key)
+	switch {
+	case confListKeys[lower]:
+		return strings.Join(values, ", ")
+	case confHookKeys[lower]:
+		return strings.Join(values, "; ")
+	default:
+		return values[len(values)-1]
+	}
+}
+
+func (s *confSection) getInt(key string) int {
+	n, _ := strconv.Atoi(s.get(key))
+	return n
+}
+
+// set replaces all occurrences of key with a single line at the position of the first one.
+// An empty value removes the key. Nothing is touched if the value is unchanged.
+func (s *confSection) set(key, value string) {
+	if s.get(key) == value {
+		return
+	}
+
+	insertAt := -1
+	lines := make([]confLine, 0, len(s.lines)+1)
+	for _, l := range s.lines {
+		if strings.EqualFold(l.key, key) {
+			if insertAt < 0 {
+				insertAt = len(lines)
+				key = l.key // Keep the spelling used in the file
+			}
+			continue
+		}
+		lines = append(lines, l)
+	}
+	s.lines = lines
+
+	if value == "" {
+		return
+	}
+	if insertAt < 0 {
+		// Append after the last key, before trailing blank lines and comments
+		insertAt = len(s.lines)
+		for insertAt > 0 && s.lines[insertAt-1].key == "" {
+			insertAt--
+		}
+	}
+	line := parseConfLine(key + " = " + value)
+	s.lines = append(s.lines[:insertAt], append([]confLine{line}, s.lines[insertAt:]...)...)
+}
+
+// comment returns the peer comment: the comment line directly above the header,
+// or for files written by older versions, the comments inside the section.
+func (s *confSection) comment() string {
+	if len(s.leading) > 0 {
+		return commentText(s.leading[len(s.leading)-1])
+	}
+	var comments []string
+	for _, l := range s.lines {
+		if isCommentLine(l.raw) {
+			comments = append(comments, commentText(l.raw))
+		}
+	}
+	return strings.Join(comments, "; ")
+}
+
+// setComment rewrites the managed comment line only, notes above it are kept.
+func (s *confSection) setComment(comment string) {
+	if s.comment() == comment {
+		return
+	}
+	leading := make([]string, 0, len(s.leading)+1)
+	if len(s.leading) > 0 {
+		leading = append(leading, s.leading[:len(s.leading)-1]...)
+	}
+	if comment != "" {
+		leading = append(leading, "# "+comment)
+	}
+	s.leading = leading
+}
+
+func (s *confSection) endsWithBlankLine() bool {
+	if len(s.lines) == 0 {
+		return s.header == ""
+	}
+	return strings.TrimSpace(s.lines[len(s.lines)-1].raw) == ""
+}
+
+// serverConfig extracts the fields managed by NexusPointWG.
+func (d *confDocument) serverConfig() *ServerConfig {
+	config := &ServerConfig{
+		Interface: &InterfaceConfig{},
+		Peers:     make([]*ServerPeerConfig, 0),
+	}
+	for _, s := range d.sections {
+		switch {
+		case s.is("Interface"):
+			saveConfig, _ := strconv.ParseBool(s.get("SaveConfig"))
+			config.Interface = &InterfaceConfig{
+				PrivateKey: s.get("PrivateKey"),
+				Address:    s.get("Address"),
+				ListenPort: s.getInt("ListenPort"),
+				DNS:        s.get("DNS"),
+				MTU:        s.getInt("MTU"),
+				PreUp:      s.get("PreUp"),
+				PostUp:     s.get("PostUp"),
+				PreDown:    s.get("PreDown"),
+				PostDown:   s.get("PostDown"),
+				SaveConfig: saveConfig,
+				Table:      s.get("Table"),
+			}
+		case s.is("Peer"):
+			config.Peers = append(config.Peers, &ServerPeerConfig{
+				PublicKey:           s.get("PublicKey"),
+				PresharedKey:        s.get("PresharedKey"),
+				Endpoint:            s.get("Endpoint"),
+				AllowedIPs:          s.get("AllowedIPs"),
+				PersistentKeepalive: s.getInt("PersistentKeepalive"),
+				Comment:             s.comment(),
+			})
+		}
+	}
+	return config
+}
+
+// merge applies the managed fields of config to the document. Peers are matched by public key:
+// existing peers are edited in place, missing ones removed and new ones appended.
+// Keys, comments and sections NexusPointWG does not manage are kept as they are.
+func (d *confDocument) merge(config *ServerConfig) {
+	if config.Interface != nil {
+		iface := d.section("Interface")
+		if iface == nil {
+			// New keys are inserted before the trailing blank line
+			iface = &confSection{header: "[Interface]", name: "Interface", lines: []confLine{{}}}
+			d.sections = append(d.sections[:1], append([]*confSection{iface}, d.sections[1:]...)...)
+		}
+		iface.set("PrivateKey", config.Interface.PrivateKey)
+		iface.set("Address", config.Interface.Address)
+		iface.set("ListenPort", formatConfInt(config.Interface.ListenPort))
+		iface.set("DNS", config.Interface.DNS)
+		iface.set("MTU", formatConfInt(config.Interface.MTU))
+		iface.set("PreUp", config.Interface.PreUp)
+		iface.set("PostUp", config.Interface.PostUp)
+		iface.set("PreDown", config.Interface.PreDown)
+		iface.set("PostDown", config.Interface.PostDown)
+		iface.set("Table", config.Interface.Table)
+		if saveConfig, _ := strconv.ParseBool(iface.get("SaveConfig")); saveConfig != config.Interface.SaveConfig {
+			iface.set("SaveConfig", strconv.FormatBool(config.Interface.SaveConfig))
+		}
+	}
+
+	wanted := make(map[string]*ServerPeerConfig, len(config.Peers))
+	for _, peer := range config.Peers {
+		if peer != nil && peer.PublicKey != "" {
+			wanted[peer.PublicKey] = peer
+		}
+	}
+
+	// Edit or drop existing peers, keeping their order
+	seen := make(map[string]bool, len(config.Peers))
+	sections := make([]*confSection, 0, len(d.sections)+len(config.Peers))
+	for _, s := range d.sections {
+		if s.is("Peer") {
+			publicKey := s.get("PublicKey")
+			peer, ok := wanted[publicKey]
+			if publicKey != "" && (!ok || seen[publicKey]) {
+				continue
+			}
+			if ok {
+				seen[publicKey] = true
+				s.set("PresharedKey", peer.PresharedKey)
+				s.set("Endpoint", peer.Endpoint)
+				s.set("AllowedIPs", peer.AllowedIPs)
+				s.set("PersistentKeepalive", formatConfInt(peer.PersistentKeepalive))
+				s.setComment(peer.Comment)
+			}
+		}
+		sections = append(sections, s)
+	}
+	d.sections = sections
+
+	// Append new peers
+	for _, peer := range config.Peers {
+		if peer == nil || peer.PublicKey == "" || seen[peer.PublicKey] {
+			continue
+		}
+		seen[peer.PublicKey] = true
+
+		if last := d.sections[len(d.sections)-1]; !last.endsWithBlankLine() {
+			last.lines = append(last.lines, confLine{})
+		}
+		block, _ := parseConfDocument(strings.NewReader(FormatServerPeerBlock(peer)))
+		d.sections = append(d.sections, block.sections[1:]...)
+	}
+}
+
+// renamePeer changes the public key of a [Peer] block, so that merging a config with
+// the new key edits the block in place instead of dropping it and appending a new one.
+func (d *confDocument) renamePeer(oldPublicKey, newPublicKey string) {
+	for _, s := range d.sections {
+		if s.is("Peer") && s.get("PublicKey") == oldPublicKey {
+			s.set("PublicKey", newPublicKey)
+			return
+		}
+	}
+}
+
+func (d *confDocument) section(name string) *confSection {
+	for _, s := range d.sections {
+		if s.is(name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// render formats the document back to text.
+func (d *confDocument) render() []byte {
+	var sb strings.Builder
+	for _, s := range d.sections {
+		for _, raw := range s.leading {
+			sb.WriteString(raw)
+			sb.WriteByte('\n')
+		}
+		if s.header != "" {
+			sb.WriteString(s.header)
+			sb.WriteByte('\n')
+		}
+		for _, l := range s.lines {
+			sb.WriteString(l.raw)
+			sb.WriteByte('\n')
+		}
+	}
+	return []byte(sb.String())
+}
+
+func formatConfInt(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
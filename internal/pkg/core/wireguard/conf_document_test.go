@@ -0,0 +1,151 @@
+package wireguard
+
+import (
+	"strings"
+	"testing"
+)
+
+const testServerConf = `# Managed by hand as well
+[Interface]
+PrivateKey = c2VydmVyLXByaXZhdGUta2V5
+Address = 100.100.100.1/24
+ListenPort = 51820
+FwMark = 0x42 # unknown to NexusPointWG
+
+# office router
+# phone
+[Peer]
+PublicKey = cGVlcjE=
+AllowedIPs = 100.100.100.2/32
+PersistentKeepalive = 25
+
+# laptop
+[Peer]
+PublicKey = cGVlcjI=
+AllowedIPs = 100.100.100.3/32
+`
+
+func TestConfDocumentRoundTrip(t *testing.T) {
+	doc, err := parseConfDocument(strings.NewReader(testServerConf))
+	if err != nil {
+		t.Fatalf("parseConfDocument() error = %v", err)
+	}
+	if got := string(doc.render()); got != testServerConf {
+		t.Errorf("render() without changes =\n%s\nwant\n%s", got, testServerConf)
+	}
+
+	doc.merge(doc.serverConfig())
+	if got := string(doc.render()); got != testServerConf {
+		t.Errorf("render() after merging its own config =\n%s\nwant\n%s", got, testServerConf)
+	}
+}
+
+func TestConfDocumentServerConfig(t *testing.T) {
+	doc, err := parseConfDocument(strings.NewReader(testServerConf))
+	if err != nil {
+		t.Fatalf("parseConfDocument() error = %v", err)
+	}
+	config := doc.serverConfig()
+
+	if config.Interface.ListenPort != 51820 || config.Interface.Address != "100.100.100.1/24" {
+		t.Errorf("Interface = %+v, want port 51820 and address 100.100.100.1/24", *config.Interface)
+	}
+	if len(config.Peers) != 2 {
+		t.Fatalf("got %d peers, want 2", len(config.Peers))
+	}
+	tests := []struct {
+		publicKey string
+		comment   string
+		keepalive int
+	}{
+		{publicKey: "cGVlcjE=", comment: "phone", keepalive: 25},
+		{publicKey: "cGVlcjI=", comment: "laptop"},
+	}
+	for i, tt := range tests {
+		peer := config.Peers[i]
+		if peer.PublicKey != tt.publicKey || peer.Comment != tt.comment || peer.PersistentKeepalive != tt.keepalive {
+			t.Errorf("peer %d = %+v, want %s (%s) with keepalive %d", i, *peer, tt.publicKey, tt.comment, tt.keepalive)
+		}
+	}
+}
+
+func TestConfDocumentMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(config *ServerConfig)
+		want   []string
+		unwant []string
+	}{
+		{
+			name: "unknown keys and notes are kept",
+			edit: func(config *ServerConfig) {
+				config.Interface.ListenPort = 51821
+			},
+			want: []string{"ListenPort = 51821", "FwMark = 0x42 # unknown to NexusPointWG", "# Managed by hand as well", "# office router\n# phone\n[Peer]"},
+		},
+		{
+			name: "only the managed comment line is rewritten",
+			edit: func(config *ServerConfig) {
+				config.Peers[0].Comment = "tablet"
+			},
+			want:   []string{"# office router\n# tablet\n[Peer]\nPublicKey = cGVlcjE="},
+			unwant: []string{"# phone"},
+		},
+		{
+			name: "peer settings are edited in place",
+			edit: func(config *ServerConfig) {
+				config.Peers[1].AllowedIPs = "100.100.100.3/32, 192.168.10.0/24"
+				config.Peers[1].PersistentKeepalive = 15
+			},
+			want: []string{"PublicKey = cGVlcjI=\nAllowedIPs = 100.100.100.3/32, 192.168.10.0/24\nPersistentKeepalive = 15\n"},
+		},
+		{
+			name: "removed peers are dropped with their comments",
+			edit: func(config *ServerConfig) {
+				config.Peers = config.Peers[1:]
+			},
+			want:   []string{"# laptop\n[Peer]"},
+			unwant: []string{"cGVlcjE=", "# phone", "# office router"},
+		},
+		{
+			name: "new peers are appended",
+			edit: func(config *ServerConfig) {
+				config.Peers = append(config.Peers, &ServerPeerConfig{PublicKey: "cGVlcjM=", AllowedIPs: "100.100.100.4/32", Comment: "watch"})
+			},
+			want: []string{"AllowedIPs = 100.100.100.3/32\n\n# watch\n[Peer]\nPublicKey = cGVlcjM=\nAllowedIPs = 100.100.100.4/32\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parseConfDocument(strings.NewReader(testServerConf))
+			if err != nil {
+				t.Fatalf("parseConfDocument() error = %v", err)
+			}
+			config := doc.serverConfig()
+			tt.edit(config)
+			doc.merge(config)
+
+			got := string(doc.render())
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("render() =\n%s\nwant it to contain %q", got, want)
+				}
+			}
+			for _, unwant := range tt.unwant {
+				if strings.Contains(got, unwant) {
+					t.Errorf("render() =\n%s\nwant it not to contain %q", got, unwant)
+				}
+			}
+
+			// The merged document parses back to the edited config
+			reparsed, err := parseConfDocument(strings.NewReader(got))
+			if err != nil {
+				t.Fatalf("parseConfDocument() of the merged document error = %v", err)
+			}
+			if peers := reparsed.serverConfig().Peers; len(peers) != len(config.Peers) {
+				t.Errorf("merged document has %d peers, want %d", len(peers), len(config.Peers))
+			}
+		})
+	}
+}
@@ -2,17 +2,28 @@ package wireguard
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/secret"
+	"github.com/HappyLadySauce/errors"
 )
 
+// PrivateKeyPlaceholder replaces the private key in the client config of peers whose private key
+// is only known to the client.
+const PrivateKeyPlaceholder = "<paste your private key>"
+
 // ClientConfig represents a WireGuard client configuration.
 type ClientConfig struct {
-	PrivateKey          string
+	PrivateKey          string // Empty if only the client knows it, PrivateKeyPlaceholder is written instead
 	Address             string // Client IP in CIDR format, e.g. "100.100.100.2/32"
 	DNS                 string // Optional, comma-separated
 	MTU                 int    // Optional, default 1420
 	Endpoint            string // Server endpoint, e.g. "10.10.10.10:51820"
 	PublicKey           string // Server public key
+	PresharedKey        string // Optional, must match the server-side PresharedKey
 	AllowedIPs          string // Comma-separated CIDRs
 	PersistentKeepalive int    // Optional, default 25
 }
@@ -25,6 +36,8 @@ func GenerateClientConfig(config *ClientConfig) string {
 	sb.WriteString("[Interface]\n")
 	if config.PrivateKey != "" {
 		sb.WriteString(fmt.Sprintf("PrivateKey = %s\n", config.PrivateKey))
+	} else {
+		sb.WriteString(fmt.Sprintf("PrivateKey = %s\n", PrivateKeyPlaceholder))
 	}
 	if config.Address != "" {
 		sb.WriteString(fmt.Sprintf("Address = %s\n", config.Address))
@@ -42,6 +55,9 @@ func GenerateClientConfig(config *ClientConfig) string {
 	if config.PublicKey != "" {
 		sb.WriteString(fmt.Sprintf("PublicKey = %s\n", config.PublicKey))
 	}
+	if config.PresharedKey != "" {
+		sb.WriteString(fmt.Sprintf("PresharedKey = %s\n", config.PresharedKey))
+	}
 	if config.Endpoint != "" {
 		sb.WriteString(fmt.Sprintf("Endpoint = %s\n", config.Endpoint))
 	}
@@ -58,7 +74,9 @@ func GenerateClientConfig(config *ClientConfig) string {
 // ServerPeerConfig represents a peer configuration block in the server configuration.
 type ServerPeerConfig struct {
 	PublicKey           string
-	AllowedIPs          string // Comma-separated CIDRs
+	PresharedKey        string // Optional
+	Endpoint            string // Optional, static address of the peer, e.g. a branch office router
+	AllowedIPs          string // Comma-separated CIDRs, the peer IPs and the subnets routed behind it
 	PersistentKeepalive int    // Optional
 	Comment             string // Optional comment
 }
@@ -74,6 +92,12 @@ func FormatServerPeerBlock(peer *ServerPeerConfig) string {
 	if peer.PublicKey != "" {
 		sb.WriteString(fmt.Sprintf("PublicKey = %s\n", peer.PublicKey))
 	}
+	if peer.PresharedKey != "" {
+		sb.WriteString(fmt.Sprintf("PresharedKey = %s\n", peer.PresharedKey))
+	}
+	if peer.Endpoint != "" {
+		sb.WriteString(fmt.Sprintf("Endpoint = %s\n", peer.Endpoint))
+	}
 	if peer.AllowedIPs != "" {
 		sb.WriteString(fmt.Sprintf("AllowedIPs = %s\n", peer.AllowedIPs))
 	}
@@ -84,3 +108,56 @@ func FormatServerPeerBlock(peer *ServerPeerConfig) string {
 
 	return sb.String()
 }
+
+// FillPrivateKey replaces the private key placeholder of a client config with the given private key.
+func FillPrivateKey(configContent, privateKey string) (string, error) {
+	if err := ValidatePrivateKey(privateKey); err != nil {
+		return "", err
+	}
+	placeholder := "PrivateKey = " + PrivateKeyPlaceholder
+	if !strings.Contains(configContent, placeholder) {
+		return "", errors.WithCode(code.ErrValidation, "client config has no private key placeholder")
+	}
+	return strings.Replace(configContent, placeholder, "PrivateKey = "+privateKey, 1), nil
+}
+
+// ClientConfigPath returns the path of the saved client config file of a peer under userDir.
+func ClientConfigPath(userDir, peerID string) string {
+	return filepath.Join(userDir, peerID+".conf")
+}
+
+// SaveClientConfig saves the client config file of a peer under userDir and returns its path.
+// The file is encrypted with the master key if one is configured.
+func SaveClientConfig(userDir, peerID, configContent string) (string, error) {
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		return "", errors.WithCode(code.ErrWGUserDirCreateFailed, "failed to create user directory: %s", err.Error())
+	}
+
+	content, err := secret.Encrypt(configContent)
+	if err != nil {
+		return "", errors.WithCode(code.ErrEncodingFailed, "failed to encrypt client config file: %s", err.Error())
+	}
+
+	configPath := ClientConfigPath(userDir, peerID)
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		return "", errors.WithCode(code.ErrWGConfigWriteFailed, "failed to write client config file: %s", err.Error())
+	}
+	// WriteFile keeps the mode of an existing file, which may have been written world-readable
+	if err := os.Chmod(configPath, 0600); err != nil {
+		return "", errors.WithCode(code.ErrWGConfigWriteFailed, "failed to restrict client config file permissions: %s", err.Error())
+	}
+	return configPath, nil
+}
+
+// LoadClientConfig reads the saved client config file of a peer under userDir, decrypting it if needed.
+func LoadClientConfig(userDir, peerID string) (string, error) {
+	content, err := os.ReadFile(ClientConfigPath(userDir, peerID))
+	if err != nil {
+		return "", err
+	}
+	configContent, err := secret.Decrypt(string(content))
+	if err != nil {
+		return "", errors.WithCode(code.ErrDecodingFailed, "failed to decrypt client config file: %s", err.Error())
+	}
+	return configContent, nil
+}
@@ -0,0 +1,103 @@
+package wireguard
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+)
+
+// ReadDeviceConfig reads the running configuration of an interface with `wg show <iface> dump`.
+// Only the fields known to the kernel are filled (private key, listen port and peers).
+func ReadDeviceConfig(iface string) (*ServerConfig, error) {
+	output, err := runCommand("wg", "show", iface, "dump")
+	if err != nil {
+		return nil, err
+	}
+	dump, err := parseDeviceDump(output)
+	if err != nil {
+		return nil, err
+	}
+	return dump.config, nil
+}
+
+// deviceDump is the parsed output of `wg show <iface> dump`: the configuration
+// known to the kernel and the runtime status of each peer, keyed by public key.
+type deviceDump struct {
+	config   *ServerConfig
+	statuses map[string]*PeerStatus
+}
+
+// parseDeviceDump parses the tab-separated output of `wg show <iface> dump`.
+// The first line describes the interface, each following line one peer.
+func parseDeviceDump(output []byte) (*deviceDump, error) {
+	dump := &deviceDump{
+		config:   &ServerConfig{Peers: make([]*ServerPeerConfig, 0)},
+		statuses: make(map[string]*PeerStatus),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+
+		// Interface: private-key public-key listen-port fwmark
+		if dump.config.Interface == nil {
+			if len(fields) < 3 {
+				return nil, errors.WithCode(code.ErrWGStatusUnavailable, "unexpected wg dump interface line: %s", line)
+			}
+			listenPort, _ := strconv.Atoi(fields[2])
+			dump.config.Interface = &InterfaceConfig{
+				PrivateKey: dumpValue(fields[0]),
+				ListenPort: listenPort,
+			}
+			continue
+		}
+
+		// Peer: public-key preshared-key endpoint allowed-ips latest-handshake transfer-rx transfer-tx persistent-keepalive
+		if len(fields) < 8 {
+			return nil, errors.WithCode(code.ErrWGStatusUnavailable, "unexpected wg dump peer line: %s", line)
+		}
+		keepalive, _ := strconv.Atoi(dumpValue(fields[7]))
+		dump.config.Peers = append(dump.config.Peers, &ServerPeerConfig{
+			PublicKey:           fields[0],
+			PresharedKey:        dumpValue(fields[1]),
+			Endpoint:            dumpValue(fields[2]),
+			AllowedIPs:          dumpValue(fields[3]),
+			PersistentKeepalive: keepalive,
+		})
+
+		status := &PeerStatus{
+			PublicKey: fields[0],
+			Endpoint:  dumpValue(fields[2]),
+		}
+		if handshake, _ := strconv.ParseInt(fields[4], 10, 64); handshake > 0 {
+			status.LatestHandshake = time.Unix(handshake, 0)
+		}
+		status.TransferRx, _ = strconv.ParseInt(fields[5], 10, 64)
+		status.TransferTx, _ = strconv.ParseInt(fields[6], 10, 64)
+		dump.statuses[status.PublicKey] = status
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithCode(code.ErrWGStatusUnavailable, "failed to read wg dump: %s", err.Error())
+	}
+	if dump.config.Interface == nil {
+		return nil, errors.WithCode(code.ErrWGStatusUnavailable, "empty wg dump")
+	}
+	return dump, nil
+}
+
+// dumpValue converts wg dump placeholders ("(none)", "off") to empty values.
+func dumpValue(value string) string {
+	if value == "(none)" || value == "off" {
+		return ""
+	}
+	return value
+}
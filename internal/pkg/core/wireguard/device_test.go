@@ -0,0 +1,101 @@
+package wireguard
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDeviceDump(t *testing.T) {
+	tests := []struct {
+		name       string
+		dump       string
+		wantConfig ServerConfig
+		want       map[string]PeerStatus
+		wantErr    bool
+	}{
+		{
+			name:       "interface only",
+			dump:       "cHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n",
+			wantConfig: ServerConfig{Interface: &InterfaceConfig{PrivateKey: "cHJpdmF0ZQ==", ListenPort: 51820}, Peers: []*ServerPeerConfig{}},
+			want:       map[string]PeerStatus{},
+		},
+		{
+			name: "connected and idle peers",
+			dump: "cHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n" +
+				"PEER1=\tcHNr\t203.0.113.7:51820\t100.100.100.2/32\t1760000000\t1024\t2048\t25\n" +
+				"PEER2=\t(none)\t(none)\t100.100.100.3/32,fd00:100::3/128\t0\t0\t0\toff\n",
+			wantConfig: ServerConfig{
+				Interface: &InterfaceConfig{PrivateKey: "cHJpdmF0ZQ==", ListenPort: 51820},
+				Peers: []*ServerPeerConfig{
+					{PublicKey: "PEER1=", PresharedKey: "cHNr", Endpoint: "203.0.113.7:51820", AllowedIPs: "100.100.100.2/32", PersistentKeepalive: 25},
+					{PublicKey: "PEER2=", AllowedIPs: "100.100.100.3/32,fd00:100::3/128"},
+				},
+			},
+			want: map[string]PeerStatus{
+				"PEER1=": {PublicKey: "PEER1=", Endpoint: "203.0.113.7:51820", LatestHandshake: time.Unix(1760000000, 0), TransferRx: 1024, TransferTx: 2048},
+				"PEER2=": {PublicKey: "PEER2="},
+			},
+		},
+		{
+			name: "blank lines are skipped",
+			dump: "\ncHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n\nPEER1=\t(none)\t(none)\t100.100.100.2/32\t0\t5\t6\toff\n\n",
+			wantConfig: ServerConfig{
+				Interface: &InterfaceConfig{PrivateKey: "cHJpdmF0ZQ==", ListenPort: 51820},
+				Peers:     []*ServerPeerConfig{{PublicKey: "PEER1=", AllowedIPs: "100.100.100.2/32"}},
+			},
+			want: map[string]PeerStatus{
+				"PEER1=": {PublicKey: "PEER1=", TransferRx: 5, TransferTx: 6},
+			},
+		},
+		{
+			name:    "truncated peer line",
+			dump:    "cHJpdmF0ZQ==\tcHVibGlj\t51820\toff\nPEER1=\t(none)\t(none)\n",
+			wantErr: true,
+		},
+		{
+			name:    "truncated interface line",
+			dump:    "cHJpdmF0ZQ==\tcHVibGlj\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			dump:    "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDeviceDump([]byte(tt.dump))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDeviceDump() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDeviceDump() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(*got.config, tt.wantConfig) {
+				t.Errorf("parseDeviceDump() config = %+v, want %+v", *got.config, tt.wantConfig)
+			}
+
+			if len(got.statuses) != len(tt.want) {
+				t.Fatalf("parseDeviceDump() returned %d peer statuses, want %d", len(got.statuses), len(tt.want))
+			}
+			for publicKey, want := range tt.want {
+				status, ok := got.statuses[publicKey]
+				if !ok {
+					t.Fatalf("parseDeviceDump() is missing the status of peer %s", publicKey)
+				}
+				if status.PublicKey != want.PublicKey || status.Endpoint != want.Endpoint ||
+					!status.LatestHandshake.Equal(want.LatestHandshake) ||
+					status.TransferRx != want.TransferRx || status.TransferTx != want.TransferTx {
+					t.Errorf("peer %s = %+v, want %+v", publicKey, *status, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package wireguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change.
+const diffContextLines = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// UnifiedDiff returns a unified diff (as produced by `diff -u`) between two texts.
+// An empty string means the texts are identical.
+func UnifiedDiff(fromName, toName string, from, to []byte) string {
+	a := splitLines(string(from))
+	b := splitLines(string(to))
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk until changes are more than 2*context lines apart
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContextLines {
+				break
+			}
+		}
+
+		hunkStart := max(start-diffContextLines, 0)
+		hunkEnd := min(end+diffContextLines, len(ops))
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		writeHunk(&sb, ops, hunkStart, hunkEnd)
+		start = hunkEnd
+	}
+	return sb.String()
+}
+
+// writeHunk writes ops[start:end] with its @@ header.
+func writeHunk(sb *strings.Builder, ops []diffOp, start, end int) {
+	fromLine, toLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			fromLine++
+		}
+		if op.kind != '-' {
+			toLine++
+		}
+	}
+
+	fromCount, toCount := 0, 0
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			fromCount++
+		}
+		if op.kind != '-' {
+			toCount++
+		}
+	}
+	// diff -u reports the line before an empty range
+	if fromCount == 0 {
+		fromLine--
+	}
+	if toCount == 0 {
+		toLine--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+	for _, op := range ops[start:end] {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.line)
+		sb.WriteByte('\n')
+	}
+}
+
+// diffLines computes a minimal line-based edit script with Myers' O(ND) algorithm in its
+// linear space variant, so large configs with few changes stay cheap in time and memory.
+func diffLines(a, b []string) []diffOp {
+	d := &lineDiff{a: a, b: b, ops: make([]diffOp, 0, len(a)+len(b))}
+	d.compare(0, len(a), 0, len(b))
+	return d.ops
+}
+
+type lineDiff struct {
+	a, b []string
+	ops  []diffOp
+}
+
+// compare appends the edit script turning a[aLo:aHi] into b[bLo:bHi].
+func (d *lineDiff) compare(aLo, aHi, bLo, bHi int) {
+	// Common prefix and suffix are unchanged
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, diffOp{' ', d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi -= suffix
+	bHi -= suffix
+
+	switch {
+	case aLo == aHi:
+		for ; bLo < bHi; bLo++ {
+			d.ops = append(d.ops, diffOp{'+', d.b[bLo]})
+		}
+	case bLo == bHi:
+		for ; aLo < aHi; aLo++ {
+			d.ops = append(d.ops, diffOp{'-', d.a[aLo]})
+		}
+	default:
+		// Both ranges start and end with a difference, so the middle snake splits them in two smaller problems
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, aLo+x, bLo, bLo+y)
+		for i := aLo + x; i < aLo+u; i++ {
+			d.ops = append(d.ops, diffOp{' ', d.a[i]})
+		}
+		d.compare(aLo+u, aHi, bLo+v, bHi)
+	}
+
+	for i := aHi; i < aHi+suffix; i++ {
+		d.ops = append(d.ops, diffOp{' ', d.a[i]})
+	}
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the middle snake of a shortest edit script
+// of a[aLo:aHi] into b[bLo:bHi], relative to aLo and bLo, by searching from both ends at once.
+func (d *lineDiff) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+
+	// forward[k] is the furthest x on diagonal k = x-y from the start, backward[k] the
+	// furthest distance from the end on diagonal k counted backwards
+	offset := maxD + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for step := 0; step <= maxD; step++ {
+		for k := -step; k <= step; k += 2 {
+			var x0 int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x0 = forward[offset+k+1]
+			} else {
+				x0 = forward[offset+k-1] + 1
+			}
+			y0 := x0 - k
+			x, y := x0, y0
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if odd && delta-k >= -(step-1) && delta-k <= step-1 && x+backward[offset+delta-k] >= n {
+				return x0, y0, x, y
+			}
+		}
+		for k := -step; k <= step; k += 2 {
+			var x0 int
+			if k == -step || (k != step && backward[offset+k-1] < backward[offset+k+1]) {
+				x0 = backward[offset+k+1]
+			} else {
+				x0 = backward[offset+k-1] + 1
+			}
+			y0 := x0 - k
+			x, y := x0, y0
+			for x < n && y < m && d.a[aHi-x-1] == d.b[bHi-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if !odd && delta-k >= -step && delta-k <= step && forward[offset+delta-k]+x >= n {
+				return n - x, m - y, n - x0, m - y0
+			}
+		}
+	}
+	// Unreachable: the searches meet after at most maxD steps. Deleting a and inserting b is still a valid script
+	return n, 0, n, 0
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
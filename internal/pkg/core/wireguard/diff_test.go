@@ -0,0 +1,91 @@
+package wireguard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "identical", a: "a b c", b: "a b c", want: " a  b  c"},
+		{name: "both empty", a: "", b: "", want: ""},
+		{name: "insert into empty", a: "", b: "a b", want: "+a +b"},
+		{name: "delete everything", a: "a b", b: "", want: "-a -b"},
+		{name: "insert in the middle", a: "a c", b: "a b c", want: " a +b  c"},
+		{name: "delete in the middle", a: "a b c", b: "a c", want: " a -b  c"},
+		{name: "replace a line", a: "a b c", b: "a x c", want: " a -b +x  c"},
+		{name: "nothing in common", a: "a b", b: "x y", want: "-a -b +x +y"},
+		{name: "moved line", a: "a b c d", b: "b c d a", want: "-a  b  c  d +a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := diffLines(strings.Fields(tt.a), strings.Fields(tt.b))
+			parts := make([]string, 0, len(ops))
+			for _, op := range ops {
+				parts = append(parts, string(op.kind)+op.line)
+			}
+			if got := strings.Join(parts, " "); got != tt.want {
+				t.Errorf("diffLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffLinesMinimal checks that the edit scripts rebuild both inputs and are as short as the LCS allows.
+func TestDiffLinesMinimal(t *testing.T) {
+	tests := []struct {
+		a, b string
+		lcs  int
+	}{
+		{a: "a b c a b b a", b: "c b a b a c", lcs: 4},
+		{a: "x a y b z c", b: "a b c", lcs: 3},
+		{a: "a a a a", b: "a a", lcs: 2},
+		{a: "p q r s t", b: "t s r q p", lcs: 1},
+		{a: "a b c d e f g", b: "a c e g b d f", lcs: 4},
+	}
+
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		ops := diffLines(a, b)
+
+		var from, to []string
+		edits := 0
+		for _, op := range ops {
+			if op.kind != '+' {
+				from = append(from, op.line)
+			}
+			if op.kind != '-' {
+				to = append(to, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(from, " ") != tt.a || strings.Join(to, " ") != tt.b {
+			t.Errorf("diffLines(%q, %q) rebuilds %q and %q", tt.a, tt.b, strings.Join(from, " "), strings.Join(to, " "))
+		}
+		if want := len(a) + len(b) - 2*tt.lcs; edits != want {
+			t.Errorf("diffLines(%q, %q) has %d edits, want %d", tt.a, tt.b, edits, want)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "[Interface]\nListenPort = 51820\n\n[Peer]\nPublicKey = cGVlcjE=\nAllowedIPs = 100.100.100.2/32\n"
+	to := "[Interface]\nListenPort = 51821\n\n[Peer]\nPublicKey = cGVlcjE=\nAllowedIPs = 100.100.100.2/32\n"
+	want := "--- revision 1\n+++ current\n" +
+		"@@ -1,5 +1,5 @@\n" +
+		" [Interface]\n-ListenPort = 51820\n+ListenPort = 51821\n \n [Peer]\n PublicKey = cGVlcjE=\n"
+
+	if got := UnifiedDiff("revision 1", "current", []byte(from), []byte(to)); got != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+	if got := UnifiedDiff("revision 1", "current", []byte(from), []byte(from)); got != "" {
+		t.Errorf("UnifiedDiff() of identical texts = %q, want empty", got)
+	}
+}
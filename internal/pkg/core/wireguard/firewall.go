@@ -0,0 +1,317 @@
+package wireguard
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// Supported values of wireguard.firewall.
+const (
+	// FirewallNone leaves forwarding between peers to the admin (e.g. PostUp rules).
+	FirewallNone = "none"
+	// FirewallNftables loads the ruleset as an nftables table with `nft -f`.
+	FirewallNftables = "nftables"
+	// FirewallIptables loads the ruleset as an iptables/ip6tables chain with a shell script.
+	FirewallIptables = "iptables"
+)
+
+// FirewallZone is a group of peer addresses whose traffic to and from the other peers
+// of the interface is dropped. Traffic to the server itself and to other networks is not affected.
+type FirewallZone struct {
+	// Name identifies the zone in the generated ruleset, e.g. the IP pool name.
+	Name string
+	// Prefixes are the peer addresses and the subnets routed behind them.
+	Prefixes []netip.Prefix
+	// AllowInternal lets the peers of the zone reach each other.
+	AllowInternal bool
+}
+
+// FirewallExit is the traffic of the peers egressing through the exit node of the interface. It enters
+// and leaves the interface like traffic between peers, and is accepted ahead of the zones as long as
+// its other end is outside the interface.
+type FirewallExit struct {
+	// Sources are the addresses and routed subnets of the peers assigned to the exit node.
+	Sources []netip.Prefix
+	// Internal are the IP pools of the interface and the subnets routed behind its peers.
+	Internal []netip.Prefix
+}
+
+// FirewallPath returns the path of the managed ruleset of the interface,
+// e.g. /etc/wireguard/wg0.nft, which can also be loaded from PostUp.
+func (m *ServerConfigManager) FirewallPath(backend string) string {
+	ext := ".nft"
+	if backend == FirewallIptables {
+		ext = ".rules.sh"
+	}
+	return filepath.Join(filepath.Dir(m.configPath), m.InterfaceName()+ext)
+}
+
+// ApplyFirewall writes the ruleset isolating zones, and letting exit traffic through, next to the
+// server config and loads it, replacing the rules loaded before. Nothing is loaded if the ruleset
+// did not change or the apply method does not manage the system.
+func (m *ServerConfigManager) ApplyFirewall(backend string, zones []FirewallZone, exit FirewallExit) error {
+	interfaceName := m.InterfaceName()
+	var content string
+	switch backend {
+	case FirewallNftables:
+		content = renderNftables(interfaceName, zones, exit)
+	case FirewallIptables:
+		content = renderIptables(interfaceName, zones, exit)
+	default:
+		return errors.WithCode(code.ErrWGApplyFailed, "unknown firewall backend: %s", backend)
+	}
+
+	m.firewallMu.Lock()
+	defer m.firewallMu.Unlock()
+
+	if content == m.lastFirewall {
+		return nil
+	}
+	path := m.FirewallPath(backend)
+	if err := writeFileAtomic(path, []byte(content), 0600); err != nil {
+		return errors.WithCode(code.ErrWGApplyFailed, "failed to write firewall ruleset: %s", err.Error())
+	}
+	if m.applyMethod == ApplyMethodNone || m.applyMethod == ApplyMethodFake {
+		klog.V(2).InfoS("apply method does not manage the system, skipping firewall load", "method", m.applyMethod, "path", path)
+	} else if err := loadFirewall(backend, path); err != nil {
+		return err
+	}
+	m.lastFirewall = content
+
+	klog.V(2).InfoS("firewall ruleset applied", "interface", interfaceName, "backend", backend, "zones", len(zones))
+	return nil
+}
+
+// RemoveFirewall unloads the ruleset of the interface and deletes its file.
+func (m *ServerConfigManager) RemoveFirewall(backend string) error {
+	interfaceName := m.InterfaceName()
+	m.firewallMu.Lock()
+	defer m.firewallMu.Unlock()
+
+	if m.applyMethod != ApplyMethodNone && m.applyMethod != ApplyMethodFake {
+		var err error
+		switch backend {
+		case FirewallNftables:
+			_, err = runCommand("sh", "-c", fmt.Sprintf("nft list table inet %[1]s >/dev/null 2>&1 || exit 0; nft delete table inet %[1]s", nftTableName(interfaceName)))
+		case FirewallIptables:
+			_, err = runCommand("sh", "-c", renderIptablesTeardown(interfaceName))
+		default:
+			return errors.WithCode(code.ErrWGApplyFailed, "unknown firewall backend: %s", backend)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(m.FirewallPath(backend)); err != nil && !os.IsNotExist(err) {
+		return errors.WithCode(code.ErrWGApplyFailed, "failed to remove firewall ruleset: %s", err.Error())
+	}
+	m.lastFirewall = ""
+	return nil
+}
+
+// loadFirewall loads a ruleset file written by ApplyFirewall.
+func loadFirewall(backend, path string) error {
+	var err error
+	if backend == FirewallNftables {
+		_, err = runCommand("nft", "-f", path)
+	} else {
+		_, err = runCommand("sh", path)
+	}
+	return err
+}
+
+// renderNftables renders the zones as an nftables table hooked into forwarding between
+// peers of the interface. The table is declared and deleted first so that loading the
+// file atomically replaces the previous ruleset.
+func renderNftables(interfaceName string, zones []FirewallZone, exit FirewallExit) string {
+	table := "inet " + nftTableName(interfaceName)
+	match := fmt.Sprintf("iifname %q oifname %q", interfaceName, interfaceName)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Managed by NexusPointWG, do not edit. Isolation policy of the IP pools of %s.\n", interfaceName)
+	fmt.Fprintf(&b, "table %s\ndelete table %s\n\n", table, table)
+	fmt.Fprintf(&b, "table %s {\n", table)
+	b.WriteString("\tchain forward {\n")
+	b.WriteString("\t\ttype filter hook forward priority filter; policy accept;\n")
+	for _, family := range exitFamilies(exit) {
+		b.WriteString("\n\t\t# exit node\n")
+		sources, internal := nftSet(family.sources), nftSet(family.internal)
+		fmt.Fprintf(&b, "\t\t%s %s saddr %s %s daddr != %s accept\n", match, family.name, sources, family.name, internal)
+		fmt.Fprintf(&b, "\t\t%s %s saddr != %s %s daddr %s accept\n", match, family.name, internal, family.name, sources)
+	}
+	for _, zone := range zones {
+		fmt.Fprintf(&b, "\n\t\t# %s\n", commentSafe(zone.Name))
+		for _, family := range splitFamilies(zone.Prefixes) {
+			set := nftSet(family.prefixes)
+			if zone.AllowInternal {
+				fmt.Fprintf(&b, "\t\t%s %s saddr %s %s daddr %s accept\n", match, family.name, set, family.name, set)
+			}
+			fmt.Fprintf(&b, "\t\t%s %s saddr %s drop\n", match, family.name, set)
+			fmt.Fprintf(&b, "\t\t%s %s daddr %s drop\n", match, family.name, set)
+		}
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+// renderIptables renders the zones as a shell script that (re)builds a chain jumped to
+// from FORWARD for traffic between peers of the interface. Exit traffic jumps to a second
+// chain, which returns traffic between internal addresses to the zones and accepts the rest.
+// IPv6 rules are skipped if ip6tables is not installed.
+func renderIptables(interfaceName string, zones []FirewallZone, exit FirewallExit) string {
+	chain := iptablesChainName(interfaceName)
+	exitChain := iptablesExitChainName(interfaceName)
+	exits := exitFamilies(exit)
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Managed by NexusPointWG, do not edit. Isolation policy of the IP pools of %s.\n", interfaceName)
+	b.WriteString("set -e\n")
+	for _, tool := range []string{"iptables", "ip6tables"} {
+		name := "ip"
+		if tool == "ip6tables" {
+			name = "ip6"
+			b.WriteString("\ncommand -v ip6tables >/dev/null 2>&1 || exit 0\n")
+		} else {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s -N %s 2>/dev/null || %s -F %s\n", tool, chain, tool, chain)
+		fmt.Fprintf(&b, "%[1]s -C FORWARD -i %[2]s -o %[2]s -j %[3]s 2>/dev/null || %[1]s -I FORWARD -i %[2]s -o %[2]s -j %[3]s\n", tool, interfaceName, chain)
+		fmt.Fprintf(&b, "%s -N %s 2>/dev/null || %s -F %s\n", tool, exitChain, tool, exitChain)
+		for _, family := range exits {
+			if family.name != name {
+				continue
+			}
+			b.WriteString("# exit node\n")
+			sources, internal := strings.Join(family.sources, ","), strings.Join(family.internal, ",")
+			fmt.Fprintf(&b, "%s -A %s -s %s -j %s\n", tool, chain, sources, exitChain)
+			fmt.Fprintf(&b, "%s -A %s -d %s -j %s\n", tool, chain, sources, exitChain)
+			fmt.Fprintf(&b, "%s -A %s -s %s -d %s -j RETURN\n", tool, exitChain, internal, internal)
+			fmt.Fprintf(&b, "%s -A %s -j ACCEPT\n", tool, exitChain)
+		}
+		for _, zone := range zones {
+			for _, family := range splitFamilies(zone.Prefixes) {
+				if family.name != name {
+					continue
+				}
+				fmt.Fprintf(&b, "# %s\n", commentSafe(zone.Name))
+				list := strings.Join(family.prefixes, ",")
+				if zone.AllowInternal {
+					fmt.Fprintf(&b, "%s -A %s -s %s -d %s -j ACCEPT\n", tool, chain, list, list)
+				}
+				fmt.Fprintf(&b, "%s -A %s -s %s -j DROP\n", tool, chain, list)
+				fmt.Fprintf(&b, "%s -A %s -d %s -j DROP\n", tool, chain, list)
+			}
+		}
+	}
+	return b.String()
+}
+
+// renderIptablesTeardown renders the shell commands removing the chains built by renderIptables.
+func renderIptablesTeardown(interfaceName string) string {
+	chain := iptablesChainName(interfaceName)
+	exitChain := iptablesExitChainName(interfaceName)
+	var commands []string
+	for _, tool := range []string{"iptables", "ip6tables"} {
+		commands = append(commands, fmt.Sprintf("if command -v %[1]s >/dev/null 2>&1 && %[1]s -L %[3]s >/dev/null 2>&1; then %[1]s -D FORWARD -i %[2]s -o %[2]s -j %[3]s 2>/dev/null; %[1]s -F %[3]s && %[1]s -X %[3]s; fi",
+			tool, interfaceName, chain))
+		commands = append(commands, fmt.Sprintf("if command -v %[1]s >/dev/null 2>&1 && %[1]s -L %[2]s >/dev/null 2>&1; then %[1]s -F %[2]s && %[1]s -X %[2]s; fi",
+			tool, exitChain))
+	}
+	return strings.Join(commands, "; ")
+}
+
+// firewallFamily holds the prefixes of one address family ("ip" or "ip6").
+type firewallFamily struct {
+	name     string
+	prefixes []string
+}
+
+// splitFamilies groups prefixes by address family, IPv4 first.
+func splitFamilies(prefixes []netip.Prefix) []firewallFamily {
+	v4 := firewallFamily{name: "ip"}
+	v6 := firewallFamily{name: "ip6"}
+	for _, prefix := range prefixes {
+		if prefix.Addr().Is4() {
+			v4.prefixes = append(v4.prefixes, prefix.Masked().String())
+		} else {
+			v6.prefixes = append(v6.prefixes, prefix.Masked().String())
+		}
+	}
+	var families []firewallFamily
+	for _, family := range []firewallFamily{v4, v6} {
+		if len(family.prefixes) > 0 {
+			families = append(families, family)
+		}
+	}
+	return families
+}
+
+// exitFamily holds the exit sources and internal prefixes of one address family ("ip" or "ip6").
+type exitFamily struct {
+	name     string
+	sources  []string
+	internal []string
+}
+
+// exitFamilies groups the exit traffic by address family, IPv4 first. Families without
+// sources or internal prefixes are left out.
+func exitFamilies(exit FirewallExit) []exitFamily {
+	var families []exitFamily
+	internal := splitFamilies(exit.Internal)
+	for _, sources := range splitFamilies(exit.Sources) {
+		for _, family := range internal {
+			if family.name == sources.name {
+				families = append(families, exitFamily{name: sources.name, sources: sources.prefixes, internal: family.prefixes})
+			}
+		}
+	}
+	return families
+}
+
+// nftSet renders prefixes as an anonymous nftables set.
+func nftSet(prefixes []string) string {
+	return "{ " + strings.Join(prefixes, ", ") + " }"
+}
+
+// nftTableName returns the name of the nftables table of the interface, e.g. nexuspointwg_wg0.
+func nftTableName(interfaceName string) string {
+	return "nexuspointwg_" + identifierSafe(interfaceName)
+}
+
+// iptablesChainName returns the name of the iptables chain of the interface, e.g. NXWG-wg0.
+func iptablesChainName(interfaceName string) string {
+	return "NXWG-" + identifierSafe(interfaceName)
+}
+
+// iptablesExitChainName returns the name of the iptables chain accepting exit traffic, e.g. NXWG-wg0-exit.
+func iptablesExitChainName(interfaceName string) string {
+	return iptablesChainName(interfaceName) + "-exit"
+}
+
+// identifierSafe replaces the characters interface names may contain but nftables identifiers may not.
+func identifierSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// commentSafe strips line breaks so that a name can be written on a comment line.
+func commentSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,77 @@
+package wireguard
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestRenderFirewallExit(t *testing.T) {
+	zones := []FirewallZone{{
+		Name:     "office",
+		Prefixes: []netip.Prefix{netip.MustParsePrefix("100.100.100.0/24")},
+	}}
+	exit := FirewallExit{
+		Sources:  []netip.Prefix{netip.MustParsePrefix("100.100.100.2/32")},
+		Internal: []netip.Prefix{netip.MustParsePrefix("100.100.100.0/24"), netip.MustParsePrefix("192.168.5.0/24")},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		// want are lines expected in this order: the exit traffic is accepted ahead of the zone drops
+		want []string
+	}{
+		{
+			name:    "nftables",
+			content: renderNftables("wg0", zones, exit),
+			want: []string{
+				`iifname "wg0" oifname "wg0" ip saddr { 100.100.100.2/32 } ip daddr != { 100.100.100.0/24, 192.168.5.0/24 } accept`,
+				`iifname "wg0" oifname "wg0" ip saddr != { 100.100.100.0/24, 192.168.5.0/24 } ip daddr { 100.100.100.2/32 } accept`,
+				`iifname "wg0" oifname "wg0" ip saddr { 100.100.100.0/24 } drop`,
+				`iifname "wg0" oifname "wg0" ip daddr { 100.100.100.0/24 } drop`,
+			},
+		},
+		{
+			name:    "iptables",
+			content: renderIptables("wg0", zones, exit),
+			want: []string{
+				"iptables -A NXWG-wg0 -s 100.100.100.2/32 -j NXWG-wg0-exit",
+				"iptables -A NXWG-wg0 -d 100.100.100.2/32 -j NXWG-wg0-exit",
+				"iptables -A NXWG-wg0-exit -s 100.100.100.0/24,192.168.5.0/24 -d 100.100.100.0/24,192.168.5.0/24 -j RETURN",
+				"iptables -A NXWG-wg0-exit -j ACCEPT",
+				"iptables -A NXWG-wg0 -s 100.100.100.0/24 -j DROP",
+				"iptables -A NXWG-wg0 -d 100.100.100.0/24 -j DROP",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest := tt.content
+			for _, line := range tt.want {
+				i := strings.Index(rest, line)
+				if i < 0 {
+					t.Fatalf("ruleset is missing %q after the previous rules:\n%s", line, tt.content)
+				}
+				rest = rest[i+len(line):]
+			}
+			if strings.Contains(tt.content, "ip6 saddr") || strings.Contains(tt.content, "ip6tables -A") {
+				t.Errorf("ruleset has IPv6 rules without IPv6 prefixes:\n%s", tt.content)
+			}
+		})
+	}
+}
+
+func TestRenderFirewallWithoutExit(t *testing.T) {
+	zones := []FirewallZone{{
+		Name:     "office",
+		Prefixes: []netip.Prefix{netip.MustParsePrefix("100.100.100.0/24")},
+	}}
+	if content := renderNftables("wg0", zones, FirewallExit{}); strings.Contains(content, "accept\n") {
+		t.Errorf("nftables ruleset accepts traffic without an exit node:\n%s", content)
+	}
+	if content := renderIptables("wg0", zones, FirewallExit{}); strings.Contains(content, "-j NXWG-wg0-exit") {
+		t.Errorf("iptables ruleset jumps to the exit chain without an exit node:\n%s", content)
+	}
+}
@@ -0,0 +1,378 @@
+package wireguard
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// historyLimit is the number of revisions kept per interface (0 keeps all).
+var historyLimit atomic.Int64
+
+func init() {
+	historyLimit.Store(50)
+}
+
+// SetHistoryLimit sets the number of server config revisions kept per interface (0 keeps all).
+func SetHistoryLimit(limit int) {
+	historyLimit.Store(int64(limit))
+}
+
+// RevisionInfo describes who made a server config change and through which API call.
+type RevisionInfo struct {
+	Actor  string
+	Action string
+}
+
+type revisionInfoKey struct{}
+
+// WithRevisionInfo returns a context carrying the revision info recorded with config writes.
+func WithRevisionInfo(ctx context.Context, info RevisionInfo) context.Context {
+	return context.WithValue(ctx, revisionInfoKey{}, info)
+}
+
+// RevisionInfoFromContext returns the revision info of ctx. Writes without one are attributed to "system".
+func RevisionInfoFromContext(ctx context.Context) RevisionInfo {
+	info, _ := ctx.Value(revisionInfoKey{}).(RevisionInfo)
+	if info.Actor == "" {
+		info.Actor = "system"
+	}
+	return info
+}
+
+// Revision is the metadata of one stored version of a server config file.
+type Revision struct {
+	Number    int       `json:"number"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	SHA256    string    `json:"sha256"`
+	Size      int       `json:"size"`
+}
+
+// historyDir returns the directory holding the revisions of the config file (e.g. wg0.conf -> wg0.history).
+func (m *ServerConfigManager) historyDir() string {
+	return strings.TrimSuffix(m.configPath, filepath.Ext(m.configPath)) + ".history"
+}
+
+func (m *ServerConfigManager) revisionPath(number int, ext string) string {
+	return filepath.Join(m.historyDir(), fmt.Sprintf("%06d%s", number, ext))
+}
+
+// ListRevisions returns the stored revisions, newest first. The newest revision matches the live file.
+func (m *ServerConfigManager) ListRevisions() ([]*Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.listRevisionsUnsafe()
+}
+
+// ReadRevision returns the content and metadata of a stored revision.
+func (m *ServerConfigManager) ReadRevision(number int) ([]byte, *Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.readRevisionUnsafe(number)
+}
+
+// DiffRevisions returns a unified diff from one revision to another.
+// A to of 0 compares against the live config file.
+func (m *ServerConfigManager) DiffRevisions(from, to int) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	fromContent, _, err := m.readRevisionUnsafe(from)
+	if err != nil {
+		return "", err
+	}
+
+	toName := "current"
+	var toContent []byte
+	if to == 0 {
+		toContent, err = os.ReadFile(m.configPath)
+		if err != nil && !os.IsNotExist(err) {
+			return "", errors.WithCode(code.ErrWGConfigHistoryFailed, "failed to read server config: %s", err.Error())
+		}
+	} else {
+		toName = fmt.Sprintf("revision %d", to)
+		if toContent, _, err = m.readRevisionUnsafe(to); err != nil {
+			return "", err
+		}
+	}
+
+	return UnifiedDiff(fmt.Sprintf("revision %d", from), toName, fromContent, toContent), nil
+}
+
+// RollbackToRevision writes the content of a stored revision back as the live config file.
+// The rollback itself is recorded as a new revision, so it can be undone. If the file is restored
+// but the revision cannot be recorded, the error has code ErrWGConfigHistoryFailed.
+func (m *ServerConfigManager) RollbackToRevision(ctx context.Context, number int) (*Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	content, _, err := m.readRevisionUnsafe(number)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the content still parses before it goes live
+	if _, err := m.readConfigFile(bytes.NewReader(content)); err != nil {
+		return nil, errors.Wrap(err, "revision cannot be parsed")
+	}
+
+	info := RevisionInfoFromContext(ctx)
+	info.Action = strings.TrimSpace(fmt.Sprintf("%s (rollback to revision %d)", info.Action, number))
+	revision, err := m.writeContentUnsafe(content, info)
+	if err != nil && errors.ParseCoder(err).Code() != code.ErrWGConfigHistoryFailed {
+		return nil, err
+	}
+
+	// The file is restored even if its revision could not be recorded
+	m.serverPublicKeyCache = ""
+	return revision, err
+}
+
+// ExternalEdit reports whether the live config file was written outside of NexusPointWG,
+// i.e. differs from the newest revision or was never recorded.
+func (m *ServerConfigManager) ExternalEdit() (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	content, err := os.ReadFile(m.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "failed to read server config file")
+	}
+	revisions, err := m.listRevisionsUnsafe()
+	if err != nil {
+		return false, err
+	}
+	return len(revisions) == 0 || revisions[0].SHA256 != contentHash(content), nil
+}
+
+// ImportExternalEdit records the live config file as a revision if it was written outside of NexusPointWG.
+func (m *ServerConfigManager) ImportExternalEdit() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.importExistingUnsafe()
+}
+
+// writeContentUnsafe atomically replaces the config file and records the new content as a revision.
+// If only recording the revision fails, the file is written and the error has code ErrWGConfigHistoryFailed
+// (caller must hold lock).
+func (m *ServerConfigManager) writeContentUnsafe(content []byte, info RevisionInfo) (*Revision, error) {
+	// Keep the version written outside of NexusPointWG before overwriting it
+	if err := m.importExistingUnsafe(); err != nil {
+		klog.V(1).InfoS("failed to import existing server config into history", "path", m.configPath, "error", err)
+	}
+
+	if err := writeFileAtomic(m.configPath, content, 0600); err != nil {
+		return nil, err
+	}
+
+	revision, err := m.recordRevisionUnsafe(content, info)
+	if err != nil {
+		// The config itself is written, the caller decides whether a missing revision is fatal
+		return nil, errors.WithCode(code.ErrWGConfigHistoryFailed, "server config written, but its revision could not be recorded: %s", err.Error())
+	}
+	return revision, nil
+}
+
+// importExistingUnsafe records the live file as a revision if it differs from the latest one,
+// e.g. on the first write or after the file was edited by hand.
+func (m *ServerConfigManager) importExistingUnsafe() error {
+	content, err := os.ReadFile(m.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	revisions, err := m.listRevisionsUnsafe()
+	if err != nil {
+		return err
+	}
+	if len(revisions) > 0 && revisions[0].SHA256 == contentHash(content) {
+		return nil
+	}
+
+	_, err = m.recordRevisionUnsafe(content, RevisionInfo{Actor: "system", Action: "import existing config file"})
+	return err
+}
+
+// recordRevisionUnsafe stores content as the next revision and prunes old ones (caller must hold lock).
+func (m *ServerConfigManager) recordRevisionUnsafe(content []byte, info RevisionInfo) (*Revision, error) {
+	if err := os.MkdirAll(m.historyDir(), 0700); err != nil {
+		return nil, errors.WithCode(code.ErrWGConfigHistoryFailed, "failed to create history directory: %s", err.Error())
+	}
+
+	revisions, err := m.listRevisionsUnsafe()
+	if err != nil {
+		return nil, err
+	}
+	number := 1
+	if len(revisions) > 0 {
+		number = revisions[0].Number + 1
+	}
+
+	revision := &Revision{
+		Number:    number,
+		CreatedAt: time.Now(),
+		Actor:     info.Actor,
+		Action:    info.Action,
+		SHA256:    contentHash(content),
+		Size:      len(content),
+	}
+	meta, err := json.MarshalIndent(revision, "", "  ")
+	if err != nil {
+		return nil, errors.WithCode(code.ErrWGConfigHistoryFailed, "failed to encode revision: %s", err.Error())
+	}
+
+	// Content first, metadata last: a revision only exists once its metadata is written
+	if err := writeFileAtomic(m.revisionPath(number, ".conf"), content, 0600); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(m.revisionPath(number, ".json"), meta, 0600); err != nil {
+		return nil, err
+	}
+
+	m.pruneRevisionsUnsafe(append([]*Revision{revision}, revisions...))
+	return revision, nil
+}
+
+// pruneRevisionsUnsafe removes the revisions beyond the history limit (revisions sorted newest first).
+func (m *ServerConfigManager) pruneRevisionsUnsafe(revisions []*Revision) {
+	limit := int(historyLimit.Load())
+	if limit <= 0 || len(revisions) <= limit {
+		return
+	}
+	for _, revision := range revisions[limit:] {
+		_ = os.Remove(m.revisionPath(revision.Number, ".json"))
+		_ = os.Remove(m.revisionPath(revision.Number, ".conf"))
+	}
+}
+
+func (m *ServerConfigManager) listRevisionsUnsafe() ([]*Revision, error) {
+	entries, err := os.ReadDir(m.historyDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithCode(code.ErrWGConfigHistoryFailed, "failed to read history directory: %s", err.Error())
+	}
+
+	revisions := make([]*Revision, 0, len(entries)/2)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		revision, err := m.readRevisionMetaUnsafe(number)
+		if err != nil {
+			klog.V(1).InfoS("skipping unreadable server config revision", "path", m.revisionPath(number, ".json"), "error", err)
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Number > revisions[j].Number })
+	return revisions, nil
+}
+
+func (m *ServerConfigManager) readRevisionMetaUnsafe(number int) (*Revision, error) {
+	data, err := os.ReadFile(m.revisionPath(number, ".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.WithCode(code.ErrWGConfigRevisionNotFound, "revision %d not found", number)
+		}
+		return nil, errors.WithCode(code.ErrWGConfigHistoryFailed, "failed to read revision %d: %s", number, err.Error())
+	}
+	var revision Revision
+	if err := json.Unmarshal(data, &revision); err != nil {
+		return nil, errors.WithCode(code.ErrWGConfigHistoryFailed, "failed to decode revision %d: %s", number, err.Error())
+	}
+	return &revision, nil
+}
+
+func (m *ServerConfigManager) readRevisionUnsafe(number int) ([]byte, *Revision, error) {
+	revision, err := m.readRevisionMetaUnsafe(number)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := os.ReadFile(m.revisionPath(number, ".conf"))
+	if err != nil {
+		return nil, nil, errors.WithCode(code.ErrWGConfigHistoryFailed, "failed to read revision %d: %s", number, err.Error())
+	}
+	return content, revision, nil
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames it into place,
+// so readers and crashes never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create config directory")
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp file")
+	}
+	tmpPath := tmp.Name()
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return errors.Wrap(err, "failed to write temp file")
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		cleanup()
+		return errors.Wrap(err, "failed to chmod temp file")
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return errors.Wrap(err, "failed to sync temp file")
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to close temp file")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to rename temp file")
+	}
+
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
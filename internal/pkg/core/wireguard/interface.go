@@ -0,0 +1,88 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// interfaceNameRegexp matches valid Linux interface names (IFNAMSIZ - 1 = 15 characters).
+var interfaceNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_=+.-]{1,15}$`)
+
+// managers holds one ServerConfigManager per config file path,
+// so that all callers touching the same interface share a single lock.
+var managers sync.Map
+
+// ValidateInterfaceName validates a WireGuard interface name, which is also the config file base name.
+func ValidateInterfaceName(name string) error {
+	if !interfaceNameRegexp.MatchString(name) || name == "." || name == ".." {
+		return errors.WithCode(code.ErrWGInterfaceNameInvalid, "invalid interface name: %s", name)
+	}
+	return nil
+}
+
+// GetServerConfigManager returns the shared ServerConfigManager for a config file,
+// creating it on first use.
+func GetServerConfigManager(configPath, applyMethod string) *ServerConfigManager {
+	if m, ok := managers.Load(configPath); ok {
+		return m.(*ServerConfigManager)
+	}
+	m, _ := managers.LoadOrStore(configPath, NewServerConfigManager(configPath, applyMethod))
+	return m.(*ServerConfigManager)
+}
+
+// InterfaceName returns the interface name derived from the config path (e.g., /etc/wireguard/wg0.conf -> wg0).
+func (m *ServerConfigManager) InterfaceName() string {
+	baseName := filepath.Base(m.configPath)
+	return strings.TrimSuffix(baseName, filepath.Ext(baseName))
+}
+
+// StartInterface brings the interface up and enables it on boot.
+func (m *ServerConfigManager) StartInterface() error {
+	return m.systemctl("enable", "--now")
+}
+
+// StopInterface brings the interface down and disables it on boot.
+func (m *ServerConfigManager) StopInterface() error {
+	return m.systemctl("disable", "--now")
+}
+
+// ArchiveConfig moves the config file aside (<path>.backup) and forgets the shared manager.
+// The archived file is skipped by config sync.
+func (m *ServerConfigManager) ArchiveConfig() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.Rename(m.configPath, m.configPath+".backup"); err != nil && !os.IsNotExist(err) {
+		return errors.WithCode(code.ErrWGWriteServerConfigFailed, "failed to archive server config: %s", err.Error())
+	}
+	managers.Delete(m.configPath)
+	return nil
+}
+
+// systemctl runs a systemctl command against the wg-quick unit of the interface.
+func (m *ServerConfigManager) systemctl(args ...string) error {
+	if m.applyMethod == ApplyMethodNone || m.applyMethod == ApplyMethodFake {
+		klog.V(2).InfoS("apply method does not manage the system, skipping systemctl", "method", m.applyMethod, "args", args)
+		return nil
+	}
+
+	interfaceName := m.InterfaceName()
+	cmd := exec.Command("systemctl", append(args, fmt.Sprintf("wg-quick@%s", interfaceName))...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		klog.V(1).InfoS("failed to run systemctl for WireGuard interface", "interface", interfaceName, "args", args, "error", err, "output", string(output))
+		return errors.WithCode(code.ErrWGApplyFailed, "failed to run systemctl %s for %s: %s", strings.Join(args, " "), interfaceName, string(output))
+	}
+
+	klog.V(2).InfoS("systemctl completed for WireGuard interface", "interface", interfaceName, "args", args)
+	return nil
+}
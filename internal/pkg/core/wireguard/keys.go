@@ -118,3 +118,36 @@ func ValidatePublicKey(publicKey string) error {
 
 	return nil
 }
+
+// GeneratePresharedKey generates a new WireGuard preshared key.
+// A preshared key is 32 random bytes encoded in base64 (same as `wg genpsk`).
+// Unlike private keys, no clamping is applied.
+func GeneratePresharedKey() (string, error) {
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		klog.V(1).InfoS("failed to generate random bytes for preshared key", "error", err)
+		return "", errors.WithCode(code.ErrWGKeyGenerationFailed, "failed to generate random bytes: %s", err.Error())
+	}
+
+	return base64.StdEncoding.EncodeToString(keyBytes), nil
+}
+
+// ValidatePresharedKey validates that a string is a valid WireGuard preshared key.
+func ValidatePresharedKey(presharedKey string) error {
+	if presharedKey == "" {
+		return errors.WithCode(code.ErrWGPresharedKeyInvalid, "preshared key is empty")
+	}
+
+	// Validate base64 encoding
+	decoded, err := base64.StdEncoding.DecodeString(presharedKey)
+	if err != nil {
+		return errors.WithCode(code.ErrWGPresharedKeyInvalid, "preshared key is not valid base64: %s", err.Error())
+	}
+
+	// WireGuard preshared keys are 32 bytes
+	if len(decoded) != 32 {
+		return errors.WithCode(code.ErrWGPresharedKeyInvalid, "preshared key must be 32 bytes, got %d", len(decoded))
+	}
+
+	return nil
+}
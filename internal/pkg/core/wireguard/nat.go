@@ -0,0 +1,233 @@
+package wireguard
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// linkNamePattern matches valid Linux network interface names.
+var linkNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,15}$`)
+
+// NATSettings are the structured NAT and forwarding settings of an interface, rendered into a
+// managed script that wg-quick runs from PostUp and PostDown.
+type NATSettings struct {
+	// EgressInterface is the interface peers reach the internet through, e.g. eth0.
+	EgressInterface string
+	// Masquerade translates the source of peer traffic leaving through EgressInterface.
+	Masquerade bool
+	// IPForwarding enables forwarding in the kernel when the interface comes up.
+	IPForwarding bool
+	// MSSClamping clamps the MSS of TCP connections entering the tunnel to the path MTU.
+	MSSClamping bool
+}
+
+// Enabled reports whether any setting is on, i.e. whether the interface needs the script.
+func (s NATSettings) Enabled() bool {
+	return s.Masquerade || s.IPForwarding || s.MSSClamping
+}
+
+// Validate checks that the egress interface is a valid interface name and set when masquerading.
+func (s NATSettings) Validate() error {
+	if s.EgressInterface != "" && !linkNamePattern.MatchString(s.EgressInterface) {
+		return errors.WithCode(code.ErrValidation, "invalid egress interface name: %s", s.EgressInterface)
+	}
+	if s.Masquerade && s.EgressInterface == "" {
+		return errors.WithCode(code.ErrValidation, "masquerade requires an egress interface")
+	}
+	return nil
+}
+
+// NATPath returns the path of the managed NAT script of the interface, e.g. /etc/wireguard/wg0.nat.sh.
+func (m *ServerConfigManager) NATPath() string {
+	return filepath.Join(filepath.Dir(m.configPath), m.InterfaceName()+".nat.sh")
+}
+
+// NATHooks returns the PostUp and PostDown commands running the managed NAT script.
+func (m *ServerConfigManager) NATHooks() (string, string) {
+	path := m.NATPath()
+	return "sh " + path + " up", "sh " + path + " down"
+}
+
+// MergeHook returns the hook running command followed by the raw commands, or only the raw
+// commands if enabled is false.
+func MergeHook(raw, command string, enabled bool) string {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case !enabled:
+		return raw
+	case raw == "":
+		return command
+	default:
+		return command + "; " + raw
+	}
+}
+
+// StripHook returns the raw commands of a hook written by MergeHook, without command.
+func StripHook(hook, command string) string {
+	hook = strings.TrimSpace(hook)
+	if hook == command {
+		return ""
+	}
+	if raw, ok := strings.CutPrefix(hook, command+"; "); ok {
+		return raw
+	}
+	return hook
+}
+
+// ApplyNAT writes the NAT script of the interface for the server addresses (the Address of the
+// server config) and loads it, unloading the rules of the previous script first. The rules are
+// loaded with nftables if backend is FirewallNftables and with iptables otherwise. Nothing is
+// written while the interface never had NAT settings.
+func (m *ServerConfigManager) ApplyNAT(backend, address string, settings NATSettings) error {
+	interfaceName := m.InterfaceName()
+	var sources []netip.Prefix
+	for _, part := range strings.Split(address, ",") {
+		if prefix, err := netip.ParsePrefix(strings.TrimSpace(part)); err == nil {
+			sources = append(sources, prefix.Masked())
+		}
+	}
+	content := renderNATScript(interfaceName, backend, sources, settings)
+
+	m.firewallMu.Lock()
+	defer m.firewallMu.Unlock()
+
+	if content == m.lastNAT {
+		return nil
+	}
+	path := m.NATPath()
+	_, statErr := os.Stat(path)
+	exists := statErr == nil
+	if !settings.Enabled() && !exists {
+		return nil
+	}
+
+	managed := m.applyMethod != ApplyMethodNone && m.applyMethod != ApplyMethodFake
+	if managed && exists {
+		if _, err := runCommand("sh", path, "down"); err != nil {
+			klog.V(1).InfoS("failed to unload previous NAT rules", "interface", interfaceName, "error", err)
+		}
+	}
+	if err := writeFileAtomic(path, []byte(content), 0600); err != nil {
+		return errors.WithCode(code.ErrWGApplyFailed, "failed to write NAT script: %s", err.Error())
+	}
+	if !managed {
+		klog.V(2).InfoS("apply method does not manage the system, skipping NAT load", "method", m.applyMethod, "path", path)
+	} else if _, err := runCommand("sh", path, "up"); err != nil {
+		return err
+	}
+	m.lastNAT = content
+
+	klog.V(2).InfoS("NAT rules applied", "interface", interfaceName, "backend", backend, "egress", settings.EgressInterface,
+		"masquerade", settings.Masquerade, "forwarding", settings.IPForwarding, "mssClamping", settings.MSSClamping)
+	return nil
+}
+
+// renderNATScript renders the settings as a shell script taking "up" or "down". Loading is
+// idempotent, so the script may run both from PostUp and when the settings change.
+// Kernel forwarding is left on when the interface goes down, other services may rely on it.
+func renderNATScript(interfaceName, backend string, sources []netip.Prefix, settings NATSettings) string {
+	var up, down []string
+	if settings.IPForwarding {
+		up = append(up, "sysctl -q -w net.ipv4.ip_forward=1")
+		for _, family := range splitFamilies(sources) {
+			if family.name == "ip6" {
+				up = append(up, "sysctl -q -w net.ipv6.conf.all.forwarding=1")
+			}
+		}
+	}
+	if backend == FirewallNftables {
+		natUp, natDown := renderNATNftables(interfaceName, sources, settings)
+		up = append(up, natUp...)
+		down = append(down, natDown...)
+	} else {
+		natUp, natDown := renderNATIptables(interfaceName, sources, settings)
+		up = append(up, natUp...)
+		down = append(down, natDown...)
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Managed by NexusPointWG, do not edit. NAT and forwarding of %s, run from PostUp and PostDown.\n", interfaceName)
+	b.WriteString("set -e\n\n")
+	b.WriteString("case \"$1\" in\nup)\n")
+	for _, line := range up {
+		b.WriteString("\t" + strings.ReplaceAll(line, "\n", "\n\t") + "\n")
+	}
+	b.WriteString("\t;;\ndown)\n")
+	for _, line := range down {
+		b.WriteString("\t" + line + "\n")
+	}
+	b.WriteString("\t;;\n*)\n\techo \"usage: $0 up|down\" >&2\n\texit 1\n\t;;\nesac\n")
+	return b.String()
+}
+
+// renderNATIptables renders the rules as iptables/ip6tables commands. Each rule is only inserted
+// if missing and deleted if present. Forwarding to and from the egress interface is accepted
+// ahead of other rules, as distributions and Docker often default the FORWARD policy to DROP.
+func renderNATIptables(interfaceName string, sources []netip.Prefix, settings NATSettings) ([]string, []string) {
+	var up, down []string
+	for _, family := range splitFamilies(sources) {
+		tool := "iptables"
+		if family.name == "ip6" {
+			tool = "ip6tables"
+		}
+		var rules []string
+		if settings.Masquerade {
+			for _, source := range family.prefixes {
+				rules = append(rules, fmt.Sprintf("-t nat %%s POSTROUTING -s %s -o %s -j MASQUERADE", source, settings.EgressInterface))
+			}
+			rules = append(rules,
+				fmt.Sprintf("%%s FORWARD -i %s -o %s -j ACCEPT", interfaceName, settings.EgressInterface),
+				fmt.Sprintf("%%s FORWARD -i %s -o %s -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT", settings.EgressInterface, interfaceName))
+		}
+		if settings.MSSClamping {
+			rules = append(rules, fmt.Sprintf("-t mangle %%s FORWARD -o %s -p tcp -m tcp --tcp-flags SYN,RST SYN -j TCPMSS --clamp-mss-to-pmtu", interfaceName))
+		}
+		for _, rule := range rules {
+			up = append(up, fmt.Sprintf("%s %s 2>/dev/null || %s %s", tool, fmt.Sprintf(rule, "-C"), tool, fmt.Sprintf(rule, "-I")))
+			down = append(down, fmt.Sprintf("%s %s 2>/dev/null || true", tool, fmt.Sprintf(rule, "-D")))
+		}
+	}
+	return up, down
+}
+
+// renderNATNftables renders the rules as an nftables table of its own, replaced as a whole when loaded.
+// Accepting forwarded traffic is left to the admin, as an accept verdict in this table cannot
+// override a drop in another one.
+func renderNATNftables(interfaceName string, sources []netip.Prefix, settings NATSettings) ([]string, []string) {
+	if !settings.Masquerade && !settings.MSSClamping {
+		return nil, nil
+	}
+	table := "inet " + nftTableName(interfaceName) + "_nat"
+
+	var b strings.Builder
+	b.WriteString("nft -f - <<-'EOF'\n")
+	fmt.Fprintf(&b, "table %s\ndelete table %s\n", table, table)
+	fmt.Fprintf(&b, "table %s {\n", table)
+	if settings.Masquerade {
+		b.WriteString("\tchain postrouting {\n")
+		b.WriteString("\t\ttype nat hook postrouting priority srcnat; policy accept;\n")
+		for _, family := range splitFamilies(sources) {
+			fmt.Fprintf(&b, "\t\toifname %q %s saddr %s masquerade\n", settings.EgressInterface, family.name, nftSet(family.prefixes))
+		}
+		b.WriteString("\t}\n")
+	}
+	if settings.MSSClamping {
+		b.WriteString("\tchain forward {\n")
+		b.WriteString("\t\ttype filter hook forward priority mangle; policy accept;\n")
+		fmt.Fprintf(&b, "\t\toifname %q tcp flags syn tcp option maxseg size set rt mtu\n", interfaceName)
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("}\nEOF")
+
+	down := fmt.Sprintf("nft delete table %s 2>/dev/null || true", table)
+	return []string{b.String()}, []string{down}
+}
@@ -0,0 +1,91 @@
+package wireguard
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// PortForwardRule forwards a port of the server to a port of a peer of the interface.
+type PortForwardRule struct {
+	// Protocol is "tcp" or "udp".
+	Protocol   string
+	PublicPort int
+	TargetIP   netip.Addr
+	TargetPort int
+	// Comment identifies the rule in the generated ruleset, e.g. the peer name.
+	Comment string
+}
+
+// PortForwardPath returns the path of the managed port forward ruleset of the interface,
+// e.g. /etc/wireguard/wg0.forward.nft.
+func (m *ServerConfigManager) PortForwardPath() string {
+	return filepath.Join(filepath.Dir(m.configPath), m.InterfaceName()+".forward.nft")
+}
+
+// ApplyPortForwards writes the nftables ruleset forwarding rules next to the server config and loads it,
+// replacing the forwards loaded before. Nothing is written while the interface never had a forward,
+// so that nft is only required once port forwarding is used.
+func (m *ServerConfigManager) ApplyPortForwards(rules []PortForwardRule) error {
+	interfaceName := m.InterfaceName()
+	content := renderPortForwards(interfaceName, rules)
+
+	m.firewallMu.Lock()
+	defer m.firewallMu.Unlock()
+
+	if content == m.lastPortForwards {
+		return nil
+	}
+	path := m.PortForwardPath()
+	if len(rules) == 0 && m.lastPortForwards == "" {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	if err := writeFileAtomic(path, []byte(content), 0600); err != nil {
+		return errors.WithCode(code.ErrWGApplyFailed, "failed to write port forward ruleset: %s", err.Error())
+	}
+	if m.applyMethod == ApplyMethodNone || m.applyMethod == ApplyMethodFake {
+		klog.V(2).InfoS("apply method does not manage the system, skipping port forward load", "method", m.applyMethod, "path", path)
+	} else if err := loadFirewall(FirewallNftables, path); err != nil {
+		return err
+	}
+	m.lastPortForwards = content
+
+	klog.V(2).InfoS("port forward ruleset applied", "interface", interfaceName, "forwards", len(rules))
+	return nil
+}
+
+// renderPortForwards renders the rules as an nftables table that translates the destination of
+// connections to the server itself (DNAT) and masquerades them behind the server address on the
+// interface, so that peers answer through the tunnel whatever their default route is.
+func renderPortForwards(interfaceName string, rules []PortForwardRule) string {
+	table := "ip " + nftTableName(interfaceName) + "_forward"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Managed by NexusPointWG, do not edit. Port forwards to the peers of %s.\n", interfaceName)
+	fmt.Fprintf(&b, "table %s\ndelete table %s\n\n", table, table)
+	fmt.Fprintf(&b, "table %s {\n", table)
+	b.WriteString("\tchain prerouting {\n")
+	b.WriteString("\t\ttype nat hook prerouting priority dstnat; policy accept;\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "\t\t# %s\n", commentSafe(rule.Comment))
+		fmt.Fprintf(&b, "\t\tfib daddr type local iifname != %q %s dport %d dnat to %s:%d\n",
+			interfaceName, rule.Protocol, rule.PublicPort, rule.TargetIP, rule.TargetPort)
+	}
+	b.WriteString("\t}\n\n")
+	b.WriteString("\tchain postrouting {\n")
+	b.WriteString("\t\ttype nat hook postrouting priority srcnat; policy accept;\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "\t\toifname %q ip daddr %s %s dport %d ct status dnat masquerade\n",
+			interfaceName, rule.TargetIP, rule.Protocol, rule.TargetPort)
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
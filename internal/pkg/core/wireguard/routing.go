@@ -0,0 +1,162 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultRoutingTable numbers the exit node routing table when the interface has no listen port.
+	defaultRoutingTable = 51820
+	// exitRulePriority is the priority of the policy routing rules of the exit node,
+	// ahead of the main table (32766).
+	exitRulePriority = 5000
+)
+
+// ExitRouting describes the exit node of an interface and the peer addresses egressing through it.
+type ExitRouting struct {
+	// ExitNode names the exit peer in the generated script, empty if the interface has none.
+	ExitNode string
+	// Sources are the addresses and routed subnets of the peers assigned to the exit node.
+	Sources []netip.Prefix
+}
+
+// RoutingPath returns the path of the managed exit node routing script of the interface,
+// e.g. /etc/wireguard/wg0.routing.sh.
+func (m *ServerConfigManager) RoutingPath() string {
+	return filepath.Join(filepath.Dir(m.configPath), m.InterfaceName()+".routing.sh")
+}
+
+// RoutingTable returns the routing table wg-quick adds the routes of the interface to while it
+// has an exit node. It is numbered after the listen port, like the fwmark of wg-quick.
+func RoutingTable(config *ServerConfig) int {
+	if config != nil && config.Interface != nil && config.Interface.ListenPort > 0 {
+		return config.Interface.ListenPort
+	}
+	return defaultRoutingTable
+}
+
+// ApplyExitRouting routes the traffic of the source peers through the exit node of the interface.
+//
+// The exit peer owns 0.0.0.0/0 in the server config, so the interface gets its own routing
+// table (Table = <listen port>) to keep wg-quick from replacing the default route of the
+// server. A shell script next to the server config then adds the policy rules: the server
+// keeps using the table for everything but its default route, and the traffic the sources
+// send into the tunnel is looked up in it entirely. Nothing is written while the interface
+// never had an exit node, so that ip rules are only touched once the feature is used.
+func (m *ServerConfigManager) ApplyExitRouting(ctx context.Context, routing ExitRouting) error {
+	table, staleTable, changed, err := m.setRoutingTable(ctx, routing.ExitNode != "")
+	if err != nil {
+		return err
+	}
+	if changed {
+		if _, err := m.ApplyConfig(); err != nil {
+			klog.V(1).InfoS("failed to apply server config after routing table change", "error", err)
+		}
+	}
+
+	interfaceName := m.InterfaceName()
+	content := renderExitRouting(interfaceName, table, staleTable, routing)
+
+	m.firewallMu.Lock()
+	defer m.firewallMu.Unlock()
+
+	if content == m.lastRouting {
+		return nil
+	}
+	path := m.RoutingPath()
+	if routing.ExitNode == "" && m.lastRouting == "" {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	if err := writeFileAtomic(path, []byte(content), 0600); err != nil {
+		return errors.WithCode(code.ErrWGApplyFailed, "failed to write exit node routing script: %s", err.Error())
+	}
+	if m.applyMethod == ApplyMethodNone || m.applyMethod == ApplyMethodFake {
+		klog.V(2).InfoS("apply method does not manage the system, skipping exit node routing load", "method", m.applyMethod, "path", path)
+	} else if _, err := runCommand("sh", path); err != nil {
+		return err
+	}
+	m.lastRouting = content
+
+	klog.V(2).InfoS("exit node routing applied", "interface", interfaceName, "exitNode", routing.ExitNode, "sources", len(routing.Sources))
+	return nil
+}
+
+// setRoutingTable sets the Table of the interface while it has an exit node and removes it
+// afterwards, unless the admin set another table. It returns the table, the table set before
+// if it was one of ours numbered differently (the listen port changed), and whether the
+// server config changed.
+func (m *ServerConfigManager) setRoutingTable(ctx context.Context, enabled bool) (int, int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	config, err := m.readServerConfigUnsafe()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if config.Interface == nil {
+		return 0, 0, false, errors.WithCode(code.ErrWGServerConfigNotFound, "server config has no [Interface] section")
+	}
+
+	table := RoutingTable(config)
+	previous := config.Interface.Table
+	staleTable := 0
+	if n, err := strconv.Atoi(previous); err == nil && n != table {
+		staleTable = n
+	}
+
+	want := previous
+	switch {
+	case enabled:
+		want = strconv.Itoa(table)
+	case previous == strconv.Itoa(table):
+		want = ""
+	}
+	if want == previous {
+		return table, staleTable, false, nil
+	}
+
+	config.Interface.Table = want
+	if err := m.writeServerConfigUnsafe(ctx, config); err != nil {
+		return 0, 0, false, err
+	}
+	return table, staleTable, true, nil
+}
+
+// renderExitRouting renders the policy rules of the exit node as a shell script. The rules of
+// the table are deleted first so that running the script replaces the previous rules.
+func renderExitRouting(interfaceName string, table, staleTable int, routing ExitRouting) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Managed by NexusPointWG, do not edit. Exit node routing of the peers of %s.\n", interfaceName)
+	b.WriteString("set -e\n\n")
+	fmt.Fprintf(&b, "while ip -4 rule del table %d 2>/dev/null; do :; done\n", table)
+	if staleTable > 0 {
+		fmt.Fprintf(&b, "while ip -4 rule del table %d 2>/dev/null; do :; done\n", staleTable)
+	}
+	if routing.ExitNode == "" {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n# Exit node: %s\n", commentSafe(routing.ExitNode))
+	fmt.Fprintf(&b, "ip -4 route replace default dev %s table %d\n", interfaceName, table)
+	fmt.Fprintf(&b, "ip -4 rule add priority %d lookup %d suppress_prefixlength 0\n", exitRulePriority, table)
+	for _, source := range routing.Sources {
+		if !source.Addr().Is4() {
+			continue
+		}
+		fmt.Fprintf(&b, "ip -4 rule add priority %d iif %s from %s lookup %d\n", exitRulePriority+1, interfaceName, source.Masked(), table)
+	}
+	return b.String()
+}
@@ -1,13 +1,10 @@
 package wireguard
 
 import (
-	"bufio"
-	"fmt"
+	"bytes"
+	"context"
+	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
 
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
@@ -21,6 +18,15 @@ type ServerConfigManager struct {
 	applyMethod          string
 	mu                   sync.RWMutex
 	serverPublicKeyCache string // Cached server public key
+
+	applyMu     sync.Mutex
+	lastApplied *ServerConfig // Last config successfully applied to the interface
+
+	firewallMu       sync.Mutex
+	lastFirewall     string // Last firewall ruleset successfully applied
+	lastPortForwards string // Last port forward ruleset successfully applied
+	lastRouting      string // Last exit node routing script successfully applied
+	lastNAT          string // Last NAT script successfully applied
 }
 
 // NewServerConfigManager creates a new server configuration manager.
@@ -49,6 +55,8 @@ type InterfaceConfig struct {
 	PreDown    string
 	PostDown   string
 	SaveConfig bool
+	// Table is the routing table wg-quick adds the AllowedIPs routes to ("off" disables them, empty means the main table)
+	Table string
 }
 
 // createDefaultConfig creates a default server configuration with generated private key.
@@ -91,7 +99,7 @@ func (m *ServerConfigManager) ReadServerConfig() (*ServerConfig, error) {
 				}
 
 				// Write default config to file
-				if writeErr := m.writeServerConfigUnsafe(defaultConfig); writeErr != nil {
+				if writeErr := m.writeServerConfigUnsafe(WithRevisionInfo(context.Background(), RevisionInfo{Action: "initialize default config"}), defaultConfig); writeErr != nil {
 					return nil, errors.Wrap(writeErr, "failed to write default config")
 				}
 
@@ -150,7 +158,7 @@ func (m *ServerConfigManager) ReadServerConfig() (*ServerConfig, error) {
 			}
 
 			// Write default config to file
-			if writeErr := m.writeServerConfigUnsafe(defaultConfig); writeErr != nil {
+			if writeErr := m.writeServerConfigUnsafe(WithRevisionInfo(context.Background(), RevisionInfo{Action: "initialize default config"}), defaultConfig); writeErr != nil {
 				return nil, errors.Wrap(writeErr, "failed to write default config")
 			}
 
@@ -165,129 +173,21 @@ func (m *ServerConfigManager) ReadServerConfig() (*ServerConfig, error) {
 	return config, nil
 }
 
-// readConfigFile reads and parses the configuration from an open file.
-// This method should be called while holding a read lock.
-func (m *ServerConfigManager) readConfigFile(file *os.File) (*ServerConfig, error) {
-	config := &ServerConfig{
-		Interface: &InterfaceConfig{},
-		Peers:     make([]*ServerPeerConfig, 0),
-	}
-
-	scanner := bufio.NewScanner(file)
-	var currentSection string
-	var currentPeer *ServerPeerConfig
-	var interfaceComments []string
-	var peerComments []string
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines
-		if line == "" {
-			continue
-		}
-
-		// Handle comments
-		if strings.HasPrefix(line, "#") {
-			comment := strings.TrimPrefix(line, "#")
-			comment = strings.TrimSpace(comment)
-			if currentSection == "[Peer]" && currentPeer != nil {
-				peerComments = append(peerComments, comment)
-			} else if currentSection == "[Interface]" {
-				interfaceComments = append(interfaceComments, comment)
-			}
-			continue
-		}
-
-		// Check for section headers
-		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			sectionName := strings.Trim(line, "[]")
-			currentSection = "[" + sectionName + "]"
-
-			if sectionName == "Peer" {
-				// Save previous peer if exists
-				if currentPeer != nil {
-					if len(peerComments) > 0 {
-						currentPeer.Comment = strings.Join(peerComments, "; ")
-						peerComments = nil
-					}
-					config.Peers = append(config.Peers, currentPeer)
-				}
-				// Start new peer
-				currentPeer = &ServerPeerConfig{}
-			}
-			continue
-		}
-
-		// Parse key-value pairs
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue // Skip invalid lines
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		switch currentSection {
-		case "[Interface]":
-			switch key {
-			case "PrivateKey":
-				config.Interface.PrivateKey = value
-			case "Address":
-				config.Interface.Address = value
-			case "ListenPort":
-				if port, err := strconv.Atoi(value); err == nil {
-					config.Interface.ListenPort = port
-				}
-			case "DNS":
-				config.Interface.DNS = value
-			case "MTU":
-				if mtu, err := strconv.Atoi(value); err == nil {
-					config.Interface.MTU = mtu
-				}
-			case "PreUp":
-				config.Interface.PreUp = value
-			case "PostUp":
-				config.Interface.PostUp = value
-			case "PreDown":
-				config.Interface.PreDown = value
-			case "PostDown":
-				config.Interface.PostDown = value
-			case "SaveConfig":
-				if save, err := strconv.ParseBool(value); err == nil {
-					config.Interface.SaveConfig = save
-				}
-			}
-		case "[Peer]":
-			if currentPeer == nil {
-				currentPeer = &ServerPeerConfig{}
-			}
-			switch key {
-			case "PublicKey":
-				currentPeer.PublicKey = value
-			case "AllowedIPs":
-				currentPeer.AllowedIPs = value
-			case "PersistentKeepalive":
-				if keepalive, err := strconv.Atoi(value); err == nil {
-					currentPeer.PersistentKeepalive = keepalive
-				}
-			}
-		}
-	}
+// PeekServerConfig reads the config file as it is, without initializing a missing or empty config.
+func (m *ServerConfigManager) PeekServerConfig() (*ServerConfig, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.readServerConfigUnsafe()
+}
 
-	if err := scanner.Err(); err != nil {
+// readConfigFile reads and parses the configuration from an open file (or any reader).
+// This method should be called while holding a read lock.
+func (m *ServerConfigManager) readConfigFile(file io.Reader) (*ServerConfig, error) {
+	doc, err := parseConfDocument(file)
+	if err != nil {
 		return nil, errors.Wrap(err, "failed to read server config file")
 	}
-
-	// Add last peer if exists
-	if currentPeer != nil {
-		if len(peerComments) > 0 {
-			currentPeer.Comment = strings.Join(peerComments, "; ")
-		}
-		config.Peers = append(config.Peers, currentPeer)
-	}
-
-	return config, nil
+	return doc.serverConfig(), nil
 }
 
 // GetServerPublicKey gets the server public key, generating it from the private key if needed.
@@ -325,101 +225,17 @@ func (m *ServerConfigManager) GetServerPublicKey() (string, error) {
 	return publicKey, nil
 }
 
-// WriteServerConfig writes the server configuration to file.
-func (m *ServerConfigManager) WriteServerConfig(config *ServerConfig) error {
+// WriteServerConfig atomically writes the server configuration to file and records it in the history.
+// The revision is attributed to the RevisionInfo carried by ctx.
+func (m *ServerConfigManager) WriteServerConfig(ctx context.Context, config *ServerConfig) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Create backup before writing
-	backupPath := m.configPath + ".backup"
-	if err := m.backupConfig(backupPath); err != nil {
-		klog.V(1).InfoS("failed to create backup", "error", err)
-		// Continue anyway, backup is not critical
-	}
-
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(m.configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errors.Wrap(err, "failed to create config directory")
-	}
-
-	file, err := os.Create(m.configPath)
-	if err != nil {
-		return errors.Wrap(err, "failed to create config file")
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	// Write [Interface] section
-	if config.Interface != nil {
-		writer.WriteString("[Interface]\n")
-		if config.Interface.PrivateKey != "" {
-			writer.WriteString(fmt.Sprintf("PrivateKey = %s\n", config.Interface.PrivateKey))
-		}
-		if config.Interface.Address != "" {
-			writer.WriteString(fmt.Sprintf("Address = %s\n", config.Interface.Address))
-		}
-		if config.Interface.ListenPort > 0 {
-			writer.WriteString(fmt.Sprintf("ListenPort = %d\n", config.Interface.ListenPort))
-		}
-		if config.Interface.DNS != "" {
-			writer.WriteString(fmt.Sprintf("DNS = %s\n", config.Interface.DNS))
-		}
-		if config.Interface.MTU > 0 {
-			writer.WriteString(fmt.Sprintf("MTU = %d\n", config.Interface.MTU))
-		}
-		if config.Interface.PreUp != "" {
-			writer.WriteString(fmt.Sprintf("PreUp = %s\n", config.Interface.PreUp))
-		}
-		if config.Interface.PostUp != "" {
-			writer.WriteString(fmt.Sprintf("PostUp = %s\n", config.Interface.PostUp))
-		}
-		if config.Interface.PreDown != "" {
-			writer.WriteString(fmt.Sprintf("PreDown = %s\n", config.Interface.PreDown))
-		}
-		if config.Interface.PostDown != "" {
-			writer.WriteString(fmt.Sprintf("PostDown = %s\n", config.Interface.PostDown))
-		}
-		if config.Interface.SaveConfig {
-			writer.WriteString("SaveConfig = true\n")
-		}
-		writer.WriteString("\n")
-	}
-
-	// Write [Peer] sections
-	for _, peer := range config.Peers {
-		writer.WriteString(FormatServerPeerBlock(peer))
-	}
-
-	return nil
-}
-
-// backupConfig creates a backup of the current configuration file.
-func (m *ServerConfigManager) backupConfig(backupPath string) error {
-	if _, err := os.Stat(m.configPath); os.IsNotExist(err) {
-		return nil // No file to backup
-	}
-
-	source, err := os.Open(m.configPath)
-	if err != nil {
-		return err
-	}
-	defer source.Close()
-
-	dest, err := os.Create(backupPath)
-	if err != nil {
-		return err
-	}
-	defer dest.Close()
-
-	_, err = dest.ReadFrom(source)
-	return err
+	return m.writeServerConfigUnsafe(ctx, config)
 }
 
 // AddPeer adds a new peer to the server configuration.
-func (m *ServerConfigManager) AddPeer(peer *ServerPeerConfig) error {
+func (m *ServerConfigManager) AddPeer(ctx context.Context, peer *ServerPeerConfig) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -438,11 +254,11 @@ func (m *ServerConfigManager) AddPeer(peer *ServerPeerConfig) error {
 	// Add new peer
 	config.Peers = append(config.Peers, peer)
 
-	return m.writeServerConfigUnsafe(config)
+	return m.writeServerConfigUnsafe(ctx, config)
 }
 
 // RemovePeer removes a peer from the server configuration by public key.
-func (m *ServerConfigManager) RemovePeer(publicKey string) error {
+func (m *ServerConfigManager) RemovePeer(ctx context.Context, publicKey string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -467,11 +283,11 @@ func (m *ServerConfigManager) RemovePeer(publicKey string) error {
 	}
 
 	config.Peers = newPeers
-	return m.writeServerConfigUnsafe(config)
+	return m.writeServerConfigUnsafe(ctx, config)
 }
 
 // UpdatePeer updates an existing peer in the server configuration.
-func (m *ServerConfigManager) UpdatePeer(publicKey string, peer *ServerPeerConfig) error {
+func (m *ServerConfigManager) UpdatePeer(ctx context.Context, publicKey string, peer *ServerPeerConfig) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -496,194 +312,81 @@ func (m *ServerConfigManager) UpdatePeer(publicKey string, peer *ServerPeerConfi
 		return errors.WithCode(code.ErrWGPeerNotFound, "peer with public key not found")
 	}
 
-	return m.writeServerConfigUnsafe(config)
+	return m.writeServerConfigUnsafe(ctx, config)
 }
 
-// readServerConfigUnsafe reads the config without acquiring lock (caller must hold lock).
-func (m *ServerConfigManager) readServerConfigUnsafe() (*ServerConfig, error) {
-	file, err := os.Open(m.configPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, errors.WithCode(code.ErrWGServerConfigNotFound, "server config file not found: %s", m.configPath)
-		}
-		return nil, errors.Wrap(err, "failed to open server config file")
-	}
-	defer file.Close()
+// ReplacePeerKey replaces the peer identified by oldPublicKey with peer, which carries a new public key.
+// It is used for key rotation; the [Peer] block is rewritten in place, keeping its position in the file.
+func (m *ServerConfigManager) ReplacePeerKey(ctx context.Context, oldPublicKey string, peer *ServerPeerConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	config := &ServerConfig{
-		Interface: &InterfaceConfig{},
-		Peers:     make([]*ServerPeerConfig, 0),
+	config, err := m.readServerConfigUnsafe()
+	if err != nil {
+		return err
 	}
 
-	scanner := bufio.NewScanner(file)
-	var currentSection string
-	var currentPeer *ServerPeerConfig
-	var peerComments []string
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if line == "" {
-			continue
-		}
-
-		if strings.HasPrefix(line, "#") {
-			comment := strings.TrimPrefix(line, "#")
-			comment = strings.TrimSpace(comment)
-			if currentSection == "[Peer]" && currentPeer != nil {
-				peerComments = append(peerComments, comment)
-			}
-			continue
-		}
-
-		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			sectionName := strings.Trim(line, "[]")
-			currentSection = "[" + sectionName + "]"
-
-			if sectionName == "Peer" {
-				if currentPeer != nil {
-					if len(peerComments) > 0 {
-						currentPeer.Comment = strings.Join(peerComments, "; ")
-						peerComments = nil
-					}
-					config.Peers = append(config.Peers, currentPeer)
-				}
-				currentPeer = &ServerPeerConfig{}
-			}
-			continue
+	index := -1
+	for i, existingPeer := range config.Peers {
+		if existingPeer.PublicKey == peer.PublicKey && peer.PublicKey != oldPublicKey {
+			return errors.WithCode(code.ErrWGPeerKeyConflict, "peer with public key already exists")
 		}
-
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		switch currentSection {
-		case "[Interface]":
-			switch key {
-			case "PrivateKey":
-				config.Interface.PrivateKey = value
-			case "Address":
-				config.Interface.Address = value
-			case "ListenPort":
-				if port, err := strconv.Atoi(value); err == nil {
-					config.Interface.ListenPort = port
-				}
-			case "DNS":
-				config.Interface.DNS = value
-			case "MTU":
-				if mtu, err := strconv.Atoi(value); err == nil {
-					config.Interface.MTU = mtu
-				}
-			case "PreUp":
-				config.Interface.PreUp = value
-			case "PostUp":
-				config.Interface.PostUp = value
-			case "PreDown":
-				config.Interface.PreDown = value
-			case "PostDown":
-				config.Interface.PostDown = value
-			case "SaveConfig":
-				if save, err := strconv.ParseBool(value); err == nil {
-					config.Interface.SaveConfig = save
-				}
-			}
-		case "[Peer]":
-			if currentPeer == nil {
-				currentPeer = &ServerPeerConfig{}
-			}
-			switch key {
-			case "PublicKey":
-				currentPeer.PublicKey = value
-			case "AllowedIPs":
-				currentPeer.AllowedIPs = value
-			case "PersistentKeepalive":
-				if keepalive, err := strconv.Atoi(value); err == nil {
-					currentPeer.PersistentKeepalive = keepalive
-				}
-			}
+		if existingPeer.PublicKey == oldPublicKey {
+			index = i
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, errors.Wrap(err, "failed to read server config file")
+	if index < 0 {
+		return errors.WithCode(code.ErrWGPeerNotFound, "peer with public key not found")
 	}
 
-	if currentPeer != nil {
-		if len(peerComments) > 0 {
-			currentPeer.Comment = strings.Join(peerComments, "; ")
+	config.Peers[index] = peer
+	return m.mergeServerConfigUnsafe(ctx, config, map[string]string{oldPublicKey: peer.PublicKey})
+}
+
+// readServerConfigUnsafe reads the config without acquiring lock (caller must hold lock).
+func (m *ServerConfigManager) readServerConfigUnsafe() (*ServerConfig, error) {
+	file, err := os.Open(m.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.WithCode(code.ErrWGServerConfigNotFound, "server config file not found: %s", m.configPath)
 		}
-		config.Peers = append(config.Peers, currentPeer)
+		return nil, errors.Wrap(err, "failed to open server config file")
 	}
+	defer file.Close()
 
-	return config, nil
+	return m.readConfigFile(file)
 }
 
 // writeServerConfigUnsafe writes the config without acquiring lock (caller must hold lock).
-func (m *ServerConfigManager) writeServerConfigUnsafe(config *ServerConfig) error {
-	// Create backup
-	backupPath := m.configPath + ".backup"
-	if err := m.backupConfig(backupPath); err != nil {
-		klog.V(1).InfoS("failed to create backup", "error", err)
-	}
+// The config is merged into the existing file, so keys, comments and sections that
+// NexusPointWG does not manage (e.g. FwMark) are preserved.
+func (m *ServerConfigManager) writeServerConfigUnsafe(ctx context.Context, config *ServerConfig) error {
+	return m.mergeServerConfigUnsafe(ctx, config, nil)
+}
 
-	// Create directory if needed
-	dir := filepath.Dir(m.configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errors.Wrap(err, "failed to create config directory")
+// mergeServerConfigUnsafe is writeServerConfigUnsafe for configs changing public keys:
+// renamed maps old public keys to new ones, whose [Peer] blocks are edited in place.
+func (m *ServerConfigManager) mergeServerConfigUnsafe(ctx context.Context, config *ServerConfig, renamed map[string]string) error {
+	existing, err := os.ReadFile(m.configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read server config file")
 	}
-
-	file, err := os.Create(m.configPath)
+	doc, err := parseConfDocument(bytes.NewReader(existing))
 	if err != nil {
-		return errors.Wrap(err, "failed to create config file")
+		return errors.Wrap(err, "failed to parse server config file")
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	// Write [Interface] section
-	if config.Interface != nil {
-		writer.WriteString("[Interface]\n")
-		if config.Interface.PrivateKey != "" {
-			writer.WriteString(fmt.Sprintf("PrivateKey = %s\n", config.Interface.PrivateKey))
-		}
-		if config.Interface.Address != "" {
-			writer.WriteString(fmt.Sprintf("Address = %s\n", config.Interface.Address))
-		}
-		if config.Interface.ListenPort > 0 {
-			writer.WriteString(fmt.Sprintf("ListenPort = %d\n", config.Interface.ListenPort))
-		}
-		if config.Interface.DNS != "" {
-			writer.WriteString(fmt.Sprintf("DNS = %s\n", config.Interface.DNS))
-		}
-		if config.Interface.MTU > 0 {
-			writer.WriteString(fmt.Sprintf("MTU = %d\n", config.Interface.MTU))
-		}
-		if config.Interface.PreUp != "" {
-			writer.WriteString(fmt.Sprintf("PreUp = %s\n", config.Interface.PreUp))
-		}
-		if config.Interface.PostUp != "" {
-			writer.WriteString(fmt.Sprintf("PostUp = %s\n", config.Interface.PostUp))
-		}
-		if config.Interface.PreDown != "" {
-			writer.WriteString(fmt.Sprintf("PreDown = %s\n", config.Interface.PreDown))
-		}
-		if config.Interface.PostDown != "" {
-			writer.WriteString(fmt.Sprintf("PostDown = %s\n", config.Interface.PostDown))
-		}
-		if config.Interface.SaveConfig {
-			writer.WriteString("SaveConfig = true\n")
-		}
-		writer.WriteString("\n")
+	for oldPublicKey, newPublicKey := range renamed {
+		doc.renamePeer(oldPublicKey, newPublicKey)
 	}
+	doc.merge(config)
 
-	// Write [Peer] sections
-	for _, peer := range config.Peers {
-		writer.WriteString(FormatServerPeerBlock(peer))
+	if _, err := m.writeContentUnsafe(doc.render(), RevisionInfoFromContext(ctx)); err != nil {
+		if errors.ParseCoder(err).Code() != code.ErrWGConfigHistoryFailed {
+			return err
+		}
+		// The config itself is written, history is best effort for regular writes
+		klog.V(1).InfoS("failed to record server config revision", "path", m.configPath, "error", err)
 	}
 
 	// Clear server public key cache since config might have changed
@@ -692,25 +395,40 @@ func (m *ServerConfigManager) writeServerConfigUnsafe(config *ServerConfig) erro
 	return nil
 }
 
-// ApplyConfig applies the server configuration by reloading the WireGuard interface.
-func (m *ServerConfigManager) ApplyConfig() error {
-	if m.applyMethod == "none" {
-		klog.V(2).InfoS("apply method is 'none', skipping config reload")
-		return nil
+// ApplyConfig applies the written server configuration to the running interface
+// with the applier selected by wireguard.apply-method, and reports what changed.
+func (m *ServerConfigManager) ApplyConfig() (*ApplyResult, error) {
+	applier, err := GetApplier(m.applyMethod)
+	if err != nil {
+		return nil, err
 	}
 
-	// Extract interface name from config path (e.g., /etc/wireguard/wg0.conf -> wg0)
-	baseName := filepath.Base(m.configPath)
-	interfaceName := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+	config, err := m.ReadServerConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Serialize applies so that Previous always matches what the device last received
+	m.applyMu.Lock()
+	defer m.applyMu.Unlock()
 
-	// Execute systemctl reload
-	cmd := exec.Command("systemctl", "reload", fmt.Sprintf("wg-quick@%s", interfaceName))
-	output, err := cmd.CombinedOutput()
+	interfaceName := m.InterfaceName()
+	result, err := applier.Apply(&ApplyRequest{
+		Interface:  interfaceName,
+		ConfigPath: m.configPath,
+		Config:     config,
+		Previous:   m.lastApplied,
+	})
 	if err != nil {
-		klog.V(1).InfoS("failed to reload WireGuard config", "interface", interfaceName, "error", err, "output", string(output))
-		return errors.WithCode(code.ErrWGApplyFailed, "failed to reload WireGuard config: %s", string(output))
+		klog.V(1).InfoS("failed to apply WireGuard config", "interface", interfaceName, "method", applier.Name(), "error", err)
+		return nil, err
 	}
+	result.Method = applier.Name()
+	result.Interface = interfaceName
+	m.lastApplied = config
 
-	klog.V(2).InfoS("WireGuard config reloaded successfully", "interface", interfaceName)
-	return nil
+	klog.V(2).InfoS("WireGuard config applied", "interface", interfaceName, "method", result.Method,
+		"added", len(result.Added), "removed", len(result.Removed), "updated", len(result.Updated),
+		"interfaceChanged", result.InterfaceChanged, "restarted", result.Restarted)
+	return result, nil
 }
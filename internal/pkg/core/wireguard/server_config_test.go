@@ -0,0 +1,78 @@
+package wireguard
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+)
+
+func TestReplacePeerKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		oldPublicKey string
+		peer         *ServerPeerConfig
+		wantCode     int
+		want         string
+	}{
+		{
+			name:         "block is rewritten in place",
+			oldPublicKey: "cGVlcjE=",
+			peer:         &ServerPeerConfig{PublicKey: "bmV3MQ==", PresharedKey: "cHNr", AllowedIPs: "100.100.100.2/32", PersistentKeepalive: 25, Comment: "phone"},
+			want: "# office router\n# phone\n[Peer]\nPublicKey = bmV3MQ==\nAllowedIPs = 100.100.100.2/32\nPersistentKeepalive = 25\nPresharedKey = cHNr\n\n" +
+				"# laptop\n[Peer]\nPublicKey = cGVlcjI=\n",
+		},
+		{
+			name:         "new key of another peer",
+			oldPublicKey: "cGVlcjE=",
+			peer:         &ServerPeerConfig{PublicKey: "cGVlcjI=", AllowedIPs: "100.100.100.2/32"},
+			wantCode:     code.ErrWGPeerKeyConflict,
+		},
+		{
+			name:         "unknown old key",
+			oldPublicKey: "bm9uZQ==",
+			peer:         &ServerPeerConfig{PublicKey: "bmV3MQ==", AllowedIPs: "100.100.100.2/32"},
+			wantCode:     code.ErrWGPeerNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "wg0.conf")
+			if err := os.WriteFile(configPath, []byte(testServerConf), 0600); err != nil {
+				t.Fatal(err)
+			}
+			m := NewServerConfigManager(configPath, ApplyMethodNone)
+
+			err := m.ReplacePeerKey(context.Background(), tt.oldPublicKey, tt.peer)
+			if tt.wantCode != 0 {
+				if err == nil {
+					t.Fatalf("ReplacePeerKey() error = nil, want code %d", tt.wantCode)
+				}
+				if got := errors.ParseCoder(err).Code(); got != tt.wantCode {
+					t.Errorf("ReplacePeerKey() error code = %d, want %d", got, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReplacePeerKey() error = %v", err)
+			}
+
+			content, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(content)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("config =\n%s\nwant it to contain\n%s", got, tt.want)
+			}
+			if strings.Contains(got, tt.oldPublicKey) {
+				t.Errorf("config =\n%s\nstill holds the old key %s", got, tt.oldPublicKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package wireguard
+
+import "testing"
+
+func TestFileStatusSource(t *testing.T) {
+	source := NewFileStatusSource("testdata")
+
+	statuses, err := source.PeerStatuses("wg0")
+	if err != nil {
+		t.Fatalf("PeerStatuses() error = %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("PeerStatuses() returned %d peers, want 2", len(statuses))
+	}
+	if got := statuses["PEER1="]; got == nil || got.TransferRx != 1024 || got.Endpoint != "203.0.113.7:51820" {
+		t.Errorf("PEER1= = %+v, want rx 1024 from 203.0.113.7:51820", got)
+	}
+	if statuses["PEER2="].Online() {
+		t.Errorf("PEER2= is online, want offline without a handshake")
+	}
+
+	if _, err := source.PeerStatuses("wg1"); err == nil {
+		t.Errorf("PeerStatuses() of a missing dump error = nil, want an error")
+	}
+}
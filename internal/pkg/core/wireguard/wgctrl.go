@@ -0,0 +1,153 @@
+package wireguard
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/errors"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// wgctrlApplier programs the running device directly through wgctrl (netlink on Linux, the
+// userspace socket elsewhere). Only changed peers are touched, so other sessions are kept.
+// Address, MTU and hook changes in [Interface] are not applied and need an interface restart.
+type wgctrlApplier struct{}
+
+func (wgctrlApplier) Name() string { return ApplyMethodWgctrl }
+
+func (wgctrlApplier) Apply(req *ApplyRequest) (*ApplyResult, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, errors.WithCode(code.ErrWGApplyFailed, "failed to open wgctrl client: %s", err.Error())
+	}
+	defer client.Close()
+
+	device, err := client.Device(req.Interface)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrWGApplyFailed, "failed to read device %s: %s", req.Interface, err.Error())
+	}
+	running := deviceToServerConfig(device)
+	result := diffAgainstDevice(running, req)
+	desired := peersByPublicKey(req.Config.Peers)
+
+	var cfg wgtypes.Config
+	for _, publicKey := range result.Removed {
+		key, err := wgtypes.ParseKey(publicKey)
+		if err != nil {
+			return nil, errors.WithCode(code.ErrWGApplyFailed, "invalid public key of running peer %s: %s", publicKey, err.Error())
+		}
+		cfg.Peers = append(cfg.Peers, wgtypes.PeerConfig{PublicKey: key, Remove: true})
+	}
+	for _, publicKey := range result.Added {
+		peerCfg, err := devicePeerConfig(desired[publicKey])
+		if err != nil {
+			return nil, err
+		}
+		cfg.Peers = append(cfg.Peers, peerCfg)
+	}
+	for _, publicKey := range result.Updated {
+		peerCfg, err := devicePeerConfig(desired[publicKey])
+		if err != nil {
+			return nil, err
+		}
+		peerCfg.UpdateOnly = true
+		cfg.Peers = append(cfg.Peers, peerCfg)
+	}
+
+	// Listen port and private key can be changed on the running device
+	if req.Config.Interface != nil && running.Interface != nil &&
+		(req.Config.Interface.ListenPort != running.Interface.ListenPort || req.Config.Interface.PrivateKey != running.Interface.PrivateKey) {
+		privateKey, err := wgtypes.ParseKey(req.Config.Interface.PrivateKey)
+		if err != nil {
+			return nil, errors.WithCode(code.ErrWGApplyFailed, "invalid interface private key: %s", err.Error())
+		}
+		listenPort := req.Config.Interface.ListenPort
+		cfg.PrivateKey = &privateKey
+		cfg.ListenPort = &listenPort
+		result.InterfaceChanged = true
+	}
+
+	if len(cfg.Peers) == 0 && cfg.PrivateKey == nil {
+		return result, nil
+	}
+	if err := client.ConfigureDevice(req.Interface, cfg); err != nil {
+		return nil, errors.WithCode(code.ErrWGApplyFailed, "failed to configure device %s: %s", req.Interface, err.Error())
+	}
+	return result, nil
+}
+
+// devicePeerConfig converts a peer of the config file to a wgctrl peer config that replaces
+// the allowed IPs, preshared key and keepalive of the running peer.
+func devicePeerConfig(peer *ServerPeerConfig) (wgtypes.PeerConfig, error) {
+	publicKey, err := wgtypes.ParseKey(peer.PublicKey)
+	if err != nil {
+		return wgtypes.PeerConfig{}, errors.WithCode(code.ErrWGApplyFailed, "invalid public key %s: %s", peer.PublicKey, err.Error())
+	}
+
+	// The zero key clears the preshared key
+	var presharedKey wgtypes.Key
+	if peer.PresharedKey != "" {
+		if presharedKey, err = wgtypes.ParseKey(peer.PresharedKey); err != nil {
+			return wgtypes.PeerConfig{}, errors.WithCode(code.ErrWGApplyFailed, "invalid preshared key of peer %s: %s", peer.PublicKey, err.Error())
+		}
+	}
+	keepalive := time.Duration(peer.PersistentKeepalive) * time.Second
+
+	peerCfg := wgtypes.PeerConfig{
+		PublicKey:                   publicKey,
+		PresharedKey:                &presharedKey,
+		PersistentKeepaliveInterval: &keepalive,
+		ReplaceAllowedIPs:           true,
+	}
+	for _, cidr := range strings.Split(peer.AllowedIPs, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return wgtypes.PeerConfig{}, errors.WithCode(code.ErrWGApplyFailed, "invalid allowed IP %s of peer %s: %s", cidr, peer.PublicKey, err.Error())
+		}
+		peerCfg.AllowedIPs = append(peerCfg.AllowedIPs, *ipNet)
+	}
+	if peer.Endpoint != "" {
+		endpoint, err := net.ResolveUDPAddr("udp", peer.Endpoint)
+		if err != nil {
+			return wgtypes.PeerConfig{}, errors.WithCode(code.ErrWGApplyFailed, "invalid endpoint %s of peer %s: %s", peer.Endpoint, peer.PublicKey, err.Error())
+		}
+		peerCfg.Endpoint = endpoint
+	}
+	return peerCfg, nil
+}
+
+// deviceToServerConfig converts the running device to the fields of a server config it knows about.
+func deviceToServerConfig(device *wgtypes.Device) *ServerConfig {
+	config := &ServerConfig{
+		Interface: &InterfaceConfig{
+			PrivateKey: device.PrivateKey.String(),
+			ListenPort: device.ListenPort,
+		},
+		Peers: make([]*ServerPeerConfig, 0, len(device.Peers)),
+	}
+	for _, peer := range device.Peers {
+		peerConfig := &ServerPeerConfig{
+			PublicKey:           peer.PublicKey.String(),
+			PersistentKeepalive: int(peer.PersistentKeepaliveInterval / time.Second),
+		}
+		if peer.PresharedKey != (wgtypes.Key{}) {
+			peerConfig.PresharedKey = peer.PresharedKey.String()
+		}
+		if peer.Endpoint != nil {
+			peerConfig.Endpoint = peer.Endpoint.String()
+		}
+		allowedIPs := make([]string, 0, len(peer.AllowedIPs))
+		for _, ipNet := range peer.AllowedIPs {
+			allowedIPs = append(allowedIPs, ipNet.String())
+		}
+		peerConfig.AllowedIPs = strings.Join(allowedIPs, ",")
+		config.Peers = append(config.Peers, peerConfig)
+	}
+	return config
+}
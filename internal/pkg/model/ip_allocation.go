@@ -1,18 +1,21 @@
 package model
 
 import (
+	"fmt"
+	"net/netip"
 	"time"
 )
 
 // IPAllocation represents an IP address allocation record for a WireGuard peer.
 type IPAllocation struct {
-	ID        string    `json:"id" gorm:"primaryKey"`
-	IPPoolID  string    `json:"ip_pool_id" gorm:"index;not null"`
-	PeerID    string    `json:"peer_id" gorm:"uniqueIndex;not null"` // 关联的Peer
-	IPAddress string    `json:"ip_address" gorm:"uniqueIndex;not null"` // e.g. "100.100.100.2"
-	Status    string    `json:"status" gorm:"not null;default:allocated"` // allocated, released
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           string    `json:"id" gorm:"primaryKey"`
+	IPPoolID     string    `json:"ip_pool_id" gorm:"index;not null"`
+	PeerID       string    `json:"peer_id" gorm:"index;not null"`            // 关联的Peer（双栈 Peer 每个地址族一条记录）
+	IPAddress    string    `json:"ip_address" gorm:"uniqueIndex;not null"`   // e.g. "100.100.100.2" or "fd00::2"，前缀分配时为前缀的首地址
+	PrefixLength int       `json:"prefix_length" gorm:"default:0"`           // 分配的前缀长度（如 29），0 表示单个地址
+	Status       string    `json:"status" gorm:"not null;default:allocated"` // allocated, released
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 const (
@@ -22,3 +25,15 @@ const (
 	IPAllocationStatusReleased = "released"
 )
 
+// CIDR returns the allocated address as a host CIDR (e.g. "100.100.100.2/32"),
+// or the allocated prefix for pools delegating prefixes (e.g. "100.100.100.8/29").
+func (a *IPAllocation) CIDR() string {
+	if a.PrefixLength > 0 {
+		return fmt.Sprintf("%s/%d", a.IPAddress, a.PrefixLength)
+	}
+	addr, err := netip.ParseAddr(a.IPAddress)
+	if err != nil {
+		return a.IPAddress
+	}
+	return fmt.Sprintf("%s/%d", addr, addr.BitLen())
+}
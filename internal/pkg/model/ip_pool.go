@@ -6,16 +6,24 @@ import (
 
 // IPPool represents an IP address pool for WireGuard peer allocation.
 type IPPool struct {
-	ID          string    `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name" gorm:"uniqueIndex;not null"`             // 地址池名称
-	CIDR        string    `json:"cidr" gorm:"column:cidr;uniqueIndex;not null"` // e.g. "100.100.100.0/24"
-	Routes      string    `json:"routes" gorm:""`                               // 路由（逗号分隔的CIDR），用于客户端的AllowedIPs
-	DNS         string    `json:"dns" gorm:""`                                  // DNS服务器（逗号分隔），用于客户端配置
-	Endpoint    string    `json:"endpoint" gorm:""`                             // 服务器端点，格式如 "10.10.10.10:51820"
-	Description string    `json:"description" gorm:""`                          // 描述
-	Status      string    `json:"status" gorm:"not null;default:active"`        // active, disabled
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                   string    `json:"id" gorm:"primaryKey"`
+	Name                 string    `json:"name" gorm:"uniqueIndex;not null"`             // 地址池名称
+	CIDR                 string    `json:"cidr" gorm:"column:cidr;uniqueIndex;not null"` // e.g. "100.100.100.0/24" 或双栈 "100.100.100.0/24,fd00:100::/64"
+	Routes               string    `json:"routes" gorm:""`                               // 路由（逗号分隔的CIDR），用于客户端的AllowedIPs
+	DNS                  string    `json:"dns" gorm:""`                                  // DNS服务器（逗号分隔），用于客户端配置
+	Endpoint             string    `json:"endpoint" gorm:""`                             // 服务器端点，格式如 "10.10.10.10:51820"
+	Description          string    `json:"description" gorm:""`                          // 描述
+	RequirePresharedKey  bool      `json:"require_preshared_key" gorm:"default:false"`   // 是否要求该地址池中的 peer 使用预共享密钥
+	Status               string    `json:"status" gorm:"not null;default:active"`        // active, disabled
+	InterfaceID          string    `json:"interface_id" gorm:"index"`                    // 关联的 WireGuard 接口
+	PeerLifetimeHours    int       `json:"peer_lifetime_hours" gorm:"default:0"`         // 新建 peer 的默认有效期（小时），0 表示永不过期
+	RequirePublicKeyOnly bool      `json:"require_public_key_only" gorm:"default:false"` // 是否要求该地址池中的新 peer 只提交公钥（服务器不保存私钥）
+	PrefixLength         int       `json:"prefix_length" gorm:"default:0"`               // 每个 peer 分配的 IPv4 前缀长度（如 29），0 表示单个地址
+	PrefixLengthV6       int       `json:"prefix_length_v6" gorm:"default:0"`            // 每个 peer 分配的 IPv6 前缀长度（如 80），0 表示单个地址
+	Isolation            string    `json:"isolation" gorm:"not null;default:any"`        // peer 之间的互访策略：any（任意互访）、pool（仅本地址池内互访）、isolated（完全隔离，只能访问服务器）
+	ExitPeerID           string    `json:"exit_peer_id" gorm:"index"`                    // 地址池内 peer 默认经由的出口节点 peer，为空表示直接从服务器出网
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
 }
 
 const (
@@ -24,3 +32,12 @@ const (
 	// IPPoolStatusDisabled indicates the IP pool is disabled and cannot be used for allocation.
 	IPPoolStatusDisabled = "disabled"
 )
+
+const (
+	// IPPoolIsolationAny lets the peers of the pool reach any peer of the interface.
+	IPPoolIsolationAny = "any"
+	// IPPoolIsolationPool lets the peers of the pool reach only each other.
+	IPPoolIsolationPool = "pool"
+	// IPPoolIsolationIsolated lets the peers of the pool reach only the server (hub-and-spoke).
+	IPPoolIsolationIsolated = "isolated"
+)
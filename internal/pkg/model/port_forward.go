@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// PortForward exposes a port of a peer on the public address of the server (DNAT).
+// A forward is only loaded while its peer is active.
+type PortForward struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	Protocol    string    `json:"protocol" gorm:"uniqueIndex:idx_port_forwards_public;not null"`    // tcp, udp
+	PublicPort  int       `json:"public_port" gorm:"uniqueIndex:idx_port_forwards_public;not null"` // 服务器上对外暴露的端口
+	PeerID      string    `json:"peer_id" gorm:"index;not null"`                                    // 目标 peer
+	TargetPort  int       `json:"target_port" gorm:"not null"`                                      // 目标 peer 上的端口
+	Description string    `json:"description" gorm:""`                                              // 描述
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const (
+	// PortForwardProtocolTCP forwards TCP connections.
+	PortForwardProtocolTCP = "tcp"
+	// PortForwardProtocolUDP forwards UDP datagrams.
+	PortForwardProtocolUDP = "udp"
+)
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+)
+
+// RevokedKey records a revoked peer public key. Revoked keys can never be added to
+// a server config again, neither by creating a peer nor by the config file sync.
+type RevokedKey struct {
+	PublicKey  string    `json:"public_key" gorm:"primaryKey"`
+	PeerID     string    `json:"peer_id" gorm:"index;not null"`
+	UserID     string    `json:"user_id" gorm:"index;not null"` // Peer 所属用户
+	DeviceName string    `json:"device_name" gorm:""`           // 吊销时的设备名
+	Reason     string    `json:"reason" gorm:"not null"`        // 吊销原因，例如设备丢失
+	RevokedBy  string    `json:"revoked_by" gorm:"not null"`    // 执行吊销的用户名
+	CreatedAt  time.Time `json:"created_at"`
+}
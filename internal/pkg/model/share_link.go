@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+)
+
+// ShareLink is a signed, time-limited link to download the client config of a peer without logging in.
+// The link token is derived from the ID and the server secret, so it is not stored.
+type ShareLink struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	PeerID    string     `json:"peer_id" gorm:"index;not null"`
+	CreatedBy string     `json:"created_by" gorm:"not null"`          // 创建链接的用户名
+	MaxUses   int        `json:"max_uses" gorm:"not null;default:1"`  // 最多可下载次数
+	UseCount  int        `json:"use_count" gorm:"not null;default:0"` // 已下载次数
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at"` // 非空表示链接已被撤销
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Usable reports whether the link can still be used at the given time.
+func (l *ShareLink) Usable(now time.Time) bool {
+	return l.RevokedAt == nil && now.Before(l.ExpiresAt) && l.UseCount < l.MaxUses
+}
+
+// ShareLinkUse records one download through a share link.
+type ShareLinkUse struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	LinkID    string    `json:"link_id" gorm:"index;not null"`
+	PeerID    string    `json:"peer_id" gorm:"index;not null"`
+	ClientIP  string    `json:"client_ip"`  // 下载方 IP
+	UserAgent string    `json:"user_agent"` // 下载方 User-Agent
+	CreatedAt time.Time `json:"created_at"`
+}
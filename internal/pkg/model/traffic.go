@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+)
+
+// TrafficCounter holds the last transfer counters sampled from the kernel for a peer.
+// It is used to compute the usage between two samples and to detect counter resets.
+type TrafficCounter struct {
+	PeerID     string    `json:"peer_id" gorm:"primaryKey"`
+	PublicKey  string    `json:"public_key" gorm:"not null"` // 密钥轮换后计数器从 0 开始
+	TransferRx int64     `json:"transfer_rx" gorm:"not null;default:0"`
+	TransferTx int64     `json:"transfer_tx" gorm:"not null;default:0"`
+	SampledAt  time.Time `json:"sampled_at"`
+}
+
+// TrafficUsage is the traffic of a peer during one day.
+// UserID is copied from the peer so usage stays attributable after the peer is deleted.
+type TrafficUsage struct {
+	PeerID    string    `json:"peer_id" gorm:"primaryKey"`
+	Day       string    `json:"day" gorm:"primaryKey"` // 服务器本地日期，例如 2026-10-16
+	UserID    string    `json:"user_id" gorm:"index;not null"`
+	RxBytes   int64     `json:"rx_bytes" gorm:"not null;default:0"` // 服务器接收（客户端上传）
+	TxBytes   int64     `json:"tx_bytes" gorm:"not null;default:0"` // 服务器发送（客户端下载）
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	// TrafficDayLayout is the layout of TrafficUsage.Day.
+	TrafficDayLayout = "2006-01-02"
+	// TrafficMonthLayout is the layout of monthly usage periods.
+	TrafficMonthLayout = "2006-01"
+)
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+// TrafficQuota limits the traffic of a user, a peer or an IP pool during a period.
+// Peers over a quota are disabled until the period resets.
+type TrafficQuota struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	Scope       string    `json:"scope" gorm:"uniqueIndex:idx_traffic_quotas_target;not null"`     // user, peer, ip_pool
+	TargetID    string    `json:"target_id" gorm:"uniqueIndex:idx_traffic_quotas_target;not null"` // 用户、Peer 或 IP 池的 ID
+	LimitBytes  int64     `json:"limit_bytes" gorm:"not null"`                                     // 收发字节数之和的上限
+	Period      string    `json:"period" gorm:"not null;default:monthly"`                          // monthly, rolling
+	RollingDays int       `json:"rolling_days" gorm:"not null;default:30"`                         // period 为 rolling 时的窗口天数（含当天）
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const (
+	// TrafficQuotaScopeUser applies the quota to the sum of all peers of a user.
+	TrafficQuotaScopeUser = "user"
+	// TrafficQuotaScopePeer applies the quota to a single peer.
+	TrafficQuotaScopePeer = "peer"
+	// TrafficQuotaScopeIPPool applies the quota to the sum of all peers of an IP pool.
+	TrafficQuotaScopeIPPool = "ip_pool"
+
+	// TrafficQuotaPeriodMonthly counts traffic since the first day of the current month.
+	TrafficQuotaPeriodMonthly = "monthly"
+	// TrafficQuotaPeriodRolling counts traffic during the last RollingDays days.
+	TrafficQuotaPeriodRolling = "rolling"
+)
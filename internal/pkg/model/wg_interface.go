@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// WGInterface represents a WireGuard server interface managed by NexusPointWG.
+// ListenPort, keys and Address are kept in the interface config file (<root-dir>/<name>.conf).
+// The NAT settings are rendered into <root-dir>/<name>.nat.sh, which the config runs from PostUp and PostDown.
+type WGInterface struct {
+	ID              string    `json:"id" gorm:"primaryKey"`
+	Name            string    `json:"name" gorm:"uniqueIndex;not null"`      // 接口名称，同时是配置文件名，例如 wg0 -> wg0.conf
+	Description     string    `json:"description" gorm:""`                   // 描述
+	Status          string    `json:"status" gorm:"not null;default:active"` // active, disabled
+	EgressInterface string    `json:"egress_interface" gorm:""`              // 出网接口，例如 eth0
+	Masquerade      bool      `json:"masquerade" gorm:"default:false"`       // 是否对经出网接口离开的 peer 流量做源地址伪装
+	IPForwarding    bool      `json:"ip_forwarding" gorm:"default:false"`    // 接口启动时是否开启内核 IP 转发
+	MSSClamping     bool      `json:"mss_clamping" gorm:"default:false"`     // 是否将进入隧道的 TCP 连接 MSS 钳制为路径 MTU
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+const (
+	// WGInterfaceStatusActive indicates the interface is active and can be used for new peers and IP pools.
+	WGInterfaceStatusActive = "active"
+	// WGInterfaceStatusDisabled indicates the interface is disabled and cannot be used for new peers and IP pools.
+	WGInterfaceStatusDisabled = "disabled"
+)
@@ -6,20 +6,30 @@ import (
 
 // WGPeer represents a WireGuard peer configuration.
 type WGPeer struct {
-	ID                  string    `json:"id" gorm:"primaryKey"`
-	UserID              string    `json:"user_id" gorm:"index;not null"`
-	DeviceName          string    `json:"device_name" gorm:"not null"`
-	ClientPrivateKey    string    `json:"client_private_key,omitempty" gorm:"column:client_private_key;not null"`
-	ClientPublicKey     string    `json:"client_public_key" gorm:"uniqueIndex;not null"`
-	ClientIP            string    `json:"client_ip" gorm:"index;not null"` // IPv4 CIDR, e.g. "100.100.100.2/32"
-	AllowedIPs          string    `json:"allowed_ips" gorm:"not null"`     // Comma-separated CIDRs
-	DNS                 string    `json:"dns" gorm:""`                      // Optional, comma-separated
-	Endpoint            string    `json:"endpoint" gorm:""`                // Optional, overrides server default
-	PersistentKeepalive int       `json:"persistent_keepalive" gorm:"default:25"`
-	Status              string    `json:"status" gorm:"not null;default:active"` // active, disabled
-	IPPoolID            string    `json:"ip_pool_id" gorm:"index"`                // 关联的IP池
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  string     `json:"id" gorm:"primaryKey"`
+	UserID              string     `json:"user_id" gorm:"index;not null"`
+	DeviceName          string     `json:"device_name" gorm:"not null"`
+	ClientPrivateKey    string     `json:"client_private_key,omitempty" gorm:"column:client_private_key;not null"` // 仅提交公钥的 peer 为空，私钥只保存在客户端
+	ClientPublicKey     string     `json:"client_public_key" gorm:"uniqueIndex;not null"`
+	PresharedKey        string     `json:"preshared_key,omitempty" gorm:"column:preshared_key"` // Optional, base64-encoded 32-byte key
+	ClientIP            string     `json:"client_ip" gorm:"index;not null"`                     // Host CIDRs, e.g. "100.100.100.2/32" or "100.100.100.2/32,fd00::2/128"
+	AllowedIPs          string     `json:"allowed_ips" gorm:"not null"`                         // Comma-separated CIDRs
+	DNS                 string     `json:"dns" gorm:""`                                         // Optional, comma-separated
+	Endpoint            string     `json:"endpoint" gorm:""`                                    // Optional, overrides server default
+	RoutedSubnets       string     `json:"routed_subnets,omitempty" gorm:""`                    // 站点到站点 peer 后方的子网（逗号分隔 CIDR），服务端 AllowedIPs 包含这些子网
+	PeerEndpoint        string     `json:"peer_endpoint,omitempty" gorm:""`                     // 服务端配置中 peer 的固定地址，例如分支机构路由器的公网地址
+	ExitNode            bool       `json:"exit_node" gorm:"default:false"`                      // 是否为出口节点，服务端 AllowedIPs 包含 0.0.0.0/0，每个接口最多一个
+	ExitPeerID          string     `json:"exit_peer_id,omitempty" gorm:"index"`                 // 流量经由的出口节点 peer，为空时跟随 IP 池的出口节点
+	PersistentKeepalive int        `json:"persistent_keepalive" gorm:"default:25"`
+	Status              string     `json:"status" gorm:"not null;default:active"` // active, disabled
+	DisabledReason      string     `json:"disabled_reason,omitempty" gorm:""`     // 自动禁用的原因，例如 quota_exceeded；手动禁用时为空
+	IPPoolID            string     `json:"ip_pool_id" gorm:"index"`               // 关联的IP池
+	InterfaceID         string     `json:"interface_id" gorm:"index"`             // 关联的 WireGuard 接口（跟随 IP 池）
+	ExpiresAt           *time.Time `json:"expires_at,omitempty" gorm:"index"`     // 过期时间，为空表示永不过期
+	KeyRotatedAt        *time.Time `json:"key_rotated_at,omitempty" gorm:""`      // 最近一次密钥轮换时间，为空表示从未轮换
+	SecretsUnreadable   bool       `json:"-" gorm:"-"`                            // 私钥或预共享密钥无法用当前主密钥解密，读出的密钥为空，保存时保留数据库中的密文
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 const (
@@ -29,3 +39,14 @@ const (
 	WGPeerStatusDisabled = "disabled"
 )
 
+const (
+	// WGPeerDisabledReasonQuota indicates the peer was disabled because a traffic quota was exceeded.
+	// It is re-enabled automatically when the quota period resets.
+	WGPeerDisabledReasonQuota = "quota_exceeded"
+	// WGPeerDisabledReasonExpired indicates the peer was disabled because it expired.
+	// Its IP addresses are released, so it cannot be re-enabled.
+	WGPeerDisabledReasonExpired = "expired"
+	// WGPeerDisabledReasonRevoked indicates the peer was revoked, e.g. because the device was lost.
+	// Its public key is on the revocation list, so it cannot be re-enabled.
+	WGPeerDisabledReasonRevoked = "revoked"
+)
@@ -7,11 +7,14 @@ import "fmt"
 type Resource string
 
 const (
-	ResourceUser     Resource = "user"
-	ResourceWGPeer   Resource = "wg_peer"
-	ResourceWGConfig Resource = "wg_config"
-	ResourceIPPool   Resource = "ip_pool"
-	ResourceWGServer Resource = "wg_server"
+	ResourceUser        Resource = "user"
+	ResourceWGPeer      Resource = "wg_peer"
+	ResourceWGConfig    Resource = "wg_config"
+	ResourceIPPool      Resource = "ip_pool"
+	ResourceWGServer    Resource = "wg_server"
+	ResourceWGInterface Resource = "wg_interface"
+	ResourceWGQuota     Resource = "wg_quota"
+	ResourceWGForward   Resource = "wg_port_forward"
 )
 
 // Scope represents ownership scope of a resource.
@@ -70,6 +73,8 @@ const (
 	ActionWGConfigRevoke Action = "wg_config:revoke"
 	// Update: update WireGuard peer configuration
 	ActionWGConfigUpdate Action = "wg_config:update"
+	// Share: create and manage download links for WireGuard client configuration
+	ActionWGConfigShare Action = "wg_config:share"
 
 	// ---- IP pool (admin-only) ----
 	// Create: create a new IP pool
@@ -86,4 +91,40 @@ const (
 	ActionWGServerGet Action = "wg_server:get"
 	// Update: update server configuration
 	ActionWGServerUpdate Action = "wg_server:update"
+	// RawHooks: set raw PostUp/PostDown shell commands, which wg-quick runs as root
+	ActionWGServerRawHooks Action = "wg_server:raw_hooks"
+	// Reconcile: reconcile the database with the config files on demand
+	ActionWGServerReconcile Action = "wg_server:reconcile"
+
+	// ---- WireGuard interface (admin-only) ----
+	// Create: create a new WireGuard interface
+	ActionWGInterfaceCreate Action = "wg_interface:create"
+	// Get: get a WireGuard interface
+	ActionWGInterfaceGet Action = "wg_interface:get"
+	// Update: update an existing WireGuard interface
+	ActionWGInterfaceUpdate Action = "wg_interface:update"
+	// Delete: delete a WireGuard interface
+	ActionWGInterfaceDelete Action = "wg_interface:delete"
+	// List: list WireGuard interfaces
+	ActionWGInterfaceList Action = "wg_interface:list"
+
+	// ---- WireGuard traffic quota (admin-only) ----
+	// Create: create a traffic quota on a user, peer or IP pool
+	ActionWGQuotaCreate Action = "wg_quota:create"
+	// Update: update an existing traffic quota
+	ActionWGQuotaUpdate Action = "wg_quota:update"
+	// Delete: delete a traffic quota
+	ActionWGQuotaDelete Action = "wg_quota:delete"
+	// List: list traffic quotas and their usage
+	ActionWGQuotaList Action = "wg_quota:list"
+
+	// ---- WireGuard port forward (admin-only) ----
+	// Create: expose a port of a peer on the server
+	ActionWGForwardCreate Action = "wg_port_forward:create"
+	// Update: update an existing port forward
+	ActionWGForwardUpdate Action = "wg_port_forward:update"
+	// Delete: delete a port forward
+	ActionWGForwardDelete Action = "wg_port_forward:delete"
+	// List: list port forwards
+	ActionWGForwardList Action = "wg_port_forward:list"
 )
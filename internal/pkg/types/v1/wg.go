@@ -11,8 +11,9 @@ type CreateWGPeerRequest struct {
 	// DeviceName is the name of the device (e.g., "My Laptop", "iPhone")
 	DeviceName string `json:"device_name" binding:"required,min=1,max=64"`
 	// ClientIP is the IP address to assign to the client (optional, will be auto-allocated if not provided)
-	// Format: IPv4 address without CIDR (e.g., "100.100.100.2")
-	ClientIP string `json:"client_ip,omitempty" binding:"omitempty,ipv4"`
+	// Format: IP address without CIDR, one per address family, comma-separated
+	// (e.g., "100.100.100.2" or "100.100.100.2,fd00:100::2"). Families not specified are auto-allocated.
+	ClientIP string `json:"client_ip,omitempty" binding:"omitempty,iplist"`
 	// IPPoolID is the ID of the IP pool to allocate from (required if ClientIP is not provided)
 	IPPoolID string `json:"ip_pool_id,omitempty" binding:"omitempty"`
 	// AllowedIPs is the allowed IPs for the peer (comma-separated CIDRs, optional, uses server default if not provided)
@@ -25,6 +26,23 @@ type CreateWGPeerRequest struct {
 	PersistentKeepalive *int `json:"persistent_keepalive,omitempty" binding:"omitempty,min=0,max=65535"`
 	// ClientPrivateKey is the WireGuard private key (optional, will be auto-generated if not provided)
 	ClientPrivateKey string `json:"client_private_key,omitempty" binding:"omitempty"`
+	// ClientPublicKey is the WireGuard public key of a client that keeps its private key (optional)
+	// The server then stores no private key and downloaded configs contain a placeholder instead.
+	// Cannot be combined with ClientPrivateKey; required if the IP pool requires public-key-only peers
+	ClientPublicKey string `json:"client_public_key,omitempty" binding:"omitempty,excluded_with=ClientPrivateKey"`
+	// PresharedKey is the WireGuard preshared key (optional, base64-encoded 32 bytes)
+	PresharedKey string `json:"preshared_key,omitempty" binding:"omitempty,wgpresharedkey"`
+	// EnablePresharedKey generates a preshared key when PresharedKey is not provided (optional)
+	// A preshared key is always generated when required by the IP pool or server policy
+	EnablePresharedKey *bool `json:"enable_preshared_key,omitempty" binding:"omitempty"`
+	// ExpiresAt is when the peer expires, RFC3339 (optional, uses the IP pool's default lifetime if not provided)
+	// Expired peers are disabled (or deleted) and their IP addresses are released
+	ExpiresAt string `json:"expires_at,omitempty" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	// RoutedSubnets are the subnets routed behind a site-to-site peer, e.g. an office LAN (optional, sensitive operation)
+	// Format: comma-separated CIDRs (e.g., "192.168.50.0/24"). They must not overlap an IP pool or another peer
+	RoutedSubnets string `json:"routed_subnets,omitempty" binding:"omitempty,cidr"`
+	// PeerEndpoint is the static address the server connects to, e.g. an office router (optional, sensitive operation)
+	PeerEndpoint string `json:"peer_endpoint,omitempty" binding:"omitempty,endpoint"`
 }
 
 // UpdateWGPeerRequest represents a request to update a WireGuard peer.
@@ -32,8 +50,9 @@ type CreateWGPeerRequest struct {
 type UpdateWGPeerRequest struct {
 	// DeviceName is the name of the device
 	DeviceName *string `json:"device_name,omitempty" binding:"omitempty,min=1,max=64"`
-	// ClientIP is the IP address to assign to the client (IPv4 address without CIDR, e.g., "100.100.100.2")
-	ClientIP *string `json:"client_ip,omitempty" binding:"omitempty,ipv4"`
+	// ClientIP is the IP address to assign to the client (without CIDR, one per address family,
+	// comma-separated, e.g., "100.100.100.2" or "100.100.100.2,fd00:100::2")
+	ClientIP *string `json:"client_ip,omitempty" binding:"omitempty,iplist"`
 	// IPPoolID is the ID of the IP pool to allocate from
 	IPPoolID *string `json:"ip_pool_id,omitempty" binding:"omitempty"`
 	// ClientPrivateKey is the WireGuard private key
@@ -50,6 +69,20 @@ type UpdateWGPeerRequest struct {
 	Status *string `json:"status,omitempty" binding:"omitempty,oneof=active disabled"`
 	// Username is the username of the user to bind this peer to (admin-only, sensitive operation)
 	Username *string `json:"username,omitempty" binding:"omitempty"`
+	// PresharedKey is the WireGuard preshared key, empty string removes it (sensitive operation)
+	PresharedKey *string `json:"preshared_key,omitempty" binding:"omitempty,wgpresharedkey"`
+	// RegeneratePresharedKey generates a new preshared key for the peer
+	RegeneratePresharedKey *bool `json:"regenerate_preshared_key,omitempty" binding:"omitempty"`
+	// ExpiresAt is when the peer expires, RFC3339, empty string removes the expiration (sensitive operation)
+	ExpiresAt *string `json:"expires_at,omitempty" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	// RoutedSubnets are the subnets routed behind the peer (comma-separated CIDRs), empty string removes them (sensitive operation)
+	RoutedSubnets *string `json:"routed_subnets,omitempty" binding:"omitempty,cidr"`
+	// PeerEndpoint is the static address the server connects to, empty string removes it (sensitive operation)
+	PeerEndpoint *string `json:"peer_endpoint,omitempty" binding:"omitempty,endpoint"`
+	// ExitNode makes the peer the exit node of its interface, routing the traffic of the peers assigned to it (sensitive operation)
+	ExitNode *bool `json:"exit_node,omitempty" binding:"omitempty"`
+	// ExitPeerID is the exit node the peer egresses through, empty string follows its IP pool (sensitive operation)
+	ExitPeerID *string `json:"exit_peer_id,omitempty" binding:"omitempty"`
 }
 
 // WGPeerResponse represents a WireGuard peer response.
@@ -61,15 +94,70 @@ type WGPeerResponse struct {
 	DeviceName          string `json:"device_name"`
 	ClientPublicKey     string `json:"client_public_key"`
 	ClientPrivateKey    string `json:"client_private_key,omitempty"` // Optional, sensitive information
+	PublicKeyOnly       bool   `json:"public_key_only"`              // The private key is only known to the client
+	PresharedKey        string `json:"preshared_key,omitempty"`      // Optional, sensitive information
 	ClientIP            string `json:"client_ip"`
 	AllowedIPs          string `json:"allowed_ips"`
 	DNS                 string `json:"dns,omitempty"`
 	Endpoint            string `json:"endpoint,omitempty"`
+	RoutedSubnets       string `json:"routed_subnets,omitempty"` // Subnets routed behind a site-to-site peer
+	PeerEndpoint        string `json:"peer_endpoint,omitempty"`  // Static address the server connects to
+	ExitNode            bool   `json:"exit_node"`                // The peer routes the traffic of the peers assigned to it
+	ExitPeerID          string `json:"exit_peer_id,omitempty"`   // Exit node the peer egresses through, empty if it follows its IP pool
 	PersistentKeepalive int    `json:"persistent_keepalive"`
 	Status              string `json:"status"`
+	DisabledReason      string `json:"disabled_reason,omitempty"` // Set when the peer was disabled automatically, e.g. quota_exceeded
 	IPPoolID            string `json:"ip_pool_id,omitempty"`
+	InterfaceID         string `json:"interface_id,omitempty"` // Follows the IP pool
+	ExpiresAt           string `json:"expires_at,omitempty"`   // Empty if the peer never expires
 	CreatedAt           string `json:"created_at"`
 	UpdatedAt           string `json:"updated_at"`
+
+	// Runtime status, populated when getting or listing peers if the interface is up
+	Online          bool   `json:"online"`
+	LatestHandshake string `json:"latest_handshake,omitempty"` // Empty if the peer never connected
+	TransferRx      int64  `json:"transfer_rx"`
+	TransferTx      int64  `json:"transfer_tx"`
+	RemoteEndpoint  string `json:"remote_endpoint,omitempty"` // Current remote address of the peer
+
+	// Applied reports what creating or updating the peer changed on the running interfaces
+	Applied []WGApplyResultResponse `json:"applied,omitempty"`
+}
+
+// WGApplyResultResponse reports what applying a server config changed on the running interface.
+// swagger:model
+type WGApplyResultResponse struct {
+	Method    string   `json:"method"`
+	Interface string   `json:"interface"`
+	Added     []string `json:"added,omitempty"`   // Public keys of added peers
+	Removed   []string `json:"removed,omitempty"` // Public keys of removed peers
+	Updated   []string `json:"updated,omitempty"` // Public keys of peers whose settings changed
+	// InterfaceChanged indicates the [Interface] section changed
+	InterfaceChanged bool `json:"interface_changed"`
+	// Restarted indicates the whole interface was reloaded, which may reset every peer's session
+	Restarted bool `json:"restarted"`
+	// Exact is false when the previous state was unknown and the change set is a best guess
+	Exact bool `json:"exact"`
+}
+
+// WGApplyResponse reports what a change applied to the running interfaces.
+// swagger:model
+type WGApplyResponse struct {
+	// Applied is empty if no config was applied (apply failed or the peer was on no interface)
+	Applied []WGApplyResultResponse `json:"applied"`
+}
+
+// WGPeerStatusResponse represents the runtime status of a WireGuard peer.
+// swagger:model
+type WGPeerStatusResponse struct {
+	PeerID          string `json:"peer_id"`
+	InterfaceID     string `json:"interface_id,omitempty"`
+	PublicKey       string `json:"public_key"`
+	Online          bool   `json:"online"`
+	LatestHandshake string `json:"latest_handshake,omitempty"` // Empty if the peer never connected
+	TransferRx      int64  `json:"transfer_rx"`
+	TransferTx      int64  `json:"transfer_tx"`
+	RemoteEndpoint  string `json:"remote_endpoint,omitempty"`
 }
 
 // WGPeerListResponse represents a paginated list of WireGuard peers.
@@ -84,8 +172,9 @@ type WGPeerListResponse struct {
 type CreateIPPoolRequest struct {
 	// Name is the name of the IP pool
 	Name string `json:"name" binding:"required,min=1,max=64"`
-	// CIDR is the CIDR range for the IP pool (e.g., "100.100.100.0/24")
-	CIDR string `json:"cidr" binding:"required,cidr"`
+	// CIDR is the CIDR range for the IP pool, IPv4, IPv6 or dual-stack
+	// (e.g., "100.100.100.0/24", "fd00:100::/64" or "100.100.100.0/24,fd00:100::/64")
+	CIDR string `json:"cidr" binding:"required,poolcidr"`
 	// Routes is the routes (comma-separated CIDRs) for client AllowedIPs (optional)
 	Routes string `json:"routes,omitempty" binding:"omitempty,cidr"`
 	// DNS is the DNS servers (comma-separated) for client config (optional)
@@ -94,6 +183,25 @@ type CreateIPPoolRequest struct {
 	Endpoint string `json:"endpoint,omitempty" binding:"omitempty,endpoint"`
 	// Description is a description of the IP pool
 	Description string `json:"description,omitempty" binding:"omitempty,max=255"`
+	// RequirePresharedKey requires all peers in this pool to use a preshared key (optional)
+	RequirePresharedKey bool `json:"require_preshared_key,omitempty" binding:"omitempty"`
+	// InterfaceID is the WireGuard interface the pool belongs to (optional, uses the default interface if not provided)
+	InterfaceID string `json:"interface_id,omitempty" binding:"omitempty"`
+	// PeerLifetimeHours is the default lifetime of new peers in this pool, in hours (optional, 0 means never expire)
+	PeerLifetimeHours int `json:"peer_lifetime_hours,omitempty" binding:"omitempty,min=0,max=87600"`
+	// RequirePublicKeyOnly requires new peers in this pool to submit only their public key,
+	// so the server never holds their private key (optional)
+	RequirePublicKeyOnly bool `json:"require_public_key_only,omitempty" binding:"omitempty"`
+	// PrefixLength allocates a whole IPv4 prefix of this length to each peer instead of a single address,
+	// e.g. 29 for a /29 per router or container host (optional, 0 means a single address)
+	PrefixLength int `json:"prefix_length,omitempty" binding:"omitempty,min=1,max=32"`
+	// PrefixLengthV6 allocates a whole IPv6 prefix of this length to each peer, e.g. 80 (optional, 0 means a single address)
+	PrefixLengthV6 int `json:"prefix_length_v6,omitempty" binding:"omitempty,min=1,max=128"`
+	// Isolation controls which peers the peers of this pool can reach through the server:
+	// any (default), pool (only peers of the same pool) or isolated (only the server)
+	Isolation string `json:"isolation,omitempty" binding:"omitempty,oneof=any pool isolated"`
+	// ExitPeerID is the exit node the peers of this pool egress through (optional, empty egresses from the server)
+	ExitPeerID string `json:"exit_peer_id,omitempty" binding:"omitempty"`
 }
 
 // UpdateIPPoolRequest represents a request to update an IP pool.
@@ -101,9 +209,10 @@ type CreateIPPoolRequest struct {
 type UpdateIPPoolRequest struct {
 	// Name is the name of the IP pool
 	Name *string `json:"name,omitempty" binding:"omitempty,min=1,max=64"`
-	// CIDR is the CIDR range for the IP pool (e.g., "100.100.100.0/24")
-	// Can only be modified when no IPs are allocated from this pool
-	CIDR *string `json:"cidr,omitempty" binding:"omitempty,cidr"`
+	// CIDR is the CIDR range for the IP pool, IPv4, IPv6 or dual-stack
+	// (e.g., "100.100.100.0/24" or "100.100.100.0/24,fd00:100::/64")
+	// Can only be modified when no IPs are allocated from this pool, except for adding an address family
+	CIDR *string `json:"cidr,omitempty" binding:"omitempty,poolcidr"`
 	// Routes is the routes (comma-separated CIDRs) for client AllowedIPs
 	Routes *string `json:"routes,omitempty" binding:"omitempty,cidr"`
 	// DNS is the DNS servers (comma-separated) for client config
@@ -114,6 +223,28 @@ type UpdateIPPoolRequest struct {
 	Description *string `json:"description,omitempty" binding:"omitempty,max=255"`
 	// Status is the pool status (active/disabled)
 	Status *string `json:"status,omitempty" binding:"omitempty,oneof=active disabled"`
+	// RequirePresharedKey requires all peers in this pool to use a preshared key
+	RequirePresharedKey *bool `json:"require_preshared_key,omitempty" binding:"omitempty"`
+	// InterfaceID is the WireGuard interface the pool belongs to
+	// Can only be modified when no IPs are allocated from this pool
+	InterfaceID *string `json:"interface_id,omitempty" binding:"omitempty"`
+	// PeerLifetimeHours is the default lifetime of new peers in this pool, in hours (0 means never expire)
+	// Existing peers keep their expiration
+	PeerLifetimeHours *int `json:"peer_lifetime_hours,omitempty" binding:"omitempty,min=0,max=87600"`
+	// RequirePublicKeyOnly requires new peers in this pool to submit only their public key
+	// Existing peers keep their keys
+	RequirePublicKeyOnly *bool `json:"require_public_key_only,omitempty" binding:"omitempty"`
+	// PrefixLength is the length of the IPv4 prefix allocated to each peer, 0 means a single address
+	// Can only be modified when no IPs are allocated from this pool
+	PrefixLength *int `json:"prefix_length,omitempty" binding:"omitempty,min=0,max=32"`
+	// PrefixLengthV6 is the length of the IPv6 prefix allocated to each peer, 0 means a single address
+	// Can only be modified when no IPs are allocated from this pool
+	PrefixLengthV6 *int `json:"prefix_length_v6,omitempty" binding:"omitempty,min=0,max=128"`
+	// Isolation controls which peers the peers of this pool can reach through the server (any/pool/isolated)
+	Isolation *string `json:"isolation,omitempty" binding:"omitempty,oneof=any pool isolated"`
+	// ExitPeerID is the exit node the peers of this pool egress through, empty string removes it
+	// Peers assigned to an exit node themselves are not affected
+	ExitPeerID *string `json:"exit_peer_id,omitempty" binding:"omitempty"`
 }
 
 // IPPoolResponse represents an IP pool response.
@@ -127,8 +258,18 @@ type IPPoolResponse struct {
 	Endpoint    string `json:"endpoint,omitempty"`
 	Description string `json:"description,omitempty"`
 	Status      string `json:"status"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	// RequirePresharedKey indicates whether peers in this pool must use a preshared key
+	RequirePresharedKey bool   `json:"require_preshared_key"`
+	InterfaceID         string `json:"interface_id,omitempty"`
+	PeerLifetimeHours   int    `json:"peer_lifetime_hours"` // Default lifetime of new peers, 0 means never expire
+	// RequirePublicKeyOnly indicates whether new peers in this pool must submit only their public key
+	RequirePublicKeyOnly bool   `json:"require_public_key_only"`
+	PrefixLength         int    `json:"prefix_length,omitempty"`    // Length of the IPv4 prefix allocated to each peer, empty for single addresses
+	PrefixLengthV6       int    `json:"prefix_length_v6,omitempty"` // Length of the IPv6 prefix allocated to each peer, empty for single addresses
+	Isolation            string `json:"isolation"`                  // Which peers the peers of this pool can reach: any, pool or isolated
+	ExitPeerID           string `json:"exit_peer_id,omitempty"`     // Exit node the peers of this pool egress through
+	CreatedAt            string `json:"created_at"`
+	UpdatedAt            string `json:"updated_at"`
 }
 
 // IPPoolListResponse represents a paginated list of IP pools.
@@ -141,10 +282,12 @@ type IPPoolListResponse struct {
 // AvailableIPsResponse represents a response containing available IP addresses.
 // swagger:model
 type AvailableIPsResponse struct {
-	IPPoolID string   `json:"ip_pool_id"`
-	CIDR     string   `json:"cidr"`
-	IPs      []string `json:"ips"`
-	Total    int      `json:"total"`
+	IPPoolID       string   `json:"ip_pool_id"`
+	CIDR           string   `json:"cidr"`
+	PrefixLength   int      `json:"prefix_length,omitempty"`    // Set if the pool allocates IPv4 prefixes, which are listed as CIDRs
+	PrefixLengthV6 int      `json:"prefix_length_v6,omitempty"` // Set if the pool allocates IPv6 prefixes, which are listed as CIDRs
+	IPs            []string `json:"ips"`
+	Total          int      `json:"total"`
 }
 
 // GetServerConfigResponse represents a response containing server configuration.
@@ -158,10 +301,18 @@ type GetServerConfigResponse struct {
 	PrivateKey string `json:"private_key"`
 	// MTU is the Maximum Transmission Unit (e.g., 1420)
 	MTU int `json:"mtu"`
-	// PostUp is the PostUp command
+	// PostUp is the raw PostUp command, without the command running the NAT script
 	PostUp string `json:"post_up"`
-	// PostDown is the PostDown command
+	// PostDown is the raw PostDown command, without the command running the NAT script
 	PostDown string `json:"post_down"`
+	// EgressInterface is the interface peers reach the internet through
+	EgressInterface string `json:"egress_interface"`
+	// Masquerade indicates whether peer traffic leaving through the egress interface is masqueraded
+	Masquerade bool `json:"masquerade"`
+	// IPForwarding indicates whether kernel IP forwarding is enabled when the interface comes up
+	IPForwarding bool `json:"ip_forwarding"`
+	// MSSClamping indicates whether the MSS of TCP connections entering the tunnel is clamped
+	MSSClamping bool `json:"mss_clamping"`
 	// PublicKey is the server public key (calculated from private key)
 	PublicKey string `json:"public_key"`
 	// ServerIP is the server public IP for client endpoint (optional, auto-detected if empty)
@@ -173,7 +324,7 @@ type GetServerConfigResponse struct {
 // UpdateServerConfigRequest represents a request to update server configuration.
 // swagger:model
 type UpdateServerConfigRequest struct {
-	// Address is the server tunnel IP (e.g., "100.100.100.1/24")
+	// Address is the server tunnel IP, one per address family (e.g., "100.100.100.1/24" or "100.100.100.1/24,fd00:100::1/64")
 	Address *string `json:"address,omitempty" binding:"omitempty,cidr"`
 	// ListenPort is the listening port
 	ListenPort *int `json:"listen_port,omitempty" binding:"omitempty,min=1,max=65535"`
@@ -181,16 +332,191 @@ type UpdateServerConfigRequest struct {
 	PrivateKey *string `json:"private_key,omitempty" binding:"omitempty,wgprivatekey"`
 	// MTU is the Maximum Transmission Unit
 	MTU *int `json:"mtu,omitempty" binding:"omitempty,min=68,max=65535"`
-	// PostUp is the PostUp command
+	// PostUp is a raw shell command run as root after the interface comes up (requires wg_server:raw_hooks)
 	PostUp *string `json:"post_up,omitempty" binding:"omitempty,max=1000"`
-	// PostDown is the PostDown command
+	// PostDown is a raw shell command run as root after the interface goes down (requires wg_server:raw_hooks)
 	PostDown *string `json:"post_down,omitempty" binding:"omitempty,max=1000"`
+	// EgressInterface is the interface peers reach the internet through (e.g., "eth0"), required for masquerading
+	EgressInterface *string `json:"egress_interface,omitempty" binding:"omitempty,max=15"`
+	// Masquerade translates the source of peer traffic leaving through the egress interface
+	Masquerade *bool `json:"masquerade,omitempty" binding:"omitempty"`
+	// IPForwarding enables kernel IP forwarding when the interface comes up
+	IPForwarding *bool `json:"ip_forwarding,omitempty" binding:"omitempty"`
+	// MSSClamping clamps the MSS of TCP connections entering the tunnel to the path MTU
+	MSSClamping *bool `json:"mss_clamping,omitempty" binding:"omitempty"`
 	// ServerIP is the server public IP for client endpoint (optional, auto-detected if empty)
 	ServerIP *string `json:"server_ip,omitempty" binding:"omitempty,ipv4"`
 	// DNS is the DNS server for client configs (optional, comma-separated IP addresses)
 	DNS *string `json:"dns,omitempty" binding:"omitempty,dnslist"`
 }
 
+// ServerConfigRevisionResponse represents a stored version of a server config file.
+// swagger:model
+type ServerConfigRevisionResponse struct {
+	// Revision is the revision number, increasing with each write
+	Revision  int    `json:"revision"`
+	CreatedAt string `json:"created_at"`
+	// Actor is the username that made the change ("system" for background jobs)
+	Actor string `json:"actor"`
+	// Action is the API call that made the change (e.g., "POST /api/v1/wg/peers")
+	Action string `json:"action"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+	// Applied reports what a rollback changed on the running interface
+	Applied []WGApplyResultResponse `json:"applied,omitempty"`
+}
+
+// ServerConfigRevisionListResponse represents the history of a server config file.
+// swagger:model
+type ServerConfigRevisionListResponse struct {
+	Total int64                          `json:"total"`
+	Items []ServerConfigRevisionResponse `json:"items"`
+}
+
+// ServerConfigDiffResponse represents a diff between two server config revisions.
+// swagger:model
+type ServerConfigDiffResponse struct {
+	From int `json:"from"`
+	// To is the target revision, 0 means the live config file
+	To int `json:"to"`
+	// Diff is a unified diff, empty if both versions are identical
+	Diff string `json:"diff"`
+}
+
+// DriftDifferenceResponse represents a field of a peer that differs between the database and its config file.
+// swagger:model
+type DriftDifferenceResponse struct {
+	// Field is allowed_ips, persistent_keepalive or comment
+	Field      string `json:"field"`
+	Database   string `json:"database"`
+	ConfigFile string `json:"config_file"`
+}
+
+// ReconcileActionResponse represents a difference between the database and a config file.
+// swagger:model
+type ReconcileActionResponse struct {
+	// ID identifies the action to approve, as long as the difference persists
+	ID string `json:"id"`
+	// Kind is import_peer, activate_peer, disable_peer, update_peer or remove_revoked_peer
+	Kind      string `json:"kind"`
+	Interface string `json:"interface"`
+	PublicKey string `json:"public_key"`
+	// PeerID is empty for peers unknown to the database
+	PeerID string `json:"peer_id,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// Differences are the fields differing for update_peer
+	Differences []DriftDifferenceResponse `json:"differences,omitempty"`
+	// IPPool is the CIDR of the IP pool created when the peer is imported
+	IPPool string `json:"ip_pool,omitempty"`
+}
+
+// DriftIPPoolResponse represents an IP pool that importing the config files would create.
+// swagger:model
+type DriftIPPoolResponse struct {
+	Name      string `json:"name"`
+	CIDR      string `json:"cidr"`
+	Interface string `json:"interface"`
+	// PublicKeys are the keys of the peers imported into the pool
+	PublicKeys []string `json:"public_keys"`
+}
+
+// DriftReportResponse represents the differences between the database and the config files.
+// swagger:model
+type DriftReportResponse struct {
+	GeneratedAt string `json:"generated_at"`
+	// Policy is what the automatic reconcile runs do about the differences: import, enforce or report
+	Policy string `json:"policy"`
+	// ExternalEdits are the interfaces whose config file was edited outside of NexusPointWG
+	ExternalEdits []string                  `json:"external_edits"`
+	Actions       []ReconcileActionResponse `json:"actions"`
+	// IPPools are the IP pools created when the actions are imported
+	IPPools []DriftIPPoolResponse `json:"ip_pools"`
+	Errors  []string              `json:"errors"`
+}
+
+// ApproveDriftActionRequest represents a request to resolve a single difference of the drift report.
+// swagger:model
+type ApproveDriftActionRequest struct {
+	// Resolution is import (the config file wins) or enforce (the database wins), default: the reconcile policy
+	Resolution string `json:"resolution,omitempty" binding:"omitempty,oneof=import enforce"`
+}
+
+// ReconcileStatusResponse represents the status of the reconciliation between the database and the config files.
+// swagger:model
+type ReconcileStatusResponse struct {
+	// Policy is import (the config files win), enforce (the database wins) or report
+	Policy  string `json:"policy"`
+	Running bool   `json:"running"`
+	// LastRun is when the last run started, empty before the first run
+	LastRun string `json:"last_run,omitempty"`
+	// LastTrigger is what started the last run: startup, interval, watch or api
+	LastTrigger    string `json:"last_trigger,omitempty"`
+	LastDurationMs int64  `json:"last_duration_ms"`
+	Runs           int    `json:"runs"`
+	// Applied is the number of differences resolved by the last run
+	Applied int `json:"applied"`
+	// ExternalEdits are the interfaces whose config file the last run found edited outside of NexusPointWG
+	ExternalEdits []string `json:"external_edits"`
+	// PendingActions are the differences left after the last run
+	PendingActions []ReconcileActionResponse `json:"pending_actions"`
+	Errors         []string                  `json:"errors"`
+}
+
+// CreateWGInterfaceRequest represents a request to create a WireGuard interface.
+// swagger:model
+type CreateWGInterfaceRequest struct {
+	// Name is the interface name, also used as config file name (e.g., "wg1" -> <root-dir>/wg1.conf)
+	Name string `json:"name" binding:"required,min=1,max=15"`
+	// Description is a description of the interface
+	Description string `json:"description,omitempty" binding:"omitempty,max=255"`
+	// Address is the server tunnel IP, one per address family (e.g., "100.100.101.1/24" or "100.100.101.1/24,fd00:101::1/64")
+	Address string `json:"address" binding:"required,cidr"`
+	// ListenPort is the listening port, must differ from other interfaces (optional, default 51820)
+	ListenPort int `json:"listen_port,omitempty" binding:"omitempty,min=1,max=65535"`
+	// PrivateKey is the server private key (optional, will be auto-generated if not provided)
+	PrivateKey string `json:"private_key,omitempty" binding:"omitempty,wgprivatekey"`
+	// MTU is the Maximum Transmission Unit (optional, default 1420)
+	MTU int `json:"mtu,omitempty" binding:"omitempty,min=68,max=65535"`
+	// PostUp is a raw shell command run as root after the interface comes up (requires wg_server:raw_hooks)
+	PostUp string `json:"post_up,omitempty" binding:"omitempty,max=1000"`
+	// PostDown is a raw shell command run as root after the interface goes down (requires wg_server:raw_hooks)
+	PostDown string `json:"post_down,omitempty" binding:"omitempty,max=1000"`
+}
+
+// UpdateWGInterfaceRequest represents a request to update a WireGuard interface.
+// Server config fields (Address, ListenPort, keys...) are updated via the server-config endpoint.
+// swagger:model
+type UpdateWGInterfaceRequest struct {
+	// Description is a description of the interface
+	Description *string `json:"description,omitempty" binding:"omitempty,max=255"`
+	// Status is the interface status (active/disabled), disabled interfaces accept no new peers or IP pools
+	Status *string `json:"status,omitempty" binding:"omitempty,oneof=active disabled"`
+}
+
+// WGInterfaceResponse represents a WireGuard interface response.
+// swagger:model
+type WGInterfaceResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Status      string `json:"status"`
+	// Address, ListenPort and PublicKey are read from the interface config file
+	Address    string `json:"address,omitempty"`
+	ListenPort int    `json:"listen_port,omitempty"`
+	PublicKey  string `json:"public_key,omitempty"`
+	// IsDefault indicates the interface configured by wireguard.interface
+	IsDefault bool   `json:"is_default"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// WGInterfaceListResponse represents a paginated list of WireGuard interfaces.
+// swagger:model
+type WGInterfaceListResponse struct {
+	Total int64                 `json:"total"`
+	Items []WGInterfaceResponse `json:"items"`
+}
+
 // BatchCreateIPPoolsRequest represents a batch IP pool creation request.
 // swagger:model
 type BatchCreateIPPoolsRequest struct {
@@ -292,3 +618,235 @@ type BatchDeleteWGPeersResponse struct {
 	// Count is the number of WireGuard peers deleted successfully
 	Count int64 `json:"count"`
 }
+
+// TrafficUsageResponse represents the traffic during one day or month.
+// swagger:model
+type TrafficUsageResponse struct {
+	Period     string `json:"period"`   // e.g. "2026-10-16" (day) or "2026-10" (month)
+	RxBytes    int64  `json:"rx_bytes"` // Received by the server (uploaded by the client)
+	TxBytes    int64  `json:"tx_bytes"` // Sent by the server (downloaded by the client)
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// TrafficUsageListResponse represents the traffic usage of a peer or a user.
+// swagger:model
+type TrafficUsageListResponse struct {
+	PeerID     string                 `json:"peer_id,omitempty"`
+	UserID     string                 `json:"user_id,omitempty"`
+	Period     string                 `json:"period"` // day or month
+	RxBytes    int64                  `json:"rx_bytes"`
+	TxBytes    int64                  `json:"tx_bytes"`
+	TotalBytes int64                  `json:"total_bytes"`
+	Items      []TrafficUsageResponse `json:"items"`
+}
+
+// CreateTrafficQuotaRequest represents a request to create a traffic quota.
+// swagger:model
+type CreateTrafficQuotaRequest struct {
+	// Scope is what the quota applies to: user, peer or ip_pool
+	Scope string `json:"scope" binding:"required,oneof=user peer ip_pool"`
+	// TargetID is the ID of the user, peer or IP pool
+	TargetID string `json:"target_id" binding:"required"`
+	// LimitBytes is the maximum of received plus sent bytes during the period
+	LimitBytes int64 `json:"limit_bytes" binding:"required,min=1"`
+	// Period is monthly (calendar month) or rolling (last rolling_days days), default monthly
+	Period string `json:"period,omitempty" binding:"omitempty,oneof=monthly rolling"`
+	// RollingDays is the window of a rolling quota in days, including today (default 30)
+	RollingDays int `json:"rolling_days,omitempty" binding:"omitempty,min=1,max=366"`
+}
+
+// UpdateTrafficQuotaRequest represents a request to update a traffic quota.
+// swagger:model
+type UpdateTrafficQuotaRequest struct {
+	LimitBytes  *int64  `json:"limit_bytes,omitempty" binding:"omitempty,min=1"`
+	Period      *string `json:"period,omitempty" binding:"omitempty,oneof=monthly rolling"`
+	RollingDays *int    `json:"rolling_days,omitempty" binding:"omitempty,min=1,max=366"`
+}
+
+// TrafficQuotaResponse represents a traffic quota and its usage in the current period.
+// swagger:model
+type TrafficQuotaResponse struct {
+	ID          string `json:"id"`
+	Scope       string `json:"scope"`
+	TargetID    string `json:"target_id"`
+	LimitBytes  int64  `json:"limit_bytes"`
+	Period      string `json:"period"`
+	RollingDays int    `json:"rolling_days,omitempty"`
+	PeriodStart string `json:"period_start"` // First day counted, YYYY-MM-DD
+	UsedBytes   int64  `json:"used_bytes"`
+	Exceeded    bool   `json:"exceeded"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// TrafficQuotaListResponse represents a list of traffic quotas.
+// swagger:model
+type TrafficQuotaListResponse struct {
+	Total int64                  `json:"total"`
+	Items []TrafficQuotaResponse `json:"items"`
+}
+
+// CreatePortForwardRequest represents a request to expose a port of a peer on the server.
+// swagger:model
+type CreatePortForwardRequest struct {
+	// Protocol is tcp or udp
+	Protocol string `json:"protocol" binding:"required,oneof=tcp udp"`
+	// PublicPort is the port exposed on the public address of the server
+	PublicPort int `json:"public_port" binding:"required,min=1,max=65535"`
+	// PeerID is the peer the port is forwarded to
+	PeerID string `json:"peer_id" binding:"required"`
+	// TargetPort is the port on the peer (optional, defaults to the public port)
+	TargetPort int `json:"target_port,omitempty" binding:"omitempty,min=1,max=65535"`
+	// Description is a description of the forward, e.g. "RDP to Alice's workstation"
+	Description string `json:"description,omitempty" binding:"omitempty,max=255"`
+}
+
+// UpdatePortForwardRequest represents a request to update a port forward.
+// swagger:model
+type UpdatePortForwardRequest struct {
+	Protocol    *string `json:"protocol,omitempty" binding:"omitempty,oneof=tcp udp"`
+	PublicPort  *int    `json:"public_port,omitempty" binding:"omitempty,min=1,max=65535"`
+	PeerID      *string `json:"peer_id,omitempty" binding:"omitempty"`
+	TargetPort  *int    `json:"target_port,omitempty" binding:"omitempty,min=1,max=65535"`
+	Description *string `json:"description,omitempty" binding:"omitempty,max=255"`
+}
+
+// PortForwardResponse represents a port forward.
+// swagger:model
+type PortForwardResponse struct {
+	ID          string `json:"id"`
+	Protocol    string `json:"protocol"`
+	PublicPort  int    `json:"public_port"`
+	PeerID      string `json:"peer_id"`
+	DeviceName  string `json:"device_name,omitempty"` // Name of the target peer
+	TargetIP    string `json:"target_ip,omitempty"`   // Peer address the forward is translated to
+	TargetPort  int    `json:"target_port"`
+	Description string `json:"description,omitempty"`
+	// Active indicates the forward is loaded, i.e. its peer is active
+	Active    bool   `json:"active"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// PortForwardListResponse represents a list of port forwards.
+// swagger:model
+type PortForwardListResponse struct {
+	Total int64                 `json:"total"`
+	Items []PortForwardResponse `json:"items"`
+}
+
+// RotateWGPeerKeysRequest represents a request to rotate the keys of many WireGuard peers.
+// swagger:model
+type RotateWGPeerKeysRequest struct {
+	// IPPoolID limits the rotation to the peers of an IP pool (optional)
+	IPPoolID string `json:"ip_pool_id,omitempty" binding:"omitempty"`
+	// UserID limits the rotation to the peers of a user (optional)
+	UserID string `json:"user_id,omitempty" binding:"omitempty"`
+	// OlderThanDays only rotates peers whose keys are older than this many days (optional, 0 rotates all)
+	OlderThanDays int `json:"older_than_days,omitempty" binding:"omitempty,min=0"`
+}
+
+// RotateWGPeerKeysResponse represents a response to a bulk key rotation.
+// swagger:model
+type RotateWGPeerKeysResponse struct {
+	// Count is the number of peers whose keys were rotated
+	Count int64 `json:"count"`
+}
+
+// RevokeWGPeerRequest represents a request to revoke a WireGuard peer.
+// swagger:model
+type RevokeWGPeerRequest struct {
+	// Reason explains why the peer is revoked (e.g., "device lost")
+	Reason string `json:"reason" binding:"required,min=1,max=255"`
+}
+
+// RevokedKeyResponse represents a revoked WireGuard public key.
+// swagger:model
+type RevokedKeyResponse struct {
+	PublicKey  string `json:"public_key"`
+	PeerID     string `json:"peer_id"`
+	UserID     string `json:"user_id"`
+	DeviceName string `json:"device_name"`
+	Reason     string `json:"reason"`
+	RevokedBy  string `json:"revoked_by"` // Username of the user who revoked the peer
+	CreatedAt  string `json:"created_at"`
+}
+
+// RevokedKeyListResponse represents a paginated list of revoked public keys.
+// swagger:model
+type RevokedKeyListResponse struct {
+	Total int64                `json:"total"`
+	Items []RevokedKeyResponse `json:"items"`
+}
+
+// ExportWGPeerConfigsRequest represents a request to export client configurations as a ZIP archive.
+// Peers are selected by PeerIDs, or else by UserID and/or IPPoolID; regular users default to their own peers.
+// swagger:model
+type ExportWGPeerConfigsRequest struct {
+	// PeerIDs is the list of peer IDs to export (optional, max 200 items)
+	PeerIDs []string `json:"peer_ids,omitempty" binding:"omitempty,max=200,dive,required"`
+	// UserID exports the peers of a user (optional)
+	UserID string `json:"user_id,omitempty" binding:"omitempty"`
+	// IPPoolID exports the peers of an IP pool (optional)
+	IPPoolID string `json:"ip_pool_id,omitempty" binding:"omitempty"`
+	// IncludeQR adds a PNG QR code next to each configuration
+	IncludeQR bool `json:"include_qr,omitempty"`
+	// IncludeManifest adds a manifest.json describing the exported peers
+	IncludeManifest bool `json:"include_manifest,omitempty"`
+}
+
+// ExportManifestEntry describes one exported peer in the manifest.json of a config export.
+// swagger:model
+type ExportManifestEntry struct {
+	PeerID      string `json:"peer_id"`
+	UserID      string `json:"user_id"`
+	DeviceName  string `json:"device_name"`
+	ClientIP    string `json:"client_ip"`
+	IPPoolID    string `json:"ip_pool_id,omitempty"`
+	InterfaceID string `json:"interface_id,omitempty"`
+	Status      string `json:"status"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	ConfigFile  string `json:"config_file"`
+	QRFile      string `json:"qr_file,omitempty"`
+}
+
+// CreateShareLinkRequest represents a request to create a config share link.
+// swagger:model
+type CreateShareLinkRequest struct {
+	// MaxUses is how many times the link can be used (optional, default 1)
+	MaxUses int `json:"max_uses,omitempty" binding:"omitempty,min=1,max=100"`
+	// ExpiresInMinutes is how long the link is valid (optional, default 60, max 7 days)
+	ExpiresInMinutes int `json:"expires_in_minutes,omitempty" binding:"omitempty,min=1,max=10080"`
+}
+
+// ShareLinkUseResponse represents one download through a share link.
+// swagger:model
+type ShareLinkUseResponse struct {
+	ClientIP  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ShareLinkResponse represents a config share link.
+// swagger:model
+type ShareLinkResponse struct {
+	ID        string `json:"id"`
+	PeerID    string `json:"peer_id"`
+	CreatedBy string `json:"created_by"`
+	MaxUses   int    `json:"max_uses"`
+	UseCount  int    `json:"use_count"`
+	ExpiresAt string `json:"expires_at"`
+	RevokedAt string `json:"revoked_at,omitempty"`
+	Usable    bool   `json:"usable"`
+	CreatedAt string `json:"created_at"`
+	// URL is the download URL, only returned when the link is created
+	URL  string                 `json:"url,omitempty"`
+	Uses []ShareLinkUseResponse `json:"uses,omitempty"`
+}
+
+// ShareLinkListResponse represents a list of config share links.
+// swagger:model
+type ShareLinkListResponse struct {
+	Total int64               `json:"total"`
+	Items []ShareLinkResponse `json:"items"`
+}
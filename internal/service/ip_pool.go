@@ -3,12 +3,13 @@ package service
 import (
 	"context"
 
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
-	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 	"github.com/HappyLadySauce/NexusPointWG/internal/store"
 	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
 	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/network"
+	"github.com/HappyLadySauce/errors"
 	"k8s.io/klog/v2"
 )
 
@@ -30,6 +31,8 @@ type IPPoolSrv interface {
 	BatchUpdateIPPools(ctx context.Context, pools []*model.IPPool) error
 	// BatchDeleteIPPools deletes multiple IP pools by IDs in a transaction.
 	BatchDeleteIPPools(ctx context.Context, ids []string) error
+	// SyncFirewalls rebuilds and loads the firewall rulesets enforcing the isolation policy of the pools of all interfaces.
+	SyncFirewalls(ctx context.Context)
 }
 
 type ipPoolSrv struct {
@@ -44,7 +47,23 @@ func newIPPools(s *service) *ipPoolSrv {
 }
 
 func (i *ipPoolSrv) CreateIPPool(ctx context.Context, pool *model.IPPool) error {
-	return i.store.IPPools().CreateIPPool(ctx, pool)
+	if err := ip.ValidatePoolPrefixLength(pool.CIDR, pool.PrefixLength, pool.PrefixLengthV6); err != nil {
+		return err
+	}
+	if err := i.bindInterface(ctx, pool); err != nil {
+		return err
+	}
+	if pool.Isolation == "" {
+		pool.Isolation = model.IPPoolIsolationAny
+	}
+	if err := validatePoolExitPeer(ctx, i.store, pool); err != nil {
+		return err
+	}
+	if err := i.store.IPPools().CreateIPPool(ctx, pool); err != nil {
+		return err
+	}
+	syncFirewall(ctx, i.store, pool.InterfaceID)
+	return nil
 }
 
 func (i *ipPoolSrv) GetIPPool(ctx context.Context, id string) (*model.IPPool, error) {
@@ -56,11 +75,46 @@ func (i *ipPoolSrv) GetIPPoolByCIDR(ctx context.Context, cidr string) (*model.IP
 }
 
 func (i *ipPoolSrv) UpdateIPPool(ctx context.Context, pool *model.IPPool) error {
-	return i.store.IPPools().UpdateIPPool(ctx, pool)
+	existingPool, err := i.store.IPPools().GetIPPool(ctx, pool.ID)
+	if err != nil {
+		return err
+	}
+	if err := i.checkPrefixLengthChange(ctx, existingPool, pool); err != nil {
+		return err
+	}
+	if pool.InterfaceID != existingPool.InterfaceID {
+		if err := i.bindInterface(ctx, pool); err != nil {
+			return err
+		}
+	}
+	if pool.ExitPeerID != existingPool.ExitPeerID || pool.InterfaceID != existingPool.InterfaceID {
+		if err := validatePoolExitPeer(ctx, i.store, pool); err != nil {
+			return err
+		}
+	}
+	if err := i.store.IPPools().UpdateIPPool(ctx, pool); err != nil {
+		return err
+	}
+	syncFirewall(ctx, i.store, pool.InterfaceID)
+	if pool.InterfaceID != existingPool.InterfaceID {
+		syncFirewall(ctx, i.store, existingPool.InterfaceID)
+	}
+	if pool.ExitPeerID != existingPool.ExitPeerID {
+		syncExitRouting(ctx, i.store, pool.InterfaceID)
+	}
+	return nil
 }
 
 func (i *ipPoolSrv) DeleteIPPool(ctx context.Context, id string) error {
-	return i.store.IPPools().DeleteIPPool(ctx, id)
+	pool, err := i.store.IPPools().GetIPPool(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := i.store.IPPools().DeleteIPPool(ctx, id); err != nil {
+		return err
+	}
+	syncFirewall(ctx, i.store, pool.InterfaceID)
+	return nil
 }
 
 // HasAllocatedIPs checks if an IP pool has any allocated IPs.
@@ -107,12 +161,6 @@ func (i *ipPoolSrv) UpdateIPPoolsEndpointForGlobalConfigChange(ctx context.Conte
 		}
 	}
 
-	// Create config manager for calculating endpoint
-	var configManager *wireguard.ServerConfigManager
-	if wgOpts.ServerConfigPath() != "" {
-		configManager = wireguard.NewServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod)
-	}
-
 	// Update each pool if needed
 	for _, pool := range pools {
 		needsUpdate := false
@@ -138,6 +186,8 @@ func (i *ipPoolSrv) UpdateIPPoolsEndpointForGlobalConfigChange(ctx context.Conte
 		if needsUpdate {
 			// Recalculate endpoint
 			oldEndpoint := pool.Endpoint
+			// Use the config manager of the pool's interface (nil if unavailable)
+			configManager, _ := interfaceConfigManager(ctx, i.store, pool.InterfaceID)
 			pool.Endpoint = CalculateIPPoolEndpoint("", wgOpts, configManager, ctx)
 
 			// Only update if endpoint actually changed
@@ -203,15 +253,98 @@ func (i *ipPoolSrv) UpdateIPPoolsDNSForGlobalConfigChange(ctx context.Context) e
 
 // BatchCreateIPPools creates multiple IP pools in a transaction.
 func (i *ipPoolSrv) BatchCreateIPPools(ctx context.Context, pools []*model.IPPool) error {
-	return i.store.IPPools().BatchCreateIPPools(ctx, pools)
+	for _, pool := range pools {
+		if err := ip.ValidatePoolPrefixLength(pool.CIDR, pool.PrefixLength, pool.PrefixLengthV6); err != nil {
+			return err
+		}
+		if err := i.bindInterface(ctx, pool); err != nil {
+			return err
+		}
+		if pool.Isolation == "" {
+			pool.Isolation = model.IPPoolIsolationAny
+		}
+		if err := validatePoolExitPeer(ctx, i.store, pool); err != nil {
+			return err
+		}
+	}
+	if err := i.store.IPPools().BatchCreateIPPools(ctx, pools); err != nil {
+		return err
+	}
+	syncAllFirewalls(ctx, i.store)
+	return nil
 }
 
 // BatchUpdateIPPools updates multiple IP pools in a transaction.
 func (i *ipPoolSrv) BatchUpdateIPPools(ctx context.Context, pools []*model.IPPool) error {
-	return i.store.IPPools().BatchUpdateIPPools(ctx, pools)
+	for _, pool := range pools {
+		existingPool, err := i.store.IPPools().GetIPPool(ctx, pool.ID)
+		if err != nil {
+			return err
+		}
+		if err := i.checkPrefixLengthChange(ctx, existingPool, pool); err != nil {
+			return err
+		}
+		if pool.InterfaceID != existingPool.InterfaceID {
+			if err := i.bindInterface(ctx, pool); err != nil {
+				return err
+			}
+		}
+		if pool.ExitPeerID != existingPool.ExitPeerID || pool.InterfaceID != existingPool.InterfaceID {
+			if err := validatePoolExitPeer(ctx, i.store, pool); err != nil {
+				return err
+			}
+		}
+	}
+	if err := i.store.IPPools().BatchUpdateIPPools(ctx, pools); err != nil {
+		return err
+	}
+	syncAllFirewalls(ctx, i.store)
+	syncAllExitRouting(ctx, i.store)
+	return nil
 }
 
 // BatchDeleteIPPools deletes multiple IP pools by IDs in a transaction.
 func (i *ipPoolSrv) BatchDeleteIPPools(ctx context.Context, ids []string) error {
-	return i.store.IPPools().BatchDeleteIPPools(ctx, ids)
+	if err := i.store.IPPools().BatchDeleteIPPools(ctx, ids); err != nil {
+		return err
+	}
+	syncAllFirewalls(ctx, i.store)
+	return nil
+}
+
+func (i *ipPoolSrv) SyncFirewalls(ctx context.Context) {
+	syncAllFirewalls(ctx, i.store)
+}
+
+// bindInterface binds a pool without an interface to the default interface
+// and checks that the pool's interface exists and is active.
+func (i *ipPoolSrv) bindInterface(ctx context.Context, pool *model.IPPool) error {
+	iface, err := resolveInterface(ctx, i.store, pool.InterfaceID)
+	if err != nil {
+		return err
+	}
+	if iface.Status != model.WGInterfaceStatusActive {
+		return errors.WithCode(code.ErrWGInterfaceDisabled, "interface %s is disabled", iface.Name)
+	}
+	pool.InterfaceID = iface.ID
+	return nil
+}
+
+// checkPrefixLengthChange validates the prefix lengths delegated by an updated pool. They can only be
+// modified while no IPs are allocated from the pool, since allocations of one pool share one size.
+func (i *ipPoolSrv) checkPrefixLengthChange(ctx context.Context, existingPool, pool *model.IPPool) error {
+	if err := ip.ValidatePoolPrefixLength(pool.CIDR, pool.PrefixLength, pool.PrefixLengthV6); err != nil {
+		return err
+	}
+	if pool.PrefixLength == existingPool.PrefixLength && pool.PrefixLengthV6 == existingPool.PrefixLengthV6 {
+		return nil
+	}
+	hasAllocated, err := i.HasAllocatedIPs(ctx, pool.ID)
+	if err != nil {
+		return err
+	}
+	if hasAllocated {
+		return errors.WithCode(code.ErrIPPoolInUse, "IP pool is in use and prefix length cannot be modified")
+	}
+	return nil
 }
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/snowflake"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// PortForwardSrv defines the interface for port forward business logic.
+type PortForwardSrv interface {
+	CreatePortForward(ctx context.Context, forward *model.PortForward) error
+	GetPortForward(ctx context.Context, id string) (*model.PortForward, error)
+	UpdatePortForward(ctx context.Context, forward *model.PortForward) error
+	DeletePortForward(ctx context.Context, id string) error
+	ListPortForwards(ctx context.Context, opt store.PortForwardListOptions) ([]*model.PortForward, error)
+	// TargetIP returns the peer address a forward is translated to, invalid if the peer has no IPv4 address.
+	TargetIP(peer *model.WGPeer) netip.Addr
+	// SyncPortForwards rebuilds and loads the port forward rulesets of all interfaces.
+	SyncPortForwards(ctx context.Context)
+}
+
+type portForwardSrv struct {
+	store store.Factory
+}
+
+// PortForwardSrv if implemented, then portForwardSrv implements PortForwardSrv interface.
+var _ PortForwardSrv = (*portForwardSrv)(nil)
+
+func newPortForwards(s *service) *portForwardSrv {
+	return &portForwardSrv{store: s.store}
+}
+
+func (p *portForwardSrv) CreatePortForward(ctx context.Context, forward *model.PortForward) error {
+	peer, err := p.validatePortForward(ctx, forward)
+	if err != nil {
+		return err
+	}
+	forwardID, err := snowflake.GenerateID()
+	if err != nil {
+		return errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate port forward ID")
+	}
+	forward.ID = forwardID
+	if err := p.store.PortForwards().CreatePortForward(ctx, forward); err != nil {
+		return err
+	}
+	syncPortForwards(ctx, p.store, peer.InterfaceID)
+	return nil
+}
+
+func (p *portForwardSrv) GetPortForward(ctx context.Context, id string) (*model.PortForward, error) {
+	return p.store.PortForwards().GetPortForward(ctx, id)
+}
+
+func (p *portForwardSrv) UpdatePortForward(ctx context.Context, forward *model.PortForward) error {
+	existing, err := p.store.PortForwards().GetPortForward(ctx, forward.ID)
+	if err != nil {
+		return err
+	}
+	peer, err := p.validatePortForward(ctx, forward)
+	if err != nil {
+		return err
+	}
+	if err := p.store.PortForwards().UpdatePortForward(ctx, forward); err != nil {
+		return err
+	}
+	syncPortForwards(ctx, p.store, peer.InterfaceID)
+	// The forward may have moved to a peer of another interface
+	if existing.PeerID != forward.PeerID {
+		if oldPeer, err := p.store.WGPeers().GetPeer(ctx, existing.PeerID); err == nil && oldPeer.InterfaceID != peer.InterfaceID {
+			syncPortForwards(ctx, p.store, oldPeer.InterfaceID)
+		}
+	}
+	return nil
+}
+
+func (p *portForwardSrv) DeletePortForward(ctx context.Context, id string) error {
+	forward, err := p.store.PortForwards().GetPortForward(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := p.store.PortForwards().DeletePortForward(ctx, id); err != nil {
+		return err
+	}
+	if peer, err := p.store.WGPeers().GetPeer(ctx, forward.PeerID); err == nil {
+		syncPortForwards(ctx, p.store, peer.InterfaceID)
+	}
+	return nil
+}
+
+func (p *portForwardSrv) ListPortForwards(ctx context.Context, opt store.PortForwardListOptions) ([]*model.PortForward, error) {
+	return p.store.PortForwards().ListPortForwards(ctx, opt)
+}
+
+func (p *portForwardSrv) TargetIP(peer *model.WGPeer) netip.Addr {
+	return portForwardTargetIP(peer)
+}
+
+func (p *portForwardSrv) SyncPortForwards(ctx context.Context) {
+	ifaces, _, err := p.store.WGInterfaces().ListInterfaces(ctx, store.WGInterfaceListOptions{Limit: 200})
+	if err != nil {
+		klog.V(1).InfoS("failed to list interfaces for port forward rulesets", "error", err)
+		return
+	}
+	for _, iface := range ifaces {
+		syncPortForwards(ctx, p.store, iface.ID)
+	}
+}
+
+// validatePortForward checks the protocol and ports of a forward and returns its target peer.
+// The public port must not be the WireGuard listen port of an interface (UDP), the port of the
+// API server (TCP) or the public port of another forward.
+func (p *portForwardSrv) validatePortForward(ctx context.Context, forward *model.PortForward) (*model.WGPeer, error) {
+	if forward.Protocol != model.PortForwardProtocolTCP && forward.Protocol != model.PortForwardProtocolUDP {
+		return nil, errors.WithCode(code.ErrValidation, "protocol must be tcp or udp")
+	}
+	if forward.PublicPort < 1 || forward.PublicPort > 65535 || forward.TargetPort < 1 || forward.TargetPort > 65535 {
+		return nil, errors.WithCode(code.ErrValidation, "ports must be between 1 and 65535")
+	}
+
+	peer, err := p.store.WGPeers().GetPeer(ctx, forward.PeerID)
+	if err != nil {
+		return nil, err
+	}
+	if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+		return nil, errors.WithCode(code.ErrWGPeerRevoked, "peer %s has been revoked", peer.ID)
+	}
+	if !portForwardTargetIP(peer).IsValid() {
+		return nil, errors.WithCode(code.ErrValidation, "peer %s has no IPv4 address to forward to", peer.DeviceName)
+	}
+
+	forwards, err := p.store.PortForwards().ListPortForwards(ctx, store.PortForwardListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range forwards {
+		if other.ID != forward.ID && other.Protocol == forward.Protocol && other.PublicPort == forward.PublicPort {
+			return nil, errors.WithCode(code.ErrWGPortForwardConflict, "%s port %d is already forwarded", forward.Protocol, forward.PublicPort)
+		}
+	}
+
+	if forward.Protocol == model.PortForwardProtocolTCP {
+		if cfg := config.Get(); cfg != nil && cfg.InsecureServing != nil && cfg.InsecureServing.BindPort == forward.PublicPort {
+			return nil, errors.WithCode(code.ErrWGPortForwardConflict, "tcp port %d is used by the API server", forward.PublicPort)
+		}
+		return peer, nil
+	}
+
+	ifaces, _, err := p.store.WGInterfaces().ListInterfaces(ctx, store.WGInterfaceListOptions{Limit: 200})
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		configManager, err := interfaceConfigManager(ctx, p.store, iface.ID)
+		if err != nil {
+			return nil, err
+		}
+		serverConfig, err := configManager.ReadServerConfig()
+		if err != nil {
+			return nil, err
+		}
+		if serverConfig.Interface != nil && serverConfig.Interface.ListenPort == forward.PublicPort {
+			return nil, errors.WithCode(code.ErrWGPortForwardConflict, "udp port %d is the listen port of interface %s", forward.PublicPort, iface.Name)
+		}
+	}
+	return peer, nil
+}
+
+// checkListenPortForwarded returns an error if a UDP forward already uses the public port
+// a WireGuard interface is about to listen on.
+func checkListenPortForwarded(ctx context.Context, s store.Factory, listenPort int) error {
+	forwards, err := s.PortForwards().ListPortForwards(ctx, store.PortForwardListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, forward := range forwards {
+		if forward.Protocol == model.PortForwardProtocolUDP && forward.PublicPort == listenPort {
+			return errors.WithCode(code.ErrWGPortForwardConflict, "udp port %d is already forwarded", listenPort)
+		}
+	}
+	return nil
+}
+
+// portForwardTargetIP returns the first IPv4 address of a peer, which forwards are translated to.
+func portForwardTargetIP(peer *model.WGPeer) netip.Addr {
+	prefixes, _ := parsePrefixes(peer.ClientIP)
+	for _, prefix := range prefixes {
+		if prefix.Addr().Is4() {
+			return prefix.Addr()
+		}
+	}
+	return netip.Addr{}
+}
+
+// syncPortForwards rebuilds and loads the port forward ruleset of an interface (the default
+// interface if interfaceID is empty) from the forwards to its active peers. Failures are logged,
+// the ruleset is retried on the next change.
+func syncPortForwards(ctx context.Context, s store.Factory, interfaceID string) {
+	iface, err := resolveInterface(ctx, s, interfaceID)
+	if err != nil {
+		klog.V(1).InfoS("failed to resolve interface for port forward ruleset", "interfaceID", interfaceID, "error", err)
+		return
+	}
+	forwards, err := s.PortForwards().ListPortForwards(ctx, store.PortForwardListOptions{})
+	if err != nil {
+		klog.V(1).InfoS("failed to list port forwards", "interface", iface.Name, "error", err)
+		return
+	}
+
+	peers := make(map[string]*model.WGPeer)
+	var rules []wireguard.PortForwardRule
+	for _, forward := range forwards {
+		peer, ok := peers[forward.PeerID]
+		if !ok {
+			peer, err = s.WGPeers().GetPeer(ctx, forward.PeerID)
+			if err != nil {
+				klog.V(1).InfoS("skipping port forward to unknown peer", "forwardID", forward.ID, "peerID", forward.PeerID, "error", err)
+				continue
+			}
+			peers[forward.PeerID] = peer
+		}
+		// Forwards to disabled peers stay defined but are not loaded
+		if peer.InterfaceID != iface.ID || peer.Status != model.WGPeerStatusActive {
+			continue
+		}
+		targetIP := portForwardTargetIP(peer)
+		if !targetIP.IsValid() {
+			continue
+		}
+		rules = append(rules, wireguard.PortForwardRule{
+			Protocol:   forward.Protocol,
+			PublicPort: forward.PublicPort,
+			TargetIP:   targetIP,
+			TargetPort: forward.TargetPort,
+			Comment:    peer.DeviceName,
+		})
+	}
+
+	configManager, err := interfaceConfigManager(ctx, s, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get server config manager for port forward ruleset", "interface", iface.Name, "error", err)
+		return
+	}
+	if err := configManager.ApplyPortForwards(rules); err != nil {
+		klog.V(1).InfoS("failed to apply port forward ruleset", "interface", iface.Name, "error", err)
+	}
+}
+
+// syncPeerPortForwards reloads the port forward rulesets of the given interfaces if a peer has forwards,
+// e.g. after it was disabled, re-enabled or got a new address.
+func syncPeerPortForwards(ctx context.Context, s store.Factory, peerID string, interfaceIDs ...string) {
+	forwards, err := s.PortForwards().ListPortForwards(ctx, store.PortForwardListOptions{PeerID: peerID})
+	if err != nil {
+		klog.V(1).InfoS("failed to list port forwards of peer", "peerID", peerID, "error", err)
+		return
+	}
+	if len(forwards) == 0 {
+		return
+	}
+	synced := make(map[string]bool, len(interfaceIDs))
+	for _, interfaceID := range interfaceIDs {
+		if !synced[interfaceID] {
+			synced[interfaceID] = true
+			syncPortForwards(ctx, s, interfaceID)
+		}
+	}
+}
+
+// deletePeerPortForwards deletes the port forwards to a deleted peer and unloads them.
+func deletePeerPortForwards(ctx context.Context, s store.Factory, peerID, interfaceID string) {
+	forwards, err := s.PortForwards().ListPortForwards(ctx, store.PortForwardListOptions{PeerID: peerID})
+	if err != nil {
+		klog.V(1).InfoS("failed to list port forwards of peer", "peerID", peerID, "error", err)
+		return
+	}
+	if len(forwards) == 0 {
+		return
+	}
+	if err := s.PortForwards().DeletePortForwardsByPeerID(ctx, peerID); err != nil {
+		klog.V(1).InfoS("failed to delete port forwards of peer", "peerID", peerID, "error", err)
+		return
+	}
+	syncPortForwards(ctx, s, interfaceID)
+}
@@ -10,6 +10,12 @@ type Service interface {
 	WGPeers() WGPeerSrv
 	IPPools() IPPoolSrv
 	WGServer() WGServerSrv
+	WGInterfaces() WGInterfaceSrv
+	Traffic() TrafficSrv
+	TrafficQuotas() TrafficQuotaSrv
+	ShareLinks() ShareLinkSrv
+	PortForwards() PortForwardSrv
+	Reconciler() ReconcileSrv
 }
 
 type service struct {
@@ -39,3 +45,27 @@ func (s *service) IPPools() IPPoolSrv {
 func (s *service) WGServer() WGServerSrv {
 	return newWGServer(s)
 }
+
+func (s *service) WGInterfaces() WGInterfaceSrv {
+	return newWGInterfaces(s)
+}
+
+func (s *service) Traffic() TrafficSrv {
+	return newTraffic(s)
+}
+
+func (s *service) TrafficQuotas() TrafficQuotaSrv {
+	return newTrafficQuotas(s)
+}
+
+func (s *service) ShareLinks() ShareLinkSrv {
+	return newShareLinks(s)
+}
+
+func (s *service) PortForwards() PortForwardSrv {
+	return newPortForwards(s)
+}
+
+func (s *service) Reconciler() ReconcileSrv {
+	return newReconciler(s)
+}
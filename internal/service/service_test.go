@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store/sqlite"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/options"
+)
+
+// testConfig returns the global config. It can only be initialized once, so the tests
+// initialize it empty and set the parts they need.
+func testConfig() *config.Config {
+	config.Init(&config.Config{})
+	return config.Get()
+}
+
+// newTestStore returns a sqlite temp store with the admin user user-1 and an active IP pool 100.100.100.0/24
+// of the default interface wg0, whose server config lives under a temp root dir and is applied with the none method.
+func newTestStore(t *testing.T) (store.Factory, *model.IPPool) {
+	t.Helper()
+	rootDir := t.TempDir()
+	wgOpts := options.NewWireGuardOptions()
+	wgOpts.RootDir = rootDir
+	wgOpts.ApplyMethod = wireguard.ApplyMethodNone
+	wgOpts.Endpoint = "vpn.example.com:51820"
+	wgOpts.ServerIP = "203.0.113.1"
+
+	cfg := testConfig()
+	previous := cfg.WireGuard
+	cfg.WireGuard = wgOpts
+	t.Cleanup(func() { cfg.WireGuard = previous })
+
+	privateKey, _, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	serverConfig := fmt.Sprintf("[Interface]\nPrivateKey = %s\nAddress = 100.100.100.1/24\nListenPort = 51820\n", privateKey)
+	if err := os.WriteFile(wgOpts.ServerConfigPath(), []byte(serverConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := sqlite.NewSqliteFactory(&options.SqliteOptions{DataSourceName: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("NewSqliteFactory() error = %v", err)
+	}
+	admin := &model.User{
+		ID:           "user-1",
+		Username:     "admin",
+		Nickname:     "admin",
+		Avatar:       model.DefaultAvatarURL,
+		Email:        "admin@example.com",
+		Salt:         "salt",
+		PasswordHash: "hash",
+		Status:       model.UserStatusActive,
+		Role:         model.UserRoleAdmin,
+	}
+	if err := s.Users().CreateUser(context.Background(), admin); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	pool := &model.IPPool{
+		ID:       "pool-1",
+		Name:     "default",
+		CIDR:     "100.100.100.0/24",
+		Routes:   "100.100.100.0/24",
+		Endpoint: wgOpts.Endpoint,
+		Status:   model.IPPoolStatusActive,
+	}
+	if err := s.IPPools().CreateIPPool(context.Background(), pool); err != nil {
+		t.Fatalf("CreateIPPool() error = %v", err)
+	}
+	return s, pool
+}
+
+// createTestPeer creates an active peer of the pool through the peer service.
+func createTestPeer(t *testing.T, s store.Factory, pool *model.IPPool, deviceName string) *model.WGPeer {
+	t.Helper()
+	peer, _, err := (&wgPeerSrv{store: s}).CreatePeer(context.Background(), "user-1", deviceName, pool.ID, "", "", "", "", "", "", "", "", "", nil, nil)
+	if err != nil {
+		t.Fatalf("CreatePeer(%s) error = %v", deviceName, err)
+	}
+	return peer
+}
+
+// serverConfigKeys returns the public keys of the peers in the server config of the default interface.
+func serverConfigKeys(t *testing.T) map[string]bool {
+	t.Helper()
+	wgOpts := config.Get().WireGuard
+	serverConfig, err := wireguard.GetServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod).ReadServerConfig()
+	if err != nil {
+		t.Fatalf("ReadServerConfig() error = %v", err)
+	}
+	keys := make(map[string]bool, len(serverConfig.Peers))
+	for _, peer := range serverConfig.Peers {
+		keys[peer.PublicKey] = true
+	}
+	return keys
+}
+
+// appendServerConfigPeer adds a [Peer] block to the server config of the default interface by hand,
+// the way an edit outside of NexusPointWG would.
+func appendServerConfigPeer(t *testing.T, comment, publicKey, allowedIPs string) {
+	t.Helper()
+	f, err := os.OpenFile(config.Get().WireGuard.ServerConfigPath(), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fmt.Fprintf(f, "\n# %s\n[Peer]\nPublicKey = %s\nAllowedIPs = %s\n", comment, publicKey, allowedIPs)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/snowflake"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// ShareLinkSrv defines the interface for config share link business logic.
+type ShareLinkSrv interface {
+	// CreateShareLink creates a link to download the config of a peer up to maxUses times within ttl.
+	// It returns the link and its token, which is only handed out once.
+	CreateShareLink(ctx context.Context, peer *model.WGPeer, createdBy string, maxUses int, ttl time.Duration) (*model.ShareLink, string, error)
+	GetShareLink(ctx context.Context, id string) (*model.ShareLink, error)
+	ListShareLinks(ctx context.Context, peerID string) ([]*model.ShareLink, error)
+	ListShareLinkUses(ctx context.Context, linkID string) ([]*model.ShareLinkUse, error)
+	RevokeShareLink(ctx context.Context, link *model.ShareLink) error
+	// OpenShareLink verifies a link token and returns the link and the peer to download the config of.
+	// The use is not counted: call RedeemShareLink once the config has been rendered.
+	OpenShareLink(ctx context.Context, token string) (*model.ShareLink, *model.WGPeer, error)
+	// RedeemShareLink counts and records a use of a link opened with OpenShareLink.
+	RedeemShareLink(ctx context.Context, link *model.ShareLink, peer *model.WGPeer, clientIP, userAgent string) error
+}
+
+type shareLinkSrv struct {
+	store store.Factory
+}
+
+// ShareLinkSrv if implemented, then shareLinkSrv implements ShareLinkSrv interface.
+var _ ShareLinkSrv = (*shareLinkSrv)(nil)
+
+func newShareLinks(s *service) *shareLinkSrv {
+	return &shareLinkSrv{store: s.store}
+}
+
+func (s *shareLinkSrv) CreateShareLink(ctx context.Context, peer *model.WGPeer, createdBy string, maxUses int, ttl time.Duration) (*model.ShareLink, string, error) {
+	if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+		return nil, "", errors.WithCode(code.ErrWGPeerRevoked, "peer %s has been revoked", peer.ID)
+	}
+
+	linkID, err := snowflake.GenerateID()
+	if err != nil {
+		return nil, "", errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate share link ID")
+	}
+	token, err := signShareLink(linkID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	link := &model.ShareLink{
+		ID:        linkID,
+		PeerID:    peer.ID,
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.store.ShareLinks().CreateShareLink(ctx, link); err != nil {
+		return nil, "", err
+	}
+
+	klog.V(1).InfoS("created config share link", "linkID", link.ID, "peerID", peer.ID, "createdBy", createdBy, "maxUses", maxUses, "expiresAt", link.ExpiresAt)
+	return link, token, nil
+}
+
+func (s *shareLinkSrv) GetShareLink(ctx context.Context, id string) (*model.ShareLink, error) {
+	return s.store.ShareLinks().GetShareLink(ctx, id)
+}
+
+func (s *shareLinkSrv) ListShareLinks(ctx context.Context, peerID string) ([]*model.ShareLink, error) {
+	return s.store.ShareLinks().ListShareLinks(ctx, peerID)
+}
+
+func (s *shareLinkSrv) ListShareLinkUses(ctx context.Context, linkID string) ([]*model.ShareLinkUse, error) {
+	return s.store.ShareLinks().ListShareLinkUses(ctx, linkID)
+}
+
+func (s *shareLinkSrv) RevokeShareLink(ctx context.Context, link *model.ShareLink) error {
+	if link.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	if err := s.store.ShareLinks().RevokeShareLink(ctx, link.ID, now); err != nil {
+		return err
+	}
+	link.RevokedAt = &now
+	return nil
+}
+
+func (s *shareLinkSrv) OpenShareLink(ctx context.Context, token string) (*model.ShareLink, *model.WGPeer, error) {
+	linkID, err := verifyShareLink(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	link, err := s.store.ShareLinks().GetShareLink(ctx, linkID)
+	if err != nil {
+		if errors.ParseCoder(err).Code() == code.ErrWGShareLinkNotFound {
+			return nil, nil, errors.WithCode(code.ErrWGShareLinkInvalid, "%s", code.Message(code.ErrWGShareLinkInvalid))
+		}
+		return nil, nil, err
+	}
+	if !link.Usable(time.Now()) {
+		return nil, nil, errors.WithCode(code.ErrWGShareLinkInvalid, "%s", code.Message(code.ErrWGShareLinkInvalid))
+	}
+
+	peer, err := s.store.WGPeers().GetPeer(ctx, link.PeerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+		return nil, nil, errors.WithCode(code.ErrWGPeerRevoked, "peer %s has been revoked", peer.ID)
+	}
+	return link, peer, nil
+}
+
+func (s *shareLinkSrv) RedeemShareLink(ctx context.Context, link *model.ShareLink, peer *model.WGPeer, clientIP, userAgent string) error {
+	useID, err := snowflake.GenerateID()
+	if err != nil {
+		return errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate share link use ID")
+	}
+	use := &model.ShareLinkUse{
+		ID:        useID,
+		LinkID:    link.ID,
+		PeerID:    peer.ID,
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+	}
+	// The link may have been used up or revoked since it was opened
+	if err := s.store.ShareLinks().UseShareLink(ctx, link.ID, use, time.Now()); err != nil {
+		return err
+	}
+
+	klog.V(1).InfoS("config share link used", "linkID", link.ID, "peerID", peer.ID, "clientIP", clientIP)
+	return nil
+}
+
+// signShareLink returns the token of a link: its ID and an HMAC of the ID keyed with the server secret.
+func signShareLink(linkID string) (string, error) {
+	signature, err := shareLinkSignature(linkID)
+	if err != nil {
+		return "", err
+	}
+	return linkID + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// verifyShareLink checks the signature of a link token and returns the link ID.
+func verifyShareLink(token string) (string, error) {
+	linkID, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok || linkID == "" {
+		return "", errors.WithCode(code.ErrWGShareLinkInvalid, "%s", code.Message(code.ErrWGShareLinkInvalid))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", errors.WithCode(code.ErrWGShareLinkInvalid, "%s", code.Message(code.ErrWGShareLinkInvalid))
+	}
+	expected, err := shareLinkSignature(linkID)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal(signature, expected) {
+		return "", errors.WithCode(code.ErrWGShareLinkInvalid, "%s", code.Message(code.ErrWGShareLinkInvalid))
+	}
+	return linkID, nil
+}
+
+func shareLinkSignature(linkID string) ([]byte, error) {
+	cfg := config.Get()
+	if cfg == nil || cfg.JWT == nil || cfg.JWT.Secret == "" {
+		return nil, errors.WithCode(code.ErrUnknown, "server secret not configured")
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.JWT.Secret))
+	mac.Write([]byte("share-link:" + linkID))
+	return mac.Sum(nil), nil
+}
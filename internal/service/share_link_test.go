@@ -0,0 +1,61 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/options"
+	"github.com/HappyLadySauce/errors"
+)
+
+func TestShareLinkSignature(t *testing.T) {
+	testConfig().JWT = &options.JWTOptions{Secret: "share-link-test-secret"}
+
+	token, err := signShareLink("1001")
+	if err != nil {
+		t.Fatalf("signShareLink() error = %v", err)
+	}
+	other, err := signShareLink("1002")
+	if err != nil {
+		t.Fatalf("signShareLink() error = %v", err)
+	}
+	_, otherSignature, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", token: token, want: "1001"},
+		{name: "signature of another link", token: "1001." + otherSignature, wantErr: true},
+		{name: "tampered link ID", token: "1002" + strings.TrimPrefix(token, "1001"), wantErr: true},
+		{name: "truncated signature", token: token[:len(token)-2], wantErr: true},
+		{name: "no signature", token: "1001", wantErr: true},
+		{name: "no link ID", token: "." + otherSignature, wantErr: true},
+		{name: "signature is not base64url", token: "1001.not base64!", wantErr: true},
+		{name: "empty", token: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			linkID, err := verifyShareLink(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("verifyShareLink(%q) = %q, want an error", tt.token, linkID)
+				}
+				if got := errors.ParseCoder(err).Code(); got != code.ErrWGShareLinkInvalid {
+					t.Errorf("verifyShareLink(%q) error code = %d, want %d", tt.token, got, code.ErrWGShareLinkInvalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyShareLink(%q) error = %v", tt.token, err)
+			}
+			if linkID != tt.want {
+				t.Errorf("verifyShareLink(%q) = %q, want %q", tt.token, linkID, tt.want)
+			}
+		})
+	}
+}
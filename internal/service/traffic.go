@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// TrafficSrv defines the interface for traffic accounting business logic.
+type TrafficSrv interface {
+	// Collect samples the transfer counters of all interfaces once and records the usage since the previous sample.
+	Collect(ctx context.Context) error
+	// Run collects traffic and enforces traffic quotas every interval until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+	// ListUsage returns the usage of a peer or a user by day or by month.
+	ListUsage(ctx context.Context, opt store.TrafficUsageOptions) ([]*store.TrafficUsagePoint, error)
+}
+
+type trafficSrv struct {
+	store store.Factory
+}
+
+// TrafficSrv if implemented, then trafficSrv implements TrafficSrv interface.
+var _ TrafficSrv = (*trafficSrv)(nil)
+
+func newTraffic(s *service) *trafficSrv {
+	return &trafficSrv{store: s.store}
+}
+
+func (t *trafficSrv) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Collect(ctx); err != nil {
+			klog.V(1).InfoS("failed to collect traffic", "error", err)
+		}
+		if err := (&trafficQuotaSrv{store: t.store}).EnforceQuotas(ctx); err != nil {
+			klog.V(1).InfoS("failed to enforce traffic quotas", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *trafficSrv) Collect(ctx context.Context) error {
+	ifaces, _, err := t.store.WGInterfaces().ListInterfaces(ctx, store.WGInterfaceListOptions{Limit: 200})
+	if err != nil {
+		return err
+	}
+	counters, err := t.store.Traffic().ListCounters(ctx)
+	if err != nil {
+		return err
+	}
+	previous := make(map[string]*model.TrafficCounter, len(counters))
+	for _, counter := range counters {
+		previous[counter.PeerID] = counter
+	}
+
+	now := time.Now()
+	for _, iface := range ifaces {
+		statuses, err := wireguard.GetPeerStatuses(iface.Name)
+		if err != nil {
+			// The interface may be down; its counters are picked up again on the next run
+			klog.V(2).InfoS("skipping traffic collection for interface", "interface", iface.Name, "error", err)
+			continue
+		}
+
+		for publicKey, status := range statuses {
+			peer, err := t.store.WGPeers().GetPeerByPublicKey(ctx, publicKey)
+			if err != nil {
+				if errors.ParseCoder(err).Code() != code.ErrWGPeerNotFound {
+					klog.V(1).InfoS("failed to get peer for traffic collection", "publicKey", publicKey, "error", err)
+				}
+				continue
+			}
+
+			counter := &model.TrafficCounter{
+				PeerID:     peer.ID,
+				PublicKey:  publicKey,
+				TransferRx: status.TransferRx,
+				TransferTx: status.TransferTx,
+				SampledAt:  now,
+			}
+			rx, tx := trafficDelta(previous[peer.ID], counter)
+
+			var usage *model.TrafficUsage
+			if rx > 0 || tx > 0 {
+				usage = &model.TrafficUsage{
+					PeerID:  peer.ID,
+					Day:     now.Format(model.TrafficDayLayout),
+					UserID:  peer.UserID,
+					RxBytes: rx,
+					TxBytes: tx,
+				}
+			}
+			if err := t.store.Traffic().RecordSample(ctx, counter, usage); err != nil {
+				klog.V(1).InfoS("failed to record traffic sample", "peerID", peer.ID, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// trafficDelta returns the bytes transferred between two samples of a peer.
+// The kernel counters restart from zero when the interface or the peer is re-created,
+// in which case everything counted since then is new traffic.
+func trafficDelta(prev, next *model.TrafficCounter) (rx, tx int64) {
+	if prev == nil || prev.PublicKey != next.PublicKey ||
+		next.TransferRx < prev.TransferRx || next.TransferTx < prev.TransferTx {
+		return next.TransferRx, next.TransferTx
+	}
+	return next.TransferRx - prev.TransferRx, next.TransferTx - prev.TransferTx
+}
+
+func (t *trafficSrv) ListUsage(ctx context.Context, opt store.TrafficUsageOptions) ([]*store.TrafficUsagePoint, error) {
+	return t.store.Traffic().ListUsage(ctx, opt)
+}
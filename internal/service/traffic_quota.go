@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/snowflake"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// TrafficQuotaSrv defines the interface for traffic quota business logic.
+type TrafficQuotaSrv interface {
+	CreateQuota(ctx context.Context, quota *model.TrafficQuota) error
+	GetQuota(ctx context.Context, id string) (*model.TrafficQuota, error)
+	UpdateQuota(ctx context.Context, quota *model.TrafficQuota) error
+	DeleteQuota(ctx context.Context, id string) error
+	ListQuotas(ctx context.Context, opt store.TrafficQuotaListOptions) ([]*model.TrafficQuota, error)
+	// QuotaUsage returns the bytes counted against a quota in its current period and the first day of the period.
+	QuotaUsage(ctx context.Context, quota *model.TrafficQuota) (int64, string, error)
+	// EnforceQuotas disables active peers over a quota and re-enables peers
+	// disabled by a quota once they are within all of their quotas again.
+	EnforceQuotas(ctx context.Context) error
+}
+
+type trafficQuotaSrv struct {
+	store store.Factory
+}
+
+// TrafficQuotaSrv if implemented, then trafficQuotaSrv implements TrafficQuotaSrv interface.
+var _ TrafficQuotaSrv = (*trafficQuotaSrv)(nil)
+
+func newTrafficQuotas(s *service) *trafficQuotaSrv {
+	return &trafficQuotaSrv{store: s.store}
+}
+
+func (t *trafficQuotaSrv) CreateQuota(ctx context.Context, quota *model.TrafficQuota) error {
+	if err := t.validateQuota(ctx, quota); err != nil {
+		return err
+	}
+	quotaID, err := snowflake.GenerateID()
+	if err != nil {
+		return errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate traffic quota ID")
+	}
+	quota.ID = quotaID
+	return t.store.Traffic().CreateQuota(ctx, quota)
+}
+
+func (t *trafficQuotaSrv) GetQuota(ctx context.Context, id string) (*model.TrafficQuota, error) {
+	return t.store.Traffic().GetQuota(ctx, id)
+}
+
+func (t *trafficQuotaSrv) UpdateQuota(ctx context.Context, quota *model.TrafficQuota) error {
+	if err := t.validateQuota(ctx, quota); err != nil {
+		return err
+	}
+	return t.store.Traffic().UpdateQuota(ctx, quota)
+}
+
+func (t *trafficQuotaSrv) DeleteQuota(ctx context.Context, id string) error {
+	if _, err := t.store.Traffic().GetQuota(ctx, id); err != nil {
+		return err
+	}
+	return t.store.Traffic().DeleteQuota(ctx, id)
+}
+
+func (t *trafficQuotaSrv) ListQuotas(ctx context.Context, opt store.TrafficQuotaListOptions) ([]*model.TrafficQuota, error) {
+	return t.store.Traffic().ListQuotas(ctx, opt)
+}
+
+// validateQuota checks the period and that the quota target exists.
+func (t *trafficQuotaSrv) validateQuota(ctx context.Context, quota *model.TrafficQuota) error {
+	if quota.LimitBytes <= 0 {
+		return errors.WithCode(code.ErrValidation, "limit_bytes must be positive")
+	}
+	switch quota.Period {
+	case model.TrafficQuotaPeriodMonthly:
+	case model.TrafficQuotaPeriodRolling:
+		if quota.RollingDays <= 0 {
+			return errors.WithCode(code.ErrValidation, "rolling_days must be positive for rolling quotas")
+		}
+	default:
+		return errors.WithCode(code.ErrValidation, "period must be monthly or rolling")
+	}
+
+	var err error
+	switch quota.Scope {
+	case model.TrafficQuotaScopeUser:
+		_, err = t.store.Users().GetUser(ctx, quota.TargetID)
+	case model.TrafficQuotaScopePeer:
+		_, err = t.store.WGPeers().GetPeer(ctx, quota.TargetID)
+	case model.TrafficQuotaScopeIPPool:
+		_, err = t.store.IPPools().GetIPPool(ctx, quota.TargetID)
+	default:
+		return errors.WithCode(code.ErrValidation, "scope must be user, peer or ip_pool")
+	}
+	return err
+}
+
+func (t *trafficQuotaSrv) QuotaUsage(ctx context.Context, quota *model.TrafficQuota) (int64, string, error) {
+	from := quotaPeriodStart(quota, time.Now())
+	opt := store.TrafficUsageOptions{
+		Period: store.TrafficPeriodMonth,
+		From:   from,
+	}
+	switch quota.Scope {
+	case model.TrafficQuotaScopeUser:
+		opt.UserID = quota.TargetID
+	case model.TrafficQuotaScopePeer:
+		opt.PeerID = quota.TargetID
+	case model.TrafficQuotaScopeIPPool:
+		peers, err := listAllPeers(ctx, t.store, store.WGPeerListOptions{IPPoolID: quota.TargetID})
+		if err != nil {
+			return 0, from, err
+		}
+		opt.PeerIDs = make([]string, 0, len(peers))
+		for _, peer := range peers {
+			opt.PeerIDs = append(opt.PeerIDs, peer.ID)
+		}
+	}
+
+	points, err := t.store.Traffic().ListUsage(ctx, opt)
+	if err != nil {
+		return 0, from, err
+	}
+	var used int64
+	for _, point := range points {
+		used += point.RxBytes + point.TxBytes
+	}
+	return used, from, nil
+}
+
+// quotaPeriodStart returns the first day counted by a quota at the given time.
+func quotaPeriodStart(quota *model.TrafficQuota, now time.Time) string {
+	if quota.Period == model.TrafficQuotaPeriodRolling {
+		return now.AddDate(0, 0, -(quota.RollingDays - 1)).Format(model.TrafficDayLayout)
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format(model.TrafficDayLayout)
+}
+
+func (t *trafficQuotaSrv) EnforceQuotas(ctx context.Context) error {
+	quotas, err := t.store.Traffic().ListQuotas(ctx, store.TrafficQuotaListOptions{})
+	if err != nil {
+		return err
+	}
+
+	// Find the peers over at least one of their quotas
+	exceeded := make(map[string]bool)
+	for _, quota := range quotas {
+		used, _, err := t.QuotaUsage(ctx, quota)
+		if err != nil {
+			klog.V(1).InfoS("failed to get traffic quota usage", "quotaID", quota.ID, "error", err)
+			continue
+		}
+		if used < quota.LimitBytes {
+			continue
+		}
+
+		var peers []*model.WGPeer
+		switch quota.Scope {
+		case model.TrafficQuotaScopeUser:
+			peers, err = listAllPeers(ctx, t.store, store.WGPeerListOptions{UserID: quota.TargetID})
+		case model.TrafficQuotaScopePeer:
+			var peer *model.WGPeer
+			if peer, err = t.store.WGPeers().GetPeer(ctx, quota.TargetID); err == nil {
+				peers = []*model.WGPeer{peer}
+			}
+		case model.TrafficQuotaScopeIPPool:
+			peers, err = listAllPeers(ctx, t.store, store.WGPeerListOptions{IPPoolID: quota.TargetID})
+		}
+		if err != nil {
+			klog.V(1).InfoS("failed to list peers of traffic quota", "quotaID", quota.ID, "error", err)
+			continue
+		}
+		for _, peer := range peers {
+			exceeded[peer.ID] = true
+		}
+	}
+
+	peers, err := listAllPeers(ctx, t.store, store.WGPeerListOptions{})
+	if err != nil {
+		return err
+	}
+
+	// Status changes go through the regular peer update, which rewrites and applies the server config
+	peerSrv := &wgPeerSrv{store: t.store}
+	ctx = wireguard.WithRevisionInfo(ctx, wireguard.RevisionInfo{Actor: "quota", Action: "enforce traffic quotas"})
+	for _, peer := range peers {
+		switch {
+		case exceeded[peer.ID] && peer.Status == model.WGPeerStatusActive:
+			peer.Status = model.WGPeerStatusDisabled
+			peer.DisabledReason = model.WGPeerDisabledReasonQuota
+			klog.V(1).InfoS("disabling peer over traffic quota", "peerID", peer.ID, "userID", peer.UserID)
+		case !exceeded[peer.ID] && peer.DisabledReason == model.WGPeerDisabledReasonQuota:
+			peer.Status = model.WGPeerStatusActive
+			peer.DisabledReason = ""
+			klog.V(1).InfoS("re-enabling peer within traffic quota", "peerID", peer.ID, "userID", peer.UserID)
+		default:
+			continue
+		}
+		if _, err := peerSrv.UpdatePeer(ctx, peer, nil, nil); err != nil {
+			klog.V(1).InfoS("failed to update peer for traffic quota", "peerID", peer.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+// listAllPeers lists all peers matching opt, page by page.
+func listAllPeers(ctx context.Context, s store.Factory, opt store.WGPeerListOptions) ([]*model.WGPeer, error) {
+	const pageSize = 200
+
+	var all []*model.WGPeer
+	opt.Limit = pageSize
+	for opt.Offset = 0; ; opt.Offset += pageSize {
+		peers, total, err := s.WGPeers().ListPeers(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, peers...)
+		if len(peers) < pageSize || int64(len(all)) >= total {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+)
+
+// disablePeerWith returns a setup disabling a peer through the peer service with the given reason.
+func disablePeerWith(reason string) func(t *testing.T, s store.Factory, peer *model.WGPeer) {
+	return func(t *testing.T, s store.Factory, peer *model.WGPeer) {
+		peer.Status = model.WGPeerStatusDisabled
+		peer.DisabledReason = reason
+		if _, err := (&wgPeerSrv{store: s}).UpdatePeer(context.Background(), peer, nil, nil); err != nil {
+			t.Fatalf("UpdatePeer() error = %v", err)
+		}
+	}
+}
+
+// recordTestUsage records bytes of traffic of a peer on the day daysAgo days before today.
+func recordTestUsage(t *testing.T, s store.Factory, peer *model.WGPeer, daysAgo int, bytes int64) {
+	t.Helper()
+	counter := &model.TrafficCounter{PeerID: peer.ID, PublicKey: peer.ClientPublicKey, SampledAt: time.Now()}
+	usage := &model.TrafficUsage{
+		PeerID:  peer.ID,
+		Day:     time.Now().AddDate(0, 0, -daysAgo).Format(model.TrafficDayLayout),
+		UserID:  peer.UserID,
+		RxBytes: bytes,
+	}
+	if err := s.Traffic().RecordSample(context.Background(), counter, usage); err != nil {
+		t.Fatalf("RecordSample() error = %v", err)
+	}
+}
+
+func TestEnforceQuotas(t *testing.T) {
+	revoke := func(t *testing.T, s store.Factory, peer *model.WGPeer) {
+		if _, err := (&wgPeerSrv{store: s}).RevokePeer(context.Background(), peer, "lost", "admin"); err != nil {
+			t.Fatalf("RevokePeer() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// setup puts the peer in its state before the quotas are enforced
+		setup        func(t *testing.T, s store.Factory, peer *model.WGPeer)
+		usageDaysAgo int
+		wantStatus   string
+		wantReason   string
+	}{
+		{
+			name:       "active peer over quota is disabled",
+			wantStatus: model.WGPeerStatusDisabled,
+			wantReason: model.WGPeerDisabledReasonQuota,
+		},
+		{
+			name:         "active peer within quota stays active",
+			usageDaysAgo: 10,
+			wantStatus:   model.WGPeerStatusActive,
+		},
+		{
+			name:       "peer disabled by quota stays disabled while over quota",
+			setup:      disablePeerWith(model.WGPeerDisabledReasonQuota),
+			wantStatus: model.WGPeerStatusDisabled,
+			wantReason: model.WGPeerDisabledReasonQuota,
+		},
+		{
+			name:         "peer disabled by quota is re-enabled once the period reset",
+			setup:        disablePeerWith(model.WGPeerDisabledReasonQuota),
+			usageDaysAgo: 10,
+			wantStatus:   model.WGPeerStatusActive,
+		},
+		{
+			name:       "expired peer over quota keeps its reason",
+			setup:      disablePeerWith(model.WGPeerDisabledReasonExpired),
+			wantStatus: model.WGPeerStatusDisabled,
+			wantReason: model.WGPeerDisabledReasonExpired,
+		},
+		{
+			name:         "expired peer within quota is not re-enabled",
+			setup:        disablePeerWith(model.WGPeerDisabledReasonExpired),
+			usageDaysAgo: 10,
+			wantStatus:   model.WGPeerStatusDisabled,
+			wantReason:   model.WGPeerDisabledReasonExpired,
+		},
+		{
+			name:       "revoked peer over quota keeps its reason",
+			setup:      revoke,
+			wantStatus: model.WGPeerStatusDisabled,
+			wantReason: model.WGPeerDisabledReasonRevoked,
+		},
+		{
+			name:         "revoked peer within quota is not re-enabled",
+			setup:        revoke,
+			usageDaysAgo: 10,
+			wantStatus:   model.WGPeerStatusDisabled,
+			wantReason:   model.WGPeerDisabledReasonRevoked,
+		},
+		{
+			name:         "peer disabled by hand is not re-enabled",
+			setup:        disablePeerWith(""),
+			usageDaysAgo: 10,
+			wantStatus:   model.WGPeerStatusDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, pool := newTestStore(t)
+			peer := createTestPeer(t, s, pool, "phone")
+			quotas := &trafficQuotaSrv{store: s}
+			quota := &model.TrafficQuota{
+				Scope:       model.TrafficQuotaScopePeer,
+				TargetID:    peer.ID,
+				LimitBytes:  1000,
+				Period:      model.TrafficQuotaPeriodRolling,
+				RollingDays: 7,
+			}
+			if err := quotas.CreateQuota(ctx, quota); err != nil {
+				t.Fatalf("CreateQuota() error = %v", err)
+			}
+			recordTestUsage(t, s, peer, tt.usageDaysAgo, 2000)
+			if tt.setup != nil {
+				tt.setup(t, s, peer)
+			}
+
+			if err := quotas.EnforceQuotas(ctx); err != nil {
+				t.Fatalf("EnforceQuotas() error = %v", err)
+			}
+
+			got, err := s.WGPeers().GetPeer(ctx, peer.ID)
+			if err != nil {
+				t.Fatalf("GetPeer() error = %v", err)
+			}
+			if got.Status != tt.wantStatus || got.DisabledReason != tt.wantReason {
+				t.Errorf("peer is %s (%q), want %s (%q)", got.Status, got.DisabledReason, tt.wantStatus, tt.wantReason)
+			}
+			// Only active peers are in the server config
+			if inConfig, wantInConfig := serverConfigKeys(t)[got.ClientPublicKey], tt.wantStatus == model.WGPeerStatusActive; inConfig != wantInConfig {
+				t.Errorf("peer in server config = %v, want %v", inConfig, wantInConfig)
+			}
+		})
+	}
+}
+
+func TestEnforceQuotasPeriodReset(t *testing.T) {
+	ctx := context.Background()
+	s, pool := newTestStore(t)
+	peer := createTestPeer(t, s, pool, "phone")
+	quotas := &trafficQuotaSrv{store: s}
+
+	// Yesterday's traffic counts against a quota over the last two days
+	quota := &model.TrafficQuota{
+		Scope:       model.TrafficQuotaScopePeer,
+		TargetID:    peer.ID,
+		LimitBytes:  1000,
+		Period:      model.TrafficQuotaPeriodRolling,
+		RollingDays: 2,
+	}
+	if err := quotas.CreateQuota(ctx, quota); err != nil {
+		t.Fatalf("CreateQuota() error = %v", err)
+	}
+	recordTestUsage(t, s, peer, 1, 2000)
+
+	steps := []struct {
+		name        string
+		rollingDays int
+		wantStatus  string
+		wantReason  string
+	}{
+		{name: "over quota", rollingDays: 2, wantStatus: model.WGPeerStatusDisabled, wantReason: model.WGPeerDisabledReasonQuota},
+		{name: "period reset", rollingDays: 1, wantStatus: model.WGPeerStatusActive},
+	}
+	for _, step := range steps {
+		quota.RollingDays = step.rollingDays
+		if err := quotas.UpdateQuota(ctx, quota); err != nil {
+			t.Fatalf("%s: UpdateQuota() error = %v", step.name, err)
+		}
+		if err := quotas.EnforceQuotas(ctx); err != nil {
+			t.Fatalf("%s: EnforceQuotas() error = %v", step.name, err)
+		}
+
+		got, err := s.WGPeers().GetPeer(ctx, peer.ID)
+		if err != nil {
+			t.Fatalf("%s: GetPeer() error = %v", step.name, err)
+		}
+		if got.Status != step.wantStatus || got.DisabledReason != step.wantReason {
+			t.Errorf("%s: peer is %s (%q), want %s (%q)", step.name, got.Status, got.DisabledReason, step.wantStatus, step.wantReason)
+		}
+		if inConfig, wantInConfig := serverConfigKeys(t)[got.ClientPublicKey], step.wantStatus == model.WGPeerStatusActive; inConfig != wantInConfig {
+			t.Errorf("%s: peer in server config = %v, want %v", step.name, inConfig, wantInConfig)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+)
+
+func TestTrafficDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		prev   *model.TrafficCounter
+		next   *model.TrafficCounter
+		wantRx int64
+		wantTx int64
+	}{
+		{
+			name:   "first sample counts the whole counter",
+			next:   &model.TrafficCounter{PublicKey: "key", TransferRx: 100, TransferTx: 200},
+			wantRx: 100, wantTx: 200,
+		},
+		{
+			name:   "counters grew",
+			prev:   &model.TrafficCounter{PublicKey: "key", TransferRx: 100, TransferTx: 200},
+			next:   &model.TrafficCounter{PublicKey: "key", TransferRx: 150, TransferTx: 260},
+			wantRx: 50, wantTx: 60,
+		},
+		{
+			name:   "idle peer",
+			prev:   &model.TrafficCounter{PublicKey: "key", TransferRx: 100, TransferTx: 200},
+			next:   &model.TrafficCounter{PublicKey: "key", TransferRx: 100, TransferTx: 200},
+			wantRx: 0, wantTx: 0,
+		},
+		{
+			name:   "interface restarted and reset the counters",
+			prev:   &model.TrafficCounter{PublicKey: "key", TransferRx: 100, TransferTx: 200},
+			next:   &model.TrafficCounter{PublicKey: "key", TransferRx: 30, TransferTx: 250},
+			wantRx: 30, wantTx: 250,
+		},
+		{
+			name:   "key rotated",
+			prev:   &model.TrafficCounter{PublicKey: "old", TransferRx: 100, TransferTx: 200},
+			next:   &model.TrafficCounter{PublicKey: "new", TransferRx: 300, TransferTx: 400},
+			wantRx: 300, wantTx: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rx, tx := trafficDelta(tt.prev, tt.next)
+			if rx != tt.wantRx || tx != tt.wantTx {
+				t.Errorf("trafficDelta() = (%d, %d), want (%d, %d)", rx, tx, tt.wantRx, tt.wantTx)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// DriftIPPool is an IP pool that importing the config files would create.
+type DriftIPPool struct {
+	Name       string
+	CIDR       string
+	Interface  string
+	PublicKeys []string
+}
+
+// DriftReport lists the differences between the database and the config files, without changing either.
+type DriftReport struct {
+	GeneratedAt time.Time
+	// Policy is what the automatic reconcile runs do about the differences
+	Policy string
+	// ExternalEdits are the interfaces whose config file was edited outside of NexusPointWG
+	ExternalEdits []string
+	Actions       []ReconcileAction
+	// IPPools are the IP pools created when the actions are imported
+	IPPools []DriftIPPool
+	Errors  []string
+}
+
+func (r *reconcileSrv) DriftReport(ctx context.Context) (*DriftReport, error) {
+	if cfg := config.Get(); cfg == nil || cfg.WireGuard == nil {
+		return nil, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+
+	actions, externalEdits, errs := planReconcile(ctx, r.store)
+	report := &DriftReport{
+		GeneratedAt:   time.Now(),
+		Policy:        reconcilePolicy(),
+		ExternalEdits: externalEdits,
+		Actions:       actions,
+		Errors:        errs,
+	}
+
+	pools := make(map[string]*DriftIPPool)
+	for _, action := range actions {
+		if action.IPPool == "" {
+			continue
+		}
+		pool, ok := pools[action.IPPool]
+		if !ok {
+			pool = &DriftIPPool{Name: ip.AutoIPPoolName(action.IPPool), CIDR: action.IPPool, Interface: action.Interface}
+			pools[action.IPPool] = pool
+		}
+		pool.PublicKeys = append(pool.PublicKeys, action.PublicKey)
+	}
+	for _, pool := range pools {
+		report.IPPools = append(report.IPPools, *pool)
+	}
+	sort.Slice(report.IPPools, func(i, j int) bool { return report.IPPools[i].CIDR < report.IPPools[j].CIDR })
+	return report, nil
+}
+
+func (r *reconcileSrv) ApproveDriftAction(ctx context.Context, id, resolution string) (*ReconcileAction, error) {
+	if cfg := config.Get(); cfg == nil || cfg.WireGuard == nil {
+		return nil, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+	policy := resolution
+	if policy == "" {
+		policy = reconcilePolicy()
+	}
+	if policy != ReconcilePolicyImport && policy != ReconcilePolicyEnforce {
+		return nil, errors.WithCode(code.ErrValidation, "resolution must be import or enforce when the reconcile policy is %s", policy)
+	}
+
+	reconcileState.runMu.Lock()
+	defer reconcileState.runMu.Unlock()
+
+	actions, _, _ := planReconcile(ctx, r.store)
+	var action *ReconcileAction
+	for i := range actions {
+		if actions[i].ID == id {
+			action = &actions[i]
+			break
+		}
+	}
+	if action == nil {
+		return nil, errors.WithCode(code.ErrWGDriftActionNotFound, "drift action %s not found", id)
+	}
+
+	if _, errs := applyReconcileActions(ctx, r.store, []ReconcileAction{*action}, policy); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	klog.V(1).InfoS("approved drift action", "id", id, "kind", action.Kind, "interface", action.Interface, "resolution", policy)
+
+	// The action is no longer pending
+	reconcileState.mu.Lock()
+	pending := reconcileState.status.PendingActions[:0:0]
+	for _, pendingAction := range reconcileState.status.PendingActions {
+		if pendingAction.ID != id {
+			pending = append(pending, pendingAction)
+		}
+	}
+	reconcileState.status.PendingActions = pending
+	reconcileState.mu.Unlock()
+
+	return action, nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/errors"
+)
+
+// newExternalPeer adds a peer to the server config by hand and returns its public key.
+func newExternalPeer(t *testing.T, comment, allowedIPs string) string {
+	t.Helper()
+	_, publicKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	appendServerConfigPeer(t, comment, publicKey, allowedIPs)
+	return publicKey
+}
+
+func TestDriftReport(t *testing.T) {
+	ctx := context.Background()
+	s, pool := newTestStore(t)
+	config.Get().WireGuard.ReconcilePolicy = ReconcilePolicyImport
+	createTestPeer(t, s, pool, "phone")
+	inPool := newExternalPeer(t, "watch", "100.100.100.9/32")
+	outsidePools := newExternalPeer(t, "router", "10.9.0.5/32")
+	serverConfigBefore, err := os.ReadFile(config.Get().WireGuard.ServerConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := (&reconcileSrv{store: s}).DriftReport(ctx)
+	if err != nil {
+		t.Fatalf("DriftReport() error = %v", err)
+	}
+
+	if report.Policy != ReconcilePolicyImport {
+		t.Errorf("report policy = %s, want %s", report.Policy, ReconcilePolicyImport)
+	}
+	if !reflect.DeepEqual(report.ExternalEdits, []string{"wg0"}) {
+		t.Errorf("report external edits = %v, want [wg0]", report.ExternalEdits)
+	}
+	wantIPPools := map[string]string{inPool: "", outsidePools: "10.9.0.0/24"}
+	if len(report.Actions) != len(wantIPPools) {
+		t.Fatalf("report has %d actions, want %d", len(report.Actions), len(wantIPPools))
+	}
+	for _, action := range report.Actions {
+		wantIPPool, ok := wantIPPools[action.PublicKey]
+		switch {
+		case !ok:
+			t.Errorf("unexpected %s action for %s", action.Kind, action.PublicKey)
+		case action.Kind != ReconcileImportPeer:
+			t.Errorf("action for %s is %s, want %s", action.PublicKey, action.Kind, ReconcileImportPeer)
+		case action.IPPool != wantIPPool:
+			t.Errorf("action for %s creates IP pool %q, want %q", action.PublicKey, action.IPPool, wantIPPool)
+		}
+	}
+	wantPools := []DriftIPPool{{Name: ip.AutoIPPoolName("10.9.0.0/24"), CIDR: "10.9.0.0/24", Interface: "wg0", PublicKeys: []string{outsidePools}}}
+	if !reflect.DeepEqual(report.IPPools, wantPools) {
+		t.Errorf("report IP pools = %+v, want %+v", report.IPPools, wantPools)
+	}
+
+	// Nothing is changed by the report
+	serverConfig, err := os.ReadFile(config.Get().WireGuard.ServerConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(serverConfig) != string(serverConfigBefore) {
+		t.Errorf("server config changed by the report")
+	}
+	if _, total, err := s.WGPeers().ListPeers(ctx, store.WGPeerListOptions{}); err != nil || total != 1 {
+		t.Errorf("ListPeers() = %d peers (error %v), want only the peer created through the API", total, err)
+	}
+	if _, total, err := s.IPPools().ListIPPools(ctx, store.IPPoolListOptions{}); err != nil || total != 1 {
+		t.Errorf("ListIPPools() = %d pools (error %v), want only the existing pool", total, err)
+	}
+}
+
+func TestApproveDriftAction(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		resolution string
+		// unknownID approves an action that does not exist
+		unknownID    bool
+		wantCode     int
+		wantImported bool
+		wantInConfig bool
+	}{
+		{
+			name:         "import resolution under the report policy",
+			policy:       ReconcilePolicyReport,
+			resolution:   ReconcilePolicyImport,
+			wantImported: true,
+			wantInConfig: true,
+		},
+		{
+			name:         "enforce resolution under the report policy",
+			policy:       ReconcilePolicyReport,
+			resolution:   ReconcilePolicyEnforce,
+			wantInConfig: false,
+		},
+		{
+			name:         "enforce resolution overrides the import policy",
+			policy:       ReconcilePolicyImport,
+			resolution:   ReconcilePolicyEnforce,
+			wantInConfig: false,
+		},
+		{
+			name:         "no resolution uses the import policy",
+			policy:       ReconcilePolicyImport,
+			wantImported: true,
+			wantInConfig: true,
+		},
+		{
+			name:         "no resolution uses the enforce policy",
+			policy:       ReconcilePolicyEnforce,
+			wantInConfig: false,
+		},
+		{
+			name:         "no resolution under the report policy",
+			policy:       ReconcilePolicyReport,
+			wantCode:     code.ErrValidation,
+			wantInConfig: true,
+		},
+		{
+			name:         "unknown action",
+			policy:       ReconcilePolicyReport,
+			resolution:   ReconcilePolicyImport,
+			unknownID:    true,
+			wantCode:     code.ErrWGDriftActionNotFound,
+			wantInConfig: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, pool := newTestStore(t)
+			createTestPeer(t, s, pool, "phone")
+			publicKey := newExternalPeer(t, "watch", "100.100.100.9/32")
+
+			// A report run leaves the external edit pending
+			config.Get().WireGuard.ReconcilePolicy = ReconcilePolicyReport
+			reconcileState.status = ReconcileStatus{}
+			reconciler := &reconcileSrv{store: s}
+			status, err := reconciler.Reconcile(ctx, "test", false)
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if len(status.PendingActions) != 1 || status.PendingActions[0].PublicKey != publicKey {
+				t.Fatalf("pending actions = %+v, want the import of %s", status.PendingActions, publicKey)
+			}
+			id := status.PendingActions[0].ID
+			if tt.unknownID {
+				id = "unknown"
+			}
+
+			config.Get().WireGuard.ReconcilePolicy = tt.policy
+			action, err := reconciler.ApproveDriftAction(ctx, id, tt.resolution)
+			if tt.wantCode != 0 {
+				if err == nil {
+					t.Fatalf("ApproveDriftAction() error = nil, want code %d", tt.wantCode)
+				}
+				if got := errors.ParseCoder(err).Code(); got != tt.wantCode {
+					t.Errorf("ApproveDriftAction() error = %v, want code %d", err, tt.wantCode)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("ApproveDriftAction() error = %v", err)
+				}
+				if action.ID != id || action.PublicKey != publicKey {
+					t.Errorf("approved action %s for %s, want %s for %s", action.ID, action.PublicKey, id, publicKey)
+				}
+			}
+
+			// Only an approved action is no longer pending
+			if pending := len(reconciler.Status().PendingActions) == 1; pending != (tt.wantCode != 0) {
+				t.Errorf("action pending = %v after approving it, want %v", pending, tt.wantCode != 0)
+			}
+			peer, err := s.WGPeers().GetPeerByPublicKey(ctx, publicKey)
+			if imported := err == nil; imported != tt.wantImported {
+				t.Errorf("external peer imported = %v, want %v", imported, tt.wantImported)
+			} else if imported && peer.DeviceName != ip.ExternalDeviceNamePrefix+"watch" {
+				t.Errorf("imported peer is named %q, want it named after its comment", peer.DeviceName)
+			}
+			if inConfig := serverConfigKeys(t)[publicKey]; inConfig != tt.wantInConfig {
+				t.Errorf("external peer in server config = %v, want %v", inConfig, tt.wantInConfig)
+			}
+		})
+	}
+}
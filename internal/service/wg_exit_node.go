@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// validateExitSettings checks the exit node settings of an updated peer. WireGuard routes 0.0.0.0/0 to a
+// single peer, so an interface has at most one exit node, and an exit node cannot be taken away while
+// peers or IP pools still egress through it.
+func validateExitSettings(ctx context.Context, s store.Factory, peer, existing *model.WGPeer) error {
+	if existing != nil && existing.ExitNode && (!peer.ExitNode || peer.InterfaceID != existing.InterfaceID) {
+		if err := checkExitNodeUnused(ctx, s, existing); err != nil {
+			return err
+		}
+	}
+
+	if peer.ExitNode {
+		if peer.ExitPeerID != "" {
+			return errors.WithCode(code.ErrWGExitNodeInvalid, "exit node %s cannot egress through another exit node", peer.DeviceName)
+		}
+		exitNode, err := interfaceExitNode(ctx, s, peer.InterfaceID)
+		if err != nil {
+			return err
+		}
+		if exitNode != nil && exitNode.ID != peer.ID {
+			return errors.WithCode(code.ErrWGExitNodeConflict, "peer %s is already the exit node of the interface", exitNode.DeviceName)
+		}
+		return nil
+	}
+
+	if peer.ExitPeerID == "" {
+		return nil
+	}
+	if peer.ExitPeerID == peer.ID {
+		return errors.WithCode(code.ErrWGExitNodeInvalid, "peer %s cannot egress through itself", peer.DeviceName)
+	}
+	return checkExitPeer(ctx, s, peer.ExitPeerID, peer.InterfaceID)
+}
+
+// validatePoolExitPeer checks the exit node the peers of an IP pool egress through by default.
+func validatePoolExitPeer(ctx context.Context, s store.Factory, pool *model.IPPool) error {
+	if pool.ExitPeerID == "" {
+		return nil
+	}
+	return checkExitPeer(ctx, s, pool.ExitPeerID, pool.InterfaceID)
+}
+
+// checkExitPeer checks that exitPeerID is the exit node of the interface.
+func checkExitPeer(ctx context.Context, s store.Factory, exitPeerID, interfaceID string) error {
+	exitPeer, err := s.WGPeers().GetPeer(ctx, exitPeerID)
+	if err != nil {
+		return err
+	}
+	if !exitPeer.ExitNode {
+		return errors.WithCode(code.ErrWGExitNodeInvalid, "peer %s is not an exit node", exitPeer.DeviceName)
+	}
+	if interfaceID != "" && exitPeer.InterfaceID != interfaceID {
+		return errors.WithCode(code.ErrWGExitNodeInvalid, "exit node %s belongs to another interface", exitPeer.DeviceName)
+	}
+	return nil
+}
+
+// checkExitNodeUnused returns ErrWGExitNodeInUse if peers or IP pools are assigned to the exit node.
+func checkExitNodeUnused(ctx context.Context, s store.Factory, exitNode *model.WGPeer) error {
+	peers, err := listAllPeers(ctx, s, store.WGPeerListOptions{InterfaceID: exitNode.InterfaceID})
+	if err != nil {
+		return err
+	}
+	for _, peer := range peers {
+		if peer.ExitPeerID == exitNode.ID {
+			return errors.WithCode(code.ErrWGExitNodeInUse, "exit node %s is still assigned to peer %s", exitNode.DeviceName, peer.DeviceName)
+		}
+	}
+	pools, err := listAllIPPools(ctx, s)
+	if err != nil {
+		return err
+	}
+	for _, pool := range pools {
+		if pool.ExitPeerID == exitNode.ID {
+			return errors.WithCode(code.ErrWGExitNodeInUse, "exit node %s is still assigned to IP pool %s", exitNode.DeviceName, pool.Name)
+		}
+	}
+	return nil
+}
+
+// interfaceExitNode returns the exit node of an interface, nil if it has none.
+func interfaceExitNode(ctx context.Context, s store.Factory, interfaceID string) (*model.WGPeer, error) {
+	peers, err := listAllPeers(ctx, s, store.WGPeerListOptions{InterfaceID: interfaceID})
+	if err != nil {
+		return nil, err
+	}
+	for _, peer := range peers {
+		if peer.ExitNode {
+			return peer, nil
+		}
+	}
+	return nil, nil
+}
+
+// clearExitAssignments unassigns the peers and IP pools of a deleted exit node, which then egress from the server again.
+func clearExitAssignments(ctx context.Context, s store.Factory, exitNode *model.WGPeer) {
+	peers, err := listAllPeers(ctx, s, store.WGPeerListOptions{InterfaceID: exitNode.InterfaceID})
+	if err != nil {
+		klog.V(1).InfoS("failed to list peers assigned to exit node", "peerID", exitNode.ID, "error", err)
+	}
+	for _, peer := range peers {
+		if peer.ExitPeerID != exitNode.ID {
+			continue
+		}
+		peer.ExitPeerID = ""
+		if err := s.WGPeers().UpdatePeer(ctx, peer); err != nil {
+			klog.V(1).InfoS("failed to unassign peer from deleted exit node", "peerID", peer.ID, "error", err)
+		}
+	}
+
+	pools, err := listAllIPPools(ctx, s)
+	if err != nil {
+		klog.V(1).InfoS("failed to list IP pools assigned to exit node", "peerID", exitNode.ID, "error", err)
+	}
+	for _, pool := range pools {
+		if pool.ExitPeerID != exitNode.ID {
+			continue
+		}
+		pool.ExitPeerID = ""
+		if err := s.IPPools().UpdateIPPool(ctx, pool); err != nil {
+			klog.V(1).InfoS("failed to unassign IP pool from deleted exit node", "poolID", pool.ID, "error", err)
+		}
+	}
+}
+
+// exitRouting collects the active peers of an interface egressing through its exit node, either
+// assigned directly or through their IP pool. Peers assigned to a disabled exit node keep their
+// rules and lose internet access rather than silently egressing from the server.
+func exitRouting(ctx context.Context, s store.Factory, interfaceID string) (wireguard.ExitRouting, error) {
+	var routing wireguard.ExitRouting
+	peers, err := listAllPeers(ctx, s, store.WGPeerListOptions{InterfaceID: interfaceID})
+	if err != nil {
+		return routing, err
+	}
+	var exitNode *model.WGPeer
+	for _, peer := range peers {
+		if peer.ExitNode {
+			exitNode = peer
+			break
+		}
+	}
+	if exitNode == nil {
+		return routing, nil
+	}
+	routing.ExitNode = exitNode.DeviceName
+
+	pools, err := listAllIPPools(ctx, s)
+	if err != nil {
+		return routing, err
+	}
+	poolExits := make(map[string]string, len(pools))
+	for _, pool := range pools {
+		poolExits[pool.ID] = pool.ExitPeerID
+	}
+
+	for _, peer := range peers {
+		if peer.ID == exitNode.ID || peer.Status != model.WGPeerStatusActive {
+			continue
+		}
+		exitPeerID := peer.ExitPeerID
+		if exitPeerID == "" {
+			exitPeerID = poolExits[peer.IPPoolID]
+		}
+		if exitPeerID != exitNode.ID {
+			continue
+		}
+		// Malformed addresses of legacy peers are skipped rather than blocking the rules
+		prefixes, _ := parsePrefixes(peer.ClientIP + "," + peer.RoutedSubnets)
+		routing.Sources = append(routing.Sources, prefixes...)
+	}
+	return routing, nil
+}
+
+// syncExitRouting rebuilds and loads the exit node routing of an interface (the default interface
+// if interfaceID is empty). Failures are logged, the routing is retried on the next change.
+func syncExitRouting(ctx context.Context, s store.Factory, interfaceID string) {
+	iface, err := resolveInterface(ctx, s, interfaceID)
+	if err != nil {
+		klog.V(1).InfoS("failed to resolve interface for exit node routing", "interfaceID", interfaceID, "error", err)
+		return
+	}
+	routing, err := exitRouting(ctx, s, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to compile exit node routing", "interface", iface.Name, "error", err)
+		return
+	}
+	configManager, err := interfaceConfigManager(ctx, s, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get server config manager for exit node routing", "interface", iface.Name, "error", err)
+		return
+	}
+	if err := configManager.ApplyExitRouting(ctx, routing); err != nil {
+		klog.V(1).InfoS("failed to apply exit node routing", "interface", iface.Name, "error", err)
+	}
+
+	// The isolation firewall lets the traffic of the peers egressing through the exit node through
+	syncFirewall(ctx, s, iface.ID)
+}
+
+func (w *wgPeerSrv) SyncExitRouting(ctx context.Context) {
+	syncAllExitRouting(ctx, w.store)
+}
+
+// syncAllExitRouting rebuilds and loads the exit node routing of all interfaces.
+func syncAllExitRouting(ctx context.Context, s store.Factory) {
+	ifaces, _, err := s.WGInterfaces().ListInterfaces(ctx, store.WGInterfaceListOptions{Limit: 200})
+	if err != nil {
+		klog.V(1).InfoS("failed to list interfaces for exit node routing", "error", err)
+		return
+	}
+	for _, iface := range ifaces {
+		syncExitRouting(ctx, s, iface.ID)
+	}
+}
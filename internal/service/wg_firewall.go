@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+// firewallBackend returns the configured firewall backend, FirewallNone if the ruleset is not managed.
+func firewallBackend() string {
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil || cfg.WireGuard.Firewall == "" {
+		return wireguard.FirewallNone
+	}
+	return cfg.WireGuard.Firewall
+}
+
+// firewallZones compiles the isolation policy of the IP pools of an interface into firewall zones.
+// A zone holds the pool addresses and the subnets routed behind its peers; pools that allow
+// any-to-any traffic get no zone.
+func firewallZones(ctx context.Context, s store.Factory, interfaceID string) ([]wireguard.FirewallZone, error) {
+	pools, err := listAllIPPools(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	sitePeers, err := listAllPeers(ctx, s, store.WGPeerListOptions{InterfaceID: interfaceID, HasRoutedSubnets: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var zones []wireguard.FirewallZone
+	for _, pool := range pools {
+		if pool.InterfaceID != interfaceID || pool.Isolation == "" || pool.Isolation == model.IPPoolIsolationAny {
+			continue
+		}
+		prefixes, err := ip.ParsePoolCIDR(pool.CIDR)
+		if err != nil {
+			klog.V(1).InfoS("skipping IP pool with invalid CIDR in firewall ruleset", "poolID", pool.ID, "cidr", pool.CIDR, "error", err)
+			continue
+		}
+		for _, peer := range sitePeers {
+			if peer.IPPoolID != pool.ID {
+				continue
+			}
+			// Malformed subnets of legacy peers are skipped rather than blocking the ruleset
+			subnets, _ := parsePrefixes(peer.RoutedSubnets)
+			prefixes = append(prefixes, subnets...)
+		}
+		zones = append(zones, wireguard.FirewallZone{
+			Name:          pool.Name,
+			Prefixes:      prefixes,
+			AllowInternal: pool.Isolation == model.IPPoolIsolationPool,
+		})
+	}
+	return zones, nil
+}
+
+// firewallExit collects the exit traffic of an interface: the peers egressing through its exit node,
+// and the pools and routed subnets of the interface their traffic to stays subject to the zones.
+func firewallExit(ctx context.Context, s store.Factory, interfaceID string) (wireguard.FirewallExit, error) {
+	var exit wireguard.FirewallExit
+	routing, err := exitRouting(ctx, s, interfaceID)
+	if err != nil || len(routing.Sources) == 0 {
+		return exit, err
+	}
+	exit.Sources = routing.Sources
+
+	pools, err := listAllIPPools(ctx, s)
+	if err != nil {
+		return exit, err
+	}
+	for _, pool := range pools {
+		if pool.InterfaceID != interfaceID {
+			continue
+		}
+		prefixes, err := ip.ParsePoolCIDR(pool.CIDR)
+		if err != nil {
+			klog.V(1).InfoS("skipping IP pool with invalid CIDR in firewall ruleset", "poolID", pool.ID, "cidr", pool.CIDR, "error", err)
+			continue
+		}
+		exit.Internal = append(exit.Internal, prefixes...)
+	}
+	sitePeers, err := listAllPeers(ctx, s, store.WGPeerListOptions{InterfaceID: interfaceID, HasRoutedSubnets: true})
+	if err != nil {
+		return exit, err
+	}
+	for _, peer := range sitePeers {
+		subnets, _ := parsePrefixes(peer.RoutedSubnets)
+		exit.Internal = append(exit.Internal, subnets...)
+	}
+	return exit, nil
+}
+
+// syncFirewall rebuilds and loads the managed firewall ruleset of an interface (the default
+// interface if interfaceID is empty). Failures are logged, the ruleset is retried on the next change.
+func syncFirewall(ctx context.Context, s store.Factory, interfaceID string) {
+	backend := firewallBackend()
+	if backend == wireguard.FirewallNone {
+		return
+	}
+
+	iface, err := resolveInterface(ctx, s, interfaceID)
+	if err != nil {
+		klog.V(1).InfoS("failed to resolve interface for firewall ruleset", "interfaceID", interfaceID, "error", err)
+		return
+	}
+	zones, err := firewallZones(ctx, s, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to compile firewall ruleset", "interface", iface.Name, "error", err)
+		return
+	}
+	exit, err := firewallExit(ctx, s, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to compile firewall ruleset", "interface", iface.Name, "error", err)
+		return
+	}
+	configManager, err := interfaceConfigManager(ctx, s, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get server config manager for firewall ruleset", "interface", iface.Name, "error", err)
+		return
+	}
+	if err := configManager.ApplyFirewall(backend, zones, exit); err != nil {
+		klog.V(1).InfoS("failed to apply firewall ruleset", "interface", iface.Name, "error", err)
+	}
+}
+
+// syncAllFirewalls rebuilds and loads the managed firewall rulesets of all interfaces.
+func syncAllFirewalls(ctx context.Context, s store.Factory) {
+	if firewallBackend() == wireguard.FirewallNone {
+		return
+	}
+
+	ifaces, _, err := s.WGInterfaces().ListInterfaces(ctx, store.WGInterfaceListOptions{Limit: 200})
+	if err != nil {
+		klog.V(1).InfoS("failed to list interfaces for firewall rulesets", "error", err)
+		return
+	}
+	for _, iface := range ifaces {
+		syncFirewall(ctx, s, iface.ID)
+	}
+}
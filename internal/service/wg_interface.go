@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"os"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/snowflake"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// WGInterfaceSrv defines the interface for WireGuard interface business logic.
+type WGInterfaceSrv interface {
+	// CreateInterface registers a new interface and writes its server config file.
+	CreateInterface(ctx context.Context, iface *model.WGInterface, ifaceConfig *wireguard.InterfaceConfig) error
+	GetInterface(ctx context.Context, id string) (*model.WGInterface, error)
+	GetInterfaceByName(ctx context.Context, name string) (*model.WGInterface, error)
+	UpdateInterface(ctx context.Context, iface *model.WGInterface) error
+	// DeleteInterface removes an unused interface, stops it and archives its server config file.
+	DeleteInterface(ctx context.Context, id string) error
+	ListInterfaces(ctx context.Context, opt store.WGInterfaceListOptions) ([]*model.WGInterface, int64, error)
+	// EnsureDefaultInterface makes sure the interface configured by wireguard.interface exists
+	// and binds IP pools and peers without an interface to it.
+	EnsureDefaultInterface(ctx context.Context) (*model.WGInterface, error)
+	// ConfigManager returns the server config manager of an interface (the default interface if id is empty).
+	ConfigManager(ctx context.Context, id string) (*wireguard.ServerConfigManager, error)
+}
+
+type wgInterfaceSrv struct {
+	store store.Factory
+}
+
+// WGInterfaceSrv if implemented, then wgInterfaceSrv implements WGInterfaceSrv interface.
+var _ WGInterfaceSrv = (*wgInterfaceSrv)(nil)
+
+func newWGInterfaces(s *service) *wgInterfaceSrv {
+	return &wgInterfaceSrv{store: s.store}
+}
+
+func (w *wgInterfaceSrv) CreateInterface(ctx context.Context, iface *model.WGInterface, ifaceConfig *wireguard.InterfaceConfig) error {
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+	wgOpts := cfg.WireGuard
+
+	if err := wireguard.ValidateInterfaceName(iface.Name); err != nil {
+		return err
+	}
+
+	// Existing config files are imported by config sync instead of being overwritten
+	configPath := wgOpts.InterfaceConfigPath(iface.Name)
+	if _, err := os.Stat(configPath); err == nil {
+		return errors.WithCode(code.ErrWGInterfaceAlreadyExists, "config file for interface %s already exists", iface.Name)
+	}
+
+	// Listen ports must be unique across interfaces
+	if ifaceConfig.ListenPort > 0 {
+		ifaces, _, err := w.store.WGInterfaces().ListInterfaces(ctx, store.WGInterfaceListOptions{Limit: 200})
+		if err != nil {
+			return err
+		}
+		for _, existing := range ifaces {
+			configManager := wireguard.GetServerConfigManager(wgOpts.InterfaceConfigPath(existing.Name), wgOpts.ApplyMethod)
+			serverConfig, err := configManager.ReadServerConfig()
+			if err != nil || serverConfig.Interface == nil {
+				continue
+			}
+			if serverConfig.Interface.ListenPort == ifaceConfig.ListenPort {
+				return errors.WithCode(code.ErrValidation, "listen port %d is already used by interface %s", ifaceConfig.ListenPort, existing.Name)
+			}
+		}
+	}
+
+	if ifaceConfig.PrivateKey == "" {
+		privateKey, err := wireguard.GeneratePrivateKey()
+		if err != nil {
+			return err
+		}
+		ifaceConfig.PrivateKey = privateKey
+	} else if err := wireguard.ValidatePrivateKey(ifaceConfig.PrivateKey); err != nil {
+		return err
+	}
+	if ifaceConfig.ListenPort == 0 {
+		ifaceConfig.ListenPort = 51820
+	}
+	if err := checkListenPortForwarded(ctx, w.store, ifaceConfig.ListenPort); err != nil {
+		return err
+	}
+	if ifaceConfig.MTU == 0 {
+		ifaceConfig.MTU = 1420
+	}
+
+	interfaceID, err := snowflake.GenerateID()
+	if err != nil {
+		return errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate interface ID")
+	}
+	iface.ID = interfaceID
+	if iface.Status == "" {
+		iface.Status = model.WGInterfaceStatusActive
+	}
+
+	if err := w.store.WGInterfaces().CreateInterface(ctx, iface); err != nil {
+		return err
+	}
+
+	configManager := wireguard.GetServerConfigManager(configPath, wgOpts.ApplyMethod)
+	if err := configManager.WriteServerConfig(ctx, &wireguard.ServerConfig{
+		Interface: ifaceConfig,
+		Peers:     make([]*wireguard.ServerPeerConfig, 0),
+	}); err != nil {
+		// Rollback: delete interface if config file cannot be written
+		_ = w.store.WGInterfaces().DeleteInterface(ctx, iface.ID)
+		return errors.Wrap(err, "failed to write server config")
+	}
+
+	if iface.Status == model.WGInterfaceStatusActive {
+		if err := configManager.StartInterface(); err != nil {
+			klog.V(1).InfoS("failed to start WireGuard interface", "interface", iface.Name, "error", err)
+			// Continue anyway, config is written and can be started manually
+		}
+	}
+
+	return nil
+}
+
+func (w *wgInterfaceSrv) GetInterface(ctx context.Context, id string) (*model.WGInterface, error) {
+	return w.store.WGInterfaces().GetInterface(ctx, id)
+}
+
+func (w *wgInterfaceSrv) GetInterfaceByName(ctx context.Context, name string) (*model.WGInterface, error) {
+	return w.store.WGInterfaces().GetInterfaceByName(ctx, name)
+}
+
+func (w *wgInterfaceSrv) UpdateInterface(ctx context.Context, iface *model.WGInterface) error {
+	return w.store.WGInterfaces().UpdateInterface(ctx, iface)
+}
+
+func (w *wgInterfaceSrv) DeleteInterface(ctx context.Context, id string) error {
+	iface, err := w.store.WGInterfaces().GetInterface(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+	wgOpts := cfg.WireGuard
+	if iface.Name == wgOpts.Interface {
+		return errors.WithCode(code.ErrWGInterfaceInUse, "default interface %s cannot be deleted", iface.Name)
+	}
+
+	if err := w.store.WGInterfaces().DeleteInterface(ctx, id); err != nil {
+		return err
+	}
+
+	configManager := wireguard.GetServerConfigManager(wgOpts.InterfaceConfigPath(iface.Name), wgOpts.ApplyMethod)
+	if err := configManager.StopInterface(); err != nil {
+		klog.V(1).InfoS("failed to stop WireGuard interface", "interface", iface.Name, "error", err)
+		// Continue anyway
+	}
+	if backend := firewallBackend(); backend != wireguard.FirewallNone {
+		if err := configManager.RemoveFirewall(backend); err != nil {
+			klog.V(1).InfoS("failed to remove firewall ruleset", "interface", iface.Name, "error", err)
+			// Continue anyway
+		}
+	}
+	if err := configManager.ArchiveConfig(); err != nil {
+		klog.V(1).InfoS("failed to archive server config", "interface", iface.Name, "error", err)
+		// Continue anyway, interface is removed from database
+	}
+
+	return nil
+}
+
+func (w *wgInterfaceSrv) ListInterfaces(ctx context.Context, opt store.WGInterfaceListOptions) ([]*model.WGInterface, int64, error) {
+	return w.store.WGInterfaces().ListInterfaces(ctx, opt)
+}
+
+func (w *wgInterfaceSrv) EnsureDefaultInterface(ctx context.Context) (*model.WGInterface, error) {
+	iface, err := ensureDefaultInterface(ctx, w.store)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.store.WGInterfaces().AssignUnboundToInterface(ctx, iface.ID); err != nil {
+		return nil, err
+	}
+	return iface, nil
+}
+
+func (w *wgInterfaceSrv) ConfigManager(ctx context.Context, id string) (*wireguard.ServerConfigManager, error) {
+	return interfaceConfigManager(ctx, w.store, id)
+}
+
+// ensureDefaultInterface returns the interface configured by wireguard.interface, creating its record if needed.
+// The config file itself is created on first read by ServerConfigManager.
+func ensureDefaultInterface(ctx context.Context, s store.Factory) (*model.WGInterface, error) {
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return nil, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+
+	name := cfg.WireGuard.Interface
+	iface, err := s.WGInterfaces().GetInterfaceByName(ctx, name)
+	if err == nil {
+		return iface, nil
+	}
+	if errors.ParseCoder(err).Code() != code.ErrWGInterfaceNotFound {
+		return nil, err
+	}
+
+	interfaceID, err := snowflake.GenerateID()
+	if err != nil {
+		return nil, errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate interface ID")
+	}
+	iface = &model.WGInterface{
+		ID:          interfaceID,
+		Name:        name,
+		Description: "Default interface",
+		Status:      model.WGInterfaceStatusActive,
+	}
+	if err := s.WGInterfaces().CreateInterface(ctx, iface); err != nil {
+		// Another request may have created it concurrently
+		if existing, getErr := s.WGInterfaces().GetInterfaceByName(ctx, name); getErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+
+	klog.V(1).InfoS("created default WireGuard interface", "interfaceID", iface.ID, "name", name)
+	return iface, nil
+}
+
+// resolveInterface returns the interface with the given ID, or the default interface if id is empty.
+func resolveInterface(ctx context.Context, s store.Factory, id string) (*model.WGInterface, error) {
+	if id == "" {
+		return ensureDefaultInterface(ctx, s)
+	}
+	return s.WGInterfaces().GetInterface(ctx, id)
+}
+
+// interfaceConfigManager returns the shared server config manager of an interface
+// (the default interface if id is empty).
+func interfaceConfigManager(ctx context.Context, s store.Factory, id string) (*wireguard.ServerConfigManager, error) {
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return nil, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+	wgOpts := cfg.WireGuard
+
+	// The default interface does not need a database lookup
+	if id == "" {
+		return wireguard.GetServerConfigManager(wgOpts.ServerConfigPath(), wgOpts.ApplyMethod), nil
+	}
+
+	iface, err := resolveInterface(ctx, s, id)
+	if err != nil {
+		return nil, err
+	}
+	return wireguard.GetServerConfigManager(wgOpts.InterfaceConfigPath(iface.Name), wgOpts.ApplyMethod), nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"k8s.io/klog/v2"
+)
+
+// natBackend returns the backend of the NAT rules: nftables if the firewall is managed with nftables, iptables otherwise.
+func natBackend() string {
+	if firewallBackend() == wireguard.FirewallNftables {
+		return wireguard.FirewallNftables
+	}
+	return wireguard.FirewallIptables
+}
+
+// interfaceNATSettings returns the NAT settings stored on an interface.
+func interfaceNATSettings(iface *model.WGInterface) wireguard.NATSettings {
+	return wireguard.NATSettings{
+		EgressInterface: iface.EgressInterface,
+		Masquerade:      iface.Masquerade,
+		IPForwarding:    iface.IPForwarding,
+		MSSClamping:     iface.MSSClamping,
+	}
+}
+
+// syncNAT writes and loads the NAT script of an interface (the default interface if interfaceID
+// is empty). Failures are logged, the script is retried on the next change.
+func syncNAT(ctx context.Context, s store.Factory, interfaceID string) {
+	iface, err := resolveInterface(ctx, s, interfaceID)
+	if err != nil {
+		klog.V(1).InfoS("failed to resolve interface for NAT script", "interfaceID", interfaceID, "error", err)
+		return
+	}
+	configManager, err := interfaceConfigManager(ctx, s, iface.ID)
+	if err != nil {
+		klog.V(1).InfoS("failed to get server config manager for NAT script", "interface", iface.Name, "error", err)
+		return
+	}
+	serverConfig, err := configManager.ReadServerConfig()
+	if err != nil {
+		klog.V(1).InfoS("failed to read server config for NAT script", "interface", iface.Name, "error", err)
+		return
+	}
+	if err := configManager.ApplyNAT(natBackend(), serverConfig.Interface.Address, interfaceNATSettings(iface)); err != nil {
+		klog.V(1).InfoS("failed to apply NAT script", "interface", iface.Name, "error", err)
+	}
+}
+
+func (w *wgServerSrv) GetNATSettings(ctx context.Context, interfaceID string) (*wireguard.NATSettings, error) {
+	iface, err := resolveInterface(ctx, w.store, interfaceID)
+	if err != nil {
+		return nil, err
+	}
+	settings := interfaceNATSettings(iface)
+	return &settings, nil
+}
+
+func (w *wgServerSrv) SyncNAT(ctx context.Context) {
+	ifaces, _, err := w.store.WGInterfaces().ListInterfaces(ctx, store.WGInterfaceListOptions{Limit: 200})
+	if err != nil {
+		klog.V(1).InfoS("failed to list interfaces for NAT scripts", "error", err)
+		return
+	}
+	for _, iface := range ifaces {
+		syncNAT(ctx, w.store, iface.ID)
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
@@ -23,11 +26,14 @@ import (
 
 // WGPeerSrv defines the interface for WireGuard peer business logic.
 type WGPeerSrv interface {
-	CreatePeer(ctx context.Context, userID, deviceName, ipPoolID, clientIP, allowedIPs, dns, endpoint, clientPrivateKey string, persistentKeepalive *int) (*model.WGPeer, error)
+	// CreatePeer creates a peer. If clientPublicKey is given, the client keeps its private key and the server stores none.
+	// routedSubnets and peerEndpoint make it a site-to-site peer standing for the subnets behind it.
+	// The peer methods changing the server config also return what applying it changed on the running interfaces.
+	CreatePeer(ctx context.Context, userID, deviceName, ipPoolID, clientIP, allowedIPs, dns, endpoint, clientPrivateKey, clientPublicKey, presharedKey, routedSubnets, peerEndpoint string, persistentKeepalive *int, expiresAt *time.Time) (*model.WGPeer, []*wireguard.ApplyResult, error)
 	GetPeer(ctx context.Context, id string) (*model.WGPeer, error)
 	GetPeerByPublicKey(ctx context.Context, publicKey string) (*model.WGPeer, error)
-	UpdatePeer(ctx context.Context, peer *model.WGPeer, newClientIP, newIPPoolID *string) error
-	DeletePeer(ctx context.Context, id string, isHardDelete bool) error
+	UpdatePeer(ctx context.Context, peer *model.WGPeer, newClientIP, newIPPoolID *string) ([]*wireguard.ApplyResult, error)
+	DeletePeer(ctx context.Context, id string, isHardDelete bool) ([]*wireguard.ApplyResult, error)
 	ListPeers(ctx context.Context, opt store.WGPeerListOptions) ([]*model.WGPeer, int64, error)
 	ReleaseIP(ctx context.Context, peerID string) error
 	CountPeersByUserID(ctx context.Context, userID string) (int64, error)
@@ -42,37 +48,47 @@ type WGPeerSrv interface {
 	BatchUpdatePeers(ctx context.Context, peers []*model.WGPeer) error
 	// BatchDeletePeers deletes multiple WireGuard peers by IDs in a transaction.
 	BatchDeletePeers(ctx context.Context, ids []string) error
+	// GetPeerStatus reads the runtime status (handshake, transfer, endpoint) of a peer from its interface.
+	GetPeerStatus(ctx context.Context, peer *model.WGPeer) (*wireguard.PeerStatus, error)
+	// GetPeerStatuses reads the runtime status of peers, keyed by peer ID.
+	// Peers on interfaces whose status cannot be read are left out.
+	GetPeerStatuses(ctx context.Context, peers []*model.WGPeer) map[string]*wireguard.PeerStatus
+	// ReapExpiredPeers disables (or deletes, if deleteExpired is set) expired peers and releases their IP addresses.
+	ReapExpiredPeers(ctx context.Context, deleteExpired bool) (int, error)
+	// RunReaper reaps expired peers every interval until ctx is done.
+	RunReaper(ctx context.Context, interval time.Duration, deleteExpired bool)
+	// RotatePeerKeys generates new keys for a peer and updates the server and client configs.
+	RotatePeerKeys(ctx context.Context, peer *model.WGPeer) ([]*wireguard.ApplyResult, error)
+	// RotatePeersKeys rotates the keys of peers matching opt whose keys are older than maxAge (0 rotates all).
+	RotatePeersKeys(ctx context.Context, opt store.WGPeerListOptions, maxAge time.Duration) (int, error)
+	// RunKeyRotation rotates the keys of peers older than maxAge every interval until ctx is done.
+	RunKeyRotation(ctx context.Context, interval, maxAge time.Duration)
+	// RevokePeer blocks the public key of a peer for good, removes it from its interface and deletes its client config.
+	RevokePeer(ctx context.Context, peer *model.WGPeer, reason, revokedBy string) ([]*wireguard.ApplyResult, error)
+	// ListRevokedKeys lists the revoked public keys.
+	ListRevokedKeys(ctx context.Context, opt store.RevokedKeyListOptions) ([]*model.RevokedKey, int64, error)
+	// ReencryptSecrets rewrites the stored peer secrets and client config files with the current master key.
+	// It returns the number of peers and client config files rewritten.
+	ReencryptSecrets(ctx context.Context) (int, int, error)
+	// ClientAllowedIPs returns the AllowedIPs of the client config of a peer: allowedIPs plus the subnets
+	// routed behind the other site-to-site peers of its interface.
+	ClientAllowedIPs(ctx context.Context, peer *model.WGPeer, allowedIPs string) string
+	// SyncExitRouting rebuilds and loads the exit node routing of all interfaces.
+	SyncExitRouting(ctx context.Context)
 }
 
 type wgPeerSrv struct {
-	store         store.Factory
-	configManager *wireguard.ServerConfigManager
+	store store.Factory
 }
 
 // WGPeerSrv if implemented, then wgPeerSrv implements WGPeerSrv interface.
 var _ WGPeerSrv = (*wgPeerSrv)(nil)
 
 func newWGPeers(s *service) *wgPeerSrv {
-	cfg := config.Get()
-	if cfg == nil || cfg.WireGuard == nil {
-		klog.V(1).InfoS("WireGuard config not available, config manager will be nil")
-		return &wgPeerSrv{
-			store:         s.store,
-			configManager: nil,
-		}
-	}
-
-	wgOpts := cfg.WireGuard
-	configPath := wgOpts.ServerConfigPath()
-	configManager := wireguard.NewServerConfigManager(configPath, wgOpts.ApplyMethod)
-
-	return &wgPeerSrv{
-		store:         s.store,
-		configManager: configManager,
-	}
+	return &wgPeerSrv{store: s.store}
 }
 
-func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID, clientIP, allowedIPs, dns, endpoint, clientPrivateKey string, persistentKeepalive *int) (*model.WGPeer, error) {
+func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID, clientIP, allowedIPs, dns, endpoint, clientPrivateKey, clientPublicKey, presharedKey, routedSubnets, peerEndpoint string, persistentKeepalive *int, expiresAt *time.Time) (*model.WGPeer, []*wireguard.ApplyResult, error) {
 	// Get default IP pool if not specified
 	var pool *model.IPPool
 	if ipPoolID == "" {
@@ -81,7 +97,7 @@ func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID
 			Limit:  1,
 		})
 		if err != nil || len(pools) == 0 {
-			return nil, errors.WithCode(code.ErrIPPoolNotFound, "no active IP pool found")
+			return nil, nil, errors.WithCode(code.ErrIPPoolNotFound, "no active IP pool found")
 		}
 		pool = pools[0]
 		ipPoolID = pool.ID
@@ -90,9 +106,35 @@ func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID
 		var err error
 		pool, err = w.store.IPPools().GetIPPool(ctx, ipPoolID)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+	}
+
+	// Public-key-only pools never receive a private key
+	if clientPublicKey != "" && clientPrivateKey != "" {
+		return nil, nil, errors.WithCode(code.ErrValidation, "client_public_key cannot be combined with client_private_key")
+	}
+	if pool.RequirePublicKeyOnly && clientPublicKey == "" {
+		return nil, nil, errors.WithCode(code.ErrWGPublicKeyOnlyRequired, "%s", code.Message(code.ErrWGPublicKeyOnlyRequired))
+	}
+
+	// Peers are bound to the interface of their IP pool
+	if pool.InterfaceID != "" {
+		iface, err := w.store.WGInterfaces().GetInterface(ctx, pool.InterfaceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if iface.Status != model.WGInterfaceStatusActive {
+			return nil, nil, errors.WithCode(code.ErrWGInterfaceDisabled, "interface %s is disabled", iface.Name)
 		}
 	}
+	configManager := w.configManagerFor(ctx, pool.InterfaceID)
+
+	// Site-to-site peers route subnets that must not collide with other addresses
+	routedSubnets, err := w.validateRoutedSubnets(ctx, "", routedSubnets)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Use IP pool configuration if peer fields are not specified
 	// Priority: Peer specified > IP Pool config > Global config
@@ -107,67 +149,71 @@ func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID
 		}
 	}
 
-	// Get server tunnel IP from server config Address
-	serverTunnelIP := ""
-	if w.configManager != nil {
-		serverConfig, err := w.configManager.ReadServerConfig()
-		if err == nil && serverConfig != nil && serverConfig.Interface != nil && serverConfig.Interface.Address != "" {
-			// Extract IP from Address (e.g., "100.100.100.1/24" -> "100.100.100.1")
-			extractedIP, err := ip.ExtractIPFromCIDR(serverConfig.Interface.Address)
-			if err == nil {
-				serverTunnelIP = extractedIP
-			}
-		}
-	}
+	// Get server tunnel IPs from server config Address
+	serverTunnelIP := getServerTunnelIP(configManager)
 
-	// Allocate IP address
+	// Allocate IP addresses (one per address family served by the pool)
+	// clientIP may specify up to one IPv4 and one IPv6 address, the rest is auto-allocated
 	allocator := ip.NewAllocator(w.store)
-	var allocatedIP string
-	if clientIP != "" {
-		// Validate and use provided IP
-		if err := allocator.ValidateAndAllocateIP(ctx, ipPoolID, clientIP, serverTunnelIP); err != nil {
-			return nil, err
-		}
-		allocatedIP = clientIP
-	} else {
-		// Auto-allocate IP
-		var err error
-		allocatedIP, err = allocator.AllocateIP(ctx, ipPoolID, "", serverTunnelIP)
-		if err != nil {
-			return nil, err
+	preferredIPs := ip.SplitIPList(clientIP)
+	for _, preferredIP := range preferredIPs {
+		if err := allocator.ValidateAndAllocateIP(ctx, ipPoolID, preferredIP, serverTunnelIP); err != nil {
+			return nil, nil, err
 		}
 	}
-
-	// Format IP as CIDR
-	clientIPCIDR, err := ip.FormatIPAsCIDR(allocatedIP)
+	allocatedIPs, err := allocator.AllocateIPs(ctx, ipPoolID, preferredIPs, serverTunnelIP)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	// Allocated IPs are host CIDRs, or the delegated prefixes for pools delegating prefixes
+	clientIPCIDR := strings.Join(allocatedIPs, ",")
+
 	// Generate key pair
 	var privateKey, publicKey string
-	if clientPrivateKey != "" {
+	if clientPublicKey != "" {
+		// The client keeps its private key, the server stores none
+		if err := wireguard.ValidatePublicKey(clientPublicKey); err != nil {
+			return nil, nil, err
+		}
+		publicKey = clientPublicKey
+	} else if clientPrivateKey != "" {
 		// Validate provided private key
 		if err := wireguard.ValidatePrivateKey(clientPrivateKey); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		privateKey = clientPrivateKey
 		publicKey, err = wireguard.GeneratePublicKey(privateKey)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	} else {
 		// Auto-generate key pair
 		privateKey, publicKey, err = wireguard.GenerateKeyPair()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+	}
+	if err := w.checkKeyNotRevoked(ctx, publicKey); err != nil {
+		return nil, nil, err
+	}
+
+	// Validate provided preshared key, or generate one if required by policy
+	if presharedKey != "" {
+		if err := wireguard.ValidatePresharedKey(presharedKey); err != nil {
+			return nil, nil, err
+		}
+	} else if IsPresharedKeyRequired(pool) {
+		presharedKey, err = wireguard.GeneratePresharedKey()
+		if err != nil {
+			return nil, nil, err
 		}
 	}
 
 	// Generate peer ID
 	peerID, err := snowflake.GenerateID()
 	if err != nil {
-		return nil, errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate peer ID")
+		return nil, nil, errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate peer ID")
 	}
 
 	// Get global config for calculating effective endpoint and DNS
@@ -188,7 +234,7 @@ func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID
 	// This ensures we store the complete values (including defaults) in the database
 	var effectiveEndpoint, effectiveDNS string
 	if wgOpts != nil {
-		effectiveEndpoint = CalculateEffectiveEndpoint(tempPeer, pool, wgOpts, w.configManager, ctx)
+		effectiveEndpoint = CalculateEffectiveEndpoint(tempPeer, pool, wgOpts, configManager, ctx)
 		effectiveDNS = CalculateEffectiveDNS(tempPeer, pool, wgOpts)
 	} else {
 		// Fallback to provided values if config is not available
@@ -203,42 +249,42 @@ func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID
 		DeviceName:          deviceName,
 		ClientPrivateKey:    privateKey,
 		ClientPublicKey:     publicKey,
+		PresharedKey:        presharedKey,
 		ClientIP:            clientIPCIDR,
 		AllowedIPs:          allowedIPs,
 		DNS:                 effectiveDNS,
 		Endpoint:            effectiveEndpoint,
+		RoutedSubnets:       routedSubnets,
+		PeerEndpoint:        peerEndpoint,
 		PersistentKeepalive: 25,
 		Status:              model.WGPeerStatusActive,
 		IPPoolID:            ipPoolID,
+		InterfaceID:         pool.InterfaceID,
 	}
 
 	if persistentKeepalive != nil {
 		peer.PersistentKeepalive = *persistentKeepalive
 	}
 
-	// Create IP allocation record
-	allocationID, err := snowflake.GenerateID()
-	if err != nil {
-		return nil, errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate allocation ID")
-	}
-
-	allocation := &model.IPAllocation{
-		ID:        allocationID,
-		IPPoolID:  ipPoolID,
-		PeerID:    peerID,
-		IPAddress: allocatedIP,
-		Status:    model.IPAllocationStatusAllocated,
+	// Peers without an explicit expiration get the default lifetime of their IP pool
+	if expiresAt != nil {
+		peer.ExpiresAt = expiresAt
+	} else if pool.PeerLifetimeHours > 0 {
+		defaultExpiresAt := time.Now().Add(time.Duration(pool.PeerLifetimeHours) * time.Hour)
+		peer.ExpiresAt = &defaultExpiresAt
 	}
 
 	// Save to database
 	if err := w.store.WGPeers().CreatePeer(ctx, peer); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if err := w.store.IPAllocations().CreateIPAllocation(ctx, allocation); err != nil {
-		// Rollback: delete peer if allocation fails
+	// Create IP allocation records
+	if err := w.createIPAllocations(ctx, ipPoolID, peerID, allocatedIPs); err != nil {
+		// Rollback: delete peer and its allocations if allocation fails
+		_ = w.store.IPAllocations().DeleteIPAllocationByPeerID(ctx, peerID)
 		_ = w.store.WGPeers().DeletePeer(ctx, peerID)
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Generate and save client config file
@@ -248,20 +294,33 @@ func (w *wgPeerSrv) CreatePeer(ctx context.Context, userID, deviceName, ipPoolID
 	}
 
 	// Update server config file
-	if w.configManager != nil {
-		if err := w.updateServerConfigForPeer(peer, true); err != nil {
+	var applied []*wireguard.ApplyResult
+	if configManager != nil {
+		if err := updateServerConfigForPeer(ctx, configManager, peer, true); err != nil {
 			klog.V(1).InfoS("failed to update server config", "peerID", peerID, "error", err)
 			// Continue anyway, server config update failure is logged but doesn't block
 		} else {
 			// Apply server config
-			if err := w.configManager.ApplyConfig(); err != nil {
+			if result, err := configManager.ApplyConfig(); err != nil {
 				klog.V(1).InfoS("failed to apply server config", "peerID", peerID, "error", err)
 				// Continue anyway
+			} else {
+				applied = append(applied, result)
 			}
 		}
 	}
 
-	return peer, nil
+	// The other peers of the interface route to the subnets behind a new site-to-site peer
+	if peer.RoutedSubnets != "" {
+		w.regenerateClientConfigs(ctx, peer.InterfaceID, peer.ID)
+		syncFirewall(ctx, w.store, peer.InterfaceID)
+	}
+	// A new peer of a pool with an exit node egresses through it
+	if pool.ExitPeerID != "" {
+		syncExitRouting(ctx, w.store, peer.InterfaceID)
+	}
+
+	return peer, applied, nil
 }
 
 func (w *wgPeerSrv) GetPeer(ctx context.Context, id string) (*model.WGPeer, error) {
@@ -272,20 +331,62 @@ func (w *wgPeerSrv) GetPeerByPublicKey(ctx context.Context, publicKey string) (*
 	return w.store.WGPeers().GetPeerByPublicKey(ctx, publicKey)
 }
 
-func (w *wgPeerSrv) UpdatePeer(ctx context.Context, peer *model.WGPeer, newClientIP, newIPPoolID *string) error {
+func (w *wgPeerSrv) UpdatePeer(ctx context.Context, peer *model.WGPeer, newClientIP, newIPPoolID *string) ([]*wireguard.ApplyResult, error) {
 	// Get existing peer to check what changed
 	existingPeer, err := w.store.WGPeers().GetPeer(ctx, peer.ID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Revoked peers stay disabled for good
+	if existingPeer.DisabledReason == model.WGPeerDisabledReasonRevoked &&
+		(peer.Status == model.WGPeerStatusActive || peer.DisabledReason != model.WGPeerDisabledReasonRevoked) {
+		return nil, errors.WithCode(code.ErrWGPeerRevoked, "peer %s has been revoked", peer.ID)
+	}
+
+	// Peers follow the interface of their IP pool, so changing the pool may move the peer
+	if newIPPoolID != nil && *newIPPoolID != "" && *newIPPoolID != existingPeer.IPPoolID {
+		newPool, err := w.store.IPPools().GetIPPool(ctx, *newIPPoolID)
+		if err != nil {
+			return nil, err
+		}
+		peer.InterfaceID = newPool.InterfaceID
 	}
+	configManager := w.configManagerFor(ctx, peer.InterfaceID)
 
 	// Handle IP address change
+	// newClientIP may hold up to one IPv4 and one IPv6 address, comma-separated
 	if newClientIP != nil && *newClientIP != "" {
-		// Extract IP from existing CIDR format
-		existingIP, _ := ip.ExtractIPFromCIDR(existingPeer.ClientIP)
-		
+		newIPs := ip.SplitIPList(*newClientIP)
+		if err := ip.ValidateIPList(newIPs); err != nil {
+			return nil, err
+		}
+
+		// Extract IPs from existing CIDR format
+		existingIPs, _ := ip.ExtractIPsFromCIDRs(existingPeer.ClientIP)
+		existingSet := make(map[string]bool, len(existingIPs))
+		for _, existingIP := range existingIPs {
+			existingSet[existingIP] = true
+		}
+		newSet := make(map[string]bool, len(newIPs))
+		for _, newIP := range newIPs {
+			newSet[newIP] = true
+		}
+
+		var addedIPs, removedIPs []string
+		for _, newIP := range newIPs {
+			if !existingSet[newIP] {
+				addedIPs = append(addedIPs, newIP)
+			}
+		}
+		for _, existingIP := range existingIPs {
+			if !newSet[existingIP] {
+				removedIPs = append(removedIPs, existingIP)
+			}
+		}
+
 		// Check if IP actually changed
-		if existingIP != *newClientIP {
+		if len(addedIPs) > 0 || len(removedIPs) > 0 {
 			// Determine which IP pool to use
 			ipPoolID := peer.IPPoolID
 			if newIPPoolID != nil && *newIPPoolID != "" {
@@ -293,65 +394,54 @@ func (w *wgPeerSrv) UpdatePeer(ctx context.Context, peer *model.WGPeer, newClien
 			} else if ipPoolID == "" {
 				// Get default IP pool if not specified
 				pools, _, err := w.store.IPPools().ListIPPools(ctx, store.IPPoolListOptions{
-					Status: model.IPPoolStatusActive,
-					Limit:  1,
+					Status:      model.IPPoolStatusActive,
+					InterfaceID: peer.InterfaceID,
+					Limit:       1,
 				})
 				if err != nil || len(pools) == 0 {
-					return errors.WithCode(code.ErrIPPoolNotFound, "no active IP pool found")
+					return nil, errors.WithCode(code.ErrIPPoolNotFound, "no active IP pool found")
 				}
 				ipPoolID = pools[0].ID
 				peer.IPPoolID = ipPoolID
 			}
 
-			// Get server tunnel IP from server config Address
-			serverTunnelIP := ""
-			if w.configManager != nil {
-				serverConfig, err := w.configManager.ReadServerConfig()
-				if err == nil && serverConfig != nil && serverConfig.Interface != nil && serverConfig.Interface.Address != "" {
-					// Extract IP from Address (e.g., "100.100.100.1/24" -> "100.100.100.1")
-					extractedIP, err := ip.ExtractIPFromCIDR(serverConfig.Interface.Address)
-					if err == nil {
-						serverTunnelIP = extractedIP
-					}
-				}
-			}
+			// Get server tunnel IPs from server config Address
+			serverTunnelIP := getServerTunnelIP(configManager)
 
-			// Validate and allocate new IP
+			// Validate new IPs
 			allocator := ip.NewAllocator(w.store)
-			if err := allocator.ValidateAndAllocateIP(ctx, ipPoolID, *newClientIP, serverTunnelIP); err != nil {
-				return err
+			for _, addedIP := range addedIPs {
+				if err := allocator.ValidateAndAllocateIP(ctx, ipPoolID, addedIP, serverTunnelIP); err != nil {
+					return nil, err
+				}
 			}
 
-			// Release old IP allocation
-			if err := allocator.ReleaseIP(ctx, peer.ID); err != nil {
-				klog.V(1).InfoS("failed to release old IP allocation", "peerID", peer.ID, "error", err)
-				// Continue anyway
+			// Release removed IP allocations
+			if len(removedIPs) > 0 {
+				if err := allocator.ReleaseIPAddresses(ctx, peer.ID, removedIPs); err != nil {
+					klog.V(1).InfoS("failed to release old IP allocation", "peerID", peer.ID, "error", err)
+					// Continue anyway
+				}
 			}
 
-			// Create new IP allocation record
-			allocationID, err := snowflake.GenerateID()
+			// Create new IP allocation records
+			addedCIDRs, err := allocator.AllocationCIDRs(ctx, ipPoolID, addedIPs)
 			if err != nil {
-				return errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate allocation ID")
-			}
-
-			allocation := &model.IPAllocation{
-				ID:        allocationID,
-				IPPoolID:  ipPoolID,
-				PeerID:    peer.ID,
-				IPAddress: *newClientIP,
-				Status:    model.IPAllocationStatusAllocated,
+				return nil, err
 			}
-
-			if err := w.store.IPAllocations().CreateIPAllocation(ctx, allocation); err != nil {
-				return err
+			if err := w.createIPAllocations(ctx, ipPoolID, peer.ID, addedCIDRs); err != nil {
+				return nil, err
 			}
 
-			// Format IP as CIDR
-			clientIPCIDR, err := ip.FormatIPAsCIDR(*newClientIP)
+			// Format IPs as CIDR (the delegated prefixes for pools delegating prefixes)
+			newCIDRs, err := allocator.AllocationCIDRs(ctx, ipPoolID, newIPs)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			peer.ClientIP = clientIPCIDR
+			peer.ClientIP = strings.Join(newCIDRs, ",")
+		} else {
+			// Keep the stored CIDR format
+			peer.ClientIP = existingPeer.ClientIP
 		}
 	}
 
@@ -365,16 +455,60 @@ func (w *wgPeerSrv) UpdatePeer(ctx context.Context, peer *model.WGPeer, newClien
 
 	// Handle private key change - regenerate public key if needed
 	if peer.ClientPrivateKey != existingPeer.ClientPrivateKey && peer.ClientPrivateKey != "" {
+		if peer.IPPoolID != "" {
+			if pool, err := w.store.IPPools().GetIPPool(ctx, peer.IPPoolID); err == nil && pool.RequirePublicKeyOnly {
+				return nil, errors.WithCode(code.ErrWGPublicKeyOnlyRequired, "%s", code.Message(code.ErrWGPublicKeyOnlyRequired))
+			}
+		}
 		// Validate private key
 		if err := wireguard.ValidatePrivateKey(peer.ClientPrivateKey); err != nil {
-			return errors.WithCode(code.ErrWGPrivateKeyInvalid, "invalid private key: %s", err.Error())
+			return nil, errors.WithCode(code.ErrWGPrivateKeyInvalid, "invalid private key: %s", err.Error())
 		}
 		// Public key should already be updated in controller, but regenerate to be safe
 		publicKey, err := wireguard.GeneratePublicKey(peer.ClientPrivateKey)
 		if err != nil {
-			return errors.WithCode(code.ErrWGPublicKeyGenerationFailed, "failed to generate public key: %s", err.Error())
+			return nil, errors.WithCode(code.ErrWGPublicKeyGenerationFailed, "failed to generate public key: %s", err.Error())
 		}
 		peer.ClientPublicKey = publicKey
+		if err := w.checkKeyNotRevoked(ctx, publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	// Handle preshared key change
+	if peer.PresharedKey != existingPeer.PresharedKey {
+		if peer.PresharedKey != "" {
+			if err := wireguard.ValidatePresharedKey(peer.PresharedKey); err != nil {
+				return nil, err
+			}
+		} else {
+			var pool *model.IPPool
+			if peer.IPPoolID != "" {
+				pool, _ = w.store.IPPools().GetIPPool(ctx, peer.IPPoolID)
+			}
+			if IsPresharedKeyRequired(pool) {
+				return nil, errors.WithCode(code.ErrWGPresharedKeyRequired, "preshared key is required for peer %s", peer.ID)
+			}
+		}
+	}
+
+	// Handle routed subnets change
+	routedSubnetsChanged := peer.RoutedSubnets != existingPeer.RoutedSubnets
+	if routedSubnetsChanged {
+		routedSubnets, err := w.validateRoutedSubnets(ctx, peer.ID, peer.RoutedSubnets)
+		if err != nil {
+			return nil, err
+		}
+		peer.RoutedSubnets = routedSubnets
+	}
+
+	// Handle exit node change
+	exitNodeChanged := peer.ExitNode != existingPeer.ExitNode
+	exitChanged := exitNodeChanged || peer.ExitPeerID != existingPeer.ExitPeerID
+	if exitChanged || peer.InterfaceID != existingPeer.InterfaceID {
+		if err := validateExitSettings(ctx, w.store, peer, existingPeer); err != nil {
+			return nil, err
+		}
 	}
 
 	// Recalculate effective endpoint and DNS if needed:
@@ -405,7 +539,7 @@ func (w *wgPeerSrv) UpdatePeer(ctx context.Context, peer *model.WGPeer, newClien
 		if wgOpts != nil {
 			// Only recalculate if the field is empty or IP Pool changed
 			if peer.Endpoint == "" || ipPoolChanged {
-				peer.Endpoint = CalculateEffectiveEndpoint(peer, pool, wgOpts, w.configManager, ctx)
+				peer.Endpoint = CalculateEffectiveEndpoint(peer, pool, wgOpts, configManager, ctx)
 			}
 			if peer.DNS == "" || ipPoolChanged {
 				peer.DNS = CalculateEffectiveDNS(peer, pool, wgOpts)
@@ -415,38 +549,69 @@ func (w *wgPeerSrv) UpdatePeer(ctx context.Context, peer *model.WGPeer, newClien
 
 	// Update database
 	if err := w.store.WGPeers().UpdatePeer(ctx, peer); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Update server config if status or AllowedIPs changed
-	if w.configManager != nil {
+	// Move peer to the server config of its new interface
+	var applied []*wireguard.ApplyResult
+	if peer.InterfaceID != existingPeer.InterfaceID {
+		if oldConfigManager := w.configManagerFor(ctx, existingPeer.InterfaceID); oldConfigManager != nil {
+			if err := oldConfigManager.RemovePeer(ctx, existingPeer.ClientPublicKey); err != nil {
+				klog.V(1).InfoS("failed to remove peer from old interface server config", "peerID", peer.ID, "error", err)
+				// Continue anyway
+			} else if result, err := oldConfigManager.ApplyConfig(); err != nil {
+				klog.V(1).InfoS("failed to apply server config", "peerID", peer.ID, "error", err)
+				// Continue anyway
+			} else {
+				applied = append(applied, result)
+			}
+		}
+		if configManager != nil && peer.Status == model.WGPeerStatusActive {
+			if err := updateServerConfigForPeer(ctx, configManager, peer, true); err != nil {
+				klog.V(1).InfoS("failed to add peer to new interface server config", "peerID", peer.ID, "error", err)
+				// Continue anyway
+			} else if result, err := configManager.ApplyConfig(); err != nil {
+				klog.V(1).InfoS("failed to apply server config", "peerID", peer.ID, "error", err)
+				// Continue anyway
+			} else {
+				applied = append(applied, result)
+			}
+		}
+	} else if configManager != nil {
+		// Update server config if status or AllowedIPs changed
 		statusChanged := existingPeer.Status != peer.Status
 		allowedIPsChanged := existingPeer.AllowedIPs != peer.AllowedIPs
 		persistentKeepaliveChanged := existingPeer.PersistentKeepalive != peer.PersistentKeepalive
+		presharedKeyChanged := existingPeer.PresharedKey != peer.PresharedKey
+		peerEndpointChanged := existingPeer.PeerEndpoint != peer.PeerEndpoint
 
-		if statusChanged || allowedIPsChanged || persistentKeepaliveChanged {
-			// Only update if peer is active
+		if statusChanged || allowedIPsChanged || persistentKeepaliveChanged || presharedKeyChanged || routedSubnetsChanged || peerEndpointChanged || exitNodeChanged {
+			// Only update if peer is active; a re-enabled peer was removed from the server config and is added back
 			if peer.Status == model.WGPeerStatusActive {
-				if err := w.updateServerConfigForPeer(peer, false); err != nil {
+				if err := updateServerConfigForPeer(ctx, configManager, peer, statusChanged); err != nil {
 					klog.V(1).InfoS("failed to update server config", "peerID", peer.ID, "error", err)
 					// Continue anyway
 				} else {
 					// Apply server config
-					if err := w.configManager.ApplyConfig(); err != nil {
+					if result, err := configManager.ApplyConfig(); err != nil {
 						klog.V(1).InfoS("failed to apply server config", "peerID", peer.ID, "error", err)
 						// Continue anyway
+					} else {
+						applied = append(applied, result)
 					}
 				}
 			} else {
 				// Peer is disabled, remove from server config
-				if err := w.configManager.RemovePeer(peer.ClientPublicKey); err != nil {
+				if err := configManager.RemovePeer(ctx, peer.ClientPublicKey); err != nil {
 					klog.V(1).InfoS("failed to remove peer from server config", "peerID", peer.ID, "error", err)
 					// Continue anyway
 				} else {
 					// Apply server config
-					if err := w.configManager.ApplyConfig(); err != nil {
+					if result, err := configManager.ApplyConfig(); err != nil {
 						klog.V(1).InfoS("failed to apply server config", "peerID", peer.ID, "error", err)
 						// Continue anyway
+					} else {
+						applied = append(applied, result)
 					}
 				}
 			}
@@ -459,25 +624,55 @@ func (w *wgPeerSrv) UpdatePeer(ctx context.Context, peer *model.WGPeer, newClien
 		// Continue anyway
 	}
 
-	return nil
+	// The other peers of the interface route to the subnets behind site-to-site peers
+	if routedSubnetsChanged || (peer.RoutedSubnets != "" && (existingPeer.Status != peer.Status || existingPeer.InterfaceID != peer.InterfaceID)) {
+		w.regenerateClientConfigs(ctx, peer.InterfaceID, peer.ID)
+		if existingPeer.InterfaceID != peer.InterfaceID {
+			w.regenerateClientConfigs(ctx, existingPeer.InterfaceID, peer.ID)
+		}
+	}
+	// The subnets behind a site-to-site peer share the isolation policy of its IP pool
+	if routedSubnetsChanged || (peer.RoutedSubnets != "" && (existingPeer.IPPoolID != peer.IPPoolID || existingPeer.InterfaceID != peer.InterfaceID)) {
+		syncFirewall(ctx, w.store, peer.InterfaceID)
+		if existingPeer.InterfaceID != peer.InterfaceID {
+			syncFirewall(ctx, w.store, existingPeer.InterfaceID)
+		}
+	}
+	// Port forwards follow the peer address and are only loaded while the peer is active
+	if existingPeer.Status != peer.Status || existingPeer.ClientIP != peer.ClientIP || existingPeer.InterfaceID != peer.InterfaceID {
+		syncPeerPortForwards(ctx, w.store, peer.ID, peer.InterfaceID, existingPeer.InterfaceID)
+	}
+	// The exit node routes the addresses of the active peers egressing through it
+	if exitChanged || existingPeer.Status != peer.Status || existingPeer.ClientIP != peer.ClientIP ||
+		routedSubnetsChanged || existingPeer.IPPoolID != peer.IPPoolID || existingPeer.InterfaceID != peer.InterfaceID {
+		syncExitRouting(ctx, w.store, peer.InterfaceID)
+		if existingPeer.InterfaceID != peer.InterfaceID {
+			syncExitRouting(ctx, w.store, existingPeer.InterfaceID)
+		}
+	}
+
+	return applied, nil
 }
 
-func (w *wgPeerSrv) DeletePeer(ctx context.Context, id string, isHardDelete bool) error {
+func (w *wgPeerSrv) DeletePeer(ctx context.Context, id string, isHardDelete bool) ([]*wireguard.ApplyResult, error) {
 	// Get peer before deletion to remove from server config
+	var applied []*wireguard.ApplyResult
 	peer, err := w.store.WGPeers().GetPeer(ctx, id)
 	if err != nil {
 		// If peer not found, still try to release/delete IP and continue
 		klog.V(1).InfoS("peer not found, continuing with deletion", "peerID", id, "error", err)
-	} else if w.configManager != nil {
+	} else if configManager := w.configManagerFor(ctx, peer.InterfaceID); configManager != nil {
 		// Remove peer from server config
-		if err := w.configManager.RemovePeer(peer.ClientPublicKey); err != nil {
+		if err := configManager.RemovePeer(ctx, peer.ClientPublicKey); err != nil {
 			klog.V(1).InfoS("failed to remove peer from server config", "peerID", id, "error", err)
 			// Continue anyway
 		} else {
 			// Apply server config
-			if err := w.configManager.ApplyConfig(); err != nil {
+			if result, err := configManager.ApplyConfig(); err != nil {
 				klog.V(1).InfoS("failed to apply server config", "peerID", id, "error", err)
 				// Continue anyway
+			} else {
+				applied = append(applied, result)
 			}
 		}
 	}
@@ -513,7 +708,31 @@ func (w *wgPeerSrv) DeletePeer(ctx context.Context, id string, isHardDelete bool
 		}
 	}
 
-	return w.store.WGPeers().DeletePeer(ctx, id)
+	if err := w.store.WGPeers().DeletePeer(ctx, id); err != nil {
+		return nil, err
+	}
+
+	// Port forwards to the peer are removed with it
+	interfaceID := ""
+	if peer != nil {
+		interfaceID = peer.InterfaceID
+	}
+	deletePeerPortForwards(ctx, w.store, id, interfaceID)
+
+	// Peers and pools egressing through a deleted exit node egress from the server again
+	if peer != nil {
+		if peer.ExitNode {
+			clearExitAssignments(ctx, w.store, peer)
+		}
+		syncExitRouting(ctx, w.store, peer.InterfaceID)
+	}
+
+	// The other peers of the interface no longer route to the subnets behind a deleted site-to-site peer
+	if peer != nil && peer.RoutedSubnets != "" {
+		w.regenerateClientConfigs(ctx, peer.InterfaceID, id)
+		syncFirewall(ctx, w.store, peer.InterfaceID)
+	}
+	return applied, nil
 }
 
 // ReleaseIP releases the IP allocation for a peer.
@@ -534,7 +753,7 @@ func (w *wgPeerSrv) CountPeersByUserID(ctx context.Context, userID string) (int6
 // when the pool's Endpoint or DNS changes.
 func (w *wgPeerSrv) UpdatePeersForIPPoolChange(ctx context.Context, poolID string, newPool *model.IPPool) error {
 	// Find all peers using this pool
-	peers, _, err := w.store.WGPeers().ListPeers(ctx, store.WGPeerListOptions{
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{
 		IPPoolID: poolID,
 	})
 	if err != nil {
@@ -547,6 +766,7 @@ func (w *wgPeerSrv) UpdatePeersForIPPoolChange(ctx context.Context, poolID strin
 	if cfg != nil && cfg.WireGuard != nil {
 		wgOpts = cfg.WireGuard
 	}
+	configManager := w.configManagerFor(ctx, newPool.InterfaceID)
 
 	// Update each peer if needed
 	for _, peer := range peers {
@@ -555,7 +775,7 @@ func (w *wgPeerSrv) UpdatePeersForIPPoolChange(ctx context.Context, poolID strin
 		// If peer's Endpoint is empty, it uses pool's default, so recalculate
 		if peer.Endpoint == "" {
 			if wgOpts != nil {
-				peer.Endpoint = CalculateEffectiveEndpoint(peer, newPool, wgOpts, w.configManager, ctx)
+				peer.Endpoint = CalculateEffectiveEndpoint(peer, newPool, wgOpts, configManager, ctx)
 				needsUpdate = true
 			}
 		}
@@ -589,18 +809,29 @@ func (w *wgPeerSrv) UpdatePeersForIPPoolChange(ctx context.Context, poolID strin
 
 // generateAndSaveClientConfig generates and saves the client configuration file.
 func (w *wgPeerSrv) generateAndSaveClientConfig(ctx context.Context, peer *model.WGPeer) error {
+	// The client config of a revoked peer was deleted and is never written again
+	if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+		return nil
+	}
+	// Keep the saved client config of a peer whose private key could not be decrypted rather than blank its key
+	if peer.SecretsUnreadable && peer.ClientPrivateKey == "" {
+		klog.V(1).InfoS("skipped saving client config, the peer secrets cannot be decrypted", "peerID", peer.ID)
+		return nil
+	}
+
 	cfg := config.Get()
 	if cfg == nil || cfg.WireGuard == nil {
 		return errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
 	}
 
 	wgOpts := cfg.WireGuard
+	configManager := w.configManagerFor(ctx, peer.InterfaceID)
 
 	// Get server public key
 	var serverPublicKey string
-	if w.configManager != nil {
+	if configManager != nil {
 		var err error
-		serverPublicKey, err = w.configManager.GetServerPublicKey()
+		serverPublicKey, err = configManager.GetServerPublicKey()
 		if err != nil {
 			return errors.Wrap(err, "failed to get server public key")
 		}
@@ -608,8 +839,8 @@ func (w *wgPeerSrv) generateAndSaveClientConfig(ctx context.Context, peer *model
 
 	// Get server MTU from server config
 	var mtu int
-	if w.configManager != nil {
-		serverConfig, err := w.configManager.ReadServerConfig()
+	if configManager != nil {
+		serverConfig, err := configManager.ReadServerConfig()
 		if err == nil && serverConfig != nil && serverConfig.Interface != nil {
 			if serverConfig.Interface.MTU > 0 {
 				mtu = serverConfig.Interface.MTU
@@ -640,6 +871,9 @@ func (w *wgPeerSrv) generateAndSaveClientConfig(ctx context.Context, peer *model
 		}
 	}
 
+	// Route to the subnets behind the site-to-site peers of the interface
+	allowedIPs = clientAllowedIPs(ctx, w.store, peer, allowedIPs)
+
 	// Generate client config
 	clientConfig := &wireguard.ClientConfig{
 		PrivateKey:          peer.ClientPrivateKey,
@@ -648,31 +882,102 @@ func (w *wgPeerSrv) generateAndSaveClientConfig(ctx context.Context, peer *model
 		MTU:                 mtu,
 		Endpoint:            endpoint,
 		PublicKey:           serverPublicKey,
+		PresharedKey:        peer.PresharedKey,
 		AllowedIPs:          allowedIPs,
 		PersistentKeepalive: peer.PersistentKeepalive,
 	}
 
 	configContent := wireguard.GenerateClientConfig(clientConfig)
 
-	// Save to file
+	configPath, err := saveClientConfig(wgOpts, peer.ID, configContent)
+	if err != nil {
+		return err
+	}
+
+	klog.V(2).InfoS("client config file saved", "peerID", peer.ID, "path", configPath)
+	return nil
+}
+
+// saveClientConfig saves the client config file of a peer under the user directory and returns its path.
+// With wireguard.client-config-on-demand the config is generated on download instead: nothing is
+// saved and a stale file is removed.
+func saveClientConfig(wgOpts *options.WireGuardOptions, peerID, configContent string) (string, error) {
 	userDir := wgOpts.ResolvedUserDir()
-	if err := os.MkdirAll(userDir, 0755); err != nil {
-		return errors.WithCode(code.ErrWGUserDirCreateFailed, "failed to create user directory: %s", err.Error())
+	if wgOpts.ClientConfigOnDemand {
+		configPath := wireguard.ClientConfigPath(userDir, peerID)
+		if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+			klog.V(1).InfoS("failed to delete client config file", "peerID", peerID, "path", configPath, "error", err)
+		}
+		return "", nil
 	}
+	return wireguard.SaveClientConfig(userDir, peerID, configContent)
+}
 
-	// Use peer ID as filename
-	configPath := filepath.Join(userDir, peer.ID+".conf")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		return errors.WithCode(code.ErrWGConfigWriteFailed, "failed to write client config file: %s", err.Error())
+// configManagerFor returns the server config manager of the given interface (the default interface if empty).
+// Returns nil if it is not available, in which case server config updates are skipped.
+func (w *wgPeerSrv) configManagerFor(ctx context.Context, interfaceID string) *wireguard.ServerConfigManager {
+	configManager, err := interfaceConfigManager(ctx, w.store, interfaceID)
+	if err != nil {
+		klog.V(1).InfoS("server config manager not available", "interfaceID", interfaceID, "error", err)
+		return nil
 	}
+	return configManager
+}
 
-	klog.V(2).InfoS("client config file saved", "peerID", peer.ID, "path", configPath)
+// getServerTunnelIP returns the server tunnel IPs from the server config Address,
+// comma-separated (e.g., "100.100.100.1/24,fd00::1/64" -> "100.100.100.1,fd00::1").
+func getServerTunnelIP(configManager *wireguard.ServerConfigManager) string {
+	if configManager == nil {
+		return ""
+	}
+	serverConfig, err := configManager.ReadServerConfig()
+	if err != nil || serverConfig == nil || serverConfig.Interface == nil || serverConfig.Interface.Address == "" {
+		return ""
+	}
+	serverIPs, err := ip.ExtractIPsFromCIDRs(serverConfig.Interface.Address)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(serverIPs, ",")
+}
+
+// createIPAllocations creates one IP allocation record per allocated CIDR for a peer:
+// host CIDRs (e.g. "100.100.100.2/32") or delegated prefixes (e.g. "100.100.100.8/29").
+func (w *wgPeerSrv) createIPAllocations(ctx context.Context, ipPoolID, peerID string, cidrs []string) error {
+	allocator := ip.NewAllocator(w.store)
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return errors.WithCode(code.ErrValidation, "invalid CIDR format: %s", cidr)
+		}
+		prefixLength := 0
+		if prefix.Bits() < prefix.Addr().BitLen() {
+			prefixLength = prefix.Bits()
+		}
+
+		allocationID, err := snowflake.GenerateID()
+		if err != nil {
+			return errors.WithCode(code.ErrWGPeerIDGenerationFailed, "failed to generate allocation ID")
+		}
+
+		allocation := &model.IPAllocation{
+			ID:           allocationID,
+			IPPoolID:     ipPoolID,
+			PeerID:       peerID,
+			IPAddress:    prefix.Addr().String(),
+			PrefixLength: prefixLength,
+			Status:       model.IPAllocationStatusAllocated,
+		}
+		if err := allocator.RecordAllocation(ctx, allocation); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // updateServerConfigForPeer updates the server configuration for a peer.
-func (w *wgPeerSrv) updateServerConfigForPeer(peer *model.WGPeer, isNew bool) error {
-	if w.configManager == nil {
+func updateServerConfigForPeer(ctx context.Context, configManager *wireguard.ServerConfigManager, peer *model.WGPeer, isNew bool) error {
+	if configManager == nil {
 		return errors.WithCode(code.ErrWGConfigNotInitialized, "config manager not initialized")
 	}
 
@@ -681,17 +986,22 @@ func (w *wgPeerSrv) updateServerConfigForPeer(peer *model.WGPeer, isNew bool) er
 		return nil
 	}
 
-	serverPeer := &wireguard.ServerPeerConfig{
-		PublicKey:           peer.ClientPublicKey,
-		AllowedIPs:          peer.ClientIP, // Use ClientIP as AllowedIPs in server config
-		PersistentKeepalive: peer.PersistentKeepalive,
-		Comment:             peer.DeviceName,
-	}
+	serverPeer := serverPeerConfig(peer)
 
 	if isNew {
-		return w.configManager.AddPeer(serverPeer)
+		return configManager.AddPeer(ctx, serverPeer)
 	}
-	return w.configManager.UpdatePeer(peer.ClientPublicKey, serverPeer)
+	return configManager.UpdatePeer(ctx, peer.ClientPublicKey, serverPeer)
+}
+
+// IsPresharedKeyRequired reports whether peers in the given pool must use a preshared key.
+// The global wireguard.require-preshared-key option applies to all pools.
+func IsPresharedKeyRequired(pool *model.IPPool) bool {
+	if pool != nil && pool.RequirePresharedKey {
+		return true
+	}
+	cfg := config.Get()
+	return cfg != nil && cfg.WireGuard != nil && cfg.WireGuard.RequirePresharedKey
 }
 
 // CalculateEffectiveEndpoint calculates the effective endpoint for a peer.
@@ -775,9 +1085,7 @@ func CalculateIPPoolEndpoint(poolEndpoint string, wgOpts *options.WireGuardOptio
 // when global config (ServerIP, ListenPort, or Endpoint) changes.
 func (w *wgPeerSrv) UpdatePeersEndpointForGlobalConfigChange(ctx context.Context) error {
 	// Get all peers
-	peers, _, err := w.store.WGPeers().ListPeers(ctx, store.WGPeerListOptions{
-		Limit: 10000, // Get all peers
-	})
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{})
 	if err != nil {
 		return err
 	}
@@ -798,18 +1106,27 @@ func (w *wgPeerSrv) UpdatePeersEndpointForGlobalConfigChange(ctx context.Context
 		}
 	}
 
-	// Get current ListenPort from server config
-	var currentListenPort int
-	if w.configManager != nil {
-		serverConfig, err := w.configManager.ReadServerConfig()
-		if err == nil && serverConfig != nil && serverConfig.Interface != nil {
-			currentListenPort = serverConfig.Interface.ListenPort
+	// Get current ListenPort from the server config of each interface
+	listenPorts := make(map[string]int)
+	listenPortFor := func(interfaceID string) int {
+		if listenPort, ok := listenPorts[interfaceID]; ok {
+			return listenPort
 		}
+		var listenPort int
+		if configManager := w.configManagerFor(ctx, interfaceID); configManager != nil {
+			serverConfig, err := configManager.ReadServerConfig()
+			if err == nil && serverConfig != nil && serverConfig.Interface != nil {
+				listenPort = serverConfig.Interface.ListenPort
+			}
+		}
+		listenPorts[interfaceID] = listenPort
+		return listenPort
 	}
 
 	// Update each peer if needed
 	for _, peer := range peers {
 		needsUpdate := false
+		currentListenPort := listenPortFor(peer.InterfaceID)
 
 		// Check if peer uses default endpoint
 		if peer.Endpoint == "" {
@@ -863,7 +1180,7 @@ func (w *wgPeerSrv) UpdatePeersEndpointForGlobalConfigChange(ctx context.Context
 			var newEndpoint string
 			if peer.Endpoint == "" {
 				// Use CalculateEffectiveEndpoint for empty endpoint
-				newEndpoint = CalculateEffectiveEndpoint(peer, pool, wgOpts, w.configManager, ctx)
+				newEndpoint = CalculateEffectiveEndpoint(peer, pool, wgOpts, w.configManagerFor(ctx, peer.InterfaceID), ctx)
 			} else {
 				// For non-empty endpoint, recalculate based on current ServerIP and ListenPort
 				endpointIP, _ := ip.ExtractIPFromEndpoint(peer.Endpoint)
@@ -908,9 +1225,7 @@ func (w *wgPeerSrv) UpdatePeersEndpointForGlobalConfigChange(ctx context.Context
 // when global config DNS changes.
 func (w *wgPeerSrv) UpdatePeersDNSForGlobalConfigChange(ctx context.Context) error {
 	// Get all peers
-	peers, _, err := w.store.WGPeers().ListPeers(ctx, store.WGPeerListOptions{
-		Limit: 10000, // Get all peers
-	})
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{})
 	if err != nil {
 		return err
 	}
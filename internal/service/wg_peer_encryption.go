@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// ReencryptSecrets reads every peer and saved client config file, which decrypts them with any of the
+// master keys known to the master cipher, and writes them back, which encrypts them with the current one.
+// Plaintext data left from before encryption was enabled gets encrypted the same way.
+func (w *wgPeerSrv) ReencryptSecrets(ctx context.Context) (int, int, error) {
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, peer := range peers {
+		if peer.SecretsUnreadable {
+			return 0, 0, errors.WithCode(code.ErrDecodingFailed, "failed to decrypt the secrets of peer %s, pass the master key they were encrypted with as --old-master-key-file", peer.ID)
+		}
+	}
+	for _, peer := range peers {
+		if err := w.store.WGPeers().UpdatePeer(ctx, peer); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return len(peers), 0, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+	wgOpts := cfg.WireGuard
+	userDir := wgOpts.ResolvedUserDir()
+
+	configPaths, err := filepath.Glob(filepath.Join(userDir, "*.conf"))
+	if err != nil {
+		return len(peers), 0, errors.WithCode(code.ErrUnknown, "failed to list client config files: %s", err.Error())
+	}
+	files := 0
+	for _, configPath := range configPaths {
+		peerID := strings.TrimSuffix(filepath.Base(configPath), ".conf")
+		configContent, err := wireguard.LoadClientConfig(userDir, peerID)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return len(peers), files, err
+		}
+		// Client configs generated on demand are not kept on disk at all
+		if _, err := saveClientConfig(wgOpts, peerID, configContent); err != nil {
+			return len(peers), files, err
+		}
+		files++
+	}
+
+	klog.V(1).InfoS("re-encrypted peer secrets", "peers", len(peers), "clientConfigs", files)
+	return len(peers), files, nil
+}
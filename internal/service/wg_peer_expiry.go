@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"k8s.io/klog/v2"
+)
+
+// ReapExpiredPeers disables (or deletes, if deleteExpired is set) the peers whose expiration time has passed
+// and releases their IP addresses. It returns the number of peers reaped.
+func (w *wgPeerSrv) ReapExpiredPeers(ctx context.Context, deleteExpired bool) (int, error) {
+	now := time.Now()
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{ExpiresBefore: &now})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = wireguard.WithRevisionInfo(ctx, wireguard.RevisionInfo{Actor: "reaper", Action: "reap expired peers"})
+	reaped := 0
+	for _, peer := range peers {
+		// Expired or revoked peers that were already disabled keep their record until deleted by hand
+		if peer.DisabledReason == model.WGPeerDisabledReasonExpired || peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+			continue
+		}
+
+		if deleteExpired {
+			klog.V(1).InfoS("deleting expired peer", "peerID", peer.ID, "userID", peer.UserID, "expiresAt", peer.ExpiresAt)
+			// Soft delete releases the IP allocations through the allocator
+			if _, err := w.DeletePeer(ctx, peer.ID, false); err != nil {
+				klog.V(1).InfoS("failed to delete expired peer", "peerID", peer.ID, "error", err)
+				continue
+			}
+			reaped++
+			continue
+		}
+
+		klog.V(1).InfoS("disabling expired peer", "peerID", peer.ID, "userID", peer.UserID, "expiresAt", peer.ExpiresAt)
+		// The regular peer update removes the peer from the server config and applies it
+		peer.Status = model.WGPeerStatusDisabled
+		peer.DisabledReason = model.WGPeerDisabledReasonExpired
+		if _, err := w.UpdatePeer(ctx, peer, nil, nil); err != nil {
+			klog.V(1).InfoS("failed to disable expired peer", "peerID", peer.ID, "error", err)
+			continue
+		}
+		if err := w.ReleaseIP(ctx, peer.ID); err != nil {
+			klog.V(1).InfoS("failed to release IP allocation of expired peer", "peerID", peer.ID, "error", err)
+		}
+		reaped++
+	}
+	return reaped, nil
+}
+
+// RunReaper reaps expired peers every interval until ctx is done.
+func (w *wgPeerSrv) RunReaper(ctx context.Context, interval time.Duration, deleteExpired bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if reaped, err := w.ReapExpiredPeers(ctx, deleteExpired); err != nil {
+			klog.V(1).InfoS("failed to reap expired peers", "error", err)
+		} else if reaped > 0 {
+			klog.V(1).InfoS("reaped expired peers", "count", reaped, "deleted", deleteExpired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+)
+
+func TestReapExpiredPeers(t *testing.T) {
+	tests := []struct {
+		name          string
+		deleteExpired bool
+		// expiresIn sets the expiration relative to now, 0 leaves the peer without one
+		expiresIn    time.Duration
+		setup        func(t *testing.T, s store.Factory, peer *model.WGPeer)
+		wantReaped   int
+		wantDeleted  bool
+		wantStatus   string
+		wantReason   string
+		wantReleased bool
+	}{
+		{
+			name:         "expired peer is disabled",
+			expiresIn:    -time.Hour,
+			wantReaped:   1,
+			wantStatus:   model.WGPeerStatusDisabled,
+			wantReason:   model.WGPeerDisabledReasonExpired,
+			wantReleased: true,
+		},
+		{
+			name:          "expired peer is deleted",
+			deleteExpired: true,
+			expiresIn:     -time.Hour,
+			wantReaped:    1,
+			wantDeleted:   true,
+			wantReleased:  true,
+		},
+		{
+			name:       "peer expiring later is kept",
+			expiresIn:  time.Hour,
+			wantStatus: model.WGPeerStatusActive,
+		},
+		{
+			name:       "peer without expiration is kept",
+			wantStatus: model.WGPeerStatusActive,
+		},
+		{
+			name:         "expired peer disabled by quota is disabled as expired",
+			expiresIn:    -time.Hour,
+			setup:        disablePeerWith(model.WGPeerDisabledReasonQuota),
+			wantReaped:   1,
+			wantStatus:   model.WGPeerStatusDisabled,
+			wantReason:   model.WGPeerDisabledReasonExpired,
+			wantReleased: true,
+		},
+		{
+			name:       "peer reaped before is skipped",
+			expiresIn:  -time.Hour,
+			setup:      disablePeerWith(model.WGPeerDisabledReasonExpired),
+			wantStatus: model.WGPeerStatusDisabled,
+			wantReason: model.WGPeerDisabledReasonExpired,
+		},
+		{
+			name:          "revoked peer is not deleted",
+			deleteExpired: true,
+			expiresIn:     -time.Hour,
+			setup: func(t *testing.T, s store.Factory, peer *model.WGPeer) {
+				if _, err := (&wgPeerSrv{store: s}).RevokePeer(context.Background(), peer, "lost", "admin"); err != nil {
+					t.Fatalf("RevokePeer() error = %v", err)
+				}
+			},
+			wantStatus:   model.WGPeerStatusDisabled,
+			wantReason:   model.WGPeerDisabledReasonRevoked,
+			wantReleased: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, pool := newTestStore(t)
+			peers := &wgPeerSrv{store: s}
+			peer := createTestPeer(t, s, pool, "phone")
+			if tt.expiresIn != 0 {
+				expiresAt := time.Now().Add(tt.expiresIn)
+				peer.ExpiresAt = &expiresAt
+				if err := s.WGPeers().UpdatePeer(ctx, peer); err != nil {
+					t.Fatalf("UpdatePeer() error = %v", err)
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(t, s, peer)
+			}
+
+			reaped, err := peers.ReapExpiredPeers(ctx, tt.deleteExpired)
+			if err != nil {
+				t.Fatalf("ReapExpiredPeers() error = %v", err)
+			}
+			if reaped != tt.wantReaped {
+				t.Errorf("ReapExpiredPeers() = %d, want %d", reaped, tt.wantReaped)
+			}
+
+			got, err := s.WGPeers().GetPeer(ctx, peer.ID)
+			switch {
+			case tt.wantDeleted:
+				if err == nil {
+					t.Errorf("peer was not deleted")
+				}
+			case err != nil:
+				t.Fatalf("GetPeer() error = %v", err)
+			case got.Status != tt.wantStatus || got.DisabledReason != tt.wantReason:
+				t.Errorf("peer is %s (%q), want %s (%q)", got.Status, got.DisabledReason, tt.wantStatus, tt.wantReason)
+			}
+			if inConfig, wantInConfig := serverConfigKeys(t)[peer.ClientPublicKey], tt.wantStatus == model.WGPeerStatusActive; inConfig != wantInConfig {
+				t.Errorf("peer in server config = %v, want %v", inConfig, wantInConfig)
+			}
+
+			// Released addresses are free for other peers
+			allocated, err := s.IPAllocations().GetAllocatedIPsByPoolID(ctx, pool.ID)
+			if err != nil {
+				t.Fatalf("GetAllocatedIPsByPoolID() error = %v", err)
+			}
+			if released := len(allocated) == 0; released != tt.wantReleased {
+				t.Errorf("IP released = %v (allocated %v), want %v", released, allocated, tt.wantReleased)
+			}
+		})
+	}
+}
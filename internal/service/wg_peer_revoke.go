@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// RevokePeer revokes a peer: its public key is put on the revocation list, the peer is removed from
+// the live interface and disabled, its IP addresses are released and its client config file is deleted.
+func (w *wgPeerSrv) RevokePeer(ctx context.Context, peer *model.WGPeer, reason, revokedBy string) ([]*wireguard.ApplyResult, error) {
+	if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+		return nil, errors.WithCode(code.ErrWGPeerRevoked, "peer %s has already been revoked", peer.ID)
+	}
+
+	// Record the revocation first, so the key is blocked even if a later step fails
+	revokedKey := &model.RevokedKey{
+		PublicKey:  peer.ClientPublicKey,
+		PeerID:     peer.ID,
+		UserID:     peer.UserID,
+		DeviceName: peer.DeviceName,
+		Reason:     reason,
+		RevokedBy:  revokedBy,
+	}
+	if err := w.store.Revocations().CreateRevokedKey(ctx, revokedKey); err != nil {
+		return nil, err
+	}
+
+	// Remove peer from the server config and apply it to the live interface
+	var applied []*wireguard.ApplyResult
+	if configManager := w.configManagerFor(ctx, peer.InterfaceID); configManager != nil {
+		if err := configManager.RemovePeer(ctx, peer.ClientPublicKey); err != nil {
+			if errors.ParseCoder(err).Code() != code.ErrWGPeerNotFound {
+				klog.V(1).InfoS("failed to remove revoked peer from server config", "peerID", peer.ID, "error", err)
+			}
+		} else if result, err := configManager.ApplyConfig(); err != nil {
+			klog.V(1).InfoS("failed to apply server config", "peerID", peer.ID, "error", err)
+			// Continue anyway
+		} else {
+			applied = append(applied, result)
+		}
+	}
+
+	peer.Status = model.WGPeerStatusDisabled
+	peer.DisabledReason = model.WGPeerDisabledReasonRevoked
+	if err := w.store.WGPeers().UpdatePeer(ctx, peer); err != nil {
+		return nil, err
+	}
+
+	if err := w.ReleaseIP(ctx, peer.ID); err != nil {
+		klog.V(1).InfoS("failed to release IP allocation of revoked peer", "peerID", peer.ID, "error", err)
+	}
+	syncPeerPortForwards(ctx, w.store, peer.ID, peer.InterfaceID)
+	syncExitRouting(ctx, w.store, peer.InterfaceID)
+
+	// Delete client config file
+	cfg := config.Get()
+	if cfg != nil && cfg.WireGuard != nil {
+		configPath := filepath.Join(cfg.WireGuard.ResolvedUserDir(), peer.ID+".conf")
+		if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+			klog.V(1).InfoS("failed to delete client config file", "peerID", peer.ID, "path", configPath, "error", err)
+		}
+	}
+
+	klog.V(1).InfoS("revoked peer", "peerID", peer.ID, "userID", peer.UserID, "revokedBy", revokedBy, "reason", reason)
+	return applied, nil
+}
+
+func (w *wgPeerSrv) ListRevokedKeys(ctx context.Context, opt store.RevokedKeyListOptions) ([]*model.RevokedKey, int64, error) {
+	return w.store.Revocations().ListRevokedKeys(ctx, opt)
+}
+
+// checkKeyNotRevoked returns ErrWGPeerKeyRevoked if the public key is on the revocation list.
+func (w *wgPeerSrv) checkKeyNotRevoked(ctx context.Context, publicKey string) error {
+	revoked, err := w.store.Revocations().IsKeyRevoked(ctx, publicKey)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.WithCode(code.ErrWGPeerKeyRevoked, "public key has been revoked")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+)
+
+// newRevokedPeer returns a store with a revoked peer, and the peer as it was before it was revoked.
+func newRevokedPeer(t *testing.T) (store.Factory, *model.IPPool, *model.WGPeer) {
+	t.Helper()
+	s, pool := newTestStore(t)
+	peer := createTestPeer(t, s, pool, "lost phone")
+	old := *peer
+	if _, err := (&wgPeerSrv{store: s}).RevokePeer(context.Background(), peer, "lost", "admin"); err != nil {
+		t.Fatalf("RevokePeer() error = %v", err)
+	}
+	return s, pool, &old
+}
+
+func TestRevokedKeyIsRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		reuse    func(ctx context.Context, peers *wgPeerSrv, pool *model.IPPool, revoked *model.WGPeer) error
+		wantCode int
+	}{
+		{
+			name: "new peer with the revoked public key",
+			reuse: func(ctx context.Context, peers *wgPeerSrv, pool *model.IPPool, revoked *model.WGPeer) error {
+				_, _, err := peers.CreatePeer(ctx, "user-1", "tablet", pool.ID, "", "", "", "", "", revoked.ClientPublicKey, "", "", "", nil, nil)
+				return err
+			},
+			wantCode: code.ErrWGPeerKeyRevoked,
+		},
+		{
+			name: "new peer with the revoked private key",
+			reuse: func(ctx context.Context, peers *wgPeerSrv, pool *model.IPPool, revoked *model.WGPeer) error {
+				_, _, err := peers.CreatePeer(ctx, "user-1", "tablet", pool.ID, "", "", "", "", revoked.ClientPrivateKey, "", "", "", "", nil, nil)
+				return err
+			},
+			wantCode: code.ErrWGPeerKeyRevoked,
+		},
+		{
+			name: "other peer switching to the revoked private key",
+			reuse: func(ctx context.Context, peers *wgPeerSrv, pool *model.IPPool, revoked *model.WGPeer) error {
+				peer, _, err := peers.CreatePeer(ctx, "user-1", "tablet", pool.ID, "", "", "", "", "", "", "", "", "", nil, nil)
+				if err != nil {
+					return fmt.Errorf("CreatePeer() error = %w", err)
+				}
+				peer.ClientPrivateKey = revoked.ClientPrivateKey
+				_, err = peers.UpdatePeer(ctx, peer, nil, nil)
+				return err
+			},
+			wantCode: code.ErrWGPeerKeyRevoked,
+		},
+		{
+			name: "revoked peer re-enabled",
+			reuse: func(ctx context.Context, peers *wgPeerSrv, pool *model.IPPool, revoked *model.WGPeer) error {
+				peer, err := peers.GetPeer(ctx, revoked.ID)
+				if err != nil {
+					return fmt.Errorf("GetPeer() error = %w", err)
+				}
+				peer.Status = model.WGPeerStatusActive
+				peer.DisabledReason = ""
+				_, err = peers.UpdatePeer(ctx, peer, nil, nil)
+				return err
+			},
+			wantCode: code.ErrWGPeerRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, pool, revoked := newRevokedPeer(t)
+
+			err := tt.reuse(ctx, &wgPeerSrv{store: s}, pool, revoked)
+			if err == nil {
+				t.Fatalf("reusing the revoked key error = nil, want it rejected")
+			}
+			if got := errors.ParseCoder(err).Code(); got != tt.wantCode {
+				t.Errorf("reusing the revoked key error = %v, want code %d", err, tt.wantCode)
+			}
+			if serverConfigKeys(t)[revoked.ClientPublicKey] {
+				t.Errorf("server config holds the revoked key %s", revoked.ClientPublicKey)
+			}
+		})
+	}
+}
+
+func TestSyncRemovesRevokedKey(t *testing.T) {
+	ctx := context.Background()
+	s, _, revoked := newRevokedPeer(t)
+
+	// The revoked peer is put back into the server config by hand
+	appendServerConfigPeer(t, "lost phone", revoked.ClientPublicKey, revoked.ClientIP)
+	if !serverConfigKeys(t)[revoked.ClientPublicKey] {
+		t.Fatalf("server config does not hold the revoked key %s after editing it", revoked.ClientPublicKey)
+	}
+
+	if err := ip.SyncAllFromConfigFiles(ctx, s); err != nil {
+		t.Fatalf("SyncAllFromConfigFiles() error = %v", err)
+	}
+
+	if serverConfigKeys(t)[revoked.ClientPublicKey] {
+		t.Errorf("server config still holds the revoked key %s", revoked.ClientPublicKey)
+	}
+	peers, total, err := s.WGPeers().ListPeers(ctx, store.WGPeerListOptions{})
+	if err != nil {
+		t.Fatalf("ListPeers() error = %v", err)
+	}
+	if total != 1 || peers[0].ID != revoked.ID {
+		t.Fatalf("got %d peers, want only the revoked peer", total)
+	}
+	if peers[0].Status != model.WGPeerStatusDisabled || peers[0].DisabledReason != model.WGPeerDisabledReasonRevoked {
+		t.Errorf("revoked peer is %s (%q), want it to stay revoked", peers[0].Status, peers[0].DisabledReason)
+	}
+}
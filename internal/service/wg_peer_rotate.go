@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// RotatePeerKeys generates a new key pair for a peer, and a new preshared key if it has one
+// or one is required, replaces the old public key in the server config and regenerates the client config.
+func (w *wgPeerSrv) RotatePeerKeys(ctx context.Context, peer *model.WGPeer) ([]*wireguard.ApplyResult, error) {
+	if peer.DisabledReason == model.WGPeerDisabledReasonRevoked {
+		return nil, errors.WithCode(code.ErrWGPeerRevoked, "peer %s has been revoked", peer.ID)
+	}
+	// The client of a public-key-only peer generates its new keys itself
+	if peer.ClientPrivateKey == "" {
+		return nil, errors.WithCode(code.ErrWGPeerPublicKeyOnly, "peer %s keeps its private key on the client", peer.ID)
+	}
+
+	privateKey, publicKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	var pool *model.IPPool
+	if peer.IPPoolID != "" {
+		pool, _ = w.store.IPPools().GetIPPool(ctx, peer.IPPoolID)
+	}
+	presharedKey := ""
+	if peer.PresharedKey != "" || IsPresharedKeyRequired(pool) {
+		presharedKey, err = wireguard.GeneratePresharedKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	oldPublicKey := peer.ClientPublicKey
+	oldPrivateKey := peer.ClientPrivateKey
+	oldPresharedKey := peer.PresharedKey
+	oldKeyRotatedAt := peer.KeyRotatedAt
+
+	now := time.Now()
+	peer.ClientPrivateKey = privateKey
+	peer.ClientPublicKey = publicKey
+	peer.PresharedKey = presharedKey
+	peer.KeyRotatedAt = &now
+
+	// Swap the key in the server config first, so a failure leaves the peer untouched
+	configManager := w.configManagerFor(ctx, peer.InterfaceID)
+	inServerConfig := configManager != nil && peer.Status == model.WGPeerStatusActive
+	if inServerConfig {
+		serverPeer := serverPeerConfig(peer)
+		if err := configManager.ReplacePeerKey(ctx, oldPublicKey, serverPeer); err != nil {
+			peer.ClientPrivateKey, peer.ClientPublicKey, peer.PresharedKey, peer.KeyRotatedAt = oldPrivateKey, oldPublicKey, oldPresharedKey, oldKeyRotatedAt
+			return nil, err
+		}
+	}
+
+	if err := w.store.WGPeers().UpdatePeer(ctx, peer); err != nil {
+		// Rollback: put the old key back into the server config
+		if inServerConfig {
+			oldServerPeer := serverPeerConfig(peer)
+			oldServerPeer.PublicKey, oldServerPeer.PresharedKey = oldPublicKey, oldPresharedKey
+			if rollbackErr := configManager.ReplacePeerKey(ctx, peer.ClientPublicKey, oldServerPeer); rollbackErr != nil {
+				klog.V(1).InfoS("failed to restore old peer key in server config", "peerID", peer.ID, "error", rollbackErr)
+			}
+		}
+		peer.ClientPrivateKey, peer.ClientPublicKey, peer.PresharedKey, peer.KeyRotatedAt = oldPrivateKey, oldPublicKey, oldPresharedKey, oldKeyRotatedAt
+		return nil, err
+	}
+
+	var applied []*wireguard.ApplyResult
+	if inServerConfig {
+		if result, err := configManager.ApplyConfig(); err != nil {
+			klog.V(1).InfoS("failed to apply server config", "peerID", peer.ID, "error", err)
+			// Continue anyway
+		} else {
+			applied = append(applied, result)
+		}
+	}
+
+	// Regenerate client config with the new keys
+	if err := w.generateAndSaveClientConfig(ctx, peer); err != nil {
+		klog.V(1).InfoS("failed to regenerate client config", "peerID", peer.ID, "error", err)
+		// Continue anyway
+	}
+
+	klog.V(1).InfoS("rotated peer keys", "peerID", peer.ID, "userID", peer.UserID)
+	return applied, nil
+}
+
+// RotatePeersKeys rotates the keys of all peers matching opt whose keys are older than maxAge
+// (all matching peers if maxAge is 0). It returns the number of peers rotated.
+func (w *wgPeerSrv) RotatePeersKeys(ctx context.Context, opt store.WGPeerListOptions, maxAge time.Duration) (int, error) {
+	peers, err := listAllPeers(ctx, w.store, opt)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	rotated := 0
+	for _, peer := range peers {
+		if peer.DisabledReason == model.WGPeerDisabledReasonRevoked || peer.ClientPrivateKey == "" {
+			continue
+		}
+		if maxAge > 0 && now.Sub(peerKeyIssuedAt(peer)) < maxAge {
+			continue
+		}
+		if _, err := w.RotatePeerKeys(ctx, peer); err != nil {
+			klog.V(1).InfoS("failed to rotate peer keys", "peerID", peer.ID, "error", err)
+			continue
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// RunKeyRotation rotates the keys of peers older than maxAge every interval until ctx is done.
+func (w *wgPeerSrv) RunKeyRotation(ctx context.Context, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx = wireguard.WithRevisionInfo(ctx, wireguard.RevisionInfo{Actor: "rotation", Action: "rotate peer keys"})
+	for {
+		if rotated, err := w.RotatePeersKeys(ctx, store.WGPeerListOptions{}, maxAge); err != nil {
+			klog.V(1).InfoS("failed to rotate peer keys", "error", err)
+		} else if rotated > 0 {
+			klog.V(1).InfoS("rotated peer keys", "count", rotated)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// peerKeyIssuedAt returns when the current keys of a peer were issued.
+func peerKeyIssuedAt(peer *model.WGPeer) time.Time {
+	if peer.KeyRotatedAt != nil {
+		return *peer.KeyRotatedAt
+	}
+	return peer.CreatedAt
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+)
+
+// failingPeerUpdates is a store whose peer updates fail.
+type failingPeerUpdates struct {
+	store.Factory
+}
+
+func (f *failingPeerUpdates) WGPeers() store.WGPeerStore {
+	return &failingPeerUpdateStore{WGPeerStore: f.Factory.WGPeers()}
+}
+
+type failingPeerUpdateStore struct {
+	store.WGPeerStore
+}
+
+func (f *failingPeerUpdateStore) UpdatePeer(ctx context.Context, peer *model.WGPeer) error {
+	return errors.WithCode(code.ErrDatabase, "database is locked")
+}
+
+func TestRotatePeerKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		// disabled rotates a peer disabled by hand, which is not in the server config
+		disabled    bool
+		failUpdates bool
+		wantCode    int
+	}{
+		{name: "active peer"},
+		{name: "disabled peer", disabled: true},
+		{name: "store failure rolls back the server config", failUpdates: true, wantCode: code.ErrDatabase},
+		{name: "store failure of a disabled peer", disabled: true, failUpdates: true, wantCode: code.ErrDatabase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, pool := newTestStore(t)
+			peer := createTestPeer(t, s, pool, "phone")
+			createTestPeer(t, s, pool, "laptop")
+			if tt.disabled {
+				disablePeerWith("")(t, s, peer)
+			}
+			old := *peer
+			keysBefore := serverConfigKeys(t)
+
+			peers := &wgPeerSrv{store: s}
+			if tt.failUpdates {
+				peers.store = &failingPeerUpdates{Factory: s}
+			}
+			_, err := peers.RotatePeerKeys(ctx, peer)
+
+			stored, getErr := s.WGPeers().GetPeer(ctx, peer.ID)
+			if getErr != nil {
+				t.Fatalf("GetPeer() error = %v", getErr)
+			}
+			keys := serverConfigKeys(t)
+			if tt.wantCode != 0 {
+				if err == nil {
+					t.Fatalf("RotatePeerKeys() error = nil, want code %d", tt.wantCode)
+				}
+				if got := errors.ParseCoder(err).Code(); got != tt.wantCode {
+					t.Errorf("RotatePeerKeys() error code = %d, want %d", got, tt.wantCode)
+				}
+				// Nothing changed: the peer, its stored record and the server config keep the old key
+				if peer.ClientPublicKey != old.ClientPublicKey || peer.ClientPrivateKey != old.ClientPrivateKey || peer.KeyRotatedAt != nil {
+					t.Errorf("peer was not restored, public key %s, want %s", peer.ClientPublicKey, old.ClientPublicKey)
+				}
+				if stored.ClientPublicKey != old.ClientPublicKey {
+					t.Errorf("stored public key = %s, want %s", stored.ClientPublicKey, old.ClientPublicKey)
+				}
+				if !reflect.DeepEqual(keys, keysBefore) {
+					t.Errorf("server config keys = %v, want %v", keys, keysBefore)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RotatePeerKeys() error = %v", err)
+			}
+
+			if stored.ClientPublicKey == old.ClientPublicKey || stored.ClientPublicKey != peer.ClientPublicKey || stored.KeyRotatedAt == nil {
+				t.Errorf("stored public key = %s (rotated at %v), want the new key %s", stored.ClientPublicKey, stored.KeyRotatedAt, peer.ClientPublicKey)
+			}
+			if keys[old.ClientPublicKey] {
+				t.Errorf("server config still holds the old key %s", old.ClientPublicKey)
+			}
+			if keys[peer.ClientPublicKey] != !tt.disabled || len(keys) != len(keysBefore) {
+				t.Errorf("server config keys = %v, want the new key %s only if the peer is active", keys, peer.ClientPublicKey)
+			}
+		})
+	}
+}
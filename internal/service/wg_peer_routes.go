@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
+)
+
+// serverPeerConfig returns the server config entry of a peer. Its AllowedIPs hold the peer addresses
+// followed by the subnets routed behind it, so that a site-to-site peer can stand for a whole office,
+// and the default route for the exit node of the interface.
+func serverPeerConfig(peer *model.WGPeer) *wireguard.ServerPeerConfig {
+	allowedIPs := peer.ClientIP
+	if peer.RoutedSubnets != "" {
+		allowedIPs += "," + peer.RoutedSubnets
+	}
+	if peer.ExitNode {
+		allowedIPs += ",0.0.0.0/0"
+	}
+	return &wireguard.ServerPeerConfig{
+		PublicKey:           peer.ClientPublicKey,
+		PresharedKey:        peer.PresharedKey,
+		Endpoint:            peer.PeerEndpoint,
+		AllowedIPs:          allowedIPs,
+		PersistentKeepalive: peer.PersistentKeepalive,
+		Comment:             peer.DeviceName,
+	}
+}
+
+// parsePrefixes parses a comma-separated CIDR list, ignoring empty items.
+func parsePrefixes(cidrs string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(cidrs, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// overlapsAny reports whether prefix overlaps any of prefixes.
+func overlapsAny(prefix netip.Prefix, prefixes []netip.Prefix) bool {
+	for _, other := range prefixes {
+		if prefix.Overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRoutedSubnets checks the subnets routed behind a peer and returns them normalized,
+// e.g. "192.168.10.1/24" -> "192.168.10.0/24". They must not overlap each other, an IP pool,
+// or the addresses and routed subnets of another peer, otherwise traffic could not be routed unambiguously.
+func (w *wgPeerSrv) validateRoutedSubnets(ctx context.Context, peerID, routedSubnets string) (string, error) {
+	subnets, err := parsePrefixes(routedSubnets)
+	if err != nil {
+		return "", errors.WithCode(code.ErrValidation, "invalid routed subnet: %s", err.Error())
+	}
+	if len(subnets) == 0 {
+		return "", nil
+	}
+
+	normalized := make([]string, 0, len(subnets))
+	for i, subnet := range subnets {
+		if subnet.Bits() == 0 {
+			return "", errors.WithCode(code.ErrValidation, "routed subnet %s would route all traffic to the peer", subnet)
+		}
+		if overlapsAny(subnet, subnets[:i]) {
+			return "", errors.WithCode(code.ErrWGRoutedSubnetOverlap, "routed subnets %s overlap each other", routedSubnets)
+		}
+		normalized = append(normalized, subnet.String())
+	}
+
+	pools, err := listAllIPPools(ctx, w.store)
+	if err != nil {
+		return "", err
+	}
+	for _, pool := range pools {
+		poolPrefixes, err := ip.ParsePoolCIDR(pool.CIDR)
+		if err != nil {
+			continue
+		}
+		for _, subnet := range subnets {
+			if overlapsAny(subnet, poolPrefixes) {
+				return "", errors.WithCode(code.ErrWGRoutedSubnetOverlap, "routed subnet %s overlaps IP pool %s (%s)", subnet, pool.Name, pool.CIDR)
+			}
+		}
+	}
+
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, peer := range peers {
+		if peer.ID == peerID {
+			continue
+		}
+		// Malformed addresses of legacy peers are skipped rather than blocking the change
+		peerPrefixes, _ := parsePrefixes(peer.ClientIP + "," + peer.RoutedSubnets)
+		for _, subnet := range subnets {
+			if overlapsAny(subnet, peerPrefixes) {
+				return "", errors.WithCode(code.ErrWGRoutedSubnetOverlap, "routed subnet %s overlaps the addresses of peer %s", subnet, peer.DeviceName)
+			}
+		}
+	}
+
+	return strings.Join(normalized, ","), nil
+}
+
+// clientAllowedIPs extends the AllowedIPs of a client config with the subnets routed behind the other
+// site-to-site peers of its interface, so that laptops and offices reach the other offices through the server.
+// Subnets already covered by allowedIPs (e.g. 0.0.0.0/0) are left out.
+func clientAllowedIPs(ctx context.Context, s store.Factory, peer *model.WGPeer, allowedIPs string) string {
+	sitePeers, err := listAllPeers(ctx, s, store.WGPeerListOptions{
+		InterfaceID:      peer.InterfaceID,
+		Status:           model.WGPeerStatusActive,
+		HasRoutedSubnets: true,
+	})
+	if err != nil {
+		klog.V(1).InfoS("failed to list site-to-site peers", "interfaceID", peer.InterfaceID, "error", err)
+		return allowedIPs
+	}
+
+	covered, _ := parsePrefixes(allowedIPs)
+	routes := []string{}
+	if strings.TrimSpace(allowedIPs) != "" {
+		routes = append(routes, allowedIPs)
+	}
+	for _, sitePeer := range sitePeers {
+		if sitePeer.ID == peer.ID {
+			continue
+		}
+		subnets, _ := parsePrefixes(sitePeer.RoutedSubnets)
+		for _, subnet := range subnets {
+			if coveredBy(subnet, covered) {
+				continue
+			}
+			covered = append(covered, subnet)
+			routes = append(routes, subnet.String())
+		}
+	}
+	return strings.Join(routes, ",")
+}
+
+// coveredBy reports whether prefix lies within one of prefixes.
+func coveredBy(prefix netip.Prefix, prefixes []netip.Prefix) bool {
+	for _, other := range prefixes {
+		if other.Bits() <= prefix.Bits() && other.Contains(prefix.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAllowedIPs returns the AllowedIPs of the client config of a peer: allowedIPs plus the subnets
+// routed behind the other site-to-site peers of its interface.
+func (w *wgPeerSrv) ClientAllowedIPs(ctx context.Context, peer *model.WGPeer, allowedIPs string) string {
+	return clientAllowedIPs(ctx, w.store, peer, allowedIPs)
+}
+
+// regenerateClientConfigs regenerates the saved client configs of the other peers of an interface,
+// which route to the subnets behind its site-to-site peers.
+func (w *wgPeerSrv) regenerateClientConfigs(ctx context.Context, interfaceID, exceptPeerID string) {
+	if cfg := config.Get(); cfg == nil || cfg.WireGuard == nil || cfg.WireGuard.ClientConfigOnDemand {
+		return
+	}
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{InterfaceID: interfaceID})
+	if err != nil {
+		klog.V(1).InfoS("failed to list peers to regenerate client configs", "interfaceID", interfaceID, "error", err)
+		return
+	}
+	for _, peer := range peers {
+		if peer.ID == exceptPeerID {
+			continue
+		}
+		if err := w.generateAndSaveClientConfig(ctx, peer); err != nil {
+			klog.V(1).InfoS("failed to regenerate client config", "peerID", peer.ID, "error", err)
+		}
+	}
+}
+
+// listAllIPPools lists all IP pools, fetching them page by page.
+func listAllIPPools(ctx context.Context, s store.Factory) ([]*model.IPPool, error) {
+	const pageSize = 200
+
+	var all []*model.IPPool
+	opt := store.IPPoolListOptions{Limit: pageSize}
+	for opt.Offset = 0; ; opt.Offset += pageSize {
+		pools, total, err := s.IPPools().ListIPPools(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, pools...)
+		if len(pools) < pageSize || int64(len(all)) >= total {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"k8s.io/klog/v2"
+)
+
+// GetPeerStatus reads the runtime status of a peer from its interface.
+// A peer that is not loaded on the interface gets an empty (offline) status.
+func (w *wgPeerSrv) GetPeerStatus(ctx context.Context, peer *model.WGPeer) (*wireguard.PeerStatus, error) {
+	configManager, err := interfaceConfigManager(ctx, w.store, peer.InterfaceID)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := wireguard.GetPeerStatuses(configManager.InterfaceName())
+	if err != nil {
+		return nil, err
+	}
+	if status, ok := statuses[peer.ClientPublicKey]; ok {
+		return status, nil
+	}
+	return &wireguard.PeerStatus{PublicKey: peer.ClientPublicKey}, nil
+}
+
+// GetPeerStatuses reads the runtime status of peers, keyed by peer ID.
+// Each interface is read once; peers on interfaces whose status cannot be read are left out.
+func (w *wgPeerSrv) GetPeerStatuses(ctx context.Context, peers []*model.WGPeer) map[string]*wireguard.PeerStatus {
+	result := make(map[string]*wireguard.PeerStatus, len(peers))
+	byInterface := make(map[string]map[string]*wireguard.PeerStatus)
+
+	for _, peer := range peers {
+		statuses, ok := byInterface[peer.InterfaceID]
+		if !ok {
+			configManager, err := interfaceConfigManager(ctx, w.store, peer.InterfaceID)
+			if err == nil {
+				statuses, err = wireguard.GetPeerStatuses(configManager.InterfaceName())
+			}
+			if err != nil {
+				klog.V(2).InfoS("peer runtime status not available", "interfaceID", peer.InterfaceID, "error", err)
+			}
+			byInterface[peer.InterfaceID] = statuses
+		}
+		if statuses == nil {
+			continue
+		}
+		if status, ok := statuses[peer.ClientPublicKey]; ok {
+			result[peer.ID] = status
+		} else {
+			result[peer.ID] = &wireguard.PeerStatus{PublicKey: peer.ClientPublicKey}
+		}
+	}
+	return result
+}
@@ -0,0 +1,672 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/options"
+	"github.com/HappyLadySauce/errors"
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+// Reconcile policies (wireguard.reconcile-policy).
+const (
+	// ReconcilePolicyImport resolves drift in favor of the config files.
+	ReconcilePolicyImport = "import"
+	// ReconcilePolicyEnforce resolves drift in favor of the database and rewrites the config files.
+	ReconcilePolicyEnforce = "enforce"
+	// ReconcilePolicyReport only reports drift.
+	ReconcilePolicyReport = "report"
+)
+
+// Kinds of reconcile actions.
+const (
+	// ReconcileImportPeer: a config file holds a peer unknown to the database.
+	ReconcileImportPeer = "import_peer"
+	// ReconcileActivatePeer: a config file holds a peer disabled in the database.
+	ReconcileActivatePeer = "activate_peer"
+	// ReconcileDisablePeer: an active peer is missing from the config files.
+	ReconcileDisablePeer = "disable_peer"
+	// ReconcileUpdatePeer: the AllowedIPs, keepalive or comment of a peer differ between its config file and the database.
+	ReconcileUpdatePeer = "update_peer"
+	// ReconcileRemoveRevokedPeer: a config file holds a revoked key.
+	ReconcileRemoveRevokedPeer = "remove_revoked_peer"
+)
+
+// reconcileDebounce is how long the watcher waits for a burst of file events to settle.
+const reconcileDebounce = 2 * time.Second
+
+// DriftDifference is a field of a peer that differs between the database and its config file.
+type DriftDifference struct {
+	// Field is allowed_ips, persistent_keepalive or comment
+	Field      string
+	Database   string
+	ConfigFile string
+}
+
+// ReconcileAction is a difference between the database and a config file. The import policy
+// resolves it in favor of the config file, the enforce policy in favor of the database.
+type ReconcileAction struct {
+	// ID identifies the action across runs, as long as the difference persists
+	ID        string
+	Kind      string
+	Interface string
+	PublicKey string
+	PeerID    string
+	Detail    string
+	// Differences are the fields differing for update_peer
+	Differences []DriftDifference
+	// IPPool is the CIDR of the IP pool created when the peer is imported, empty if an existing one is used
+	IPPool string
+
+	configPath string
+}
+
+// reconcileActionID returns the ID of an action, derived from what it is about so that it stays the same across runs.
+func reconcileActionID(kind, interfaceName, publicKey string) string {
+	sum := sha256.Sum256([]byte(kind + "/" + interfaceName + "/" + publicKey))
+	return hex.EncodeToString(sum[:6])
+}
+
+// ReconcileStatus is the outcome of the last reconcile run.
+type ReconcileStatus struct {
+	Policy       string
+	Running      bool
+	LastRun      time.Time
+	LastTrigger  string
+	LastDuration time.Duration
+	Runs         int
+	// Applied is the number of actions applied by the last run
+	Applied int
+	// ExternalEdits are the interfaces whose config file was edited outside of NexusPointWG
+	ExternalEdits []string
+	// PendingActions are the differences left after the last run
+	PendingActions []ReconcileAction
+	Errors         []string
+}
+
+// ReconcileSrv defines the interface for reconciling the database with the config files.
+type ReconcileSrv interface {
+	// Reconcile compares the database with the config files once and resolves the differences with the
+	// configured policy. Unless force is set, only differences caused by an edit outside of NexusPointWG
+	// or pending since the previous run are resolved, so that API calls in flight are not undone.
+	Reconcile(ctx context.Context, trigger string, force bool) (*ReconcileStatus, error)
+	// Run reconciles every interval and, if watch is set, whenever a config file changes, until ctx is done.
+	Run(ctx context.Context, interval time.Duration, watch bool)
+	// Status returns the status of the last run.
+	Status() *ReconcileStatus
+	// DriftReport lists the differences between the database and the config files without changing either.
+	DriftReport(ctx context.Context) (*DriftReport, error)
+	// ApproveDriftAction resolves a single difference of the drift report, in favor of the config file
+	// (resolution "import") or of the database ("enforce"); an empty resolution uses the reconcile policy.
+	ApproveDriftAction(ctx context.Context, id, resolution string) (*ReconcileAction, error)
+}
+
+type reconcileSrv struct {
+	store store.Factory
+}
+
+// ReconcileSrv if implemented, then reconcileSrv implements ReconcileSrv interface.
+var _ ReconcileSrv = (*reconcileSrv)(nil)
+
+func newReconciler(s *service) *reconcileSrv {
+	return &reconcileSrv{store: s.store}
+}
+
+// reconcileState is shared by all reconcilers: runs are serialized and the status outlives the service.
+var reconcileState struct {
+	runMu  sync.Mutex
+	mu     sync.Mutex
+	status ReconcileStatus
+}
+
+// reconcilePolicy returns the configured reconcile policy.
+func reconcilePolicy() string {
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return ReconcilePolicyImport
+	}
+	switch policy := strings.ToLower(strings.TrimSpace(cfg.WireGuard.ReconcilePolicy)); policy {
+	case ReconcilePolicyEnforce, ReconcilePolicyReport:
+		return policy
+	default:
+		return ReconcilePolicyImport
+	}
+}
+
+func (r *reconcileSrv) Status() *ReconcileStatus {
+	reconcileState.mu.Lock()
+	defer reconcileState.mu.Unlock()
+
+	status := reconcileState.status
+	status.Policy = reconcilePolicy()
+	status.ExternalEdits = append([]string(nil), status.ExternalEdits...)
+	status.PendingActions = append([]ReconcileAction(nil), status.PendingActions...)
+	status.Errors = append([]string(nil), status.Errors...)
+	return &status
+}
+
+func (r *reconcileSrv) Reconcile(ctx context.Context, trigger string, force bool) (*ReconcileStatus, error) {
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return nil, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+
+	reconcileState.runMu.Lock()
+	defer reconcileState.runMu.Unlock()
+
+	reconcileState.mu.Lock()
+	reconcileState.status.Running = true
+	previous := make(map[string]bool, len(reconcileState.status.PendingActions))
+	for _, action := range reconcileState.status.PendingActions {
+		previous[action.ID] = true
+	}
+	reconcileState.mu.Unlock()
+
+	started := time.Now()
+	policy := reconcilePolicy()
+	var runErrs []string
+	// A forced import also syncs the IP allocations, as the startup sync always did
+	if policy == ReconcilePolicyImport && force {
+		if err := ip.SyncAllFromConfigFiles(ctx, r.store); err != nil {
+			runErrs = append(runErrs, err.Error())
+		}
+		syncPeerRules(ctx, r.store)
+	}
+	actions, externalEdits, planErrs := planReconcile(ctx, r.store)
+	runErrs = append(runErrs, planErrs...)
+
+	// Resolve the differences caused by an edit outside of NexusPointWG or seen by the previous run as well
+	edited := make(map[string]bool, len(externalEdits))
+	for _, name := range externalEdits {
+		edited[name] = true
+	}
+	var confirmed []ReconcileAction
+	for _, action := range actions {
+		if force || edited[action.Interface] || previous[action.ID] {
+			confirmed = append(confirmed, action)
+		}
+	}
+
+	applied := 0
+	if policy != ReconcilePolicyReport {
+		var errs []error
+		applied, errs = applyReconcileActions(ctx, r.store, confirmed, policy)
+		for _, err := range errs {
+			runErrs = append(runErrs, err.Error())
+		}
+		recordExternalEdits(cfg.WireGuard, externalEdits)
+		if len(confirmed) > 0 {
+			// Compare again to find out what is left
+			actions, _, planErrs = planReconcile(ctx, r.store)
+			runErrs = append(runErrs, planErrs...)
+		}
+	}
+
+	reconcileState.mu.Lock()
+	reconcileState.status = ReconcileStatus{
+		Policy:         policy,
+		LastRun:        started,
+		LastTrigger:    trigger,
+		LastDuration:   time.Since(started),
+		Runs:           reconcileState.status.Runs + 1,
+		Applied:        applied,
+		ExternalEdits:  externalEdits,
+		PendingActions: actions,
+		Errors:         runErrs,
+	}
+	reconcileState.mu.Unlock()
+
+	if applied > 0 || len(actions) > 0 || len(runErrs) > 0 {
+		klog.V(1).InfoS("reconciled database with config files", "trigger", trigger, "policy", policy,
+			"externalEdits", externalEdits, "applied", applied, "pending", len(actions), "errors", len(runErrs))
+	}
+	return r.Status(), nil
+}
+
+func (r *reconcileSrv) Run(ctx context.Context, interval time.Duration, watch bool) {
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var events <-chan fsnotify.Event
+	var watchErrs <-chan error
+	if watch {
+		if watcher, err := watchRootDir(); err != nil {
+			klog.V(1).InfoS("failed to watch WireGuard root directory, reconciling periodically only", "error", err)
+		} else {
+			defer watcher.Close()
+			events, watchErrs = watcher.Events, watcher.Errors
+		}
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			r.runOnce(ctx, "interval")
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if isServerConfigEvent(event) {
+				debounce = time.After(reconcileDebounce)
+			}
+		case err, ok := <-watchErrs:
+			if !ok {
+				watchErrs = nil
+				continue
+			}
+			klog.V(1).InfoS("WireGuard root directory watch error", "error", err)
+		case <-debounce:
+			debounce = nil
+			r.runOnce(ctx, "watch")
+		}
+	}
+}
+
+func (r *reconcileSrv) runOnce(ctx context.Context, trigger string) {
+	if _, err := r.Reconcile(ctx, trigger, false); err != nil {
+		klog.V(1).InfoS("failed to reconcile database with config files", "trigger", trigger, "error", err)
+	}
+}
+
+// watchRootDir watches the WireGuard root directory, where the server config files live.
+func watchRootDir() (*fsnotify.Watcher, error) {
+	cfg := config.Get()
+	if cfg == nil || cfg.WireGuard == nil {
+		return nil, errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(cfg.WireGuard.RootDir); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// isServerConfigEvent reports whether a file event concerns a server config file. Temporary
+// files of atomic writes are hidden, the rename onto the config file is reported for the file itself.
+func isServerConfigEvent(event fsnotify.Event) bool {
+	name := filepath.Base(event.Name)
+	if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".conf") {
+		return false
+	}
+	return event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove)
+}
+
+// planReconcile compares the peers of the config files under the root directory with the database,
+// without changing either. It returns the differences, the interfaces whose config file was edited
+// outside of NexusPointWG, and the errors met on the way.
+func planReconcile(ctx context.Context, s store.Factory) ([]ReconcileAction, []string, []string) {
+	var actions []ReconcileAction
+	var externalEdits, errs []string
+
+	wgOpts := config.Get().WireGuard
+	configFiles, err := ip.ScanConfigFiles(wgOpts.RootDir)
+	if err != nil {
+		return nil, nil, []string{err.Error()}
+	}
+	peers, err := listAllPeers(ctx, s, store.WGPeerListOptions{})
+	if err != nil {
+		return nil, nil, []string{err.Error()}
+	}
+	peersByKey := make(map[string]*model.WGPeer, len(peers))
+	for _, peer := range peers {
+		peersByKey[peer.ClientPublicKey] = peer
+	}
+
+	// Peers missing from the config files are only reported if all of them could be read
+	complete := true
+	configPaths := make(map[string]string, len(configFiles))
+	seen := make(map[string]bool)
+	for _, configPath := range configFiles {
+		configManager := wireguard.GetServerConfigManager(configPath, wgOpts.ApplyMethod)
+		interfaceName := configManager.InterfaceName()
+		configPaths[interfaceName] = configPath
+
+		if external, err := configManager.ExternalEdit(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", interfaceName, err.Error()))
+		} else if external {
+			externalEdits = append(externalEdits, interfaceName)
+		}
+		serverConfig, err := configManager.PeekServerConfig()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", interfaceName, err.Error()))
+			complete = false
+			continue
+		}
+
+		for _, configPeer := range serverConfig.Peers {
+			if configPeer.PublicKey == "" {
+				continue
+			}
+			seen[configPeer.PublicKey] = true
+			action := ReconcileAction{Interface: interfaceName, PublicKey: configPeer.PublicKey, configPath: configPath}
+
+			revoked, err := s.Revocations().IsKeyRevoked(ctx, configPeer.PublicKey)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", interfaceName, err.Error()))
+				continue
+			}
+			peer := peersByKey[configPeer.PublicKey]
+			if peer != nil {
+				action.PeerID = peer.ID
+			}
+			address := ip.ConfigPeerAddress(configPeer.AllowedIPs)
+			switch {
+			case revoked:
+				action.Kind = ReconcileRemoveRevokedPeer
+			case peer == nil:
+				action.Kind = ReconcileImportPeer
+				action.Detail = "AllowedIPs = " + configPeer.AllowedIPs
+			case peer.Status != model.WGPeerStatusActive:
+				action.Kind = ReconcileActivatePeer
+				action.Detail = "status " + peer.Status + " in database"
+			default:
+				action.Differences = peerDifferences(peer, configPeer)
+				if len(action.Differences) == 0 {
+					continue
+				}
+				action.Kind = ReconcileUpdatePeer
+				fields := make([]string, 0, len(action.Differences))
+				for _, difference := range action.Differences {
+					fields = append(fields, difference.Field)
+				}
+				action.Detail = strings.Join(fields, ", ") + " differ"
+				// Only a changed address may need a new IP pool
+				if addresses, _ := ip.ExtractIPsFromCIDRs(peer.ClientIP); containsAddress(addresses, address) {
+					address = ""
+				}
+			}
+			if (action.Kind == ReconcileImportPeer || action.Kind == ReconcileUpdatePeer) && address != "" {
+				if cidr, create, err := ip.PlannedIPPool(ctx, s, address); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: %s", interfaceName, err.Error()))
+				} else if create {
+					action.IPPool = cidr
+				}
+			}
+			action.ID = reconcileActionID(action.Kind, action.Interface, action.PublicKey)
+			actions = append(actions, action)
+		}
+	}
+
+	if !complete {
+		return actions, externalEdits, errs
+	}
+	for _, peer := range peers {
+		if peer.Status != model.WGPeerStatusActive || seen[peer.ClientPublicKey] {
+			continue
+		}
+		action := ReconcileAction{
+			Kind:      ReconcileDisablePeer,
+			Interface: wgOpts.Interface,
+			PublicKey: peer.ClientPublicKey,
+			PeerID:    peer.ID,
+			Detail:    "missing from the config files",
+		}
+		if peer.InterfaceID != "" {
+			iface, err := s.WGInterfaces().GetInterface(ctx, peer.InterfaceID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("peer %s: %s", peer.ID, err.Error()))
+				continue
+			}
+			action.Interface = iface.Name
+		}
+		action.configPath = configPaths[action.Interface]
+		action.ID = reconcileActionID(action.Kind, action.Interface, action.PublicKey)
+		actions = append(actions, action)
+	}
+	return actions, externalEdits, errs
+}
+
+// peerDifferences compares an active peer with its entry in a config file. Peers imported from a
+// config file keep their comment after the external prefix of their device name.
+func peerDifferences(peer *model.WGPeer, configPeer *wireguard.ServerPeerConfig) []DriftDifference {
+	var differences []DriftDifference
+	want := serverPeerConfig(peer)
+	if !samePrefixes(want.AllowedIPs, configPeer.AllowedIPs) {
+		differences = append(differences, DriftDifference{Field: "allowed_ips", Database: want.AllowedIPs, ConfigFile: configPeer.AllowedIPs})
+	}
+	if want.PersistentKeepalive != configPeer.PersistentKeepalive {
+		differences = append(differences, DriftDifference{
+			Field:      "persistent_keepalive",
+			Database:   strconv.Itoa(want.PersistentKeepalive),
+			ConfigFile: strconv.Itoa(configPeer.PersistentKeepalive),
+		})
+	}
+	if configPeer.Comment != "" && configPeer.Comment != peer.DeviceName && ip.ExternalDeviceNamePrefix+configPeer.Comment != peer.DeviceName {
+		differences = append(differences, DriftDifference{Field: "comment", Database: peer.DeviceName, ConfigFile: configPeer.Comment})
+	}
+	return differences
+}
+
+// samePrefixes reports whether two AllowedIPs lists route the same prefixes, in any order.
+func samePrefixes(a, b string) bool {
+	normalize := func(cidrs string) []string {
+		var prefixes []string
+		for _, part := range strings.Split(cidrs, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if prefix, err := netip.ParsePrefix(part); err == nil {
+				part = prefix.Masked().String()
+			} else if addr, err := netip.ParseAddr(part); err == nil {
+				part = netip.PrefixFrom(addr, addr.BitLen()).String()
+			}
+			prefixes = append(prefixes, part)
+		}
+		sort.Strings(prefixes)
+		return prefixes
+	}
+	return strings.Join(normalize(a), ",") == strings.Join(normalize(b), ",")
+}
+
+// applyReconcileActions resolves the actions in favor of the config files (import) or of the database
+// (enforce), applies the rewritten config files and reloads the rules derived from the peers.
+// It returns the number of actions applied and the errors of the others.
+func applyReconcileActions(ctx context.Context, s store.Factory, actions []ReconcileAction, policy string) (int, []error) {
+	wgOpts := config.Get().WireGuard
+	revisionCtx := wireguard.WithRevisionInfo(ctx, wireguard.RevisionInfo{Actor: "reconcile", Action: "reconcile " + policy})
+
+	applied := 0
+	var errs []error
+	changed := make(map[string]*wireguard.ServerConfigManager)
+	for _, action := range actions {
+		rewritesFile := policy == ReconcilePolicyEnforce || action.Kind == ReconcileRemoveRevokedPeer
+		if err := applyReconcileAction(ctx, revisionCtx, s, action, policy); err != nil {
+			errs = append(errs, errors.Wrapf(err, "%s %s", action.Kind, action.PublicKey))
+			continue
+		}
+		applied++
+		if rewritesFile {
+			changed[action.configPath] = wireguard.GetServerConfigManager(action.configPath, wgOpts.ApplyMethod)
+		}
+	}
+
+	for _, configManager := range changed {
+		if _, err := configManager.ApplyConfig(); err != nil {
+			klog.V(1).InfoS("failed to apply server config after reconcile", "interface", configManager.InterfaceName(), "error", err)
+		}
+	}
+	if applied > 0 {
+		syncPeerRules(ctx, s)
+	}
+	return applied, errs
+}
+
+// syncPeerRules reloads the rules derived from the peers after peers were added, removed or re-addressed.
+func syncPeerRules(ctx context.Context, s store.Factory) {
+	syncAllFirewalls(ctx, s)
+	newPortForwards(&service{store: s}).SyncPortForwards(ctx)
+	syncAllExitRouting(ctx, s)
+}
+
+// applyReconcileAction resolves one action. Revoked keys are removed from the config file with either policy.
+func applyReconcileAction(ctx, revisionCtx context.Context, s store.Factory, action ReconcileAction, policy string) error {
+	wgOpts := config.Get().WireGuard
+	if action.configPath == "" && (policy == ReconcilePolicyEnforce || action.Kind != ReconcileDisablePeer) {
+		return errors.WithCode(code.ErrWGServerConfigNotFound, "interface %s has no config file", action.Interface)
+	}
+	configManager := wireguard.GetServerConfigManager(action.configPath, wgOpts.ApplyMethod)
+
+	if action.Kind == ReconcileRemoveRevokedPeer {
+		return configManager.RemovePeer(revisionCtx, action.PublicKey)
+	}
+
+	if policy == ReconcilePolicyEnforce {
+		switch action.Kind {
+		case ReconcileImportPeer, ReconcileActivatePeer:
+			return configManager.RemovePeer(revisionCtx, action.PublicKey)
+		default:
+			peer, err := s.WGPeers().GetPeer(ctx, action.PeerID)
+			if err != nil {
+				return err
+			}
+			if action.Kind == ReconcileDisablePeer {
+				return configManager.AddPeer(revisionCtx, serverPeerConfig(peer))
+			}
+			return configManager.UpdatePeer(revisionCtx, action.PublicKey, serverPeerConfig(peer))
+		}
+	}
+
+	// Import: the config file wins
+	if action.Kind == ReconcileDisablePeer {
+		peer, err := s.WGPeers().GetPeer(ctx, action.PeerID)
+		if err != nil {
+			return err
+		}
+		peer.Status = model.WGPeerStatusDisabled
+		return s.WGPeers().UpdatePeer(ctx, peer)
+	}
+
+	configPeer, err := findConfigPeer(configManager, action.PublicKey)
+	if err != nil {
+		return err
+	}
+	iface, err := ip.FindOrCreateInterface(ctx, s, action.Interface)
+	if err != nil {
+		return err
+	}
+	switch action.Kind {
+	case ReconcileImportPeer:
+		return ip.CreateExternalPeer(ctx, s, configPeer, action.configPath, iface.ID, config.Get())
+	case ReconcileActivatePeer:
+		peer, err := s.WGPeers().GetPeer(ctx, action.PeerID)
+		if err != nil {
+			return err
+		}
+		peer.Status = model.WGPeerStatusActive
+		peer.InterfaceID = iface.ID
+		if err := s.WGPeers().UpdatePeer(ctx, peer); err != nil {
+			return err
+		}
+		_, err = ip.SyncPeerIPAllocation(ctx, s, configPeer, iface.ID)
+		return err
+	default:
+		return importPeerFields(ctx, s, action.PeerID, configPeer, iface.ID)
+	}
+}
+
+// importPeerFields updates a peer from its config file entry: the address and the routed subnets
+// from AllowedIPs, the keepalive, and the device name from the comment.
+func importPeerFields(ctx context.Context, s store.Factory, peerID string, configPeer *wireguard.ServerPeerConfig, interfaceID string) error {
+	// The address goes through the IP allocation, which may move the peer to another IP pool
+	if _, err := ip.SyncPeerIPAllocation(ctx, s, configPeer, interfaceID); err != nil {
+		return err
+	}
+	peer, err := s.WGPeers().GetPeer(ctx, peerID)
+	if err != nil {
+		return err
+	}
+
+	addresses, _ := parsePrefixes(peer.ClientIP)
+	prefixes, err := parsePrefixes(configPeer.AllowedIPs)
+	if err != nil {
+		return errors.WithCode(code.ErrValidation, "invalid AllowedIPs in config file: %s", configPeer.AllowedIPs)
+	}
+	var routedSubnets []string
+	for _, prefix := range prefixes {
+		if containsPrefix(addresses, prefix) || (peer.ExitNode && prefix.Bits() == 0) {
+			continue
+		}
+		routedSubnets = append(routedSubnets, prefix.String())
+	}
+	peer.RoutedSubnets = strings.Join(routedSubnets, ",")
+	peer.PersistentKeepalive = configPeer.PersistentKeepalive
+	if configPeer.Comment != "" && ip.ExternalDeviceNamePrefix+configPeer.Comment != peer.DeviceName {
+		peer.DeviceName = configPeer.Comment
+	}
+	return s.WGPeers().UpdatePeer(ctx, peer)
+}
+
+// findConfigPeer returns the entry of a peer in a config file.
+func findConfigPeer(configManager *wireguard.ServerConfigManager, publicKey string) (*wireguard.ServerPeerConfig, error) {
+	serverConfig, err := configManager.PeekServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	for _, configPeer := range serverConfig.Peers {
+		if configPeer.PublicKey == publicKey {
+			return configPeer, nil
+		}
+	}
+	return nil, errors.WithCode(code.ErrWGPeerNotFound, "peer not found in config file of %s", configManager.InterfaceName())
+}
+
+// recordExternalEdits records the config files edited outside of NexusPointWG in their history,
+// so that the edit is not reported again and can be diffed and rolled back.
+func recordExternalEdits(wgOpts *options.WireGuardOptions, interfaceNames []string) {
+	for _, name := range interfaceNames {
+		configManager := wireguard.GetServerConfigManager(wgOpts.InterfaceConfigPath(name), wgOpts.ApplyMethod)
+		if err := configManager.ImportExternalEdit(); err != nil {
+			klog.V(1).InfoS("failed to record external edit of server config", "interface", name, "error", err)
+		}
+	}
+}
+
+// containsAddress reports whether addresses holds address.
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPrefix reports whether prefixes holds prefix.
+func containsPrefix(prefixes []netip.Prefix, prefix netip.Prefix) bool {
+	for _, p := range prefixes {
+		if p == prefix {
+			return true
+		}
+	}
+	return false
+}
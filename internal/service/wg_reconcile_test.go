@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/wireguard"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/config"
+)
+
+// reconcileDrift is a difference between the database and the config file set up by a test.
+type reconcileDrift struct {
+	// peerID is the peer the drift is about, empty if it is not in the database
+	peerID    string
+	publicKey string
+}
+
+// databaseDrift adds an active peer to the database only, which is not an edit of the config file.
+func databaseDrift(t *testing.T, s store.Factory, pool *model.IPPool) reconcileDrift {
+	t.Helper()
+	_, publicKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	peer := &model.WGPeer{
+		ID:                  "peer-db-only",
+		UserID:              "user-1",
+		DeviceName:          "laptop",
+		ClientPublicKey:     publicKey,
+		ClientIP:            "100.100.100.20/32",
+		AllowedIPs:          pool.Routes,
+		PersistentKeepalive: 25,
+		Status:              model.WGPeerStatusActive,
+		IPPoolID:            pool.ID,
+	}
+	if err := s.WGPeers().CreatePeer(context.Background(), peer); err != nil {
+		t.Fatalf("CreatePeer() error = %v", err)
+	}
+	return reconcileDrift{peerID: peer.ID, publicKey: publicKey}
+}
+
+// externalPeerDrift adds a peer to the config file by hand.
+func externalPeerDrift(t *testing.T, s store.Factory, pool *model.IPPool) reconcileDrift {
+	t.Helper()
+	_, publicKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	appendServerConfigPeer(t, "watch", publicKey, "100.100.100.9/32")
+	return reconcileDrift{publicKey: publicKey}
+}
+
+// revokedPeerDrift revokes a peer and puts it back into the config file by hand.
+func revokedPeerDrift(t *testing.T, s store.Factory, pool *model.IPPool) reconcileDrift {
+	t.Helper()
+	peer := createTestPeer(t, s, pool, "lost phone")
+	if _, err := (&wgPeerSrv{store: s}).RevokePeer(context.Background(), peer, "lost", "admin"); err != nil {
+		t.Fatalf("RevokePeer() error = %v", err)
+	}
+	appendServerConfigPeer(t, "lost phone", peer.ClientPublicKey, peer.ClientIP)
+	return reconcileDrift{peerID: peer.ID, publicKey: peer.ClientPublicKey}
+}
+
+// reconcileRun is the expected outcome of a reconcile run.
+type reconcileRun struct {
+	applied int
+	pending []string
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		drift  func(t *testing.T, s store.Factory, pool *model.IPPool) reconcileDrift
+		// force is the trigger of the first run, the second run is never forced
+		force bool
+		first reconcileRun
+		// second is confirmed by the first run for the drift left pending by it
+		second reconcileRun
+		// wantPeer is the status of the drift peer in the database after both runs, empty if not there
+		wantPeer     string
+		wantInConfig bool
+	}{
+		{
+			name:         "import confirms database drift on the second run",
+			policy:       ReconcilePolicyImport,
+			drift:        databaseDrift,
+			first:        reconcileRun{pending: []string{ReconcileDisablePeer}},
+			second:       reconcileRun{applied: 1},
+			wantPeer:     model.WGPeerStatusDisabled,
+			wantInConfig: false,
+		},
+		{
+			name:         "enforce confirms database drift on the second run",
+			policy:       ReconcilePolicyEnforce,
+			drift:        databaseDrift,
+			first:        reconcileRun{pending: []string{ReconcileDisablePeer}},
+			second:       reconcileRun{applied: 1},
+			wantPeer:     model.WGPeerStatusActive,
+			wantInConfig: true,
+		},
+		{
+			name:         "report leaves database drift pending",
+			policy:       ReconcilePolicyReport,
+			drift:        databaseDrift,
+			first:        reconcileRun{pending: []string{ReconcileDisablePeer}},
+			second:       reconcileRun{pending: []string{ReconcileDisablePeer}},
+			wantPeer:     model.WGPeerStatusActive,
+			wantInConfig: false,
+		},
+		{
+			name:         "forced enforce resolves database drift on the first run",
+			policy:       ReconcilePolicyEnforce,
+			drift:        databaseDrift,
+			force:        true,
+			first:        reconcileRun{applied: 1},
+			wantPeer:     model.WGPeerStatusActive,
+			wantInConfig: true,
+		},
+		{
+			name:         "forced report leaves database drift pending",
+			policy:       ReconcilePolicyReport,
+			drift:        databaseDrift,
+			force:        true,
+			first:        reconcileRun{pending: []string{ReconcileDisablePeer}},
+			second:       reconcileRun{pending: []string{ReconcileDisablePeer}},
+			wantPeer:     model.WGPeerStatusActive,
+			wantInConfig: false,
+		},
+		{
+			name:         "import resolves an external edit on the first run",
+			policy:       ReconcilePolicyImport,
+			drift:        externalPeerDrift,
+			first:        reconcileRun{applied: 1},
+			wantPeer:     model.WGPeerStatusActive,
+			wantInConfig: true,
+		},
+		{
+			name:         "enforce resolves an external edit on the first run",
+			policy:       ReconcilePolicyEnforce,
+			drift:        externalPeerDrift,
+			first:        reconcileRun{applied: 1},
+			wantInConfig: false,
+		},
+		{
+			name:         "report leaves an external edit pending",
+			policy:       ReconcilePolicyReport,
+			drift:        externalPeerDrift,
+			first:        reconcileRun{pending: []string{ReconcileImportPeer}},
+			second:       reconcileRun{pending: []string{ReconcileImportPeer}},
+			wantInConfig: true,
+		},
+		{
+			name:         "import removes a revoked key",
+			policy:       ReconcilePolicyImport,
+			drift:        revokedPeerDrift,
+			first:        reconcileRun{applied: 1},
+			wantPeer:     model.WGPeerStatusDisabled,
+			wantInConfig: false,
+		},
+		{
+			name:         "enforce removes a revoked key",
+			policy:       ReconcilePolicyEnforce,
+			drift:        revokedPeerDrift,
+			first:        reconcileRun{applied: 1},
+			wantPeer:     model.WGPeerStatusDisabled,
+			wantInConfig: false,
+		},
+		{
+			name:         "report leaves a revoked key pending",
+			policy:       ReconcilePolicyReport,
+			drift:        revokedPeerDrift,
+			first:        reconcileRun{pending: []string{ReconcileRemoveRevokedPeer}},
+			second:       reconcileRun{pending: []string{ReconcileRemoveRevokedPeer}},
+			wantPeer:     model.WGPeerStatusDisabled,
+			wantInConfig: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, pool := newTestStore(t)
+			config.Get().WireGuard.ReconcilePolicy = tt.policy
+			// The pending actions of the previous test must not confirm anything
+			reconcileState.status = ReconcileStatus{}
+
+			// A peer created through the API records the config file in its history
+			createTestPeer(t, s, pool, "phone")
+			drift := tt.drift(t, s, pool)
+
+			reconciler := &reconcileSrv{store: s}
+			for i, want := range []reconcileRun{tt.first, tt.second} {
+				status, err := reconciler.Reconcile(ctx, "test", tt.force && i == 0)
+				if err != nil {
+					t.Fatalf("run %d: Reconcile() error = %v", i+1, err)
+				}
+				var pending []string
+				for _, action := range status.PendingActions {
+					pending = append(pending, action.Kind)
+					if action.PublicKey != drift.publicKey {
+						t.Errorf("run %d: pending %s of %s, want only the drift peer %s", i+1, action.Kind, action.PublicKey, drift.publicKey)
+					}
+				}
+				if status.Applied != want.applied || !reflect.DeepEqual(pending, want.pending) {
+					t.Errorf("run %d: applied %d, pending %v, want applied %d, pending %v (errors %v)", i+1, status.Applied, pending, want.applied, want.pending, status.Errors)
+				}
+			}
+
+			peer, err := s.WGPeers().GetPeerByPublicKey(ctx, drift.publicKey)
+			switch {
+			case tt.wantPeer == "":
+				if err == nil {
+					t.Errorf("drift peer %s was imported", peer.ID)
+				}
+			case err != nil:
+				t.Errorf("drift peer is not in the database, want it %s", tt.wantPeer)
+			case peer.Status != tt.wantPeer:
+				t.Errorf("drift peer is %s, want %s", peer.Status, tt.wantPeer)
+			case drift.peerID == "" && peer.DeviceName != ip.ExternalDeviceNamePrefix+"watch":
+				t.Errorf("imported peer is named %q, want it named after its comment", peer.DeviceName)
+			}
+			if inConfig := serverConfigKeys(t)[drift.publicKey]; inConfig != tt.wantInConfig {
+				t.Errorf("drift peer in server config = %v, want %v", inConfig, tt.wantInConfig)
+			}
+		})
+	}
+}
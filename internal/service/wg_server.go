@@ -3,8 +3,6 @@ package service
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/core/ip"
@@ -19,51 +17,50 @@ import (
 )
 
 // WGServerSrv defines the interface for WireGuard server configuration management.
+// interfaceID selects the WireGuard interface; empty means the default interface.
 type WGServerSrv interface {
-	GetServerConfig(ctx context.Context) (*wireguard.InterfaceConfig, string, string, string, error)
-	UpdateServerConfig(ctx context.Context, req *v1.UpdateServerConfigRequest) error
+	GetServerConfig(ctx context.Context, interfaceID string) (*wireguard.InterfaceConfig, string, string, string, error)
+	// UpdateServerConfig updates the server config of an interface and returns what applying it changed.
+	UpdateServerConfig(ctx context.Context, interfaceID string, req *v1.UpdateServerConfigRequest) (*wireguard.ApplyResult, error)
+	// ListConfigRevisions lists the stored server config revisions, newest first.
+	ListConfigRevisions(ctx context.Context, interfaceID string) ([]*wireguard.Revision, error)
+	// DiffConfigRevisions returns a unified diff between two revisions (to = 0 means the live config).
+	DiffConfigRevisions(ctx context.Context, interfaceID string, from, to int) (string, error)
+	// RollbackConfig restores a revision, applies it and re-syncs peers from the config files.
+	// It returns the new revision and what applying it changed.
+	RollbackConfig(ctx context.Context, interfaceID string, revision int) (*wireguard.Revision, *wireguard.ApplyResult, error)
+	// GetNATSettings gets the structured NAT and forwarding settings of an interface.
+	GetNATSettings(ctx context.Context, interfaceID string) (*wireguard.NATSettings, error)
+	// SyncNAT writes and loads the NAT scripts of all interfaces.
+	SyncNAT(ctx context.Context)
 }
 
 type wgServerSrv struct {
-	service       *service
-	store         store.Factory
-	configManager *wireguard.ServerConfigManager
+	service *service
+	store   store.Factory
 }
 
 // WGServerSrv if implemented, then wgServerSrv implements WGServerSrv interface.
 var _ WGServerSrv = (*wgServerSrv)(nil)
 
 func newWGServer(s *service) *wgServerSrv {
-	cfg := config.Get()
-	if cfg == nil || cfg.WireGuard == nil {
-		klog.V(1).InfoS("WireGuard config not available, config manager will be nil")
-		return &wgServerSrv{
-			service:       s,
-			store:         s.store,
-			configManager: nil,
-		}
-	}
-
-	wgOpts := cfg.WireGuard
-	configPath := wgOpts.ServerConfigPath()
-	configManager := wireguard.NewServerConfigManager(configPath, wgOpts.ApplyMethod)
-
 	return &wgServerSrv{
-		service:       s,
-		store:         s.store,
-		configManager: configManager,
+		service: s,
+		store:   s.store,
 	}
 }
 
-// GetServerConfig gets the server configuration.
+// GetServerConfig gets the server configuration of an interface.
+// PostUp and PostDown hold only the raw commands, without the command running the NAT script.
 // Returns: InterfaceConfig, PublicKey, ServerIP, DNS, error
-func (w *wgServerSrv) GetServerConfig(ctx context.Context) (*wireguard.InterfaceConfig, string, string, string, error) {
-	if w.configManager == nil {
-		return nil, "", "", "", errors.WithCode(code.ErrWGConfigNotInitialized, "config manager not initialized")
+func (w *wgServerSrv) GetServerConfig(ctx context.Context, interfaceID string) (*wireguard.InterfaceConfig, string, string, string, error) {
+	configManager, err := interfaceConfigManager(ctx, w.store, interfaceID)
+	if err != nil {
+		return nil, "", "", "", err
 	}
 
 	// Read server config
-	serverConfig, err := w.configManager.ReadServerConfig()
+	serverConfig, err := configManager.ReadServerConfig()
 	if err != nil {
 		return nil, "", "", "", errors.Wrap(err, "failed to read server config")
 	}
@@ -75,7 +72,7 @@ func (w *wgServerSrv) GetServerConfig(ctx context.Context) (*wireguard.Interface
 	// Get server public key
 	var publicKey string
 	if serverConfig.Interface.PrivateKey != "" {
-		publicKey, err = w.configManager.GetServerPublicKey()
+		publicKey, err = configManager.GetServerPublicKey()
 		if err != nil {
 			klog.V(1).InfoS("failed to get server public key", "error", err)
 			// Continue without public key
@@ -101,23 +98,33 @@ func (w *wgServerSrv) GetServerConfig(ctx context.Context) (*wireguard.Interface
 		dns = cfg.WireGuard.DNS
 	}
 
-	return serverConfig.Interface, publicKey, serverIP, dns, nil
+	interfaceConfig := *serverConfig.Interface
+	natUp, natDown := configManager.NATHooks()
+	interfaceConfig.PostUp = wireguard.StripHook(interfaceConfig.PostUp, natUp)
+	interfaceConfig.PostDown = wireguard.StripHook(interfaceConfig.PostDown, natDown)
+
+	return &interfaceConfig, publicKey, serverIP, dns, nil
 }
 
-// UpdateServerConfig updates the server configuration.
-func (w *wgServerSrv) UpdateServerConfig(ctx context.Context, req *v1.UpdateServerConfigRequest) error {
-	if w.configManager == nil {
-		return errors.WithCode(code.ErrWGConfigNotInitialized, "config manager not initialized")
+// UpdateServerConfig updates the server configuration of an interface.
+func (w *wgServerSrv) UpdateServerConfig(ctx context.Context, interfaceID string, req *v1.UpdateServerConfigRequest) (*wireguard.ApplyResult, error) {
+	iface, err := resolveInterface(ctx, w.store, interfaceID)
+	if err != nil {
+		return nil, err
+	}
+	configManager, err := interfaceConfigManager(ctx, w.store, iface.ID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Read current config
-	serverConfig, err := w.configManager.ReadServerConfig()
+	serverConfig, err := configManager.ReadServerConfig()
 	if err != nil {
-		return errors.Wrap(err, "failed to read server config")
+		return nil, errors.Wrap(err, "failed to read server config")
 	}
 
 	if serverConfig.Interface == nil {
-		return errors.WithCode(code.ErrWGServerConfigNotFound, "server interface config not found")
+		return nil, errors.WithCode(code.ErrWGServerConfigNotFound, "server interface config not found")
 	}
 
 	// Save old config for comparison
@@ -132,6 +139,7 @@ func (w *wgServerSrv) UpdateServerConfig(ctx context.Context, req *v1.UpdateServ
 		PreDown:    serverConfig.Interface.PreDown,
 		PostDown:   serverConfig.Interface.PostDown,
 		SaveConfig: serverConfig.Interface.SaveConfig,
+		Table:      serverConfig.Interface.Table,
 	}
 
 	// Merge updates (only update provided fields)
@@ -139,12 +147,18 @@ func (w *wgServerSrv) UpdateServerConfig(ctx context.Context, req *v1.UpdateServ
 		serverConfig.Interface.Address = *req.Address
 	}
 	if req.ListenPort != nil {
+		// The interface cannot listen on a UDP port forwarded to a peer
+		if *req.ListenPort != oldConfig.ListenPort {
+			if err := checkListenPortForwarded(ctx, w.store, *req.ListenPort); err != nil {
+				return nil, err
+			}
+		}
 		serverConfig.Interface.ListenPort = *req.ListenPort
 	}
 	if req.PrivateKey != nil {
 		// Validate private key
 		if err := wireguard.ValidatePrivateKey(*req.PrivateKey); err != nil {
-			return errors.Wrap(err, "invalid private key")
+			return nil, errors.Wrap(err, "invalid private key")
 		}
 		serverConfig.Interface.PrivateKey = *req.PrivateKey
 		// Clear public key cache when private key changes
@@ -153,11 +167,44 @@ func (w *wgServerSrv) UpdateServerConfig(ctx context.Context, req *v1.UpdateServ
 	if req.MTU != nil {
 		serverConfig.Interface.MTU = *req.MTU
 	}
+
+	// Structured NAT settings are kept on the interface and run from PostUp/PostDown ahead of the raw commands
+	natSettings := interfaceNATSettings(iface)
+	if req.EgressInterface != nil {
+		natSettings.EgressInterface = *req.EgressInterface
+	}
+	if req.Masquerade != nil {
+		natSettings.Masquerade = *req.Masquerade
+	}
+	if req.IPForwarding != nil {
+		natSettings.IPForwarding = *req.IPForwarding
+	}
+	if req.MSSClamping != nil {
+		natSettings.MSSClamping = *req.MSSClamping
+	}
+	if err := natSettings.Validate(); err != nil {
+		return nil, err
+	}
+	natUp, natDown := configManager.NATHooks()
+	postUp := wireguard.StripHook(serverConfig.Interface.PostUp, natUp)
+	postDown := wireguard.StripHook(serverConfig.Interface.PostDown, natDown)
 	if req.PostUp != nil {
-		serverConfig.Interface.PostUp = *req.PostUp
+		postUp = *req.PostUp
 	}
-		if req.PostDown != nil {
-		serverConfig.Interface.PostDown = *req.PostDown
+	if req.PostDown != nil {
+		postDown = *req.PostDown
+	}
+	serverConfig.Interface.PostUp = wireguard.MergeHook(postUp, natUp, natSettings.Enabled())
+	serverConfig.Interface.PostDown = wireguard.MergeHook(postDown, natDown, natSettings.Enabled())
+
+	if natSettings != interfaceNATSettings(iface) {
+		iface.EgressInterface = natSettings.EgressInterface
+		iface.Masquerade = natSettings.Masquerade
+		iface.IPForwarding = natSettings.IPForwarding
+		iface.MSSClamping = natSettings.MSSClamping
+		if err := w.store.WGInterfaces().UpdateInterface(ctx, iface); err != nil {
+			return nil, err
+		}
 	}
 
 	// Handle ServerIP and DNS update
@@ -191,15 +238,21 @@ func (w *wgServerSrv) UpdateServerConfig(ctx context.Context, req *v1.UpdateServ
 	}
 
 	// Write updated config
-	if err := w.configManager.WriteServerConfig(serverConfig); err != nil {
-		return errors.Wrap(err, "failed to write server config")
+	if err := configManager.WriteServerConfig(ctx, serverConfig); err != nil {
+		return nil, errors.Wrap(err, "failed to write server config")
 	}
 
-	// Apply config (reload WireGuard interface)
-	if err := w.configManager.ApplyConfig(); err != nil {
+	// Apply config to the running WireGuard interface
+	applied, err := configManager.ApplyConfig()
+	if err != nil {
 		klog.V(1).InfoS("failed to apply server config", "error", err)
 		// Continue anyway, config is written but not applied
 	}
+	// Load the NAT rules now, PostUp only runs when the interface comes up
+	if err := configManager.ApplyNAT(natBackend(), serverConfig.Interface.Address, natSettings); err != nil {
+		klog.V(1).InfoS("failed to apply NAT script", "error", err)
+		// Continue anyway, the script runs from PostUp
+	}
 
 	// Detect changes in global config
 	serverIPChanged := cfg != nil && cfg.WireGuard != nil && cfg.WireGuard.ServerIP != oldServerIP
@@ -208,16 +261,17 @@ func (w *wgServerSrv) UpdateServerConfig(ctx context.Context, req *v1.UpdateServ
 	// For now, we'll only handle ServerIP and DNS changes
 
 	// Sync client configs if needed
-	if err := w.syncClientConfigs(ctx, oldConfig, serverConfig.Interface, serverIPChanged, dnsChanged, false); err != nil {
+	if err := w.syncClientConfigs(ctx, configManager, iface.ID, oldConfig, serverConfig.Interface, serverIPChanged, dnsChanged, false); err != nil {
 		klog.V(1).InfoS("failed to sync client configs", "error", err)
 		// Continue anyway, server config is updated
 	}
 
-	return nil
+	return applied, nil
 }
 
-// syncClientConfigs synchronizes all client configurations when server config changes.
-func (w *wgServerSrv) syncClientConfigs(ctx context.Context, oldConfig, newConfig *wireguard.InterfaceConfig, serverIPChanged, dnsChanged, endpointChanged bool) error {
+// syncClientConfigs synchronizes the client configurations of an interface when its server config changes.
+// ServerIP and DNS are global, so their changes are propagated to peers and IP pools of all interfaces.
+func (w *wgServerSrv) syncClientConfigs(ctx context.Context, configManager *wireguard.ServerConfigManager, interfaceID string, oldConfig, newConfig *wireguard.InterfaceConfig, serverIPChanged, dnsChanged, endpointChanged bool) error {
 	// Check what changed
 	listenPortChanged := oldConfig.ListenPort != newConfig.ListenPort
 	mtuChanged := oldConfig.MTU != newConfig.MTU
@@ -238,10 +292,10 @@ func (w *wgServerSrv) syncClientConfigs(ctx context.Context, oldConfig, newConfi
 		}
 	}
 
-	// Get all active peers
-	peers, _, err := w.store.WGPeers().ListPeers(ctx, store.WGPeerListOptions{
-		Status: model.WGPeerStatusActive,
-		Limit:  10000, // Get all active peers
+	// Get all active peers of the interface
+	peers, err := listAllPeers(ctx, w.store, store.WGPeerListOptions{
+		Status:      model.WGPeerStatusActive,
+		InterfaceID: interfaceID,
 	})
 	if err != nil {
 		return errors.Wrap(err, "failed to list peers")
@@ -263,7 +317,7 @@ func (w *wgServerSrv) syncClientConfigs(ctx context.Context, oldConfig, newConfi
 
 	// Update each peer's client config
 	for _, peer := range peers {
-		if err := w.updatePeerClientConfig(ctx, peer, newConfig, newPublicKey, endpointIP, listenPortChanged, mtuChanged, privateKeyChanged); err != nil {
+		if err := w.updatePeerClientConfig(ctx, configManager, peer, newConfig, newPublicKey, endpointIP, listenPortChanged, mtuChanged, privateKeyChanged); err != nil {
 			klog.V(1).InfoS("failed to update peer client config", "peerID", peer.ID, "error", err)
 			// Continue with other peers
 		}
@@ -303,7 +357,7 @@ func (w *wgServerSrv) syncClientConfigs(ctx context.Context, oldConfig, newConfi
 }
 
 // updatePeerClientConfig updates a single peer's client configuration.
-func (w *wgServerSrv) updatePeerClientConfig(ctx context.Context, peer *model.WGPeer, newConfig *wireguard.InterfaceConfig, newPublicKey, endpointIP string, listenPortChanged, mtuChanged, privateKeyChanged bool) error {
+func (w *wgServerSrv) updatePeerClientConfig(ctx context.Context, configManager *wireguard.ServerConfigManager, peer *model.WGPeer, newConfig *wireguard.InterfaceConfig, newPublicKey, endpointIP string, listenPortChanged, mtuChanged, privateKeyChanged bool) error {
 	cfg := config.Get()
 	if cfg == nil || cfg.WireGuard == nil {
 		return errors.WithCode(code.ErrWGConfigNotInitialized, "WireGuard config not initialized")
@@ -317,8 +371,8 @@ func (w *wgServerSrv) updatePeerClientConfig(ctx context.Context, peer *model.WG
 		serverPublicKey = newPublicKey
 	} else {
 		var err error
-		if w.configManager != nil {
-			serverPublicKey, err = w.configManager.GetServerPublicKey()
+		if configManager != nil {
+			serverPublicKey, err = configManager.GetServerPublicKey()
 			if err != nil {
 				return errors.Wrap(err, "failed to get server public key")
 			}
@@ -389,6 +443,9 @@ func (w *wgServerSrv) updatePeerClientConfig(ctx context.Context, peer *model.WG
 		}
 	}
 
+	// Route to the subnets behind the site-to-site peers of the interface
+	allowedIPs = clientAllowedIPs(ctx, w.store, peer, allowedIPs)
+
 	// Generate client config
 	clientConfig := &wireguard.ClientConfig{
 		PrivateKey:          peer.ClientPrivateKey,
@@ -397,22 +454,16 @@ func (w *wgServerSrv) updatePeerClientConfig(ctx context.Context, peer *model.WG
 		MTU:                 mtu,
 		Endpoint:            endpoint,
 		PublicKey:           serverPublicKey,
+		PresharedKey:        peer.PresharedKey,
 		AllowedIPs:          allowedIPs,
 		PersistentKeepalive: peer.PersistentKeepalive,
 	}
 
 	configContent := wireguard.GenerateClientConfig(clientConfig)
 
-	// Save to file
-	userDir := wgOpts.ResolvedUserDir()
-	if err := os.MkdirAll(userDir, 0755); err != nil {
-		return errors.WithCode(code.ErrWGUserDirCreateFailed, "failed to create user directory: %s", err.Error())
-	}
-
-	// Use peer ID as filename
-	configPath := filepath.Join(userDir, peer.ID+".conf")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		return errors.WithCode(code.ErrWGConfigWriteFailed, "failed to write client config file: %s", err.Error())
+	configPath, err := saveClientConfig(wgOpts, peer.ID, configContent)
+	if err != nil {
+		return err
 	}
 
 	// If endpoint was updated, also update database
@@ -427,3 +478,50 @@ func (w *wgServerSrv) updatePeerClientConfig(ctx context.Context, peer *model.WG
 	klog.V(2).InfoS("updated client config file", "peerID", peer.ID, "path", configPath, "listenPortChanged", listenPortChanged, "mtuChanged", mtuChanged, "privateKeyChanged", privateKeyChanged)
 	return nil
 }
+
+func (w *wgServerSrv) ListConfigRevisions(ctx context.Context, interfaceID string) ([]*wireguard.Revision, error) {
+	configManager, err := interfaceConfigManager(ctx, w.store, interfaceID)
+	if err != nil {
+		return nil, err
+	}
+	return configManager.ListRevisions()
+}
+
+func (w *wgServerSrv) DiffConfigRevisions(ctx context.Context, interfaceID string, from, to int) (string, error) {
+	configManager, err := interfaceConfigManager(ctx, w.store, interfaceID)
+	if err != nil {
+		return "", err
+	}
+	return configManager.DiffRevisions(from, to)
+}
+
+func (w *wgServerSrv) RollbackConfig(ctx context.Context, interfaceID string, revision int) (*wireguard.Revision, *wireguard.ApplyResult, error) {
+	configManager, err := interfaceConfigManager(ctx, w.store, interfaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A history failure still restores the file, which then has to be applied and synced like any rollback
+	newRevision, err := configManager.RollbackToRevision(ctx, revision)
+	if err != nil && errors.ParseCoder(err).Code() != code.ErrWGConfigHistoryFailed {
+		return nil, nil, err
+	}
+	historyErr := err
+
+	// Apply config to the running WireGuard interface
+	applied, err := configManager.ApplyConfig()
+	if err != nil {
+		klog.V(1).InfoS("failed to apply server config after rollback", "revision", revision, "error", err)
+		// Continue anyway, config is written but not applied
+	}
+
+	// The restored file may add or remove peers, bring the database in line with it
+	if err := ip.SyncAllFromConfigFiles(ctx, w.store); err != nil {
+		klog.V(1).InfoS("failed to sync peers after rollback", "revision", revision, "error", err)
+	}
+
+	if historyErr != nil {
+		return nil, applied, historyErr
+	}
+	return newRevision, applied, nil
+}
@@ -17,9 +17,17 @@ type IPAllocationStore interface {
 	// GetIPAllocationByIPAddress retrieves an IP allocation by IP address.
 	GetIPAllocationByIPAddress(ctx context.Context, ipAddress string) (*model.IPAllocation, error)
 
+	// GetReleasedIPAllocationByIPAddress retrieves a released IP allocation by IP address.
+	// The IP address column is unique, so a released address is reused by re-activating its record.
+	GetReleasedIPAllocationByIPAddress(ctx context.Context, ipAddress string) (*model.IPAllocation, error)
+
 	// GetIPAllocationByPeerID retrieves an IP allocation by peer ID.
+	// For dual-stack peers this returns the first allocation; use GetIPAllocationsByPeerID to get all.
 	GetIPAllocationByPeerID(ctx context.Context, peerID string) (*model.IPAllocation, error)
 
+	// GetIPAllocationsByPeerID retrieves all allocated IP allocations of a peer (one per address family).
+	GetIPAllocationsByPeerID(ctx context.Context, peerID string) ([]*model.IPAllocation, error)
+
 	// UpdateIPAllocation updates an existing IP allocation.
 	UpdateIPAllocation(ctx context.Context, allocation *model.IPAllocation) error
 
@@ -34,6 +42,10 @@ type IPAllocationStore interface {
 
 	// GetAllocatedIPsByPoolID retrieves all allocated IP addresses for a given IP pool.
 	GetAllocatedIPsByPoolID(ctx context.Context, poolID string) ([]string, error)
+
+	// GetAllocationsByPoolID retrieves all allocated IP allocations of a given IP pool,
+	// including the prefix length of delegated prefixes.
+	GetAllocationsByPoolID(ctx context.Context, poolID string) ([]*model.IPAllocation, error)
 }
 
 // IPAllocationListOptions defines options for listing IP allocations.
@@ -35,7 +35,8 @@ type IPPoolStore interface {
 
 // IPPoolListOptions defines options for listing IP pools.
 type IPPoolListOptions struct {
-	Status string
-	Offset int
-	Limit  int
+	Status      string
+	InterfaceID string
+	Offset      int
+	Limit       int
 }
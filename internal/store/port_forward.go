@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+)
+
+// PortForwardStore defines the interface for port forward data access.
+type PortForwardStore interface {
+	// CreatePortForward creates a new port forward.
+	// Returns ErrWGPortForwardConflict if the public port is already forwarded.
+	CreatePortForward(ctx context.Context, forward *model.PortForward) error
+
+	// GetPortForward retrieves a port forward by ID.
+	GetPortForward(ctx context.Context, id string) (*model.PortForward, error)
+
+	// UpdatePortForward updates an existing port forward.
+	UpdatePortForward(ctx context.Context, forward *model.PortForward) error
+
+	// DeletePortForward deletes a port forward by ID.
+	DeletePortForward(ctx context.Context, id string) error
+
+	// DeletePortForwardsByPeerID deletes all port forwards to a peer.
+	DeletePortForwardsByPeerID(ctx context.Context, peerID string) error
+
+	// ListPortForwards lists port forwards with optional filters, ordered by protocol and public port.
+	ListPortForwards(ctx context.Context, opt PortForwardListOptions) ([]*model.PortForward, error)
+}
+
+// PortForwardListOptions defines options for listing port forwards.
+type PortForwardListOptions struct {
+	PeerID string
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+)
+
+// RevocationStore defines the interface for revoked peer key data access.
+type RevocationStore interface {
+	// CreateRevokedKey records a revoked public key.
+	CreateRevokedKey(ctx context.Context, key *model.RevokedKey) error
+
+	// IsKeyRevoked reports whether a public key has been revoked.
+	IsKeyRevoked(ctx context.Context, publicKey string) (bool, error)
+
+	// ListRevokedKeys lists revoked keys with optional filters and pagination.
+	ListRevokedKeys(ctx context.Context, opt RevokedKeyListOptions) ([]*model.RevokedKey, int64, error)
+}
+
+// RevokedKeyListOptions defines options for listing revoked keys.
+type RevokedKeyListOptions struct {
+	UserID string
+	PeerID string
+	Offset int
+	Limit  int
+}
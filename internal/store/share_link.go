@@ -0,0 +1,27 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+)
+
+// ShareLinkStore defines the interface for config share link data access.
+type ShareLinkStore interface {
+	CreateShareLink(ctx context.Context, link *model.ShareLink) error
+	GetShareLink(ctx context.Context, id string) (*model.ShareLink, error)
+
+	// RevokeShareLink sets the revocation time of a link, leaving its other columns untouched.
+	RevokeShareLink(ctx context.Context, id string, revokedAt time.Time) error
+
+	// ListShareLinks lists the share links of a peer, newest first.
+	ListShareLinks(ctx context.Context, peerID string) ([]*model.ShareLink, error)
+
+	// UseShareLink counts a use of a link and records it, if the link is still usable at now.
+	// Returns ErrWGShareLinkInvalid if it is not.
+	UseShareLink(ctx context.Context, id string, use *model.ShareLinkUse, now time.Time) error
+
+	// ListShareLinkUses lists the uses of a link, oldest first.
+	ListShareLinkUses(ctx context.Context, linkID string) ([]*model.ShareLinkUse, error)
+}
@@ -55,6 +55,18 @@ func (i *ipAllocations) GetIPAllocationByIPAddress(ctx context.Context, ipAddres
 	return &allocation, nil
 }
 
+func (i *ipAllocations) GetReleasedIPAllocationByIPAddress(ctx context.Context, ipAddress string) (*model.IPAllocation, error) {
+	var allocation model.IPAllocation
+	err := i.db.WithContext(ctx).Where("ip_address = ? AND status = ?", ipAddress, model.IPAllocationStatusReleased).First(&allocation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found, not an error
+		}
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return &allocation, nil
+}
+
 func (i *ipAllocations) GetIPAllocationByPeerID(ctx context.Context, peerID string) (*model.IPAllocation, error) {
 	var allocation model.IPAllocation
 	err := i.db.WithContext(ctx).Where("peer_id = ? AND status = ?", peerID, model.IPAllocationStatusAllocated).First(&allocation).Error
@@ -67,6 +79,15 @@ func (i *ipAllocations) GetIPAllocationByPeerID(ctx context.Context, peerID stri
 	return &allocation, nil
 }
 
+func (i *ipAllocations) GetIPAllocationsByPeerID(ctx context.Context, peerID string) ([]*model.IPAllocation, error) {
+	var allocations []*model.IPAllocation
+	err := i.db.WithContext(ctx).Where("peer_id = ? AND status = ?", peerID, model.IPAllocationStatusAllocated).Order("created_at ASC").Find(&allocations).Error
+	if err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return allocations, nil
+}
+
 func (i *ipAllocations) UpdateIPAllocation(ctx context.Context, allocation *model.IPAllocation) error {
 	err := i.db.WithContext(ctx).Save(allocation).Error
 	if err != nil {
@@ -152,3 +173,14 @@ func (i *ipAllocations) GetAllocatedIPsByPoolID(ctx context.Context, poolID stri
 	}
 	return ips, nil
 }
+
+func (i *ipAllocations) GetAllocationsByPoolID(ctx context.Context, poolID string) ([]*model.IPAllocation, error) {
+	var allocations []*model.IPAllocation
+	err := i.db.WithContext(ctx).
+		Where("ip_pool_id = ? AND status = ?", poolID, model.IPAllocationStatusAllocated).
+		Find(&allocations).Error
+	if err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return allocations, nil
+}
@@ -96,6 +96,9 @@ func (i *ipPools) ListIPPools(ctx context.Context, opt store.IPPoolListOptions)
 	if strings.TrimSpace(opt.Status) != "" {
 		dbq = dbq.Where("status = ?", opt.Status)
 	}
+	if strings.TrimSpace(opt.InterfaceID) != "" {
+		dbq = dbq.Where("interface_id = ?", opt.InterfaceID)
+	}
 
 	if err := dbq.Count(&total).Error; err != nil {
 		return nil, 0, errors.WithCode(code.ErrDatabase, "%s", err.Error())
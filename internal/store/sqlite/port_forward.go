@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+)
+
+type portForwards struct {
+	db *gorm.DB
+}
+
+func newPortForwards(ds *datastore) *portForwards {
+	return &portForwards{ds.db}
+}
+
+func (p *portForwards) CreatePortForward(ctx context.Context, forward *model.PortForward) error {
+	if err := p.db.WithContext(ctx).Create(forward).Error; err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithCode(code.ErrWGPortForwardConflict, "%s port %d is already forwarded", forward.Protocol, forward.PublicPort)
+		}
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (p *portForwards) GetPortForward(ctx context.Context, id string) (*model.PortForward, error) {
+	var forward model.PortForward
+	err := p.db.WithContext(ctx).Where("id = ?", id).First(&forward).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrWGPortForwardNotFound, "%s", err.Error())
+		}
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return &forward, nil
+}
+
+func (p *portForwards) UpdatePortForward(ctx context.Context, forward *model.PortForward) error {
+	if err := p.db.WithContext(ctx).Save(forward).Error; err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithCode(code.ErrWGPortForwardConflict, "%s port %d is already forwarded", forward.Protocol, forward.PublicPort)
+		}
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (p *portForwards) DeletePortForward(ctx context.Context, id string) error {
+	if err := p.db.WithContext(ctx).Where("id = ?", id).Delete(&model.PortForward{}).Error; err != nil {
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (p *portForwards) DeletePortForwardsByPeerID(ctx context.Context, peerID string) error {
+	if err := p.db.WithContext(ctx).Where("peer_id = ?", peerID).Delete(&model.PortForward{}).Error; err != nil {
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (p *portForwards) ListPortForwards(ctx context.Context, opt store.PortForwardListOptions) ([]*model.PortForward, error) {
+	dbq := p.db.WithContext(ctx).Model(&model.PortForward{})
+	if opt.PeerID != "" {
+		dbq = dbq.Where("peer_id = ?", opt.PeerID)
+	}
+
+	var forwards []*model.PortForward
+	if err := dbq.Order("protocol ASC, public_port ASC").Find(&forwards).Error; err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return forwards, nil
+}
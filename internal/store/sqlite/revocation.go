@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+)
+
+type revocations struct {
+	db *gorm.DB
+}
+
+func newRevocations(ds *datastore) *revocations {
+	return &revocations{ds.db}
+}
+
+func (r *revocations) CreateRevokedKey(ctx context.Context, key *model.RevokedKey) error {
+	err := r.db.WithContext(ctx).Create(key).Error
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithCode(code.ErrWGPeerRevoked, "public key is already revoked")
+		}
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (r *revocations) IsKeyRevoked(ctx context.Context, publicKey string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.RevokedKey{}).Where("public_key = ?", publicKey).Count(&count).Error; err != nil {
+		return false, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return count > 0, nil
+}
+
+func (r *revocations) ListRevokedKeys(ctx context.Context, opt store.RevokedKeyListOptions) ([]*model.RevokedKey, int64, error) {
+	var (
+		keys  []*model.RevokedKey
+		total int64
+	)
+
+	dbq := r.db.WithContext(ctx).Model(&model.RevokedKey{})
+	if strings.TrimSpace(opt.UserID) != "" {
+		dbq = dbq.Where("user_id = ?", opt.UserID)
+	}
+	if strings.TrimSpace(opt.PeerID) != "" {
+		dbq = dbq.Where("peer_id = ?", opt.PeerID)
+	}
+
+	if err := dbq.Count(&total).Error; err != nil {
+		return nil, 0, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	offset := opt.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	if err := dbq.Order("created_at DESC").Offset(offset).Limit(limit).Find(&keys).Error; err != nil {
+		return nil, 0, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return keys, total, nil
+}
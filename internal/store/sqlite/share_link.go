@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/errors"
+)
+
+type shareLinks struct {
+	db *gorm.DB
+}
+
+func newShareLinks(ds *datastore) *shareLinks {
+	return &shareLinks{ds.db}
+}
+
+func (s *shareLinks) CreateShareLink(ctx context.Context, link *model.ShareLink) error {
+	if err := s.db.WithContext(ctx).Create(link).Error; err != nil {
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (s *shareLinks) GetShareLink(ctx context.Context, id string) (*model.ShareLink, error) {
+	var link model.ShareLink
+	err := s.db.WithContext(ctx).Where("id = ?", id).First(&link).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrWGShareLinkNotFound, "%s", err.Error())
+		}
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return &link, nil
+}
+
+func (s *shareLinks) RevokeShareLink(ctx context.Context, id string, revokedAt time.Time) error {
+	// Only set revoked_at, a concurrent download may be counting a use of the link
+	err := s.db.WithContext(ctx).Model(&model.ShareLink{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at": revokedAt,
+			"updated_at": revokedAt,
+		}).Error
+	if err != nil {
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (s *shareLinks) ListShareLinks(ctx context.Context, peerID string) ([]*model.ShareLink, error) {
+	var links []*model.ShareLink
+	if err := s.db.WithContext(ctx).Where("peer_id = ?", peerID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return links, nil
+}
+
+func (s *shareLinks) UseShareLink(ctx context.Context, id string, use *model.ShareLinkUse, now time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Count the use only if the link is still usable, so concurrent downloads cannot exceed MaxUses
+		result := tx.Model(&model.ShareLink{}).
+			Where("id = ? AND revoked_at IS NULL AND expires_at > ? AND use_count < max_uses", id, now).
+			Updates(map[string]interface{}{
+				"use_count":  gorm.Expr("use_count + 1"),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", result.Error.Error())
+		}
+		if result.RowsAffected == 0 {
+			return errors.WithCode(code.ErrWGShareLinkInvalid, "%s", code.Message(code.ErrWGShareLinkInvalid))
+		}
+		if err := tx.Create(use).Error; err != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		return nil
+	})
+}
+
+func (s *shareLinks) ListShareLinkUses(ctx context.Context, linkID string) ([]*model.ShareLinkUse, error) {
+	var uses []*model.ShareLinkUse
+	if err := s.db.WithContext(ctx).Where("link_id = ?", linkID).Order("created_at ASC").Find(&uses).Error; err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return uses, nil
+}
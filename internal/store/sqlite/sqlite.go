@@ -39,6 +39,26 @@ func (ds *datastore) IPAllocations() store.IPAllocationStore {
 	return newIPAllocations(ds)
 }
 
+func (ds *datastore) WGInterfaces() store.WGInterfaceStore {
+	return newWGInterfaces(ds)
+}
+
+func (ds *datastore) Traffic() store.TrafficStore {
+	return newTraffic(ds)
+}
+
+func (ds *datastore) Revocations() store.RevocationStore {
+	return newRevocations(ds)
+}
+
+func (ds *datastore) ShareLinks() store.ShareLinkStore {
+	return newShareLinks(ds)
+}
+
+func (ds *datastore) PortForwards() store.PortForwardStore {
+	return newPortForwards(ds)
+}
+
 func (ds *datastore) Close() error {
 	sqlDB, err := ds.db.DB()
 	if err != nil {
@@ -60,71 +80,21 @@ func GetSqliteFactoryOr(opts *options.SqliteOptions) (store.Factory, error) {
 	}
 
 	var err error
-	var dbIns *gorm.DB
 	once.Do(func() {
-		// Ensure the parent directory of the database file exists
-		// SQLite will not create parent directories automatically
-		dbPath := opts.DataSourceName
-
-		// Get the absolute path to handle both relative and absolute paths
-		absPath, absErr := filepath.Abs(dbPath)
-		if absErr != nil {
-			// If we can't get absolute path, use the original path
-			absPath = dbPath
-		}
-
-		// Get the parent directory
-		parentDir := filepath.Dir(absPath)
-
-		// Create parent directory if it doesn't exist
-		if err = os.MkdirAll(parentDir, 0755); err != nil {
-			klog.V(1).InfoS("failed to create database parent directory", "parentDir", parentDir, "dataSource", opts.DataSourceName, "error", err)
-			err = errors.Wrap(err, "failed to create database parent directory")
-			return
-		}
-
-		klog.V(2).InfoS("database parent directory ensured", "parentDir", parentDir, "dataSource", opts.DataSourceName)
-
-		dbOpts := &db.Options{
-			DataSourceName: opts.DataSourceName,
-		}
-		dbIns, err = db.New(dbOpts)
+		var ds *datastore
+		ds, err = newDatastore(opts)
 		if err != nil {
-			// Preserve the original error with full context
-			klog.V(1).InfoS("failed to create sqlite database", "dataSource", opts.DataSourceName, "error", err)
-			err = errors.Wrap(err, "failed to create sqlite db with data source")
-			return
-		}
-
-		// Handle custom migrations for existing tables before AutoMigrate
-		// This is needed because SQLite doesn't allow adding NOT NULL columns to existing tables
-		if migrateErr := handleCustomMigrations(dbIns); migrateErr != nil {
-			klog.V(1).InfoS("failed to run custom migrations", "dataSource", opts.DataSourceName, "error", migrateErr)
-			// Log error but continue, as AutoMigrate might still work for new tables
-		}
-
-		// Auto migrate database schema
-		if err = dbIns.AutoMigrate(
-			&model.User{},
-			&model.WGPeer{},
-			&model.IPPool{},
-			&model.IPAllocation{},
-		); err != nil {
-			klog.V(1).InfoS("failed to auto migrate database schema", "dataSource", opts.DataSourceName, "error", err)
-			err = errors.Wrap(err, "failed to auto migrate database schema")
 			return
 		}
 
-		klog.V(1).InfoS("database schema migrated successfully", "dataSource", opts.DataSourceName)
-
 		// Initialize default admin user if not exists
-		if initErr := initializeDefaultAdmin(dbIns); initErr != nil {
+		if initErr := initializeDefaultAdmin(ds.db); initErr != nil {
 			klog.Errorf("Failed to initialize default admin user: %+v", initErr)
 			// Don't fail the entire initialization, just log the error
 			// Admin user can be created manually later
 		}
 
-		sqliteFactory = &datastore{dbIns}
+		sqliteFactory = ds
 	})
 
 	if sqliteFactory == nil {
@@ -141,6 +111,80 @@ func GetSqliteFactoryOr(opts *options.SqliteOptions) (store.Factory, error) {
 	return sqliteFactory, nil
 }
 
+// NewSqliteFactory opens the database and migrates its schema. Unlike GetSqliteFactoryOr it opens
+// a new database on each call and does not create the default admin user (e.g. for tests).
+func NewSqliteFactory(opts *options.SqliteOptions) (store.Factory, error) {
+	ds, err := newDatastore(opts)
+	if err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// newDatastore opens the database and migrates its schema.
+func newDatastore(opts *options.SqliteOptions) (*datastore, error) {
+	// Ensure the parent directory of the database file exists
+	// SQLite will not create parent directories automatically
+	dbPath := opts.DataSourceName
+
+	// Get the absolute path to handle both relative and absolute paths
+	absPath, absErr := filepath.Abs(dbPath)
+	if absErr != nil {
+		// If we can't get absolute path, use the original path
+		absPath = dbPath
+	}
+
+	// Get the parent directory
+	parentDir := filepath.Dir(absPath)
+
+	// Create parent directory if it doesn't exist
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		klog.V(1).InfoS("failed to create database parent directory", "parentDir", parentDir, "dataSource", opts.DataSourceName, "error", err)
+		return nil, errors.Wrap(err, "failed to create database parent directory")
+	}
+
+	klog.V(2).InfoS("database parent directory ensured", "parentDir", parentDir, "dataSource", opts.DataSourceName)
+
+	dbOpts := &db.Options{
+		DataSourceName: opts.DataSourceName,
+	}
+	dbIns, err := db.New(dbOpts)
+	if err != nil {
+		// Preserve the original error with full context
+		klog.V(1).InfoS("failed to create sqlite database", "dataSource", opts.DataSourceName, "error", err)
+		return nil, errors.Wrap(err, "failed to create sqlite db with data source")
+	}
+
+	// Handle custom migrations for existing tables before AutoMigrate
+	// This is needed because SQLite doesn't allow adding NOT NULL columns to existing tables
+	if migrateErr := handleCustomMigrations(dbIns); migrateErr != nil {
+		klog.V(1).InfoS("failed to run custom migrations", "dataSource", opts.DataSourceName, "error", migrateErr)
+		// Log error but continue, as AutoMigrate might still work for new tables
+	}
+
+	// Auto migrate database schema
+	if err := dbIns.AutoMigrate(
+		&model.User{},
+		&model.WGPeer{},
+		&model.IPPool{},
+		&model.IPAllocation{},
+		&model.WGInterface{},
+		&model.TrafficCounter{},
+		&model.TrafficUsage{},
+		&model.TrafficQuota{},
+		&model.RevokedKey{},
+		&model.ShareLink{},
+		&model.ShareLinkUse{},
+		&model.PortForward{},
+	); err != nil {
+		klog.V(1).InfoS("failed to auto migrate database schema", "dataSource", opts.DataSourceName, "error", err)
+		return nil, errors.Wrap(err, "failed to auto migrate database schema")
+	}
+
+	klog.V(1).InfoS("database schema migrated successfully", "dataSource", opts.DataSourceName)
+	return &datastore{dbIns}, nil
+}
+
 // initializeDefaultAdmin 初始化默认管理员用户
 // 如果数据库中不存在admin用户，则创建一个默认的admin用户
 // 使用固定默认密码并保存到 pwd.txt 文件中
@@ -286,6 +330,24 @@ func handleCustomMigrations(db *gorm.DB) error {
 		}
 	}
 
+	// Dual-stack peers hold one IP allocation per address family, so peer_id is no longer unique.
+	// Drop the old unique index; AutoMigrate recreates it as a regular index.
+	var indexes []struct {
+		Name   string `gorm:"column:name"`
+		Unique int    `gorm:"column:unique"`
+	}
+	if err := db.Raw("PRAGMA index_list(ip_allocations)").Scan(&indexes).Error; err != nil {
+		return errors.Wrap(err, "failed to get ip_allocations index list")
+	}
+	for _, idx := range indexes {
+		if idx.Name == "idx_ip_allocations_peer_id" && idx.Unique == 1 {
+			klog.V(1).InfoS("dropping unique index on ip_allocations.peer_id for dual-stack allocations")
+			if err := db.Exec("DROP INDEX IF EXISTS idx_ip_allocations_peer_id").Error; err != nil {
+				klog.V(1).InfoS("failed to drop unique index on ip_allocations.peer_id", "error", err)
+			}
+		}
+	}
+
 	return nil
 }
 
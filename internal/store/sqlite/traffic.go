@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+)
+
+type traffic struct {
+	db *gorm.DB
+}
+
+func newTraffic(ds *datastore) *traffic {
+	return &traffic{ds.db}
+}
+
+func (t *traffic) ListCounters(ctx context.Context) ([]*model.TrafficCounter, error) {
+	var counters []*model.TrafficCounter
+	if err := t.db.WithContext(ctx).Find(&counters).Error; err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return counters, nil
+}
+
+func (t *traffic) RecordSample(ctx context.Context, counter *model.TrafficCounter, usage *model.TrafficUsage) error {
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(counter).Error; err != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		if usage == nil {
+			return nil
+		}
+
+		// Add to the daily bucket, creating it on first use
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "peer_id"}, {Name: "day"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "rx_bytes"}, Value: gorm.Expr("rx_bytes + ?", usage.RxBytes)},
+				{Column: clause.Column{Name: "tx_bytes"}, Value: gorm.Expr("tx_bytes + ?", usage.TxBytes)},
+				{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("excluded.updated_at")},
+			},
+		}).Create(usage).Error
+		if err != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		return nil
+	})
+}
+
+func (t *traffic) ListUsage(ctx context.Context, opt store.TrafficUsageOptions) ([]*store.TrafficUsagePoint, error) {
+	// Days are stored as YYYY-MM-DD, so a month is the first 7 characters
+	period := "day"
+	if opt.Period == store.TrafficPeriodMonth {
+		period = "substr(day, 1, 7)"
+	}
+
+	dbq := t.db.WithContext(ctx).Model(&model.TrafficUsage{}).
+		Select(period + " AS period, SUM(rx_bytes) AS rx_bytes, SUM(tx_bytes) AS tx_bytes")
+	if opt.PeerID != "" {
+		dbq = dbq.Where("peer_id = ?", opt.PeerID)
+	}
+	if opt.UserID != "" {
+		dbq = dbq.Where("user_id = ?", opt.UserID)
+	}
+	if opt.PeerIDs != nil {
+		dbq = dbq.Where("peer_id IN ?", opt.PeerIDs)
+	}
+	if opt.From != "" {
+		dbq = dbq.Where("day >= ?", opt.From)
+	}
+	if opt.To != "" {
+		dbq = dbq.Where("day <= ?", opt.To)
+	}
+
+	var points []*store.TrafficUsagePoint
+	if err := dbq.Group("period").Order("period ASC").Scan(&points).Error; err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return points, nil
+}
+
+func (t *traffic) CreateQuota(ctx context.Context, quota *model.TrafficQuota) error {
+	err := t.db.WithContext(ctx).Create(quota).Error
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithCode(code.ErrTrafficQuotaAlreadyExists, "traffic quota already exists for %s %s", quota.Scope, quota.TargetID)
+		}
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (t *traffic) GetQuota(ctx context.Context, id string) (*model.TrafficQuota, error) {
+	var quota model.TrafficQuota
+	err := t.db.WithContext(ctx).Where("id = ?", id).First(&quota).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrTrafficQuotaNotFound, "%s", err.Error())
+		}
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return &quota, nil
+}
+
+func (t *traffic) UpdateQuota(ctx context.Context, quota *model.TrafficQuota) error {
+	if err := t.db.WithContext(ctx).Save(quota).Error; err != nil {
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (t *traffic) DeleteQuota(ctx context.Context, id string) error {
+	if err := t.db.WithContext(ctx).Where("id = ?", id).Delete(&model.TrafficQuota{}).Error; err != nil {
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (t *traffic) ListQuotas(ctx context.Context, opt store.TrafficQuotaListOptions) ([]*model.TrafficQuota, error) {
+	dbq := t.db.WithContext(ctx).Model(&model.TrafficQuota{})
+	if opt.Scope != "" {
+		dbq = dbq.Where("scope = ?", opt.Scope)
+	}
+	if opt.TargetID != "" {
+		dbq = dbq.Where("target_id = ?", opt.TargetID)
+	}
+
+	var quotas []*model.TrafficQuota
+	if err := dbq.Order("created_at ASC").Find(&quotas).Error; err != nil {
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return quotas, nil
+}
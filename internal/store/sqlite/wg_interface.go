@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/errors"
+)
+
+type wgInterfaces struct {
+	db *gorm.DB
+}
+
+func newWGInterfaces(ds *datastore) *wgInterfaces {
+	return &wgInterfaces{ds.db}
+}
+
+func (w *wgInterfaces) CreateInterface(ctx context.Context, iface *model.WGInterface) error {
+	err := w.db.WithContext(ctx).Create(iface).Error
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithCode(code.ErrWGInterfaceAlreadyExists, "WireGuard interface with this name already exists")
+		}
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (w *wgInterfaces) GetInterface(ctx context.Context, id string) (*model.WGInterface, error) {
+	var iface model.WGInterface
+	err := w.db.WithContext(ctx).Where("id = ?", id).First(&iface).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrWGInterfaceNotFound, "%s", err.Error())
+		}
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return &iface, nil
+}
+
+func (w *wgInterfaces) GetInterfaceByName(ctx context.Context, name string) (*model.WGInterface, error) {
+	var iface model.WGInterface
+	err := w.db.WithContext(ctx).Where("name = ?", name).First(&iface).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.WithCode(code.ErrWGInterfaceNotFound, "%s", err.Error())
+		}
+		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return &iface, nil
+}
+
+func (w *wgInterfaces) UpdateInterface(ctx context.Context, iface *model.WGInterface) error {
+	err := w.db.WithContext(ctx).Save(iface).Error
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithCode(code.ErrWGInterfaceAlreadyExists, "WireGuard interface with this name already exists")
+		}
+		return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return nil
+}
+
+func (w *wgInterfaces) DeleteInterface(ctx context.Context, id string) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Check if interface is in use by IP pools or peers
+		var count int64
+		if err := tx.Model(&model.IPPool{}).Where("interface_id = ?", id).Count(&count).Error; err != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		if count > 0 {
+			return errors.WithCode(code.ErrWGInterfaceInUse, "WireGuard interface has IP pools and cannot be deleted")
+		}
+		if err := tx.Model(&model.WGPeer{}).Where("interface_id = ?", id).Count(&count).Error; err != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		if count > 0 {
+			return errors.WithCode(code.ErrWGInterfaceInUse, "WireGuard interface has peers and cannot be deleted")
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&model.WGInterface{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // Idempotent delete
+			}
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		return nil
+	})
+}
+
+func (w *wgInterfaces) ListInterfaces(ctx context.Context, opt store.WGInterfaceListOptions) ([]*model.WGInterface, int64, error) {
+	var (
+		ifaces []*model.WGInterface
+		total  int64
+	)
+
+	dbq := w.db.WithContext(ctx).Model(&model.WGInterface{})
+	if strings.TrimSpace(opt.Status) != "" {
+		dbq = dbq.Where("status = ?", opt.Status)
+	}
+
+	if err := dbq.Count(&total).Error; err != nil {
+		return nil, 0, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	offset := opt.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	if err := dbq.Order("name ASC").Offset(offset).Limit(limit).Find(&ifaces).Error; err != nil {
+		return nil, 0, errors.WithCode(code.ErrDatabase, "%s", err.Error())
+	}
+	return ifaces, total, nil
+}
+
+// AssignUnboundToInterface binds all IP pools and peers without an interface to the given interface.
+// Used to migrate data created before multiple interfaces were supported.
+func (w *wgInterfaces) AssignUnboundToInterface(ctx context.Context, id string) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.IPPool{}).Where("interface_id = ? OR interface_id IS NULL", "").Update("interface_id", id).Error; err != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		if err := tx.Model(&model.WGPeer{}).Where("interface_id = ? OR interface_id IS NULL", "").Update("interface_id", id).Error; err != nil {
+			return errors.WithCode(code.ErrDatabase, "%s", err.Error())
+		}
+		return nil
+	})
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/code"
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 	"github.com/HappyLadySauce/NexusPointWG/internal/store"
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/secret"
 	"github.com/HappyLadySauce/errors"
+	"k8s.io/klog/v2"
 )
 
 type wgPeers struct {
@@ -21,7 +23,15 @@ func newWGPeers(ds *datastore) *wgPeers {
 }
 
 func (w *wgPeers) CreatePeer(ctx context.Context, peer *model.WGPeer) error {
-	err := w.db.WithContext(ctx).Create(peer).Error
+	restore, err := encryptPeerSecrets(peer)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	err = w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createPeer(tx, peer)
+	})
 	if err != nil {
 		if isUniqueConstraintError(err) {
 			return errors.WithCode(code.ErrIPAlreadyInUse, "peer with this public key already exists")
@@ -40,6 +50,7 @@ func (w *wgPeers) GetPeer(ctx context.Context, id string) (*model.WGPeer, error)
 		}
 		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
 	}
+	decryptPeerSecrets(&peer)
 	return &peer, nil
 }
 
@@ -52,11 +63,18 @@ func (w *wgPeers) GetPeerByPublicKey(ctx context.Context, publicKey string) (*mo
 		}
 		return nil, errors.WithCode(code.ErrDatabase, "%s", err.Error())
 	}
+	decryptPeerSecrets(&peer)
 	return &peer, nil
 }
 
 func (w *wgPeers) UpdatePeer(ctx context.Context, peer *model.WGPeer) error {
-	err := w.db.WithContext(ctx).Save(peer).Error
+	restore, err := encryptPeerSecrets(peer)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	err = omitUnreadableSecrets(w.db.WithContext(ctx), peer).Save(peer).Error
 	if err != nil {
 		if isUniqueConstraintError(err) {
 			return errors.WithCode(code.ErrIPAlreadyInUse, "peer with this public key already exists")
@@ -93,9 +111,18 @@ func (w *wgPeers) ListPeers(ctx context.Context, opt store.WGPeerListOptions) ([
 	if strings.TrimSpace(opt.IPPoolID) != "" {
 		dbq = dbq.Where("ip_pool_id = ?", opt.IPPoolID)
 	}
+	if strings.TrimSpace(opt.InterfaceID) != "" {
+		dbq = dbq.Where("interface_id = ?", opt.InterfaceID)
+	}
 	if strings.TrimSpace(opt.DeviceName) != "" {
 		dbq = dbq.Where("device_name LIKE ?", "%"+opt.DeviceName+"%")
 	}
+	if opt.ExpiresBefore != nil {
+		dbq = dbq.Where("expires_at IS NOT NULL AND expires_at <= ?", *opt.ExpiresBefore)
+	}
+	if opt.HasRoutedSubnets {
+		dbq = dbq.Where("routed_subnets IS NOT NULL AND routed_subnets <> ''")
+	}
 
 	if err := dbq.Count(&total).Error; err != nil {
 		return nil, 0, errors.WithCode(code.ErrDatabase, "%s", err.Error())
@@ -116,6 +143,7 @@ func (w *wgPeers) ListPeers(ctx context.Context, opt store.WGPeerListOptions) ([
 	if err := dbq.Order("created_at DESC").Offset(offset).Limit(limit).Find(&peers).Error; err != nil {
 		return nil, 0, errors.WithCode(code.ErrDatabase, "%s", err.Error())
 	}
+	decryptPeerSecrets(peers...)
 	return peers, total, nil
 }
 
@@ -133,7 +161,13 @@ func (w *wgPeers) CountPeersByUserID(ctx context.Context, userID string) (int64,
 func (w *wgPeers) BatchCreatePeers(ctx context.Context, peers []*model.WGPeer) error {
 	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		for _, peer := range peers {
-			if err := tx.Create(peer).Error; err != nil {
+			restore, err := encryptPeerSecrets(peer)
+			if err != nil {
+				return err
+			}
+			err = createPeer(tx, peer)
+			restore()
+			if err != nil {
 				if isUniqueConstraintError(err) {
 					return errors.WithCode(code.ErrIPAlreadyInUse, "peer with this public key already exists")
 				}
@@ -149,7 +183,13 @@ func (w *wgPeers) BatchCreatePeers(ctx context.Context, peers []*model.WGPeer) e
 func (w *wgPeers) BatchUpdatePeers(ctx context.Context, peers []*model.WGPeer) error {
 	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		for _, peer := range peers {
-			if err := tx.Save(peer).Error; err != nil {
+			restore, err := encryptPeerSecrets(peer)
+			if err != nil {
+				return err
+			}
+			err = omitUnreadableSecrets(tx, peer).Save(peer).Error
+			restore()
+			if err != nil {
 				if isUniqueConstraintError(err) {
 					return errors.WithCode(code.ErrIPAlreadyInUse, "peer with this public key already exists")
 				}
@@ -176,3 +216,76 @@ func (w *wgPeers) BatchDeletePeers(ctx context.Context, ids []string) error {
 		return nil
 	})
 }
+
+// createPeer inserts a peer. gorm replaces a zero PersistentKeepalive with the column default,
+// but zero turns keepalive off, so it is written again after the insert.
+func createPeer(tx *gorm.DB, peer *model.WGPeer) error {
+	keepalive := peer.PersistentKeepalive
+	if err := tx.Create(peer).Error; err != nil {
+		return err
+	}
+	if keepalive != 0 {
+		return nil
+	}
+	peer.PersistentKeepalive = 0
+	return tx.Model(peer).UpdateColumn("persistent_keepalive", 0).Error
+}
+
+// encryptPeerSecrets encrypts the private and preshared keys of a peer with the master key before it is written
+// and returns a function that puts their plaintext values back.
+func encryptPeerSecrets(peer *model.WGPeer) (func(), error) {
+	privateKey, presharedKey := peer.ClientPrivateKey, peer.PresharedKey
+
+	encryptedPrivateKey, err := secret.Encrypt(privateKey)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrEncodingFailed, "failed to encrypt peer private key: %s", err.Error())
+	}
+	encryptedPresharedKey, err := secret.Encrypt(presharedKey)
+	if err != nil {
+		return nil, errors.WithCode(code.ErrEncodingFailed, "failed to encrypt peer preshared key: %s", err.Error())
+	}
+
+	peer.ClientPrivateKey, peer.PresharedKey = encryptedPrivateKey, encryptedPresharedKey
+	return func() {
+		peer.ClientPrivateKey, peer.PresharedKey = privateKey, presharedKey
+	}, nil
+}
+
+// omitUnreadableSecrets leaves out the secret columns of a peer whose secrets could not be decrypted
+// and were not replaced, so that saving the peer keeps their stored ciphertext.
+func omitUnreadableSecrets(db *gorm.DB, peer *model.WGPeer) *gorm.DB {
+	if !peer.SecretsUnreadable {
+		return db
+	}
+	var columns []string
+	if peer.ClientPrivateKey == "" {
+		columns = append(columns, "client_private_key")
+	}
+	if peer.PresharedKey == "" {
+		columns = append(columns, "preshared_key")
+	}
+	if len(columns) == 0 {
+		return db
+	}
+	return db.Omit(columns...)
+}
+
+// decryptPeerSecrets decrypts the private and preshared keys of peers read from the database.
+// Keys stored before encryption was enabled are returned as is. A key that cannot be decrypted, e.g. because
+// it was encrypted with another master key, is logged and blanked, and the peer is flagged SecretsUnreadable
+// so that the other peers can still be read.
+func decryptPeerSecrets(peers ...*model.WGPeer) {
+	for _, peer := range peers {
+		privateKey, err := secret.Decrypt(peer.ClientPrivateKey)
+		if err != nil {
+			klog.V(1).InfoS("failed to decrypt peer private key", "peerID", peer.ID, "error", err)
+			privateKey, peer.SecretsUnreadable = "", true
+		}
+		presharedKey, err := secret.Decrypt(peer.PresharedKey)
+		if err != nil {
+			klog.V(1).InfoS("failed to decrypt peer preshared key", "peerID", peer.ID, "error", err)
+			presharedKey, peer.SecretsUnreadable = "", true
+		}
+		peer.ClientPrivateKey, peer.PresharedKey = privateKey, presharedKey
+	}
+}
@@ -10,6 +10,11 @@ type Factory interface {
 	WGPeers() WGPeerStore
 	IPPools() IPPoolStore
 	IPAllocations() IPAllocationStore
+	WGInterfaces() WGInterfaceStore
+	Traffic() TrafficStore
+	Revocations() RevocationStore
+	ShareLinks() ShareLinkStore
+	PortForwards() PortForwardStore
 	Close() error
 }
 
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+)
+
+// TrafficStore defines the interface for traffic accounting data access.
+type TrafficStore interface {
+	// ListCounters lists the last sampled transfer counters of all peers.
+	ListCounters(ctx context.Context) ([]*model.TrafficCounter, error)
+
+	// RecordSample saves the sampled counters of a peer and adds usage to its daily bucket
+	// in one transaction. usage may be nil if nothing was transferred.
+	RecordSample(ctx context.Context, counter *model.TrafficCounter, usage *model.TrafficUsage) error
+
+	// ListUsage aggregates usage by day or by month.
+	ListUsage(ctx context.Context, opt TrafficUsageOptions) ([]*TrafficUsagePoint, error)
+
+	// CreateQuota creates a new traffic quota.
+	CreateQuota(ctx context.Context, quota *model.TrafficQuota) error
+
+	// GetQuota retrieves a traffic quota by ID.
+	GetQuota(ctx context.Context, id string) (*model.TrafficQuota, error)
+
+	// UpdateQuota updates an existing traffic quota.
+	UpdateQuota(ctx context.Context, quota *model.TrafficQuota) error
+
+	// DeleteQuota deletes a traffic quota by ID.
+	DeleteQuota(ctx context.Context, id string) error
+
+	// ListQuotas lists traffic quotas with optional filters.
+	ListQuotas(ctx context.Context, opt TrafficQuotaListOptions) ([]*model.TrafficQuota, error)
+}
+
+const (
+	// TrafficPeriodDay groups usage by day.
+	TrafficPeriodDay = "day"
+	// TrafficPeriodMonth groups usage by month.
+	TrafficPeriodMonth = "month"
+)
+
+// TrafficUsageOptions defines options for querying traffic usage.
+// From and To are inclusive days (model.TrafficDayLayout); empty means unbounded.
+type TrafficUsageOptions struct {
+	PeerID string
+	UserID string
+	// PeerIDs restricts usage to a set of peers, e.g. the peers of an IP pool.
+	PeerIDs []string
+	Period  string
+	From    string
+	To      string
+}
+
+// TrafficUsagePoint is the usage during one period (a day or a month).
+type TrafficUsagePoint struct {
+	Period  string `gorm:"column:period"`
+	RxBytes int64  `gorm:"column:rx_bytes"`
+	TxBytes int64  `gorm:"column:tx_bytes"`
+}
+
+// TrafficQuotaListOptions defines options for listing traffic quotas.
+type TrafficQuotaListOptions struct {
+	Scope    string
+	TargetID string
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+
+	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
+)
+
+// WGInterfaceStore defines the interface for WireGuard interface data access.
+type WGInterfaceStore interface {
+	// CreateInterface creates a new WireGuard interface.
+	CreateInterface(ctx context.Context, iface *model.WGInterface) error
+
+	// GetInterface retrieves an interface by ID.
+	GetInterface(ctx context.Context, id string) (*model.WGInterface, error)
+
+	// GetInterfaceByName retrieves an interface by name.
+	GetInterfaceByName(ctx context.Context, name string) (*model.WGInterface, error)
+
+	// UpdateInterface updates an existing interface.
+	UpdateInterface(ctx context.Context, iface *model.WGInterface) error
+
+	// DeleteInterface deletes an interface by ID. Returns error if IP pools or peers are still bound to it.
+	DeleteInterface(ctx context.Context, id string) error
+
+	// ListInterfaces lists interfaces with optional filters and pagination.
+	ListInterfaces(ctx context.Context, opt WGInterfaceListOptions) ([]*model.WGInterface, int64, error)
+
+	// AssignUnboundToInterface binds all IP pools and peers without an interface to the given interface.
+	AssignUnboundToInterface(ctx context.Context, id string) error
+}
+
+// WGInterfaceListOptions defines options for listing WireGuard interfaces.
+type WGInterfaceListOptions struct {
+	Status string
+	Offset int
+	Limit  int
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/HappyLadySauce/NexusPointWG/internal/pkg/model"
 )
@@ -38,11 +39,15 @@ type WGPeerStore interface {
 
 // WGPeerListOptions defines options for listing WireGuard peers.
 type WGPeerListOptions struct {
-	UserID     string
-	Status     string
-	IPPoolID   string
-	DeviceName string
-	Offset     int
-	Limit      int
+	UserID      string
+	Status      string
+	IPPoolID    string
+	InterfaceID string
+	DeviceName  string
+	// ExpiresBefore, if set, selects peers whose expiration time is not after it.
+	ExpiresBefore *time.Time
+	// HasRoutedSubnets selects site-to-site peers, which have subnets routed behind them.
+	HasRoutedSubnets bool
+	Offset           int
+	Limit            int
 }
-
@@ -99,10 +99,16 @@ func FormatValidationError(err error) map[string]string {
 				message = toToken("endpoint", nil)
 			case "ipv4":
 				message = toToken("ipv4", nil)
+			case "iplist":
+				message = toToken("iplist", nil)
+			case "poolcidr":
+				message = toToken("poolcidr", nil)
 			case "dnslist":
 				message = toToken("dnslist", nil)
 			case "wgprivatekey":
 				message = toToken("wgprivatekey", nil)
+			case "wgpresharedkey":
+				message = toToken("wgpresharedkey", nil)
 			default:
 				message = toToken("invalid", map[string]string{"tag": fieldError.Tag()})
 			}
@@ -0,0 +1,56 @@
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/HappyLadySauce/NexusPointWG/pkg/utils/secret"
+)
+
+// EncryptionOptions contains the master key used to encrypt peer secrets at rest.
+// Encryption is disabled if neither MasterKeyFile nor MasterKey is set.
+type EncryptionOptions struct {
+	// MasterKeyFile is a file holding the base64 or hex encoded 32-byte master key.
+	MasterKeyFile string `json:"master-key-file" mapstructure:"master-key-file"`
+
+	// MasterKey is the base64 or hex encoded master key itself, usually passed through the
+	// NEXUSPOINTWG_ENCRYPTION_MASTER_KEY environment variable. It is never printed.
+	MasterKey string `json:"-" mapstructure:"master-key"`
+}
+
+func NewEncryptionOptions() *EncryptionOptions {
+	return &EncryptionOptions{}
+}
+
+func (o *EncryptionOptions) Validate() []error {
+	var errs []error
+	if o.MasterKeyFile != "" && o.MasterKey != "" {
+		errs = append(errs, fmt.Errorf("only one of encryption.master-key-file and encryption.master-key may be set"))
+	}
+	if _, err := o.LoadMasterKey(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (o *EncryptionOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.MasterKeyFile, "encryption.master-key-file", o.MasterKeyFile, "File holding the base64 or hex encoded 32-byte master key used to encrypt peer secrets at rest (e.g. from `openssl rand -base64 32`)")
+	fs.StringVar(&o.MasterKey, "encryption.master-key", o.MasterKey, "Base64 or hex encoded 32-byte master key, preferably set via NEXUSPOINTWG_ENCRYPTION_MASTER_KEY")
+}
+
+// LoadMasterKey returns the configured master key, nil if encryption is disabled.
+func (o *EncryptionOptions) LoadMasterKey() ([]byte, error) {
+	if strings.TrimSpace(o.MasterKeyFile) != "" {
+		return secret.LoadMasterKey(o.MasterKeyFile)
+	}
+	if strings.TrimSpace(o.MasterKey) != "" {
+		key, err := secret.ParseMasterKey(o.MasterKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption.master-key: %w", err)
+		}
+		return key, nil
+	}
+	return nil, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -14,7 +15,8 @@ type WireGuardOptions struct {
 	// RootDir is the WireGuard configuration root directory (default: /etc/wireguard).
 	RootDir string `json:"root-dir" mapstructure:"root-dir"`
 
-	// Interface is the server interface name (and config file base name), e.g. wg0 -> <rootDir>/wg0.conf.
+	// Interface is the default server interface name (and config file base name), e.g. wg0 -> <rootDir>/wg0.conf.
+	// Peers and IP pools that are not bound to another interface belong to it.
 	Interface string `json:"interface" mapstructure:"interface"`
 
 	// UserDir is the directory to store generated user configuration files.
@@ -32,11 +34,53 @@ type WireGuardOptions struct {
 	DefaultAllowedIPs string `json:"default-allowed-ips" mapstructure:"default-allowed-ips"`
 
 	// ApplyMethod determines how to apply server config changes.
-	// Supported: "systemctl", "none".
+	// Supported: "systemctl", "syncconf", "wgctrl", "exec", "fake", "none".
 	ApplyMethod string `json:"apply-method" mapstructure:"apply-method"`
 
+	// ApplyCommand is the hook run by the "exec" apply method, split on whitespace.
+	ApplyCommand string `json:"apply-command" mapstructure:"apply-command"`
+
+	// HistoryLimit is the number of server config revisions kept per interface (0 keeps all).
+	HistoryLimit int `json:"history-limit" mapstructure:"history-limit"`
+
+	// StatusDumpDir, if set, makes peer runtime status read from <dir>/<interface>.dump
+	// (saved `wg show <interface> dump` output) instead of the running interface.
+	StatusDumpDir string `json:"status-dump-dir" mapstructure:"status-dump-dir"`
+
+	// TrafficInterval is how often peer transfer counters are sampled for traffic accounting (0 disables it).
+	TrafficInterval time.Duration `json:"traffic-interval" mapstructure:"traffic-interval"`
+
+	// ReapInterval is how often expired peers are reaped (0 disables it).
+	ReapInterval time.Duration `json:"reap-interval" mapstructure:"reap-interval"`
+
+	// ExpiredPeerAction is what happens to expired peers: "disable" keeps the record, "delete" removes it.
+	ExpiredPeerAction string `json:"expired-peer-action" mapstructure:"expired-peer-action"`
+
+	// KeyRotationDays rotates the keys of peers older than this many days (0 disables scheduled rotation).
+	KeyRotationDays int `json:"key-rotation-days" mapstructure:"key-rotation-days"`
+
 	// ServerIP is the server public IP for client endpoint (optional, auto-detected if empty)
 	ServerIP string `json:"server_ip" mapstructure:"server_ip"`
+
+	// RequirePresharedKey enforces a PresharedKey on every peer managed by this server.
+	RequirePresharedKey bool `json:"require-preshared-key" mapstructure:"require-preshared-key"`
+
+	// ClientConfigOnDemand generates client configs when they are downloaded instead of saving them under UserDir.
+	ClientConfigOnDemand bool `json:"client-config-on-demand" mapstructure:"client-config-on-demand"`
+
+	// Firewall is the backend of the managed ruleset enforcing the isolation policy of IP pools.
+	// Supported: "nftables", "iptables", "none" (peers can reach each other unless PostUp says otherwise).
+	Firewall string `json:"firewall" mapstructure:"firewall"`
+
+	// ReconcileInterval is how often the database is reconciled with the config files under RootDir (0 disables periodic runs).
+	ReconcileInterval time.Duration `json:"reconcile-interval" mapstructure:"reconcile-interval"`
+
+	// ReconcileWatch reconciles as soon as a config file under RootDir changes.
+	ReconcileWatch bool `json:"reconcile-watch" mapstructure:"reconcile-watch"`
+
+	// ReconcilePolicy is how drift between the database and the config files is resolved:
+	// "import" (the config files win), "enforce" (the database wins) or "report" (only report it).
+	ReconcilePolicy string `json:"reconcile-policy" mapstructure:"reconcile-policy"`
 }
 
 func NewWireGuardOptions() *WireGuardOptions {
@@ -48,6 +92,14 @@ func NewWireGuardOptions() *WireGuardOptions {
 		DNS:               "",
 		DefaultAllowedIPs: "",
 		ApplyMethod:       "systemctl",
+		HistoryLimit:      50,
+		TrafficInterval:   5 * time.Minute,
+		ReapInterval:      time.Minute,
+		ExpiredPeerAction: "disable",
+		Firewall:          "none",
+		ReconcileInterval: 5 * time.Minute,
+		ReconcileWatch:    true,
+		ReconcilePolicy:   "import",
 	}
 }
 
@@ -62,13 +114,50 @@ func (o *WireGuardOptions) Validate() []error {
 	if strings.TrimSpace(o.Endpoint) == "" {
 		errs = append(errs, fmt.Errorf("wireguard.endpoint is required"))
 	}
+	if o.HistoryLimit < 0 {
+		errs = append(errs, fmt.Errorf("wireguard.history-limit must not be negative"))
+	}
+	if o.TrafficInterval < 0 {
+		errs = append(errs, fmt.Errorf("wireguard.traffic-interval must not be negative"))
+	}
+	if o.ReapInterval < 0 {
+		errs = append(errs, fmt.Errorf("wireguard.reap-interval must not be negative"))
+	}
+	if o.KeyRotationDays < 0 {
+		errs = append(errs, fmt.Errorf("wireguard.key-rotation-days must not be negative"))
+	}
+	switch strings.ToLower(strings.TrimSpace(o.ExpiredPeerAction)) {
+	case "", "disable", "delete":
+		// ok
+	default:
+		errs = append(errs, fmt.Errorf("wireguard.expired-peer-action must be one of [disable, delete]"))
+	}
+	switch strings.ToLower(strings.TrimSpace(o.Firewall)) {
+	case "", "none", "nftables", "iptables":
+		// ok
+	default:
+		errs = append(errs, fmt.Errorf("wireguard.firewall must be one of [none, nftables, iptables]"))
+	}
+	if o.ReconcileInterval < 0 {
+		errs = append(errs, fmt.Errorf("wireguard.reconcile-interval must not be negative"))
+	}
+	switch strings.ToLower(strings.TrimSpace(o.ReconcilePolicy)) {
+	case "", "import", "enforce", "report":
+		// ok
+	default:
+		errs = append(errs, fmt.Errorf("wireguard.reconcile-policy must be one of [import, enforce, report]"))
+	}
 	switch strings.ToLower(strings.TrimSpace(o.ApplyMethod)) {
 	case "", "systemctl":
 		// default
-	case "none":
+	case "syncconf", "wgctrl", "fake", "none":
 		// ok
+	case "exec":
+		if strings.TrimSpace(o.ApplyCommand) == "" {
+			errs = append(errs, fmt.Errorf("wireguard.apply-command is required when wireguard.apply-method is exec"))
+		}
 	default:
-		errs = append(errs, fmt.Errorf("wireguard.apply-method must be one of [systemctl, none]"))
+		errs = append(errs, fmt.Errorf("wireguard.apply-method must be one of [systemctl, syncconf, wgctrl, exec, fake, none]"))
 	}
 	return errs
 }
@@ -80,11 +169,29 @@ func (o *WireGuardOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Endpoint, "wireguard.endpoint", o.Endpoint, "Public endpoint advertised to clients, e.g. 127.0.0.1:51820")
 	fs.StringVar(&o.DNS, "wireguard.dns", o.DNS, "Optional DNS server for client configs, e.g. 1.1.1.1")
 	fs.StringVar(&o.DefaultAllowedIPs, "wireguard.default-allowed-ips", o.DefaultAllowedIPs, "Default AllowedIPs for client configs (comma-separated CIDRs), e.g. 0.0.0.0/0,::/0")
-	fs.StringVar(&o.ApplyMethod, "wireguard.apply-method", o.ApplyMethod, "How to apply server config changes: systemctl|none")
+	fs.StringVar(&o.ApplyMethod, "wireguard.apply-method", o.ApplyMethod, "How to apply server config changes: systemctl|syncconf|wgctrl|exec|fake|none")
+	fs.StringVar(&o.ApplyCommand, "wireguard.apply-command", o.ApplyCommand, "Command run by the exec apply method; the change set is passed via WG_* environment variables")
+	fs.IntVar(&o.HistoryLimit, "wireguard.history-limit", o.HistoryLimit, "Number of server config revisions kept per interface in <root-dir>/<interface>.history (0 keeps all)")
+	fs.StringVar(&o.StatusDumpDir, "wireguard.status-dump-dir", o.StatusDumpDir, "Read peer runtime status from <dir>/<interface>.dump files instead of `wg show <interface> dump` (for tests and demos)")
+	fs.DurationVar(&o.TrafficInterval, "wireguard.traffic-interval", o.TrafficInterval, "How often peer transfer counters are sampled for traffic accounting (0 disables it)")
+	fs.DurationVar(&o.ReapInterval, "wireguard.reap-interval", o.ReapInterval, "How often expired peers are disabled or deleted (0 disables it)")
+	fs.StringVar(&o.ExpiredPeerAction, "wireguard.expired-peer-action", o.ExpiredPeerAction, "What to do with expired peers: disable|delete")
+	fs.IntVar(&o.KeyRotationDays, "wireguard.key-rotation-days", o.KeyRotationDays, "Rotate the keys of peers older than this many days, checked hourly (0 disables scheduled rotation)")
+	fs.BoolVar(&o.RequirePresharedKey, "wireguard.require-preshared-key", o.RequirePresharedKey, "Require a PresharedKey on every peer (new peers get one generated automatically)")
+	fs.BoolVar(&o.ClientConfigOnDemand, "wireguard.client-config-on-demand", o.ClientConfigOnDemand, "Generate client configs when they are downloaded instead of saving them under user-dir")
+	fs.StringVar(&o.Firewall, "wireguard.firewall", o.Firewall, "Backend of the managed ruleset enforcing IP pool isolation: none|nftables|iptables")
+	fs.DurationVar(&o.ReconcileInterval, "wireguard.reconcile-interval", o.ReconcileInterval, "How often the database is reconciled with the config files under root-dir (0 disables periodic runs)")
+	fs.BoolVar(&o.ReconcileWatch, "wireguard.reconcile-watch", o.ReconcileWatch, "Reconcile as soon as a config file under root-dir changes")
+	fs.StringVar(&o.ReconcilePolicy, "wireguard.reconcile-policy", o.ReconcilePolicy, "How drift between the database and the config files is resolved: import (config files win)|enforce (database wins)|report")
 }
 
 func (o *WireGuardOptions) ServerConfigPath() string {
-	return filepath.Join(o.RootDir, o.Interface+".conf")
+	return o.InterfaceConfigPath(o.Interface)
+}
+
+// InterfaceConfigPath returns the config file path of the named interface, e.g. wg1 -> <rootDir>/wg1.conf.
+func (o *WireGuardOptions) InterfaceConfigPath(name string) string {
+	return filepath.Join(o.RootDir, name+".conf")
 }
 
 func (o *WireGuardOptions) ResolvedUserDir() string {
@@ -32,9 +32,35 @@ func LastIPv4(prefix netip.Prefix) netip.Addr {
 	return netip.AddrFrom4([4]byte{b0, b1, b2, b3})
 }
 
+// LastIP 计算子网的最后一个 IP（IPv4 为广播地址），同时支持 IPv4 和 IPv6
+func LastIP(prefix netip.Prefix) netip.Addr {
+	p := prefix.Masked()
+	if p.Addr().Is4() {
+		return LastIPv4(p)
+	}
+	if !p.IsValid() {
+		return netip.Addr{}
+	}
+	b := p.Addr().As16()
+	for i := p.Bits(); i < 128; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	return netip.AddrFrom16(b)
+}
+
 // ParseFirstV4Prefix 解析第一个 IPv4 前缀
 // Address may be comma-separated.
 func ParseFirstV4Prefix(addressLine string) (netip.Prefix, netip.Addr, error) {
+	return parseFirstPrefix(addressLine, true)
+}
+
+// ParseFirstV6Prefix 解析第一个 IPv6 前缀
+// Address may be comma-separated.
+func ParseFirstV6Prefix(addressLine string) (netip.Prefix, netip.Addr, error) {
+	return parseFirstPrefix(addressLine, false)
+}
+
+func parseFirstPrefix(addressLine string, v4 bool) (netip.Prefix, netip.Addr, error) {
 	parts := strings.Split(addressLine, ",")
 	for _, p := range parts {
 		p = strings.TrimSpace(p)
@@ -45,9 +71,12 @@ func ParseFirstV4Prefix(addressLine string) (netip.Prefix, netip.Addr, error) {
 		if err != nil {
 			continue
 		}
-		if prefix.Addr().Is4() {
+		if prefix.Addr().Is4() == v4 {
 			return prefix.Masked(), prefix.Addr(), nil
 		}
 	}
-	return netip.Prefix{}, netip.Addr{}, fmt.Errorf("no ipv4 prefix found")
+	if v4 {
+		return netip.Prefix{}, netip.Addr{}, fmt.Errorf("no ipv4 prefix found")
+	}
+	return netip.Prefix{}, netip.Addr{}, fmt.Errorf("no ipv6 prefix found")
 }
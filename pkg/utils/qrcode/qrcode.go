@@ -0,0 +1,82 @@
+package qrcode
+
+import (
+	"fmt"
+	"strings"
+
+	qr "github.com/skip2/go-qrcode"
+)
+
+const (
+	// DefaultPNGSize is the default width and height of PNG QR codes in pixels.
+	DefaultPNGSize = 512
+
+	// svgModuleSize is the size of one QR module in SVG user units.
+	svgModuleSize = 8
+
+	ansiDark  = "\x1b[40m  "
+	ansiLight = "\x1b[47m  "
+	ansiReset = "\x1b[0m"
+)
+
+// PNG encodes content as a PNG QR code of size x size pixels.
+func PNG(content string, size int) ([]byte, error) {
+	if size <= 0 {
+		size = DefaultPNGSize
+	}
+	return qr.Encode(content, qr.Medium, size)
+}
+
+// SVG encodes content as an SVG QR code.
+func SVG(content string) ([]byte, error) {
+	bitmap, err := bitmap(content)
+	if err != nil {
+		return nil, err
+	}
+
+	size := len(bitmap) * svgModuleSize
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", size, size, len(bitmap), len(bitmap))
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	b.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d,%dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/>` + "\n</svg>\n")
+	return []byte(b.String()), nil
+}
+
+// Terminal encodes content as a QR code drawn with ANSI background colors, for printing to a terminal.
+func Terminal(content string) (string, error) {
+	bitmap, err := bitmap(content)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, row := range bitmap {
+		for _, dark := range row {
+			if dark {
+				b.WriteString(ansiDark)
+			} else {
+				b.WriteString(ansiLight)
+			}
+		}
+		b.WriteString(ansiReset + "\n")
+	}
+	return b.String(), nil
+}
+
+// bitmap returns the QR modules of content, including the quiet zone, true meaning dark.
+func bitmap(content string) ([][]bool, error) {
+	code, err := qr.New(content, qr.Medium)
+	if err != nil {
+		return nil, err
+	}
+	return code.Bitmap(), nil
+}
@@ -0,0 +1,209 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// MasterKeySize 主密钥长度（字节），AES-256
+	MasterKeySize = 32
+	// dataKeySize 每个密文独立生成的数据密钥长度（字节）
+	dataKeySize = 32
+
+	// prefix 标识加密后的值，格式为 nxwg:v1:<主密钥 ID>:<被主密钥加密的数据密钥>:<被数据密钥加密的明文>
+	prefix = "nxwg:v1:"
+)
+
+// Cipher encrypts secrets with envelope encryption: every value is sealed with its own random
+// data key, which is in turn sealed with the master key. Values sealed with one of the previous
+// master keys can still be decrypted, so that data can be re-encrypted after a master key rotation.
+type Cipher struct {
+	keyID string
+	keys  map[string][]byte
+}
+
+// NewCipher returns a cipher that encrypts with masterKey and decrypts with masterKey or any of previousKeys.
+func NewCipher(masterKey []byte, previousKeys ...[]byte) (*Cipher, error) {
+	c := &Cipher{keys: make(map[string][]byte, len(previousKeys)+1)}
+	for _, key := range append([][]byte{masterKey}, previousKeys...) {
+		if len(key) != MasterKeySize {
+			return nil, fmt.Errorf("master key must be %d bytes, got %d", MasterKeySize, len(key))
+		}
+		c.keys[KeyID(key)] = key
+	}
+	c.keyID = KeyID(masterKey)
+	return c, nil
+}
+
+// KeyID returns the identifier of a master key stored alongside the values it encrypts.
+func KeyID(masterKey []byte) string {
+	sum := sha256.Sum256(masterKey)
+	return hex.EncodeToString(sum[:4])
+}
+
+// KeyID returns the identifier of the master key the cipher encrypts with.
+func (c *Cipher) KeyID() string {
+	return c.keyID
+}
+
+// Encrypt seals plaintext with a new data key and returns the encoded envelope.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	sealedKey, err := seal(c.keys[c.keyID], dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return prefix + c.keyID + ":" + base64.RawStdEncoding.EncodeToString(sealedKey) + ":" + base64.RawStdEncoding.EncodeToString(sealedValue), nil
+}
+
+// Decrypt opens an envelope returned by Encrypt. Values that are not encrypted are returned as is.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	masterKey, ok := c.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("value is encrypted with unknown master key %s", parts[0])
+	}
+	sealedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted data key: %w", err)
+	}
+	sealedValue, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	dataKey, err := open(masterKey, sealedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	plaintext, err := open(dataKey, sealedValue)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether value is an envelope returned by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// ParseMasterKey decodes a base64 or hex encoded master key.
+func ParseMasterKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == MasterKeySize {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(s); err == nil && len(key) == MasterKeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key must be %d bytes encoded as base64 or hex (e.g. `openssl rand -base64 32`)", MasterKeySize)
+}
+
+// LoadMasterKey reads a base64 or hex encoded master key from file.
+func LoadMasterKey(file string) ([]byte, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	key, err := ParseMasterKey(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key file %s: %w", file, err)
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, prepending the random nonce.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a value returned by seal.
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	masterMu     sync.RWMutex
+	masterCipher *Cipher
+)
+
+// SetMasterCipher sets the cipher used by Encrypt and Decrypt. A nil cipher disables encryption.
+func SetMasterCipher(c *Cipher) {
+	masterMu.Lock()
+	defer masterMu.Unlock()
+	masterCipher = c
+}
+
+// MasterCipher returns the cipher set by SetMasterCipher, nil if encryption is disabled.
+func MasterCipher() *Cipher {
+	masterMu.RLock()
+	defer masterMu.RUnlock()
+	return masterCipher
+}
+
+// Encrypt encrypts value with the master cipher. Empty values and values stored
+// while encryption is disabled are returned as is.
+func Encrypt(value string) (string, error) {
+	c := MasterCipher()
+	if c == nil || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	return c.Encrypt(value)
+}
+
+// Decrypt decrypts value with the master cipher. Values that are not encrypted are returned as is.
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	c := MasterCipher()
+	if c == nil {
+		return "", fmt.Errorf("value is encrypted but no master key is configured")
+	}
+	return c.Decrypt(value)
+}
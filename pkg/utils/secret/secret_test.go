@@ -0,0 +1,144 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, MasterKeySize)
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher(testKey(1))
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+
+	tests := []string{"", "x", "oK5nYl6J3ujtZHbfYqyUXnwTn4pzB1b6uCUxQWq9tWg=", strings.Repeat("long secret ", 100)}
+	for _, plaintext := range tests {
+		encrypted, err := c.Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt(%q) error = %v", plaintext, err)
+		}
+		if !IsEncrypted(encrypted) {
+			t.Errorf("Encrypt(%q) = %q, want an envelope", plaintext, encrypted)
+		}
+		decrypted, err := c.Decrypt(encrypted)
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q", plaintext, decrypted)
+		}
+	}
+}
+
+func TestCipherEncryptIsRandomized(t *testing.T) {
+	c, _ := NewCipher(testKey(1))
+	first, _ := c.Encrypt("secret")
+	second, _ := c.Encrypt("secret")
+	if first == second {
+		t.Errorf("Encrypt() returned the same envelope twice: %q", first)
+	}
+}
+
+func TestCipherDecrypt(t *testing.T) {
+	oldCipher, _ := NewCipher(testKey(1))
+	sealedWithOld, _ := oldCipher.Encrypt("secret")
+	rotated, _ := NewCipher(testKey(2), testKey(1))
+	sealedWithRotated, _ := rotated.Encrypt("secret")
+	tampered := sealedWithRotated[:len(sealedWithRotated)-2] + "AA"
+
+	tests := []struct {
+		name    string
+		cipher  *Cipher
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plaintext is returned as is", cipher: rotated, value: "not encrypted", want: "not encrypted"},
+		{name: "current master key", cipher: rotated, value: sealedWithRotated, want: "secret"},
+		{name: "previous master key", cipher: rotated, value: sealedWithOld, want: "secret"},
+		{name: "unknown master key", cipher: oldCipher, value: sealedWithRotated, wantErr: true},
+		{name: "tampered value", cipher: rotated, value: tampered, wantErr: true},
+		{name: "malformed envelope", cipher: rotated, value: prefix + "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cipher.Decrypt(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Decrypt() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCipherKeySize(t *testing.T) {
+	if _, err := NewCipher(make([]byte, 16)); err == nil {
+		t.Errorf("NewCipher() with a 16-byte key error = nil, want an error")
+	}
+	if _, err := NewCipher(testKey(1), make([]byte, 31)); err == nil {
+		t.Errorf("NewCipher() with a short previous key error = nil, want an error")
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	key := testKey(7)
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "base64", value: base64.StdEncoding.EncodeToString(key)},
+		{name: "hex", value: hex.EncodeToString(key)},
+		{name: "surrounding whitespace", value: "  " + base64.StdEncoding.EncodeToString(key) + "\n"},
+		{name: "too short", value: base64.StdEncoding.EncodeToString(key[:16]), wantErr: true},
+		{name: "not encoded", value: "not a key", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMasterKey(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMasterKey() = %x, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMasterKey() error = %v", err)
+			}
+			if !bytes.Equal(got, key) {
+				t.Errorf("ParseMasterKey() = %x, want %x", got, key)
+			}
+		})
+	}
+}
+
+func TestPackageEncryptWithoutMasterKey(t *testing.T) {
+	SetMasterCipher(nil)
+
+	got, err := Encrypt("secret")
+	if err != nil || got != "secret" {
+		t.Errorf("Encrypt() without master key = %q, %v, want the plaintext", got, err)
+	}
+	c, _ := NewCipher(testKey(1))
+	sealed, _ := c.Encrypt("secret")
+	if _, err := Decrypt(sealed); err == nil {
+		t.Errorf("Decrypt() of an envelope without master key error = nil, want an error")
+	}
+}
@@ -71,6 +71,16 @@ func RegisterCustomValidators(v *v10.Validate) error {
 		return err
 	}
 
+	// Register iplist validator: validates comma-separated IP addresses with at most one per family
+	if err := v.RegisterValidation("iplist", validateIPList); err != nil {
+		return err
+	}
+
+	// Register poolcidr validator: validates an IP pool CIDR (one IPv4 and/or one IPv6 CIDR)
+	if err := v.RegisterValidation("poolcidr", validatePoolCIDR); err != nil {
+		return err
+	}
+
 	// Register dnslist validator: validates comma-separated IP addresses (IPv4 or IPv6)
 	if err := v.RegisterValidation("dnslist", validateDNSList); err != nil {
 		return err
@@ -81,6 +91,11 @@ func RegisterCustomValidators(v *v10.Validate) error {
 		return err
 	}
 
+	// Register wgpresharedkey validator: validates WireGuard preshared key format
+	if err := v.RegisterValidation("wgpresharedkey", validateWGPresharedKey); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -234,6 +249,72 @@ func validateIPv4(fl v10.FieldLevel) bool {
 	return ip.Is4()
 }
 
+// validateIPList validates that the string is a comma-separated list of IP addresses
+// holding at most one IPv4 and one IPv6 address (e.g. "100.100.100.2,fd00::2").
+func validateIPList(fl v10.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true // empty values are handled by required tag
+	}
+
+	var has4, has6 bool
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		ip, err := netip.ParseAddr(part)
+		if err != nil {
+			return false
+		}
+		if ip.Is4() {
+			if has4 {
+				return false
+			}
+			has4 = true
+		} else {
+			if has6 {
+				return false
+			}
+			has6 = true
+		}
+	}
+	return true
+}
+
+// validatePoolCIDR validates that the string is a single IPv4/IPv6 CIDR or a dual-stack pair
+// (e.g. "100.100.100.0/24,fd00:100::/64").
+func validatePoolCIDR(fl v10.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true // empty values are handled by required tag
+	}
+
+	var has4, has6 bool
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return false
+		}
+		if prefix.Addr().Is4() {
+			if has4 {
+				return false
+			}
+			has4 = true
+		} else {
+			if has6 {
+				return false
+			}
+			has6 = true
+		}
+	}
+	return has4 || has6
+}
+
 // validateDNSList validates that the string is a comma-separated list of valid IP addresses (IPv4 or IPv6).
 func validateDNSList(fl v10.FieldLevel) bool {
 	value := fl.Field().String()
@@ -265,3 +346,12 @@ func validateWGPrivateKey(fl v10.FieldLevel) bool {
 	}
 	return wireguard.ValidatePrivateKey(value) == nil
 }
+
+// validateWGPresharedKey validates that the string is a valid WireGuard preshared key format.
+func validateWGPresharedKey(fl v10.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true // empty values are handled by required tag
+	}
+	return wireguard.ValidatePresharedKey(value) == nil
+}